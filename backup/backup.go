@@ -0,0 +1,350 @@
+// Package backup exports families with their users, lists and tasks to
+// JSON and restores them, e.g. into a new database. Tasks can be exported
+// to CSV too, CSV is not read back.
+package backup
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/maxwww/family_bot/units"
+)
+
+// Version is written to every backup, newer backups are not restored.
+const Version = 1
+
+var ErrInvalidBackup = errors.New("invalid backup")
+
+// Services are the storages the backup is made from and restored to.
+type Services struct {
+	Users    units.UserService
+	Tasks    units.TaskService
+	Families units.FamilyService
+	Lists    units.ListService
+}
+
+type Backup struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Families  []Family  `json:"families"`
+}
+
+type Family struct {
+	Name  string `json:"name"`
+	Users []User `json:"users"`
+	Lists []List `json:"lists"`
+}
+
+// User keeps the settings of a member, users are matched by TelegramID.
+type User struct {
+	TelegramID    uint   `json:"telegram_id"`
+	FirstName     string `json:"first_name"`
+	LastName      string `json:"last_name,omitempty"`
+	UserName      string `json:"user_name,omitempty"`
+	Notifications bool   `json:"notifications"`
+	Timezone      string `json:"timezone,omitempty"`
+	Language      string `json:"language,omitempty"`
+	// ActiveList is the name of the list new tasks of the user go to.
+	ActiveList string `json:"active_list,omitempty"`
+}
+
+type List struct {
+	Name  string         `json:"name"`
+	Kind  units.ListKind `json:"kind"`
+	Tasks []Task         `json:"tasks"`
+}
+
+type Task struct {
+	Title         string           `json:"title"`
+	Date          *time.Time       `json:"date,omitempty"`
+	Done          bool             `json:"done,omitempty"`
+	Notifications int              `json:"notifications,omitempty"`
+	Recurrence    units.Recurrence `json:"recurrence,omitempty"`
+	// Assignees are Telegram IDs of the users.
+	Assignees   []uint       `json:"assignees,omitempty"`
+	Quantity    *float64     `json:"quantity,omitempty"`
+	Unit        string       `json:"unit,omitempty"`
+	Category    string       `json:"category,omitempty"`
+	Notes       string       `json:"notes,omitempty"`
+	UID         string       `json:"uid,omitempty"`
+	Items       []Item       `json:"items,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+type Item struct {
+	Title string `json:"title"`
+	Done  bool   `json:"done,omitempty"`
+}
+
+// Attachment is kept by Telegram, the file id only works for the same bot.
+type Attachment struct {
+	Kind   units.AttachmentKind `json:"kind"`
+	FileID string               `json:"file_id"`
+}
+
+// Export makes a backup of the families, no ids means every family.
+func Export(ctx context.Context, services Services, familyIDs ...uint) (*Backup, error) {
+	if len(familyIDs) == 0 {
+		families, err := services.Families.Families(ctx, units.FamilyFilter{})
+		if err != nil {
+			return nil, err
+		}
+		for _, family := range families {
+			familyIDs = append(familyIDs, family.ID)
+		}
+	}
+
+	backup := &Backup{Version: Version, CreatedAt: time.Now().UTC()}
+	for _, familyID := range familyIDs {
+		family, err := exportFamily(ctx, services, familyID)
+		if err != nil {
+			return nil, err
+		}
+		backup.Families = append(backup.Families, *family)
+	}
+
+	return backup, nil
+}
+
+func exportFamily(ctx context.Context, services Services, familyID uint) (*Family, error) {
+	family, err := services.Families.FamilyByID(ctx, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := services.Users.Users(ctx, units.UserFilter{FamilyID: &familyID})
+	if err != nil {
+		return nil, err
+	}
+
+	lists, err := services.Lists.Lists(ctx, units.ListFilter{FamilyID: &familyID})
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err := services.Tasks.Tasks(ctx, units.TaskFilter{FamilyID: &familyID})
+	if err != nil {
+		return nil, err
+	}
+
+	listNames := map[uint]string{}
+	for _, list := range lists {
+		listNames[list.ID] = list.Name
+	}
+	telegramIDs := map[uint]uint{}
+	for _, user := range users {
+		telegramIDs[user.ID] = user.TelegramID
+	}
+
+	result := &Family{Name: family.Name}
+	for _, user := range users {
+		exported := User{
+			TelegramID:    user.TelegramID,
+			FirstName:     user.FirstName,
+			LastName:      user.LastName,
+			UserName:      user.UserName,
+			Notifications: user.Notifications,
+			Timezone:      user.Timezone,
+			Language:      user.Language,
+		}
+		if user.ActiveListID.Valid {
+			exported.ActiveList = listNames[uint(user.ActiveListID.Int64)]
+		}
+		result.Users = append(result.Users, exported)
+	}
+
+	for _, list := range lists {
+		exported := List{Name: list.Name, Kind: list.Kind, Tasks: []Task{}}
+		for _, task := range tasks {
+			if task.ListID == list.ID {
+				exported.Tasks = append(exported.Tasks, exportTask(task, telegramIDs))
+			}
+		}
+		result.Lists = append(result.Lists, exported)
+	}
+
+	return result, nil
+}
+
+func exportTask(task *units.Task, telegramIDs map[uint]uint) Task {
+	exported := Task{
+		Title:         task.Title,
+		Done:          task.Done,
+		Notifications: task.Notifications,
+		Recurrence:    task.Recurrence,
+		Unit:          task.Unit,
+		Category:      task.Category,
+		Notes:         task.Notes,
+		UID:           task.UID,
+	}
+	if task.Date.Valid {
+		date := task.Date.Time.UTC()
+		exported.Date = &date
+	}
+	if task.Quantity.Valid {
+		quantity := task.Quantity.Float64
+		exported.Quantity = &quantity
+	}
+	for _, userID := range task.Assignees {
+		if telegramID, ok := telegramIDs[userID]; ok {
+			exported.Assignees = append(exported.Assignees, telegramID)
+		}
+	}
+	for _, item := range task.Items {
+		exported.Items = append(exported.Items, Item{Title: item.Title, Done: item.Done})
+	}
+	for _, attachment := range task.Attachments {
+		exported.Attachments = append(exported.Attachments, Attachment{Kind: attachment.Kind, FileID: attachment.FileID})
+	}
+
+	return exported
+}
+
+func WriteJSON(w io.Writer, backup *Backup) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(backup)
+}
+
+// ReadJSON reads a backup written by WriteJSON.
+func ReadJSON(r io.Reader) (*Backup, error) {
+	var backup Backup
+	if err := json.NewDecoder(r).Decode(&backup); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	if backup.Version < 1 || backup.Version > Version {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidBackup, backup.Version)
+	}
+	if err := validate(&backup); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+
+	return &backup, nil
+}
+
+// validate checks what the database would reject halfway through a restore,
+// the limits are the sizes of the columns.
+func validate(backup *Backup) error {
+	for _, family := range backup.Families {
+		if !fits(family.Name, 255) {
+			return fmt.Errorf("family name %q is too long", family.Name)
+		}
+		for _, user := range family.Users {
+			if err := validateUser(user); err != nil {
+				return err
+			}
+		}
+		for _, list := range family.Lists {
+			if !list.Kind.IsValid() {
+				return fmt.Errorf("list %q has unknown kind %q", list.Name, list.Kind)
+			}
+			if !isValidTitle(list.Name) {
+				return fmt.Errorf("list name %q is empty or too long", list.Name)
+			}
+			for _, task := range list.Tasks {
+				if err := validateTask(task); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateUser(user User) error {
+	if !fits(user.FirstName, 255) || !fits(user.LastName, 255) || !fits(user.UserName, 255) {
+		return fmt.Errorf("name of user %d is too long", user.TelegramID)
+	}
+	if !fits(user.Language, 8) {
+		return fmt.Errorf("language %q of user %d is too long", user.Language, user.TelegramID)
+	}
+	if user.Timezone != "" {
+		if _, err := time.LoadLocation(user.Timezone); err != nil || !fits(user.Timezone, 64) {
+			return fmt.Errorf("user %d has unknown timezone %q", user.TelegramID, user.Timezone)
+		}
+	}
+
+	return nil
+}
+
+func validateTask(task Task) error {
+	if !isValidTitle(task.Title) {
+		return fmt.Errorf("task title %q is empty or too long", task.Title)
+	}
+	if !task.Recurrence.IsValid() {
+		return fmt.Errorf("task %q has unknown recurrence %q", task.Title, task.Recurrence)
+	}
+	if task.Quantity != nil && math.Abs(*task.Quantity) >= 1e9 {
+		return fmt.Errorf("quantity of task %q is too big", task.Title)
+	}
+	if !fits(task.Unit, 16) || !fits(task.Category, 32) {
+		return fmt.Errorf("unit or category of task %q is too long", task.Title)
+	}
+	for _, item := range task.Items {
+		if !isValidTitle(item.Title) {
+			return fmt.Errorf("item title %q of task %q is empty or too long", item.Title, task.Title)
+		}
+	}
+	for _, attachment := range task.Attachments {
+		if !attachment.Kind.IsValid() {
+			return fmt.Errorf("attachment of task %q has unknown kind %q", task.Title, attachment.Kind)
+		}
+		if attachment.FileID == "" || !fits(attachment.FileID, 255) {
+			return fmt.Errorf("file id of an attachment of task %q is empty or too long", task.Title)
+		}
+	}
+
+	return nil
+}
+
+func isValidTitle(title string) bool {
+	return title != "" && fits(title, units.MaxTitleLength)
+}
+
+func fits(value string, length int) bool {
+	return utf8.RuneCountInString(value) <= length
+}
+
+// WriteCSV writes one row for every task, it is meant for spreadsheets.
+func WriteCSV(w io.Writer, backup *Backup) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"family", "list", "title", "date", "done", "recurrence", "notes", "items"}); err != nil {
+		return err
+	}
+
+	for _, family := range backup.Families {
+		for _, list := range family.Lists {
+			for _, task := range list.Tasks {
+				date := ""
+				if task.Date != nil {
+					date = task.Date.Format(time.RFC3339)
+				}
+				var items []string
+				for _, item := range task.Items {
+					items = append(items, item.Title)
+				}
+				record := []string{
+					family.Name, list.Name, task.Title, date, strconv.FormatBool(task.Done),
+					string(task.Recurrence), task.Notes, strings.Join(items, "; "),
+				}
+				if err := writer.Write(record); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	writer.Flush()
+
+	return writer.Error()
+}
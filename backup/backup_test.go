@@ -0,0 +1,122 @@
+package backup
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maxwww/family_bot/units"
+)
+
+func testBackup() *Backup {
+	date := time.Date(2022, 5, 6, 15, 30, 0, 0, time.UTC)
+	quantity := 2.0
+
+	return &Backup{
+		Version:   Version,
+		CreatedAt: time.Date(2022, 5, 4, 7, 15, 0, 0, time.UTC),
+		Families: []Family{{
+			Name:  "Сім'я",
+			Users: []User{{TelegramID: 100, FirstName: "Максим", Notifications: true, Timezone: "Europe/Kyiv", ActiveList: "Справи"}},
+			Lists: []List{
+				{Name: "Справи", Kind: units.ListKindTasks, Tasks: []Task{
+					{Title: "басейн", Date: &date, Recurrence: units.RecurrenceWeekly, Assignees: []uint{100}},
+					{Title: "похід, нарешті", Items: []Item{{Title: "намет", Done: true}, {Title: "ліхтарик"}}},
+				}},
+				{Name: "Покупки", Kind: units.ListKindShopping, Tasks: []Task{
+					{Title: "молоко", Quantity: &quantity, Unit: "л", Done: true},
+				}},
+			},
+		}},
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, testBackup()); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadJSON(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := testBackup(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadJSON() = %+v, want %+v", got, want)
+	}
+}
+
+func TestReadJSONRejectsOtherFiles(t *testing.T) {
+	for _, data := range []string{"", "BEGIN:VCALENDAR", `{"families": []}`, `{"version": 2}`} {
+		if _, err := ReadJSON(strings.NewReader(data)); !errors.Is(err, ErrInvalidBackup) {
+			t.Errorf("ReadJSON(%q) error = %v, want ErrInvalidBackup", data, err)
+		}
+	}
+}
+
+func TestReadJSONValidates(t *testing.T) {
+	tests := map[string]func(*Backup){
+		"list kind":    func(b *Backup) { b.Families[0].Lists[0].Kind = "notes" },
+		"list name":    func(b *Backup) { b.Families[0].Lists[0].Name = "" },
+		"task title":   func(b *Backup) { b.Families[0].Lists[0].Tasks[0].Title = strings.Repeat("я", units.MaxTitleLength+1) },
+		"recurrence":   func(b *Backup) { b.Families[0].Lists[0].Tasks[0].Recurrence = "hourly" },
+		"item title":   func(b *Backup) { b.Families[0].Lists[0].Tasks[1].Items[0].Title = "" },
+		"anchored day": func(b *Backup) { b.Families[0].Lists[0].Tasks[0].Recurrence = "monthly:32" },
+		"unit":         func(b *Backup) { b.Families[0].Lists[1].Tasks[0].Unit = strings.Repeat("л", 17) },
+		"category":     func(b *Backup) { b.Families[0].Lists[1].Tasks[0].Category = strings.Repeat("м", 33) },
+		"quantity":     func(b *Backup) { *b.Families[0].Lists[1].Tasks[0].Quantity = 1e9 },
+		"first name":   func(b *Backup) { b.Families[0].Users[0].FirstName = strings.Repeat("м", 256) },
+		"language":     func(b *Backup) { b.Families[0].Users[0].Language = "ukrainian" },
+		"timezone":     func(b *Backup) { b.Families[0].Users[0].Timezone = "Europe/Atlantis" },
+		"attachment kind": func(b *Backup) {
+			b.Families[0].Lists[0].Tasks[0].Attachments = []Attachment{{Kind: "video", FileID: "file"}}
+		},
+		"file id": func(b *Backup) {
+			b.Families[0].Lists[0].Tasks[0].Attachments = []Attachment{{Kind: units.AttachmentPhoto, FileID: strings.Repeat("f", 256)}}
+		},
+	}
+
+	for name, change := range tests {
+		data := testBackup()
+		change(data)
+
+		var buf bytes.Buffer
+		if err := WriteJSON(&buf, data); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadJSON(&buf); !errors.Is(err, ErrInvalidBackup) {
+			t.Errorf("%s: ReadJSON() error = %v, want ErrInvalidBackup", name, err)
+		}
+	}
+
+	data := testBackup()
+	data.Families[0].Lists[0].Tasks[0].Title = strings.Repeat("я", units.MaxTitleLength)
+	data.Families[0].Lists[0].Tasks[0].Recurrence = units.RecurrenceMonthly.Anchored(31)
+	data.Families[0].Lists[0].Tasks[0].Attachments = []Attachment{{Kind: units.AttachmentDocument, FileID: strings.Repeat("f", 255)}}
+	data.Families[0].Users[0].Language = "uk"
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadJSON(&buf); err != nil {
+		t.Errorf("ReadJSON() of the longest values error = %v", err)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, testBackup()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "family,list,title,date,done,recurrence,notes,items\n" +
+		"Сім'я,Справи,басейн,2022-05-06T15:30:00Z,false,weekly,,\n" +
+		"Сім'я,Справи,\"похід, нарешті\",,false,,,намет; ліхтарик\n" +
+		"Сім'я,Покупки,молоко,,true,,,\n"
+	if got := buf.String(); got != want {
+		t.Errorf("WriteCSV() =\n%s\nwant\n%s", got, want)
+	}
+}
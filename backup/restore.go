@@ -0,0 +1,291 @@
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/maxwww/family_bot/units"
+)
+
+// Summary counts what a restore adds, a dry run counts what it would add.
+type Summary struct {
+	Families int
+	Users    int
+	Lists    int
+	Tasks    int
+	// Skipped are tasks the family already has.
+	Skipped int
+}
+
+func (s *Summary) add(other *Summary) {
+	s.Families += other.Families
+	s.Users += other.Users
+	s.Lists += other.Lists
+	s.Tasks += other.Tasks
+	s.Skipped += other.Skipped
+}
+
+// Restore restores every family of the backup. Families are matched by
+// name, missing families and users are created, users of another family
+// stay there.
+func Restore(ctx context.Context, services Services, backup *Backup, dryRun bool) (*Summary, error) {
+	families, err := services.Families.Families(ctx, units.FamilyFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &Summary{}
+	for _, family := range backup.Families {
+		var familyID uint
+		for _, existing := range families {
+			if existing.Name == family.Name {
+				familyID = existing.ID
+				break
+			}
+		}
+		if familyID == 0 {
+			summary.Families++
+			if !dryRun {
+				created := &units.Family{Name: family.Name}
+				if err := services.Families.CreateFamily(ctx, created); err != nil {
+					return nil, err
+				}
+				families = append(families, created)
+				familyID = created.ID
+			}
+		}
+
+		if err := restoreUsers(ctx, services, family, familyID, dryRun); err != nil {
+			return nil, err
+		}
+
+		restored, err := RestoreFamily(ctx, services, family, familyID, dryRun)
+		if err != nil {
+			return nil, err
+		}
+		summary.add(restored)
+	}
+
+	return summary, nil
+}
+
+// restoreUsers creates users of the backup missing in the database and
+// adds the ones without a family to the family.
+func restoreUsers(ctx context.Context, services Services, family Family, familyID uint, dryRun bool) error {
+	if dryRun {
+		return nil
+	}
+
+	familyIDValue := sql.NullInt64{Int64: int64(familyID), Valid: true}
+	for _, user := range family.Users {
+		telegramID := user.TelegramID
+		users, err := services.Users.Users(ctx, units.UserFilter{TelegramID: &telegramID, Limit: 1})
+		if err != nil {
+			return err
+		}
+
+		if len(users) == 0 {
+			err = services.Users.CreateUser(ctx, &units.User{
+				TelegramID:    user.TelegramID,
+				FirstName:     user.FirstName,
+				LastName:      user.LastName,
+				UserName:      user.UserName,
+				Notifications: user.Notifications,
+				FamilyID:      familyIDValue,
+				Timezone:      user.Timezone,
+				Language:      user.Language,
+			})
+		} else if !users[0].FamilyID.Valid {
+			err = services.Users.UpdateUser(ctx, users[0], units.UserPatch{FamilyID: &familyIDValue})
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RestoreFamily adds lists and tasks of the backup the family is missing
+// and restores settings of its members. Lists are matched by name and kind,
+// tasks by UID or by list, title and date, so a backup can be restored
+// twice. Zero familyID stands for a family a dry run has not created.
+func RestoreFamily(ctx context.Context, services Services, family Family, familyID uint, dryRun bool) (*Summary, error) {
+	var users []*units.User
+	var lists []*units.List
+	var tasks []*units.Task
+	if familyID != 0 {
+		var err error
+		if users, err = services.Users.Users(ctx, units.UserFilter{FamilyID: &familyID}); err != nil {
+			return nil, err
+		}
+		if lists, err = services.Lists.Lists(ctx, units.ListFilter{FamilyID: &familyID}); err != nil {
+			return nil, err
+		}
+		if tasks, err = services.Tasks.Tasks(ctx, units.TaskFilter{FamilyID: &familyID}); err != nil {
+			return nil, err
+		}
+	}
+
+	existing := map[string]bool{}
+	for _, task := range tasks {
+		existing[taskKey(task.ListID, task.UID, task.Title, task.Date)] = true
+	}
+	userIDs := map[uint]uint{}
+	for _, user := range users {
+		userIDs[user.TelegramID] = user.ID
+	}
+
+	summary := &Summary{}
+	listIDs := map[string]uint{}
+	for _, list := range family.Lists {
+		var listID uint
+		for _, existingList := range lists {
+			if existingList.Name == list.Name && existingList.Kind == list.Kind {
+				listID = existingList.ID
+				break
+			}
+		}
+		if listID == 0 {
+			summary.Lists++
+			if !dryRun {
+				created := &units.List{FamilyID: familyID, Name: list.Name, Kind: list.Kind}
+				if err := services.Lists.CreateList(ctx, created); err != nil {
+					return nil, err
+				}
+				lists = append(lists, created)
+				listID = created.ID
+			}
+		}
+		listIDs[list.Name] = listID
+
+		for _, task := range list.Tasks {
+			date := sql.NullTime{}
+			if task.Date != nil {
+				date = sql.NullTime{Time: *task.Date, Valid: true}
+			}
+			// lists a dry run would create are empty
+			key := taskKey(listID, task.UID, task.Title, date)
+			if listID != 0 && existing[key] {
+				summary.Skipped++
+				continue
+			}
+			summary.Tasks++
+			if dryRun {
+				continue
+			}
+
+			if err := restoreTask(ctx, services, task, familyID, listID, date, userIDs); err != nil {
+				return nil, err
+			}
+			existing[key] = true
+		}
+	}
+
+	for _, user := range family.Users {
+		member := findUser(users, user.TelegramID)
+		if member == nil {
+			continue
+		}
+		summary.Users++
+		if dryRun {
+			continue
+		}
+
+		patch := units.UserPatch{
+			Notifications: &user.Notifications,
+			Timezone:      &user.Timezone,
+			Language:      &user.Language,
+		}
+		if listID, ok := listIDs[user.ActiveList]; ok && user.ActiveList != "" {
+			patch.ActiveListID = &sql.NullInt64{Int64: int64(listID), Valid: true}
+		}
+		if err := services.Users.UpdateUser(ctx, member, patch); err != nil {
+			return nil, err
+		}
+	}
+
+	return summary, nil
+}
+
+func restoreTask(ctx context.Context, services Services, task Task, familyID, listID uint, date sql.NullTime, userIDs map[uint]uint) error {
+	created := &units.Task{
+		Title:         task.Title,
+		Date:          date,
+		Done:          task.Done,
+		Notifications: task.Notifications,
+		Recurrence:    task.Recurrence,
+		FamilyID:      familyID,
+		ListID:        listID,
+		Unit:          task.Unit,
+		Category:      task.Category,
+		Notes:         task.Notes,
+		UID:           task.UID,
+	}
+	if task.Quantity != nil {
+		created.Quantity = sql.NullFloat64{Float64: *task.Quantity, Valid: true}
+	}
+	for _, telegramID := range task.Assignees {
+		if userID, ok := userIDs[telegramID]; ok {
+			created.Assignees = append(created.Assignees, userID)
+		}
+	}
+	for _, attachment := range task.Attachments {
+		created.Attachments = append(created.Attachments, &units.Attachment{Kind: attachment.Kind, FileID: attachment.FileID})
+	}
+	if err := services.Tasks.CreateTask(ctx, created); err != nil {
+		return err
+	}
+
+	if len(task.Items) == 0 {
+		return nil
+	}
+
+	var titles []string
+	for _, item := range task.Items {
+		titles = append(titles, item.Title)
+	}
+	if err := services.Tasks.AddTaskItems(ctx, created.ID, titles); err != nil {
+		return err
+	}
+
+	stored, err := services.Tasks.TaskByID(ctx, created.ID)
+	if err != nil {
+		return err
+	}
+	for i, item := range task.Items {
+		if item.Done && i < len(stored.Items) {
+			if err := services.Tasks.ToggleTaskItem(ctx, created.ID, stored.Items[i].ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// taskKey tells tasks apart, imported events by their UIDs.
+func taskKey(listID uint, uid, title string, date sql.NullTime) string {
+	if uid != "" {
+		return uid
+	}
+
+	key := fmt.Sprintf("%d\x00%s", listID, title)
+	if date.Valid {
+		key += "\x00" + date.Time.UTC().Format(time.RFC3339)
+	}
+
+	return key
+}
+
+func findUser(users []*units.User, telegramID uint) *units.User {
+	for _, user := range users {
+		if user.TelegramID == telegramID {
+			return user
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,220 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/maxwww/family_bot/units"
+)
+
+// agendaWeekDays is how many days /week shows, the navigation moves by it.
+const agendaWeekDays = 7
+
+// agendaDay is a day of the agenda with its tasks, times first.
+type agendaDay struct {
+	Date  time.Time
+	Tasks []*units.Task
+}
+
+func (bot *Bot) handleDayCommand(chatId int64, user *units.User, day int) {
+	message, keyboard := bot.getDayAgenda(user, day)
+	bot.sendMessage(chatId, message, keyboard, "")
+}
+
+func (bot *Bot) handleWeekCommand(chatId int64, user *units.User) {
+	message, keyboard := bot.getWeekAgenda(user, 0)
+	bot.sendMessage(chatId, message, keyboard, "")
+}
+
+func (bot *Bot) showWeek(chatId int64, messageId int, user *units.User, start int) {
+	message, keyboard := bot.getWeekAgenda(user, start)
+	bot.editMessage(chatId, messageId, message, keyboard, "")
+}
+
+// completeAgendaTask toggles the task and renders the agenda it was
+// completed from again, days of the agenda are counted from today.
+func (bot *Bot) completeAgendaTask(chatId int64, messageId int, user *units.User, taskId int, start int, week bool) {
+	_, err := bot.getFamilyTask(user, taskId)
+	if err == nil {
+		_, err = bot.taskService.CompleteTask(actorContext(user), taskId, bot.userLocation(user))
+	}
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	message, keyboard := bot.getDayAgenda(user, start)
+	if week {
+		message, keyboard = bot.getWeekAgenda(user, start)
+	}
+	bot.editMessage(chatId, messageId, message, keyboard, "")
+	bot.refreshListMessages(user, 0, 0)
+}
+
+// getDayAgenda renders tasks of the day, 0 is today and 1 is tomorrow.
+func (bot *Bot) getDayAgenda(user *units.User, day int) (string, *tgbotapi.InlineKeyboardMarkup) {
+	tr := bot.localizer(user)
+	days, _, err := bot.getAgendaDays(user, day, 1, false)
+	if err != nil {
+		log.Println(err)
+		return tr.T(TextGeneralError), nil
+	}
+
+	header := TextAgendaToday
+	if day != 0 {
+		header = TextAgendaTomorrow
+	}
+	date := days[0].Date
+	message := tr.T(header, strings.ToLower(getWeekdayName(tr, date.Weekday())), date.Format(tr.T(TextFormatDate))) + "\n\n"
+	if len(days[0].Tasks) == 0 {
+		return message + tr.T(TextAgendaEmpty), nil
+	}
+
+	lines, buttons := bot.buildAgendaTasks(user, days[0].Tasks, 0, fmt.Sprintf(CQDayComplete+":%%d:%d", day))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
+
+	return message + lines, &keyboard
+}
+
+// getWeekAgenda renders the week starting the given number of days from
+// today, days without tasks are skipped and undated tasks go last.
+func (bot *Bot) getWeekAgenda(user *units.User, start int) (string, *tgbotapi.InlineKeyboardMarkup) {
+	tr := bot.localizer(user)
+	days, undated, err := bot.getAgendaDays(user, start, agendaWeekDays, true)
+	if err != nil {
+		log.Println(err)
+		return tr.T(TextGeneralError), nil
+	}
+
+	first, last := days[0].Date, days[len(days)-1].Date
+	message := tr.T(TextAgendaWeek, first.Format(tr.T(TextFormatDate)), last.Format(tr.T(TextFormatDate)))
+
+	action := fmt.Sprintf(CQWeekComplete+":%%d:%d", start)
+	number := 0
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, day := range days {
+		if len(day.Tasks) == 0 {
+			continue
+		}
+
+		lines, buttons := bot.buildAgendaTasks(user, day.Tasks, number, action)
+		message += "\n\n" + tr.T(TextAgendaDay, getWeekdayName(tr, day.Date.Weekday()), day.Date.Format(tr.T(TextFormatDate))) + "\n" + lines
+		rows = append(rows, buttons...)
+		number += len(day.Tasks)
+	}
+	if len(undated) > 0 {
+		lines, buttons := bot.buildAgendaTasks(user, undated, number, action)
+		message += "\n\n" + tr.T(TextAgendaUndated) + "\n" + lines
+		rows = append(rows, buttons...)
+		number += len(undated)
+	}
+	if number == 0 {
+		message += "\n\n" + tr.T(TextAgendaEmpty)
+	}
+
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(tr.T(TextActionPreviousWeek), fmt.Sprintf(CQWeekShow+":%d", start-agendaWeekDays)),
+		tgbotapi.NewInlineKeyboardButtonData(tr.T(TextActionNextWeek), fmt.Sprintf(CQWeekShow+":%d", start+agendaWeekDays)),
+	))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	return message, &keyboard
+}
+
+// getAgendaDays returns tasks of the task lists of the family grouped by
+// the local days of the user, undated tasks are returned when asked for.
+func (bot *Bot) getAgendaDays(user *units.User, start, count int, withUndated bool) ([]agendaDay, []*units.Task, error) {
+	ctx := context.Background()
+	familyID := uint(user.FamilyID.Int64)
+	loc := bot.userLocation(user)
+	now := bot.now().In(loc)
+	from := time.Date(now.Year(), now.Month(), now.Day()+start, 0, 0, 0, 0, loc)
+	to := time.Date(now.Year(), now.Month(), now.Day()+start+count, 0, 0, 0, 0, loc)
+
+	tasks, err := bot.getCalendarTasks(ctx, familyID, units.TaskFilter{From: &from, To: &to, OrderBy: units.TaskOrderDate})
+	if err != nil {
+		return nil, nil, err
+	}
+	moveAllDayTasksLast(tasks, loc)
+
+	days := make([]agendaDay, count)
+	for i := range days {
+		days[i].Date = time.Date(from.Year(), from.Month(), from.Day()+i, 0, 0, 0, 0, loc)
+	}
+	for _, task := range tasks {
+		date := task.Date.Time.In(loc)
+		for i := range days {
+			if isSameDay(days[i].Date, date) {
+				days[i].Tasks = append(days[i].Tasks, task)
+				break
+			}
+		}
+	}
+
+	if !withUndated {
+		return days, nil, nil
+	}
+
+	hasDate := false
+	undated, err := bot.getCalendarTasks(ctx, familyID, units.TaskFilter{HasDate: &hasDate})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return days, undated, nil
+}
+
+// buildAgendaTasks renders tasks of a day numbered after the given number
+// with the complete and edit buttons of the task list. The action of the
+// complete button is formatted with the task id.
+func (bot *Bot) buildAgendaTasks(user *units.User, tasks []*units.Task, number int, completeAction string) (string, [][]tgbotapi.InlineKeyboardButton) {
+	users := bot.getFamilyUsers(user)
+	loc := bot.userLocation(user)
+
+	message := ""
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, task := range tasks {
+		number++
+		checkBox := TextCheckbox
+		if task.Done {
+			checkBox = TextComplete
+		}
+		row = append(row,
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d %s", number, checkBox), fmt.Sprintf(completeAction, task.ID)),
+			tgbotapi.NewInlineKeyboardButtonData(TextSettings, fmt.Sprintf(CQTaskEdit+":%d", task.ID)),
+		)
+		if len(row) == 6 {
+			rows = append(rows, row)
+			row = []tgbotapi.InlineKeyboardButton{}
+		}
+
+		message += getAgendaTaskLine(task, number, users, loc) + "\n"
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+
+	return message, rows
+}
+
+func getAgendaTaskLine(task *units.Task, number int, users []*units.User, loc *time.Location) string {
+	line := fmt.Sprintf("%d. %s", number, html.EscapeString(task.Title))
+	if task.Date.Valid {
+		if date := task.Date.Time.In(loc); date.Hour() != 0 || date.Minute() != 0 {
+			line += fmt.Sprintf(" (%s)", date.Format(TimeFormat))
+		}
+	}
+	line += getTaskMarkers(task, users)
+	if task.Done {
+		line = "<s>" + line + "</s>"
+	}
+
+	return line
+}
@@ -0,0 +1,98 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	st "github.com/maxwww/family_bot/state"
+	"github.com/maxwww/family_bot/units"
+)
+
+// getMessageAttachment returns the photo or the document of the message,
+// nil means the message has neither. Only the largest size of a photo is kept.
+func getMessageAttachment(message *tgbotapi.Message) *units.Attachment {
+	if len(message.Photo) > 0 {
+		return &units.Attachment{
+			Kind:   units.AttachmentPhoto,
+			FileID: message.Photo[len(message.Photo)-1].FileID,
+		}
+	}
+
+	if message.Document != nil {
+		return &units.Attachment{
+			Kind:   units.AttachmentDocument,
+			FileID: message.Document.FileID,
+		}
+	}
+
+	return nil
+}
+
+// editTaskAttachments sends files of the task again, the next photos or
+// documents are added to it until the user goes back.
+func (bot *Bot) editTaskAttachments(chatId int64, messageId int, user *units.User, taskId int) {
+	task, err := bot.getFamilyTask(user, taskId)
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	bot.stateService.SetUserState(int(user.TelegramID), st.State{
+		Status: st.STATUS_EDIT_TASK_WAIT_FILE,
+		Task: st.Task{
+			ID: taskId,
+		},
+	})
+
+	message, keyboard := bot.getTaskAttachments(user, task)
+	bot.editMessage(chatId, messageId, message, keyboard, "")
+	bot.sendAttachments(chatId, task)
+}
+
+func (bot *Bot) getTaskAttachments(user *units.User, task *units.Task) (string, *tgbotapi.InlineKeyboardMarkup) {
+	tr := bot.localizer(user)
+	message := tr.T(TextTaskAttachmentsEmpty, task.Title)
+	if len(task.Attachments) > 0 {
+		message = tr.T(TextTaskAttachments, task.Title, len(task.Attachments))
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(tr.T(TextActionBack), fmt.Sprintf(CQTaskEditDetailsDone+":%d", task.ID)),
+	))
+
+	return message, &keyboard
+}
+
+func (bot *Bot) sendAttachments(chatId int64, task *units.Task) {
+	for _, attachment := range task.Attachments {
+		if err := bot.messenger.SendFile(chatId, attachment.Kind, attachment.FileID); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func (bot *Bot) handleTaskAttachment(chatId int64, user *units.User, attachment *units.Attachment, taskId int) {
+	task, err := bot.getFamilyTask(user, taskId)
+	if err != nil {
+		log.Println(err)
+		bot.sendParseError(chatId, user)
+		return
+	}
+
+	attachment.TaskID = task.ID
+	err = bot.taskService.AddTaskAttachment(actorContext(user), attachment)
+	if err == nil {
+		task, err = bot.getFamilyTask(user, taskId)
+	}
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	message, keyboard := bot.getTaskAttachments(user, task)
+	bot.sendMessage(chatId, message, keyboard, "")
+	bot.refreshListMessages(user, 0, 0)
+}
@@ -0,0 +1,167 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+
+	"github.com/maxwww/family_bot/backup"
+	st "github.com/maxwww/family_bot/state"
+	"github.com/maxwww/family_bot/units"
+)
+
+// backupServices are the storages a backup of a family is made from.
+func (bot *Bot) backupServices() backup.Services {
+	return backup.Services{
+		Users:    bot.userService,
+		Tasks:    bot.taskService,
+		Families: bot.familyService,
+		Lists:    bot.listService,
+	}
+}
+
+// handleExportCommand sends the backup of the family, "csv" adds the tasks
+// as a spreadsheet.
+func (bot *Bot) handleExportCommand(chatId int64, user *units.User, args string) {
+	data, err := backup.Export(context.Background(), bot.backupServices(), uint(user.FamilyID.Int64))
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	name := "family_bot-" + bot.now().In(bot.userLocation(user)).Format("2006-01-02")
+	var buf bytes.Buffer
+	if err := backup.WriteJSON(&buf, data); err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+	if err := bot.messenger.SendDocument(chatId, name+".json", buf.Bytes()); err != nil {
+		log.Println(err)
+	}
+
+	if !strings.EqualFold(trim(args), "csv") {
+		return
+	}
+
+	var csv bytes.Buffer
+	if err := backup.WriteCSV(&csv, data); err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+	if err := bot.messenger.SendDocument(chatId, name+".csv", csv.Bytes()); err != nil {
+		log.Println(err)
+	}
+}
+
+func (bot *Bot) handleImportCommand(chatId int64, user *units.User) {
+	bot.stateService.SetUserState(int(user.TelegramID), st.State{
+		Status: st.STATUS_IMPORT_BACKUP_WAIT_FILE,
+	})
+
+	bot.sendMessage(chatId, bot.localizer(user).T(TextSendBackupFile), nil, "")
+}
+
+// handleBackupFile shows what restoring the backup would add and asks to
+// confirm it.
+func (bot *Bot) handleBackupFile(chatId int64, user *units.User, fileId string) {
+	tr := bot.localizer(user)
+	data, err := bot.readBackupFile(fileId)
+	if err != nil {
+		log.Println(err)
+		bot.sendMessage(chatId, tr.T(TextBackupFileInvalid), nil, "")
+		return
+	}
+
+	summary, err := backup.RestoreFamily(context.Background(), bot.backupServices(), ownSettings(data.Families[0], user), uint(user.FamilyID.Int64), true)
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	bot.stateService.SetUserState(int(user.TelegramID), st.State{
+		Status: st.STATUS_IMPORT_BACKUP,
+		FileID: fileId,
+	})
+
+	createdAt := data.CreatedAt.In(bot.userLocation(user)).Format(tr.T(TextFormatDate))
+	message := tr.T(TextBackupSummary, html.EscapeString(data.Families[0].Name), createdAt, summary.Lists, summary.Tasks, summary.Skipped)
+	bot.sendMessage(chatId, message, bot.createYesNoKeyboard(tr, CQBackupRestoreYes, CQBackupRestoreNo), "")
+}
+
+// restoreBackup restores the confirmed backup, the file is read again so
+// the state keeps only its id.
+func (bot *Bot) restoreBackup(state *st.State, chatId int64, messageId int, user *units.User) {
+	if state.Status != st.STATUS_IMPORT_BACKUP {
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	bot.stateService.SetUserState(int(user.TelegramID), st.State{
+		Status: st.STATUS_IDLE,
+	})
+
+	tr := bot.localizer(user)
+	data, err := bot.readBackupFile(state.FileID)
+	if err != nil {
+		log.Println(err)
+		bot.editMessage(chatId, messageId, tr.T(TextBackupFileInvalid), nil, "")
+		return
+	}
+
+	// the restore is not one transaction, whatever was added before an
+	// error stays and restoring the file again skips it
+	summary, err := backup.RestoreFamily(actorContext(user), bot.backupServices(), ownSettings(data.Families[0], user), uint(user.FamilyID.Int64), false)
+	if err != nil {
+		log.Println(err)
+		bot.editMessage(chatId, messageId, tr.T(TextBackupRestoreFailed), nil, "")
+		bot.refreshListMessages(user, 0, 0)
+		return
+	}
+
+	// the language of the user may have been restored too
+	if restored, err := bot.userService.UserByTelegramID(context.Background(), user.TelegramID); err == nil {
+		user = restored
+	}
+	bot.editMessage(chatId, messageId, bot.localizer(user).T(TextBackupRestored, summary.Lists, summary.Tasks), nil, "")
+	bot.refreshListMessages(user, 0, 0)
+}
+
+// ownSettings keeps only the settings of the user in the family, members
+// restore their own settings and not the ones of the others.
+func ownSettings(family backup.Family, user *units.User) backup.Family {
+	var users []backup.User
+	for _, member := range family.Users {
+		if member.TelegramID == user.TelegramID {
+			users = append(users, member)
+		}
+	}
+	family.Users = users
+
+	return family
+}
+
+// readBackupFile reads a backup of a single family, backups of the whole
+// bot are restored with the command line only.
+func (bot *Bot) readBackupFile(fileId string) (*backup.Backup, error) {
+	file, err := bot.messenger.DownloadFile(fileId)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := backup.ReadJSON(bytes.NewReader(file))
+	if err != nil {
+		return nil, err
+	}
+	if len(data.Families) != 1 {
+		return nil, fmt.Errorf("%w: %d families", backup.ErrInvalidBackup, len(data.Families))
+	}
+
+	return data, nil
+}
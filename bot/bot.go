@@ -2,39 +2,69 @@ package bot
 
 import (
 	"context"
+	"database/sql"
 	"log"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/maxwww/family_bot/postgres"
+	"github.com/maxwww/family_bot/i18n"
 	st "github.com/maxwww/family_bot/state"
 	"github.com/maxwww/family_bot/units"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/robfig/cron/v3"
 )
 
 type Bot struct {
-	BotAPI       *tgbotapi.BotAPI
-	loc          *time.Location
-	subscribers  []int64
-	userService  units.UserService
-	taskService  units.TaskService
-	stateService st.StateServiceI
+	BotAPI    *tgbotapi.BotAPI
+	messenger Messenger
+	// loc is the default location of users who have not chosen a timezone
+	loc                 *time.Location
+	now                 func() time.Time
+	admins              []int64
+	userService         units.UserService
+	taskService         units.TaskService
+	familyService       units.FamilyService
+	stateService        st.StateServiceI
+	notificationService units.NotificationService
+	listMessageService  units.ListMessageService
+	listService         units.ListService
+	undo                UndoConfig
+	calendar            CalendarConfig
+	cron                *cron.Cron
 }
 
-func NewBot(botAPI *tgbotapi.BotAPI, db *postgres.DB, subscribers []int64, loc *time.Location) *Bot {
-	bot := Bot{
-		BotAPI:      botAPI,
-		loc:         loc,
-		subscribers: subscribers,
-	}
-
-	bot.userService = postgres.NewUserService(db)
-	bot.taskService = postgres.NewTaskService(db)
-	bot.stateService = st.NewStateService()
+// Services are the storages the bot works with.
+type Services struct {
+	Users         units.UserService
+	Tasks         units.TaskService
+	Families      units.FamilyService
+	Notifications units.NotificationService
+	ListMessages  units.ListMessageService
+	Lists         units.ListService
+	State         st.StateServiceI
+}
 
-	return &bot
+// NewBot creates a bot, admins are Telegram IDs which join the first family
+// without an invite so a fresh deployment can be bootstrapped. botAPI is only
+// used to receive updates and may be nil when they are fed to the bot directly.
+func NewBot(botAPI *tgbotapi.BotAPI, messenger Messenger, services Services, admins []int64, loc *time.Location) *Bot {
+	return &Bot{
+		BotAPI:              botAPI,
+		messenger:           messenger,
+		loc:                 loc,
+		now:                 time.Now,
+		admins:              admins,
+		userService:         services.Users,
+		taskService:         services.Tasks,
+		familyService:       services.Families,
+		stateService:        services.State,
+		notificationService: services.Notifications,
+		listMessageService:  services.ListMessages,
+		listService:         services.Lists,
+		undo:                DefaultUndoConfig,
+	}
 }
 
 func (bot *Bot) Start() error {
@@ -78,38 +108,54 @@ func (bot *Bot) handleUpdate(update tgbotapi.Update) {
 		if err != units.ErrNotFound {
 			// TODO: handle error
 			log.Println(err)
-			bot.sendGeneralError(chatId)
+			bot.sendGeneralError(chatId, nil)
 			return
 		}
 		err = bot.userService.CreateUser(context.Background(), &units.User{
-			TelegramID: uint(update.Message.From.ID),
-			FirstName:  update.Message.From.FirstName,
-			LastName:   update.Message.From.LastName,
-			UserName:   update.Message.From.UserName,
+			TelegramID: uint(fromUser.ID),
+			FirstName:  fromUser.FirstName,
+			LastName:   fromUser.LastName,
+			UserName:   fromUser.UserName,
+			Language:   i18n.Match(fromUser.LanguageCode),
 		})
 		if err != nil {
-			bot.sendGeneralError(chatId)
+			bot.sendGeneralError(chatId, nil)
 			return
 		}
 
 		user, err = bot.userService.UserByTelegramID(context.Background(), uint(fromUser.ID))
+		if err != nil {
+			log.Println(err)
+			bot.sendGeneralError(chatId, nil)
+			return
+		}
 	}
 
-	isSubscriber := false
-	for _, v := range bot.subscribers {
-		if v == int64(user.TelegramID) {
-			isSubscriber = true
+	if user.Language == "" {
+		// users created before languages existed get the one of their client
+		language := i18n.Match(fromUser.LanguageCode)
+		if err := bot.userService.UpdateUser(context.Background(), user, units.UserPatch{Language: &language}); err != nil {
+			log.Println(err)
+		}
+	}
+
+	if !user.FamilyID.Valid && bot.isAdmin(user) {
+		if err := bot.joinDefaultFamily(user); err != nil {
+			log.Println(err)
+			bot.sendGeneralError(chatId, user)
+			return
 		}
 	}
-	if !isSubscriber {
+
+	if !user.FamilyID.Valid {
+		bot.handleOutsider(update, chatId, user)
 		return
 	}
 
 	state := bot.stateService.GetUserState(int(user.TelegramID))
 
 	if update.CallbackQuery != nil {
-		msg := tgbotapi.NewCallback(update.CallbackQuery.ID, "")
-		_, err := bot.BotAPI.Request(msg)
+		err := bot.messenger.AnswerCallback(update.CallbackQuery.ID, "")
 		if err != nil {
 			log.Println(err)
 		}
@@ -128,69 +174,195 @@ func (bot *Bot) handleUpdate(update tgbotapi.Update) {
 
 		switch command {
 		case CQNewTaskSave:
-			bot.saveNewTask(state, chatId, update.CallbackQuery.Message.MessageID, int(user.TelegramID))
+			bot.saveNewTask(state, chatId, update.CallbackQuery.Message.MessageID, user)
 		case CQNewTaskEditTitle:
-			bot.editTitleNewTask(state, chatId, update.CallbackQuery.Message.MessageID, int(user.TelegramID))
+			bot.editTitleNewTask(state, chatId, update.CallbackQuery.Message.MessageID, user)
 		case CQNewTaskEditDay:
-			bot.editDayNewTask(state, chatId, update.CallbackQuery.Message.MessageID, int(user.TelegramID))
+			bot.editDayNewTask(state, chatId, update.CallbackQuery.Message.MessageID, user)
 		case CQNewTaskRemoveDay:
-			bot.removeDayNewTask(state, chatId, update.CallbackQuery.Message.MessageID, int(user.TelegramID))
+			bot.removeDayNewTask(state, chatId, update.CallbackQuery.Message.MessageID, user)
 		case CQNewTaskEditTime:
-			bot.editTimeNewTask(state, chatId, update.CallbackQuery.Message.MessageID, int(user.TelegramID))
+			bot.editTimeNewTask(state, chatId, update.CallbackQuery.Message.MessageID, user)
 		case CQNewTaskRemoveTime:
-			bot.removeTimeNewTask(state, chatId, update.CallbackQuery.Message.MessageID, int(user.TelegramID))
+			bot.removeTimeNewTask(state, chatId, update.CallbackQuery.Message.MessageID, user)
 		case CQNewTaskCancel:
-			bot.cancelNewTask(chatId, update.CallbackQuery.Message.MessageID, int(user.TelegramID))
+			bot.cancelNewTask(chatId, update.CallbackQuery.Message.MessageID, user)
 		case CQNewTaskSetNotifications:
-			bot.setNotificationsNewTask(state, chatId, update.CallbackQuery.Message.MessageID, param, int(user.TelegramID))
+			bot.setNotificationsNewTask(state, chatId, update.CallbackQuery.Message.MessageID, param, user)
+		case CQNewTaskSetRecurrence:
+			bot.setRecurrenceNewTask(state, chatId, update.CallbackQuery.Message.MessageID, param, user)
+		case CQNewTaskToggleAssignee:
+			bot.toggleAssigneeNewTask(state, chatId, update.CallbackQuery.Message.MessageID, param, user)
 		case CQTaskComplete:
-			bot.completeTask(chatId, update.CallbackQuery.Message.MessageID, id)
+			bot.completeTask(chatId, update.CallbackQuery.Message.MessageID, user, id)
 		case CQTaskRemoveAllDone:
-			bot.removeAllDoneTasks(chatId, update.CallbackQuery.Message.MessageID)
+			bot.removeAllDoneTasks(chatId, update.CallbackQuery.Message.MessageID, user, id)
 		case CQTaskRemoveAllDoneNo:
-			bot.showTaskListInSameMessage(chatId, update.CallbackQuery.Message.MessageID)
+			bot.showTaskListInSameMessage(chatId, update.CallbackQuery.Message.MessageID, user, uint(id))
 		case CQTaskRemoveAllDoneYes:
-			bot.removeAllDoneTasksYes(chatId, update.CallbackQuery.Message.MessageID)
+			bot.removeAllDoneTasksYes(chatId, update.CallbackQuery.Message.MessageID, user, id)
 		case CQTaskEdit:
-			bot.editTask(chatId, update.CallbackQuery.Message.MessageID, id)
+			bot.editTask(chatId, update.CallbackQuery.Message.MessageID, user, id)
 		case CQTaskEditOk:
-			bot.showTaskListInSameMessage(chatId, update.CallbackQuery.Message.MessageID)
+			bot.finishTaskEditing(chatId, update.CallbackQuery.Message.MessageID, user, id)
 		case CQTaskEditEditTitle:
-			bot.editTaskTitle(chatId, update.CallbackQuery.Message.MessageID, int(user.TelegramID), id)
+			bot.editTaskTitle(chatId, update.CallbackQuery.Message.MessageID, user, id)
 		case CQTaskEditEditDay:
-			bot.editTaskDay(chatId, update.CallbackQuery.Message.MessageID, int(user.TelegramID), id)
+			bot.editTaskDay(chatId, update.CallbackQuery.Message.MessageID, user, id)
 		case CQTaskEditEditTime:
-			bot.editTaskTime(chatId, update.CallbackQuery.Message.MessageID, int(user.TelegramID), id)
+			bot.editTaskTime(chatId, update.CallbackQuery.Message.MessageID, user, id)
 		case CQTaskEditRemoveDay:
-			bot.removeTaskDay(chatId, update.CallbackQuery.Message.MessageID, int(user.TelegramID), id)
+			bot.removeTaskDay(chatId, update.CallbackQuery.Message.MessageID, user, id)
 		case CQTaskEditRemoveTime:
-			bot.removeTaskTime(chatId, update.CallbackQuery.Message.MessageID, int(user.TelegramID), id)
+			bot.removeTaskTime(chatId, update.CallbackQuery.Message.MessageID, user, id)
 		case CQTaskEditDeleteTask:
-			bot.deleteTask(chatId, update.CallbackQuery.Message.MessageID, id)
+			bot.deleteTask(chatId, update.CallbackQuery.Message.MessageID, user, id)
 		case CQTaskEditSetNotifications:
-			bot.setNotifications(chatId, update.CallbackQuery.Message.MessageID, int(user.TelegramID), id, param)
+			bot.setNotifications(chatId, update.CallbackQuery.Message.MessageID, user, id, param)
+		case CQTaskEditSetRecurrence:
+			bot.setRecurrence(chatId, update.CallbackQuery.Message.MessageID, user, id, param)
+		case CQTaskEditToggleAssignee:
+			bot.toggleTaskAssignee(chatId, update.CallbackQuery.Message.MessageID, user, id, param)
+		case CQTaskEditNotes:
+			bot.editTaskNotes(chatId, update.CallbackQuery.Message.MessageID, user, id)
+		case CQTaskEditRemoveNotes:
+			bot.removeTaskNotes(chatId, update.CallbackQuery.Message.MessageID, user, id)
+		case CQTaskEditItems:
+			bot.editTaskItems(chatId, update.CallbackQuery.Message.MessageID, user, id)
+		case CQTaskEditDetailsDone:
+			bot.finishTaskDetails(chatId, update.CallbackQuery.Message.MessageID, user, id)
+		case CQTaskEditAttachments:
+			bot.editTaskAttachments(chatId, update.CallbackQuery.Message.MessageID, user, id)
+		case CQTaskEditHistory:
+			bot.showTaskHistory(chatId, update.CallbackQuery.Message.MessageID, user, id)
+		case CQTaskItemToggle:
+			bot.toggleTaskItem(chatId, update.CallbackQuery.Message.MessageID, user, id, param)
+		case CQTaskItemRemove:
+			bot.removeTaskItem(chatId, update.CallbackQuery.Message.MessageID, user, id, param)
+		case CQReminderDone:
+			bot.completeTaskFromReminder(chatId, update.CallbackQuery.Message.MessageID, user, id)
+		case CQShopItemComplete:
+			bot.completeShoppingItem(chatId, update.CallbackQuery.Message.MessageID, user, id)
+		case CQShopRemoveAllBought:
+			bot.removeAllBoughtItems(chatId, update.CallbackQuery.Message.MessageID, user, id)
+		case CQShopRemoveAllBoughtYes:
+			bot.removeAllDoneTasksYes(chatId, update.CallbackQuery.Message.MessageID, user, id)
+		case CQShopRemoveAllBoughtNo:
+			bot.showTaskListInSameMessage(chatId, update.CallbackQuery.Message.MessageID, user, uint(id))
+		case CQListsShow:
+			bot.showLists(chatId, update.CallbackQuery.Message.MessageID, user)
+		case CQListSwitch:
+			bot.switchList(chatId, update.CallbackQuery.Message.MessageID, user, id)
+		case CQListEdit:
+			bot.editList(chatId, update.CallbackQuery.Message.MessageID, user, id)
+		case CQListRename:
+			bot.renameList(chatId, update.CallbackQuery.Message.MessageID, user, id)
+		case CQListDelete:
+			bot.deleteList(chatId, update.CallbackQuery.Message.MessageID, user, id)
+		case CQListDeleteYes:
+			bot.deleteListYes(chatId, update.CallbackQuery.Message.MessageID, user, id)
+		case CQUndo:
+			bot.undoFromList(chatId, update.CallbackQuery.Message.MessageID, user, id)
+		case CQCalendarReset:
+			bot.resetCalendarLink(chatId, update.CallbackQuery.Message.MessageID, user)
+		case CQImportToggle:
+			bot.toggleImportEvent(state, chatId, update.CallbackQuery.Message.MessageID, user, id)
+		case CQImportSave:
+			bot.saveImportEvents(state, chatId, update.CallbackQuery.Message.MessageID, user)
+		case CQImportCancel:
+			bot.cancelNewTask(chatId, update.CallbackQuery.Message.MessageID, user)
+		case CQBackupRestoreYes:
+			bot.restoreBackup(state, chatId, update.CallbackQuery.Message.MessageID, user)
+		case CQBackupRestoreNo:
+			bot.cancelNewTask(chatId, update.CallbackQuery.Message.MessageID, user)
+		case CQDayComplete:
+			bot.completeAgendaTask(chatId, update.CallbackQuery.Message.MessageID, user, id, param, false)
+		case CQWeekComplete:
+			bot.completeAgendaTask(chatId, update.CallbackQuery.Message.MessageID, user, id, param, true)
+		case CQWeekShow:
+			bot.showWeek(chatId, update.CallbackQuery.Message.MessageID, user, id)
+		case CQSetTimezone:
+			// zone names contain no colons, the rest of the data is the name
+			zone := strings.TrimPrefix(update.CallbackQuery.Data, CQSetTimezone+":")
+			bot.setTimezone(chatId, update.CallbackQuery.Message.MessageID, user, zone)
+		case CQSetLanguage:
+			lang := strings.TrimPrefix(update.CallbackQuery.Data, CQSetLanguage+":")
+			bot.setLanguage(chatId, update.CallbackQuery.Message.MessageID, user, lang)
+		case CQReminderSnooze:
+			bot.snoozeReminder(chatId, update.CallbackQuery.Message.MessageID, user, id, param, update.CallbackQuery.Message.Text)
 		default:
-			bot.sendGeneralError(chatId)
+			bot.sendGeneralError(chatId, user)
 		}
 	} else if update.Message.IsCommand() {
 		switch update.Message.Command() {
 		case commandStart, commandHelp:
-			bot.handleStartCommand(chatId)
+			bot.handleStartCommand(chatId, user)
 		case commandList:
-			bot.handleListCommand(chatId)
+			bot.handleListCommand(chatId, user)
 		case commandCancel:
-			bot.handleCancelCommand(chatId, int(user.TelegramID))
+			bot.handleCancelCommand(chatId, user)
 		case commandSubscribe:
 			bot.handleSubscribeCommand(chatId, true, user)
 		case commandUnsubscribe:
 			bot.handleSubscribeCommand(chatId, false, user)
+		case commandPin:
+			bot.handlePinCommand(chatId, user)
+		case commandUnpin:
+			bot.handleUnpinCommand(chatId, user)
+		case commandShop:
+			bot.handleShopCommand(chatId, user, update.Message.CommandArguments())
+		case commandLists:
+			bot.handleListsCommand(chatId, user, update.Message.CommandArguments())
+		case commandTimezone:
+			bot.handleTimezoneCommand(chatId, user, update.Message.CommandArguments())
+		case commandLanguage:
+			bot.handleLanguageCommand(chatId, user)
+		case commandInvite:
+			bot.handleInviteCommand(chatId, user)
+		case commandJoin:
+			bot.handleJoinCommand(chatId, user, update.Message.CommandArguments())
+		case commandUndo:
+			bot.handleUndoCommand(chatId, user)
+		case commandCalendar:
+			bot.handleCalendarCommand(chatId, user)
+		case commandExport:
+			bot.handleExportCommand(chatId, user, update.Message.CommandArguments())
+		case commandImport:
+			bot.handleImportCommand(chatId, user)
+		case commandToday:
+			bot.handleDayCommand(chatId, user, 0)
+		case commandTomorrow:
+			bot.handleDayCommand(chatId, user, 1)
+		case commandWeek:
+			bot.handleWeekCommand(chatId, user)
 		default:
-			bot.handleUnknownCommand(chatId)
+			bot.handleUnknownCommand(chatId, user)
+		}
+	} else if attachment := getMessageAttachment(update.Message); attachment != nil {
+		switch state.Status {
+		case st.STATUS_IDLE:
+			// calendar files without a caption are imported, with one they
+			// are attached to the new task like other documents
+			if isCalendarDocument(update.Message.Document) && trim(update.Message.Caption) == "" {
+				bot.handleCalendarFile(chatId, user, attachment.FileID)
+			} else {
+				bot.handleIdleMessage(chatId, user, update.Message.Caption, attachment)
+			}
+		case st.STATUS_EDIT_TASK_WAIT_FILE:
+			bot.handleTaskAttachment(chatId, user, attachment, state.Task.ID)
+		case st.STATUS_IMPORT_BACKUP_WAIT_FILE:
+			if update.Message.Document != nil {
+				bot.handleBackupFile(chatId, user, attachment.FileID)
+			} else {
+				bot.sendMessage(chatId, bot.localizer(user).T(TextBackupFileInvalid), nil, "")
+			}
+		default:
+			bot.sendParseError(chatId, user)
 		}
 	} else {
 		switch state.Status {
 		case st.STATUS_IDLE:
-			bot.handleIdleMessage(chatId, user, update.Message.Text)
+			bot.handleIdleMessage(chatId, user, update.Message.Text, nil)
 		case st.STATUS_ADD_TASK_WAIT_TITLE:
 			bot.handleNewTaskEditTitle(chatId, user, state.Task, update.Message.Text)
 		case st.STATUS_ADD_TASK_WAIT_DATE:
@@ -198,11 +370,75 @@ func (bot *Bot) handleUpdate(update tgbotapi.Update) {
 		case st.STATUS_ADD_TASK_WAIT_TIME:
 			bot.handleNewTaskEditTime(chatId, user, state.Task, update.Message.Text)
 		case st.STATUS_EDIT_TASK_WAIT_TITLE:
-			bot.handleEditTaskEditTitle(chatId, int(user.TelegramID), update.Message.Text, state.Task.ID)
+			bot.handleEditTaskEditTitle(chatId, user, update.Message.Text, state.Task.ID)
 		case st.STATUS_EDIT_TASK_WAIT_DATE:
-			bot.handleEditTaskEditDate(chatId, int(user.TelegramID), update.Message.Text, state.Task.ID)
+			bot.handleEditTaskEditDate(chatId, user, update.Message.Text, state.Task.ID)
 		case st.STATUS_EDIT_TASK_WAIT_TIME:
-			bot.handleEditTaskEditTime(chatId, int(user.TelegramID), update.Message.Text, state.Task.ID)
+			bot.handleEditTaskEditTime(chatId, user, update.Message.Text, state.Task.ID)
+		case st.STATUS_EDIT_LIST_WAIT_NAME:
+			bot.handleEditListName(chatId, user, update.Message.Text, state.Task.ListID)
+		case st.STATUS_EDIT_TASK_WAIT_NOTES:
+			bot.handleEditTaskNotes(chatId, user, update.Message.Text, state.Task.ID)
+		case st.STATUS_EDIT_TASK_WAIT_ITEMS:
+			bot.handleEditTaskItems(chatId, user, update.Message.Text, state.Task.ID)
+		case st.STATUS_EDIT_TASK_WAIT_FILE, st.STATUS_IMPORT_BACKUP_WAIT_FILE:
+			bot.sendParseError(chatId, user)
+		}
+	}
+}
+
+// handleOutsider serves users who do not belong to any family yet,
+// the only thing they can do is to join one with an invite code.
+func (bot *Bot) handleOutsider(update tgbotapi.Update, chatId int64, user *units.User) {
+	if update.CallbackQuery != nil {
+		if err := bot.messenger.AnswerCallback(update.CallbackQuery.ID, ""); err != nil {
+			log.Println(err)
+		}
+	} else if update.Message.IsCommand() {
+		switch update.Message.Command() {
+		case commandStart, commandHelp:
+			bot.handleStartCommand(chatId, user)
+			return
+		case commandJoin:
+			bot.handleJoinCommand(chatId, user, update.Message.CommandArguments())
+			return
+		}
+	}
+
+	bot.sendMessage(chatId, bot.localizer(user).T(TextJoinRequired), nil, "")
+}
+
+func (bot *Bot) isAdmin(user *units.User) bool {
+	for _, v := range bot.admins {
+		if v == int64(user.TelegramID) {
+			return true
 		}
 	}
+
+	return false
+}
+
+// joinDefaultFamily puts a bootstrap admin into the first family, creating it
+// when the bot is started for the first time.
+func (bot *Bot) joinDefaultFamily(user *units.User) error {
+	families, err := bot.familyService.Families(context.Background(), units.FamilyFilter{Limit: 1})
+	if err != nil {
+		return err
+	}
+
+	var family *units.Family
+	if len(families) > 0 {
+		family = families[0]
+	} else {
+		family = &units.Family{Name: bot.localizer(user).T(TextDefaultFamilyName)}
+		if err := bot.familyService.CreateFamily(context.Background(), family); err != nil {
+			return err
+		}
+	}
+
+	familyID := sql.NullInt64{Int64: int64(family.ID), Valid: true}
+
+	return bot.userService.UpdateUser(context.Background(), user, units.UserPatch{
+		FamilyID: &familyID,
+	})
 }
@@ -0,0 +1,1203 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/maxwww/family_bot/i18n"
+	st "github.com/maxwww/family_bot/state"
+	"github.com/maxwww/family_bot/units"
+)
+
+const (
+	adminID    = 100
+	memberID   = 200
+	outsiderID = 300
+)
+
+var testLocation = time.FixedZone("EEST", 3*60*60)
+
+// uk renders messages the way test users see them, they have no language code
+var uk = i18n.For(i18n.Ukrainian)
+
+type testEnv struct {
+	bot       *Bot
+	messenger *MemoryMessenger
+	users     *memoryUserService
+	tasks     *memoryTaskService
+	families  *memoryFamilyService
+	lists     *memoryListService
+	states    st.StateServiceI
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	env := &testEnv{
+		messenger: NewMemoryMessenger(),
+		users:     newMemoryUserService(),
+		tasks:     newMemoryTaskService(),
+		states:    st.NewMemoryStateService(st.DefaultTTL),
+	}
+	env.families = newMemoryFamilyService(env.users)
+	env.lists = newMemoryListService(env.tasks)
+
+	env.bot = NewBot(nil, env.messenger, Services{
+		Users:         env.users,
+		Tasks:         env.tasks,
+		Families:      env.families,
+		Notifications: newMemoryNotificationService(),
+		ListMessages:  newMemoryListMessageService(env.users),
+		Lists:         env.lists,
+		State:         env.states,
+	}, []int64{adminID}, testLocation)
+
+	// 2022-05-04 is a Wednesday
+	env.bot.now = func() time.Time {
+		return time.Date(2022, 5, 4, 10, 15, 0, 0, testLocation)
+	}
+
+	return env
+}
+
+func (env *testEnv) send(fromID int64, text string) {
+	message := &tgbotapi.Message{
+		MessageID: 1,
+		From:      &tgbotapi.User{ID: fromID, FirstName: "User"},
+		Chat:      &tgbotapi.Chat{ID: fromID, Type: "private"},
+		Text:      text,
+	}
+	if strings.HasPrefix(text, "/") {
+		length := len(text)
+		if i := strings.Index(text, " "); i > 0 {
+			length = i
+		}
+		message.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: length}}
+	}
+
+	env.bot.handleUpdate(tgbotapi.Update{Message: message})
+}
+
+func (env *testEnv) press(fromID int64, messageID int, data string) {
+	env.bot.handleUpdate(tgbotapi.Update{
+		CallbackQuery: &tgbotapi.CallbackQuery{
+			ID:   "callback",
+			From: &tgbotapi.User{ID: fromID, FirstName: "User"},
+			Message: &tgbotapi.Message{
+				MessageID: messageID,
+				Chat:      &tgbotapi.Chat{ID: fromID, Type: "private"},
+			},
+			Data: data,
+		},
+	})
+}
+
+// last returns the latest recorded call of the given action.
+func (env *testEnv) last(t *testing.T, action string) RecordedMessage {
+	t.Helper()
+
+	records := env.messenger.Records()
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].Action == action {
+			return records[i]
+		}
+	}
+	t.Fatalf("no %s recorded in %+v", action, records)
+
+	return RecordedMessage{}
+}
+
+// lastEdit returns the latest edit of the given message.
+func (env *testEnv) lastEdit(t *testing.T, messageID int) RecordedMessage {
+	t.Helper()
+
+	records := env.messenger.Records()
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].Action == EditedAction && records[i].MessageID == messageID {
+			return records[i]
+		}
+	}
+	t.Fatalf("message %d was not edited in %+v", messageID, records)
+
+	return RecordedMessage{}
+}
+
+func hasButton(keyboard *tgbotapi.InlineKeyboardMarkup, data string) bool {
+	if keyboard == nil {
+		return false
+	}
+	for _, row := range keyboard.InlineKeyboard {
+		for _, button := range row {
+			if button.CallbackData != nil && *button.CallbackData == data {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func TestOutsiderMustJoinFamily(t *testing.T) {
+	env := newTestEnv(t)
+
+	env.send(outsiderID, "купити хліб")
+
+	sent := env.last(t, SentAction)
+	if sent.ChatID != outsiderID || sent.Text != uk.T(TextJoinRequired) {
+		t.Errorf("got %+v, want join required message", sent)
+	}
+	if tasks, _ := env.tasks.Tasks(context.Background(), units.TaskFilter{}); len(tasks) != 0 {
+		t.Errorf("outsider created %d tasks", len(tasks))
+	}
+}
+
+func TestAdminJoinsDefaultFamily(t *testing.T) {
+	env := newTestEnv(t)
+
+	env.send(adminID, "/start")
+
+	sent := env.last(t, SentAction)
+	if sent.Text != uk.T(TextStartMessage) {
+		t.Errorf("got %q, want start message", sent.Text)
+	}
+
+	user, err := env.users.UserByTelegramID(context.Background(), adminID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.FamilyID.Valid {
+		t.Error("admin did not join the default family")
+	}
+}
+
+func TestCreateTaskFromText(t *testing.T) {
+	env := newTestEnv(t)
+	env.send(adminID, "/subscribe")
+	if sent := env.last(t, SentAction); sent.Text != uk.T(TextSubscriptionsOn) {
+		t.Fatalf("got %q, want subscription confirmation", sent.Text)
+	}
+
+	env.send(adminID, "купити хліб завтра 18:00")
+
+	preview := env.last(t, SentAction)
+	if !strings.Contains(preview.Text, "купити хліб") {
+		t.Errorf("preview %q does not contain the title", preview.Text)
+	}
+	if !hasButton(preview.Keyboard, CQNewTaskSave) {
+		t.Fatalf("preview keyboard has no save button: %+v", preview.Keyboard)
+	}
+	if state := env.states.GetUserState(adminID); state.Status != st.STATUS_ADD_TASK_PARSED {
+		t.Errorf("state = %q, want %q", state.Status, st.STATUS_ADD_TASK_PARSED)
+	}
+
+	env.press(adminID, preview.MessageID, CQNewTaskSave)
+
+	tasks, _ := env.tasks.Tasks(context.Background(), units.TaskFilter{})
+	if len(tasks) != 1 {
+		t.Fatalf("got %d tasks, want 1", len(tasks))
+	}
+	task := tasks[0]
+	if task.Title != "купити хліб" || !task.Date.Time.Equal(time.Date(2022, 5, 5, 18, 0, 0, 0, testLocation)) || task.FamilyID == 0 {
+		t.Errorf("saved task = %+v", task)
+	}
+
+	if deleted := env.last(t, DeletedAction); deleted.MessageID != preview.MessageID {
+		t.Errorf("deleted message %d, want preview %d", deleted.MessageID, preview.MessageID)
+	}
+	if sent := env.last(t, SentAction); !strings.HasPrefix(sent.Text, uk.T(TextNewTaskAdded)) {
+		t.Errorf("got %q, want saved task info", sent.Text)
+	}
+	if state := env.states.GetUserState(adminID); state.Status != st.STATUS_IDLE {
+		t.Errorf("state = %q, want idle", state.Status)
+	}
+}
+
+func TestInviteAndJoin(t *testing.T) {
+	env := newTestEnv(t)
+
+	env.send(adminID, "/invite")
+
+	var code string
+	for c := range env.families.invites {
+		code = c
+	}
+	if code == "" {
+		t.Fatal("no invite created")
+	}
+	if sent := env.last(t, SentAction); !strings.Contains(sent.Text, code) {
+		t.Errorf("invite message %q does not contain the code", sent.Text)
+	}
+
+	env.send(memberID, "/join "+strings.ToLower(code))
+
+	if sent := env.last(t, SentAction); sent.ChatID != memberID || !strings.Contains(sent.Text, uk.T(TextDefaultFamilyName)) {
+		t.Errorf("got %+v, want joined message", sent)
+	}
+
+	admin, _ := env.users.UserByTelegramID(context.Background(), adminID)
+	member, _ := env.users.UserByTelegramID(context.Background(), memberID)
+	if member.FamilyID != admin.FamilyID {
+		t.Errorf("member family = %v, want %v", member.FamilyID, admin.FamilyID)
+	}
+
+	env.send(outsiderID, "/join "+code)
+
+	if sent := env.last(t, SentAction); sent.Text != uk.T(TextInviteInvalid) {
+		t.Errorf("reused code: got %q, want invalid invite", sent.Text)
+	}
+}
+
+func TestCompleteTaskFromList(t *testing.T) {
+	env := newTestEnv(t)
+	env.send(adminID, "/start")
+	admin, _ := env.users.UserByTelegramID(context.Background(), adminID)
+
+	task := &units.Task{Title: "прибрати"}
+	env.createTask(t, admin, task)
+
+	env.send(adminID, "/list")
+
+	list := env.last(t, SentAction)
+	if !strings.Contains(list.Text, "прибрати") {
+		t.Errorf("list %q does not contain the task", list.Text)
+	}
+	complete := CQTaskComplete + ":1"
+	if !hasButton(list.Keyboard, complete) {
+		t.Fatalf("list keyboard has no complete button: %+v", list.Keyboard)
+	}
+
+	env.press(adminID, list.MessageID, complete)
+
+	stored, _ := env.tasks.TaskByID(context.Background(), task.ID)
+	if !stored.Done {
+		t.Error("task is not completed")
+	}
+	if edited := env.last(t, EditedAction); edited.MessageID != list.MessageID {
+		t.Errorf("edited message %d, want list %d", edited.MessageID, list.MessageID)
+	}
+	if answered := env.last(t, AnsweredAction); answered.CallbackID != "callback" {
+		t.Errorf("callback was not answered: %+v", answered)
+	}
+}
+
+// createTask adds the task to the task list of the user's family.
+func (env *testEnv) createTask(t *testing.T, user *units.User, task *units.Task) {
+	t.Helper()
+
+	list, err := env.bot.getFamilyList(user, units.ListKindTasks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task.FamilyID = list.FamilyID
+	task.ListID = list.ID
+	if err := env.tasks.CreateTask(context.Background(), task); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// joinFamily brings the member into the admin's family.
+func (env *testEnv) joinFamily(t *testing.T, telegramID int64) {
+	t.Helper()
+
+	env.send(adminID, "/invite")
+	for code := range env.families.invites {
+		env.send(telegramID, "/join "+code)
+	}
+
+	member, _ := env.users.UserByTelegramID(context.Background(), uint(telegramID))
+	if !member.FamilyID.Valid {
+		t.Fatal("member did not join the family")
+	}
+}
+
+func TestListMessagesAreSharedLive(t *testing.T) {
+	env := newTestEnv(t)
+	env.joinFamily(t, memberID)
+	admin, _ := env.users.UserByTelegramID(context.Background(), adminID)
+
+	task := &units.Task{Title: "прибрати"}
+	env.createTask(t, admin, task)
+
+	env.send(memberID, "/list")
+	memberList := env.last(t, SentAction)
+	env.send(adminID, "/list")
+	adminList := env.last(t, SentAction)
+	env.messenger.Reset()
+
+	env.press(adminID, adminList.MessageID, CQTaskComplete+":1")
+
+	var edited []RecordedMessage
+	for _, r := range env.messenger.Records() {
+		if r.Action == EditedAction {
+			edited = append(edited, r)
+		}
+	}
+	if len(edited) != 2 {
+		t.Fatalf("got %d edits, want the lists of both members: %+v", len(edited), edited)
+	}
+	if edited[0].ChatID != adminID || edited[0].MessageID != adminList.MessageID {
+		t.Errorf("first edit = %+v, want the admin's list", edited[0])
+	}
+	if edited[1].ChatID != memberID || edited[1].MessageID != memberList.MessageID {
+		t.Errorf("second edit = %+v, want the member's list", edited[1])
+	}
+
+	// a list turned into an editing form is not overwritten
+	env.press(memberID, memberList.MessageID, CQTaskEdit+":1")
+	env.messenger.Reset()
+
+	env.send(adminID, "нова справа")
+	env.press(adminID, env.last(t, SentAction).MessageID, CQNewTaskSave)
+
+	for _, r := range env.messenger.Records() {
+		if r.Action == EditedAction && r.ChatID == memberID {
+			t.Errorf("editing form was overwritten: %+v", r)
+		}
+	}
+	if edited := env.last(t, EditedAction); edited.ChatID != adminID || !strings.Contains(edited.Text, "нова справа") {
+		t.Errorf("admin list was not refreshed: %+v", edited)
+	}
+}
+
+func TestPinnedListMoves(t *testing.T) {
+	env := newTestEnv(t)
+
+	env.send(adminID, "/pin")
+	first := env.last(t, SentAction)
+	if pinned := env.last(t, PinnedAction); pinned.MessageID != first.MessageID {
+		t.Fatalf("pinned %d, want %d", pinned.MessageID, first.MessageID)
+	}
+
+	env.send(adminID, "/list")
+	second := env.last(t, SentAction)
+	if unpinned := env.last(t, UnpinnedAction); unpinned.MessageID != first.MessageID {
+		t.Errorf("unpinned %d, want %d", unpinned.MessageID, first.MessageID)
+	}
+	if pinned := env.last(t, PinnedAction); pinned.MessageID != second.MessageID {
+		t.Errorf("pinned %d, want %d", pinned.MessageID, second.MessageID)
+	}
+
+	env.send(adminID, "/unpin")
+	if unpinned := env.last(t, UnpinnedAction); unpinned.MessageID != second.MessageID {
+		t.Errorf("unpinned %d, want %d", unpinned.MessageID, second.MessageID)
+	}
+	env.messenger.Reset()
+
+	env.send(adminID, "/list")
+	for _, r := range env.messenger.Records() {
+		if r.Action == PinnedAction {
+			t.Errorf("list was pinned after /unpin: %+v", r)
+		}
+	}
+}
+
+func TestTaskOfAnotherFamilyIsHidden(t *testing.T) {
+	env := newTestEnv(t)
+	env.send(adminID, "/start")
+
+	other := &units.Family{Name: "інша"}
+	if err := env.families.CreateFamily(context.Background(), other); err != nil {
+		t.Fatal(err)
+	}
+	task := &units.Task{Title: "чужа справа", FamilyID: other.ID}
+	if err := env.tasks.CreateTask(context.Background(), task); err != nil {
+		t.Fatal(err)
+	}
+
+	env.press(adminID, 1, CQTaskComplete+":1")
+
+	stored, _ := env.tasks.TaskByID(context.Background(), task.ID)
+	if stored.Done {
+		t.Error("task of another family was completed")
+	}
+	if sent := env.last(t, SentAction); sent.Text != uk.T(TextGeneralError) {
+		t.Errorf("got %q, want general error", sent.Text)
+	}
+}
+
+func TestAssigneeOfAnotherFamilyIsRejected(t *testing.T) {
+	env := newTestEnv(t)
+	env.send(adminID, "/start")
+	env.send(outsiderID, "/start")
+
+	admin, _ := env.users.UserByTelegramID(context.Background(), adminID)
+	outsider, _ := env.users.UserByTelegramID(context.Background(), outsiderID)
+	task := &units.Task{Title: "прибрати"}
+	env.createTask(t, admin, task)
+
+	env.press(adminID, 1, fmt.Sprintf(CQTaskEditToggleAssignee+":%d:%d", task.ID, outsider.ID))
+
+	stored, _ := env.tasks.TaskByID(context.Background(), task.ID)
+	if len(stored.Assignees) != 0 {
+		t.Errorf("assignees = %v, want none", stored.Assignees)
+	}
+	if sent := env.last(t, SentAction); sent.Text != uk.T(TextGeneralError) {
+		t.Errorf("got %q, want general error", sent.Text)
+	}
+
+	env.send(adminID, "помити посуд")
+	env.press(adminID, 1, fmt.Sprintf(CQNewTaskToggleAssignee+"::%d", outsider.ID))
+	if sent := env.last(t, SentAction); sent.Text != uk.T(TextGeneralError) {
+		t.Errorf("new task: got %q, want general error", sent.Text)
+	}
+
+	env.press(adminID, 1, fmt.Sprintf(CQNewTaskToggleAssignee+"::%d", admin.ID))
+	if edit := env.lastEdit(t, 1); !strings.Contains(edit.Text, admin.FirstName) {
+		t.Errorf("member was not assigned: %+v", edit)
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	env := newTestEnv(t)
+
+	env.send(adminID, "/dance")
+
+	if sent := env.last(t, SentAction); sent.Text != uk.T(TextUnknownCommand) {
+		t.Errorf("got %q, want unknown command message", sent.Text)
+	}
+}
+
+func TestTimezoneIsPerUser(t *testing.T) {
+	env := newTestEnv(t)
+	env.joinFamily(t, memberID)
+	admin, _ := env.users.UserByTelegramID(context.Background(), adminID)
+
+	task := &units.Task{
+		Title: "зустріч",
+		Date:  sql.NullTime{Time: time.Date(2022, 5, 5, 18, 0, 0, 0, testLocation), Valid: true},
+	}
+	env.createTask(t, admin, task)
+
+	env.press(memberID, 1, CQSetTimezone+":Europe/London")
+
+	member, _ := env.users.UserByTelegramID(context.Background(), memberID)
+	if member.Timezone != "Europe/London" {
+		t.Fatalf("member timezone = %q, want Europe/London", member.Timezone)
+	}
+
+	env.send(memberID, "/list")
+	if list := env.last(t, SentAction); !strings.Contains(list.Text, "зустріч ("+uk.T(TextTomorrow)+" 16:00)") {
+		t.Errorf("member list %q does not show London time", list.Text)
+	}
+	env.send(adminID, "/list")
+	if list := env.last(t, SentAction); !strings.Contains(list.Text, "зустріч ("+uk.T(TextTomorrow)+" 18:00)") {
+		t.Errorf("admin list %q does not show the default time", list.Text)
+	}
+
+	env.send(memberID, "дзвінок завтра 9:00")
+	preview := env.last(t, SentAction)
+	env.press(memberID, preview.MessageID, CQNewTaskSave)
+
+	saved, err := env.tasks.TaskByID(context.Background(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2022, 5, 5, 8, 0, 0, 0, time.UTC); !saved.Date.Time.Equal(want) {
+		t.Errorf("saved date = %v, want %v", saved.Date.Time, want)
+	}
+}
+
+func TestLanguageIsPerUser(t *testing.T) {
+	env := newTestEnv(t)
+	env.joinFamily(t, memberID)
+
+	env.send(memberID, "/language")
+	choose := env.last(t, SentAction)
+	if !hasButton(choose.Keyboard, CQSetLanguage+":"+i18n.English) {
+		t.Fatalf("language keyboard has no English: %+v", choose.Keyboard)
+	}
+
+	env.press(memberID, choose.MessageID, CQSetLanguage+":"+i18n.English)
+
+	en := i18n.For(i18n.English)
+	if edited := env.last(t, EditedAction); edited.Text != en.T(TextLanguageSet) {
+		t.Errorf("got %q, want the confirmation in English", edited.Text)
+	}
+
+	env.send(memberID, "/list")
+	if list := env.last(t, SentAction); !strings.HasPrefix(list.Text, en.T(TextTasksListEmpty)) {
+		t.Errorf("member list %q is not in English", list.Text)
+	}
+	env.send(adminID, "/list")
+	if list := env.last(t, SentAction); !strings.HasPrefix(list.Text, uk.T(TextTasksListEmpty)) {
+		t.Errorf("admin list %q is not in Ukrainian", list.Text)
+	}
+}
+
+func TestLanguageDefaultsFromTelegram(t *testing.T) {
+	env := newTestEnv(t)
+
+	env.bot.handleUpdate(tgbotapi.Update{Message: &tgbotapi.Message{
+		MessageID: 1,
+		From:      &tgbotapi.User{ID: outsiderID, FirstName: "Bob", LanguageCode: "en-US"},
+		Chat:      &tgbotapi.Chat{ID: outsiderID, Type: "private"},
+		Text:      "hello",
+	}})
+
+	if sent := env.last(t, SentAction); sent.Text != i18n.For(i18n.English).T(TextJoinRequired) {
+		t.Errorf("got %q, want the join message in English", sent.Text)
+	}
+	if user, _ := env.users.UserByTelegramID(context.Background(), outsiderID); user.Language != i18n.English {
+		t.Errorf("language = %q, want %q", user.Language, i18n.English)
+	}
+}
+
+func TestShoppingList(t *testing.T) {
+	env := newTestEnv(t)
+	env.send(adminID, "/start")
+
+	env.send(adminID, "/shop молоко 2л, яйця x10, хліб")
+	admin, _ := env.users.UserByTelegramID(context.Background(), adminID)
+	shoppingList, _ := env.bot.getFamilyList(admin, units.ListKindShopping)
+
+	list := env.last(t, SentAction)
+	for _, want := range []string{"молоко 2 л", "яйця ×10", "хліб", uk.T("category_dairy"), uk.T("category_bakery")} {
+		if !strings.Contains(list.Text, want) {
+			t.Errorf("shopping list %q does not contain %q", list.Text, want)
+		}
+	}
+	if strings.Index(list.Text, "молоко") > strings.Index(list.Text, "хліб") {
+		t.Errorf("shopping list %q is not grouped by category", list.Text)
+	}
+
+	env.send(adminID, "/list")
+	if tasks := env.last(t, SentAction); strings.Contains(tasks.Text, "молоко") {
+		t.Errorf("task list %q shows shopping items", tasks.Text)
+	}
+
+	complete := CQShopItemComplete + ":1"
+	if !hasButton(list.Keyboard, complete) {
+		t.Fatalf("shopping list keyboard has no complete button: %+v", list.Keyboard)
+	}
+	env.press(adminID, list.MessageID, complete)
+
+	bought := env.last(t, EditedAction)
+	if !strings.Contains(bought.Text, "<s>1. молоко 2 л</s>") {
+		t.Errorf("bought item is not crossed out in %q", bought.Text)
+	}
+	clear := fmt.Sprintf(CQShopRemoveAllBought+":%d", shoppingList.ID)
+	if !hasButton(bought.Keyboard, clear) {
+		t.Fatalf("shopping list keyboard has no clear button: %+v", bought.Keyboard)
+	}
+
+	env.press(adminID, list.MessageID, clear)
+	if confirmation := env.last(t, EditedAction); confirmation.Text != uk.T(TextRemoveAllBoughtConfirmation) {
+		t.Errorf("got %q, want clear confirmation", confirmation.Text)
+	}
+	env.press(adminID, list.MessageID, fmt.Sprintf(CQShopRemoveAllBoughtYes+":%d", shoppingList.ID))
+
+	cleared := env.lastEdit(t, list.MessageID)
+	if strings.Contains(cleared.Text, "молоко") || !strings.Contains(cleared.Text, "хліб") {
+		t.Errorf("got %q, want only bought items cleared", cleared.Text)
+	}
+	if tasks, _ := env.tasks.Tasks(context.Background(), units.TaskFilter{}); len(tasks) != 2 {
+		t.Errorf("%d items left, want 2", len(tasks))
+	}
+}
+
+func TestNamedLists(t *testing.T) {
+	env := newTestEnv(t)
+	env.send(adminID, "/lists Дача")
+
+	admin, _ := env.users.UserByTelegramID(context.Background(), adminID)
+	if !admin.ActiveListID.Valid {
+		t.Fatal("created list is not active")
+	}
+	dacha := uint(admin.ActiveListID.Int64)
+	tasksList, _ := env.bot.getFamilyList(admin, units.ListKindTasks)
+	if sent := env.last(t, SentAction); !strings.HasPrefix(sent.Text, uk.T(TextListEmpty, "Дача")) {
+		t.Errorf("got %q, want the empty new list", sent.Text)
+	}
+
+	env.send(adminID, "полити квіти")
+	env.press(adminID, env.last(t, SentAction).MessageID, CQNewTaskSave)
+	env.send(adminID, "#завдання купити хліб")
+	env.press(adminID, env.last(t, SentAction).MessageID, CQNewTaskSave)
+
+	for title, listID := range map[string]uint{"полити квіти": dacha, "купити хліб": tasksList.ID} {
+		tasks, _ := env.tasks.Tasks(context.Background(), units.TaskFilter{ListID: &listID})
+		if len(tasks) != 1 || tasks[0].Title != title {
+			t.Errorf("list %d has %+v, want %q", listID, tasks, title)
+		}
+	}
+
+	env.send(adminID, "#Ремонт фарба")
+	if sent := env.last(t, SentAction); sent.Text != uk.T(TextListNotFound, "Ремонт", "Ремонт") {
+		t.Errorf("got %q, want list not found", sent.Text)
+	}
+
+	env.send(adminID, "/lists")
+	lists := env.last(t, SentAction)
+	switchToTasks := fmt.Sprintf(CQListSwitch+":%d", tasksList.ID)
+	if !hasButton(lists.Keyboard, switchToTasks) {
+		t.Fatalf("lists keyboard has no switch button: %+v", lists.Keyboard)
+	}
+	env.press(adminID, lists.MessageID, switchToTasks)
+
+	if shown := env.lastEdit(t, lists.MessageID); !strings.Contains(shown.Text, "купити хліб") || strings.Contains(shown.Text, "полити квіти") {
+		t.Errorf("switched list shows %q", shown.Text)
+	}
+
+	env.press(adminID, lists.MessageID, fmt.Sprintf(CQListRename+":%d", dacha))
+	env.send(adminID, "Дача біля річки")
+	if list, _ := env.lists.ListByID(context.Background(), dacha); list.Name != "Дача біля річки" {
+		t.Errorf("list name = %q, want renamed", list.Name)
+	}
+
+	env.press(adminID, lists.MessageID, fmt.Sprintf(CQListDeleteYes+":%d", dacha))
+	if _, err := env.lists.ListByID(context.Background(), dacha); err != units.ErrNotFound {
+		t.Errorf("list was not removed: %v", err)
+	}
+	if tasks, _ := env.tasks.Tasks(context.Background(), units.TaskFilter{}); len(tasks) != 1 {
+		t.Errorf("%d tasks left, want tasks of the removed list gone", len(tasks))
+	}
+
+	env.press(adminID, lists.MessageID, fmt.Sprintf(CQListDeleteYes+":%d", tasksList.ID))
+	if _, err := env.lists.ListByID(context.Background(), tasksList.ID); err != nil {
+		t.Errorf("family task list was removed: %v", err)
+	}
+}
+
+func TestTaskNotesAndChecklist(t *testing.T) {
+	env := newTestEnv(t)
+	env.send(adminID, "/start")
+	admin, _ := env.users.UserByTelegramID(context.Background(), adminID)
+
+	task := &units.Task{Title: "підготувати документи до школи"}
+	env.createTask(t, admin, task)
+
+	env.send(adminID, "/list")
+	list := env.last(t, SentAction)
+	env.press(adminID, list.MessageID, fmt.Sprintf(CQTaskEdit+":%d", task.ID))
+	editing := env.lastEdit(t, list.MessageID)
+	itemsData := fmt.Sprintf(CQTaskEditItems+":%d", task.ID)
+	notesData := fmt.Sprintf(CQTaskEditNotes+":%d", task.ID)
+	if !hasButton(editing.Keyboard, itemsData) || !hasButton(editing.Keyboard, notesData) {
+		t.Fatalf("editing keyboard has no notes or checklist buttons: %+v", editing.Keyboard)
+	}
+
+	env.press(adminID, list.MessageID, notesData)
+	env.send(adminID, "копії в синій папці")
+	if stored, _ := env.tasks.TaskByID(context.Background(), task.ID); stored.Notes != "копії в синій папці" {
+		t.Errorf("notes = %q, want the sent text", stored.Notes)
+	}
+
+	env.press(adminID, list.MessageID, itemsData)
+	env.send(adminID, "свідоцтво\nфото 3x4\n\nдовідка")
+	stored, _ := env.tasks.TaskByID(context.Background(), task.ID)
+	if len(stored.Items) != 3 || stored.Items[1].Title != "фото 3x4" {
+		t.Fatalf("items = %+v, want three entries in order", stored.Items)
+	}
+
+	checklist := env.last(t, SentAction)
+	toggle := fmt.Sprintf(CQTaskItemToggle+":%d:%d", task.ID, stored.Items[0].ID)
+	if !hasButton(checklist.Keyboard, toggle) {
+		t.Fatalf("checklist keyboard has no toggle button: %+v", checklist.Keyboard)
+	}
+	env.press(adminID, checklist.MessageID, toggle)
+	env.press(adminID, checklist.MessageID, fmt.Sprintf(CQTaskItemRemove+":%d:%d", task.ID, stored.Items[2].ID))
+	env.press(adminID, checklist.MessageID, fmt.Sprintf(CQTaskEditDetailsDone+":%d", task.ID))
+
+	env.send(adminID, "/list")
+	if shown := env.last(t, SentAction); !strings.Contains(shown.Text, "підготувати документи до школи (1/2) "+TextNotes) {
+		t.Errorf("list %q does not show the checklist progress", shown.Text)
+	}
+}
+
+func TestTaskFromPhoto(t *testing.T) {
+	env := newTestEnv(t)
+	env.send(adminID, "/start")
+
+	env.bot.handleUpdate(tgbotapi.Update{Message: &tgbotapi.Message{
+		MessageID: 1,
+		From:      &tgbotapi.User{ID: adminID, FirstName: "User"},
+		Chat:      &tgbotapi.Chat{ID: adminID, Type: "private"},
+		Caption:   "оплатити цей рахунок",
+		Photo:     []tgbotapi.PhotoSize{{FileID: "small"}, {FileID: "large"}},
+	}})
+	env.press(adminID, env.last(t, SentAction).MessageID, CQNewTaskSave)
+
+	tasks, _ := env.tasks.Tasks(context.Background(), units.TaskFilter{})
+	if len(tasks) != 1 || tasks[0].Title != "оплатити цей рахунок" {
+		t.Fatalf("tasks = %+v, want the task of the caption", tasks)
+	}
+	if attachments := tasks[0].Attachments; len(attachments) != 1 || attachments[0].FileID != "large" {
+		t.Fatalf("attachments = %+v, want the largest photo", attachments)
+	}
+
+	env.send(adminID, "/list")
+	list := env.last(t, SentAction)
+	if !strings.Contains(list.Text, "оплатити цей рахунок "+TextAttachment) {
+		t.Errorf("list %q has no attachment marker", list.Text)
+	}
+
+	env.press(adminID, list.MessageID, fmt.Sprintf(CQTaskEditAttachments+":%d", tasks[0].ID))
+	if sent := env.last(t, FileAction); sent.Text != "large" || sent.ChatID != adminID {
+		t.Errorf("resent %+v, want the stored photo", sent)
+	}
+}
+
+func TestTaskHistory(t *testing.T) {
+	env := newTestEnv(t)
+	env.joinFamily(t, memberID)
+
+	env.send(adminID, "полагодити кран")
+	env.press(adminID, env.last(t, SentAction).MessageID, CQNewTaskSave)
+	tasks, _ := env.tasks.Tasks(context.Background(), units.TaskFilter{})
+	task := tasks[0]
+
+	env.send(memberID, "/list")
+	list := env.last(t, SentAction)
+	env.press(memberID, list.MessageID, fmt.Sprintf(CQTaskComplete+":%d", task.ID))
+
+	admin, _ := env.users.UserByTelegramID(context.Background(), adminID)
+	member, _ := env.users.UserByTelegramID(context.Background(), memberID)
+	stored, _ := env.tasks.TaskByID(context.Background(), task.ID)
+	if stored.CreatedBy.Int64 != int64(admin.ID) || stored.CompletedBy.Int64 != int64(member.ID) {
+		t.Errorf("created by %v and completed by %v, want the admin and the member", stored.CreatedBy, stored.CompletedBy)
+	}
+
+	env.press(adminID, list.MessageID, fmt.Sprintf(CQTaskEditHistory+":%d", task.ID))
+	history := env.lastEdit(t, list.MessageID)
+	for _, event := range []string{uk.T("task_event_created"), uk.T("task_event_completed")} {
+		if !strings.Contains(history.Text, event) {
+			t.Errorf("history %q has no %q", history.Text, event)
+		}
+	}
+
+	env.press(adminID, list.MessageID, fmt.Sprintf(CQTaskEditDeleteTask+":%d", task.ID))
+	events, _ := env.tasks.TaskEvents(context.Background(), units.TaskEventFilter{TaskID: &task.ID})
+	if len(events) != 3 || events[0].Kind != units.TaskEventDeleted || events[0].UserID.Int64 != int64(admin.ID) {
+		t.Errorf("events = %+v, want the removal by the admin first", events)
+	}
+
+	env.press(adminID, list.MessageID, fmt.Sprintf(CQTaskEditDeleteTask+":%d", task.ID))
+	if events, _ := env.tasks.TaskEvents(context.Background(), units.TaskEventFilter{TaskID: &task.ID}); len(events) != 3 {
+		t.Errorf("removing the task again recorded %d events, want 3", len(events))
+	}
+}
+
+func TestRemoveDoneTasksTwice(t *testing.T) {
+	env := newTestEnv(t)
+	env.send(adminID, "/start")
+	admin, _ := env.users.UserByTelegramID(context.Background(), adminID)
+
+	task := &units.Task{Title: "винести сміття", Done: true}
+	env.createTask(t, admin, task)
+
+	env.send(adminID, "/list")
+	list := env.last(t, SentAction)
+	remove := fmt.Sprintf(CQTaskRemoveAllDoneYes+":%d", task.ListID)
+	env.press(adminID, list.MessageID, remove)
+	if removed := env.lastEdit(t, list.MessageID); !hasButton(removed.Keyboard, fmt.Sprintf(CQUndo+":%d", task.ListID)) {
+		t.Fatalf("list after the removal has no undo button: %+v", removed.Keyboard)
+	}
+
+	env.press(adminID, list.MessageID, remove)
+	again := env.lastEdit(t, list.MessageID)
+	if hasButton(again.Keyboard, fmt.Sprintf(CQUndo+":%d", task.ListID)) {
+		t.Errorf("list after removing nothing has an undo button: %+v", again.Keyboard)
+	}
+	for _, r := range env.messenger.Records() {
+		if r.Action == SentAction && r.Text == uk.T(TextGeneralError) {
+			t.Errorf("removing nothing sent an error")
+		}
+	}
+	if events, _ := env.tasks.TaskEvents(context.Background(), units.TaskEventFilter{TaskID: &task.ID}); len(events) != 2 {
+		t.Errorf("events = %+v, want the creation and one removal", events)
+	}
+}
+
+func TestRemoveDoneOfUnknownList(t *testing.T) {
+	env := newTestEnv(t)
+	env.send(adminID, "/start")
+
+	for _, data := range []string{CQTaskRemoveAllDoneYes + ":9999", CQShopRemoveAllBoughtYes + ":9999"} {
+		env.press(adminID, 1, data)
+		if sent := env.last(t, SentAction); sent.Text != uk.T(TextGeneralError) {
+			t.Errorf("%s: sent %q, want the general error", data, sent.Text)
+		}
+	}
+}
+
+func TestUndo(t *testing.T) {
+	env := newTestEnv(t)
+	env.send(adminID, "/start")
+	admin, _ := env.users.UserByTelegramID(context.Background(), adminID)
+
+	task := &units.Task{Title: "винести сміття"}
+	env.createTask(t, admin, task)
+
+	env.send(adminID, "/list")
+	list := env.last(t, SentAction)
+	env.press(adminID, list.MessageID, fmt.Sprintf(CQTaskEditDeleteTask+":%d", task.ID))
+
+	undo := fmt.Sprintf(CQUndo+":%d", task.ListID)
+	removed := env.lastEdit(t, list.MessageID)
+	if !hasButton(removed.Keyboard, undo) {
+		t.Fatalf("list after the removal has no undo button: %+v", removed.Keyboard)
+	}
+	if _, err := env.tasks.TaskByID(context.Background(), task.ID); err != units.ErrNotFound {
+		t.Fatalf("removed task is still found, err = %v", err)
+	}
+
+	env.press(adminID, list.MessageID, undo)
+	restored := env.lastEdit(t, list.MessageID)
+	if !strings.Contains(restored.Text, "винести сміття") || hasButton(restored.Keyboard, undo) {
+		t.Errorf("list after undo = %q %+v, want the task back without the button", restored.Text, restored.Keyboard)
+	}
+
+	env.press(adminID, list.MessageID, fmt.Sprintf(CQTaskComplete+":%d", task.ID))
+	env.send(adminID, "/undo")
+	if sent := env.last(t, SentAction); !strings.HasPrefix(sent.Text, uk.T("undone")) || !strings.Contains(sent.Text, "винести сміття") {
+		t.Errorf("/undo replied %q", sent.Text)
+	}
+	stored, _ := env.tasks.TaskByID(context.Background(), task.ID)
+	if stored.Done {
+		t.Error("completion was not undone")
+	}
+
+	// the button expires after the grace period
+	env.press(adminID, list.MessageID, fmt.Sprintf(CQTaskEditDeleteTask+":%d", task.ID))
+	env.bot.now = func() time.Time {
+		return time.Now().Add(DefaultUndoConfig.Grace + time.Minute)
+	}
+	env.press(adminID, list.MessageID, undo)
+	if sent := env.last(t, SentAction); sent.Text != uk.T("undo_expired") {
+		t.Errorf("late undo replied %q", sent.Text)
+	}
+	if _, err := env.tasks.TaskByID(context.Background(), task.ID); err != units.ErrNotFound {
+		t.Errorf("late undo restored the task, err = %v", err)
+	}
+}
+
+func TestCalendarFeed(t *testing.T) {
+	env := newTestEnv(t)
+	env.bot.SetCalendarConfig(CalendarConfig{URL: "https://example.com/calendar/"})
+	env.send(adminID, "/start")
+	admin, _ := env.users.UserByTelegramID(context.Background(), adminID)
+	env.createTask(t, admin, &units.Task{
+		Title:         "басейн",
+		Date:          sql.NullTime{Time: time.Date(2022, 5, 6, 18, 30, 0, 0, testLocation), Valid: true},
+		Notifications: OneHourNotification,
+	})
+
+	env.send(adminID, "/calendar")
+	reply := env.last(t, SentAction)
+	family, _ := env.families.FamilyByID(context.Background(), uint(admin.FamilyID.Int64))
+	address := "https://example.com/calendar/" + family.CalendarToken.String + ".ics"
+	if !family.CalendarToken.Valid || !strings.Contains(reply.Text, address) {
+		t.Fatalf("/calendar replied %q, want %q", reply.Text, address)
+	}
+
+	get := func(token string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		env.bot.calendarHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/calendar/"+token+".ics", nil))
+		return rec
+	}
+
+	feed := get(family.CalendarToken.String)
+	if feed.Code != http.StatusOK || !strings.Contains(feed.Body.String(), "SUMMARY:басейн") || !strings.Contains(feed.Body.String(), "TRIGGER:-PT60M") {
+		t.Errorf("feed = %d %q", feed.Code, feed.Body.String())
+	}
+	if rec := get("wrong"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown token status = %d, want 404", rec.Code)
+	}
+
+	env.press(adminID, reply.MessageID, CQCalendarReset)
+	if rec := get(family.CalendarToken.String); rec.Code != http.StatusNotFound {
+		t.Errorf("old token status = %d after the reset, want 404", rec.Code)
+	}
+}
+
+const schoolCalendar = "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\nUID:meeting@school\r\nSUMMARY:Батьківські збори\r\nDTSTART;TZID=Europe/Kyiv:20220510T183000\r\n" +
+	"BEGIN:VALARM\r\nTRIGGER:-PT30M\r\nACTION:DISPLAY\r\nEND:VALARM\r\nEND:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\nUID:fair@school\r\nSUMMARY:Ярмарок\r\nDTSTART;VALUE=DATE:20220420\r\nEND:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\nUID:swim@club\r\nSUMMARY:Басейн\r\nDTSTART:20220404T060000Z\r\nRRULE:FREQ=WEEKLY\r\nEND:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\nUID:chess@club\r\nSUMMARY:Шахи\r\nDTSTART:20220405T140000Z\r\nRRULE:FREQ=WEEKLY;UNTIL=20220430\r\nEND:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+// sendCalendarFile sends the school calendar as a document.
+func (env *testEnv) sendCalendarFile(fromID int64) {
+	env.sendDocument(fromID, "school.ics", []byte(schoolCalendar))
+}
+
+// sendDocument sends the content as a file, the name is its file id too.
+func (env *testEnv) sendDocument(fromID int64, name string, data []byte) {
+	env.messenger.AddFile(name, data)
+	env.bot.handleUpdate(tgbotapi.Update{Message: &tgbotapi.Message{
+		MessageID: 1,
+		From:      &tgbotapi.User{ID: fromID, FirstName: "User"},
+		Chat:      &tgbotapi.Chat{ID: fromID, Type: "private"},
+		Document:  &tgbotapi.Document{FileID: name, FileName: name},
+	}})
+}
+
+func TestImportCalendarFile(t *testing.T) {
+	env := newTestEnv(t)
+	env.send(adminID, "/start")
+
+	env.sendCalendarFile(adminID)
+	preview := env.last(t, SentAction)
+	if strings.Contains(preview.Text, "Ярмарок") || strings.Contains(preview.Text, "Шахи") || !strings.Contains(preview.Text, "09.05.2022") || !strings.Contains(preview.Text, "10.05.2022") {
+		t.Fatalf("preview %q, want the upcoming events only", preview.Text)
+	}
+
+	// the repeating event is the nearest one
+	env.press(adminID, preview.MessageID, CQImportToggle+":0")
+	env.press(adminID, preview.MessageID, CQImportSave)
+
+	tasks, _ := env.tasks.Tasks(context.Background(), units.TaskFilter{})
+	if len(tasks) != 1 || tasks[0].Title != "Батьківські збори" || tasks[0].UID != "meeting@school" {
+		t.Fatalf("tasks = %+v, want the meeting", tasks)
+	}
+	if tasks[0].Notifications != ThirtyMinutesNotification || !tasks[0].Date.Time.Equal(time.Date(2022, 5, 10, 18, 30, 0, 0, testLocation)) {
+		t.Errorf("meeting at %v with notifications %d", tasks[0].Date.Time, tasks[0].Notifications)
+	}
+
+	env.sendCalendarFile(adminID)
+	preview = env.last(t, SentAction)
+	if !strings.Contains(preview.Text, uk.T("event_imported")) || hasButton(preview.Keyboard, CQImportToggle+":1") {
+		t.Errorf("preview %q does not mark the meeting imported", preview.Text)
+	}
+	env.press(adminID, preview.MessageID, CQImportSave)
+
+	tasks, _ = env.tasks.Tasks(context.Background(), units.TaskFilter{})
+	if len(tasks) != 2 || tasks[1].Recurrence != units.RecurrenceWeekly || !tasks[1].Date.Time.Equal(time.Date(2022, 5, 9, 9, 0, 0, 0, testLocation)) {
+		t.Errorf("tasks = %+v, want the swimming added on its next Monday", tasks)
+	}
+}
+
+func TestExportAndImportBackup(t *testing.T) {
+	env := newTestEnv(t)
+	env.send(adminID, "/start")
+	admin, _ := env.users.UserByTelegramID(context.Background(), adminID)
+	timezone := "Europe/Kyiv"
+	env.users.UpdateUser(context.Background(), admin, units.UserPatch{Timezone: &timezone})
+	env.joinFamily(t, memberID)
+	member, _ := env.users.UserByTelegramID(context.Background(), memberID)
+	memberTimezone := "Europe/London"
+	env.users.UpdateUser(context.Background(), member, units.UserPatch{Timezone: &memberTimezone})
+
+	env.createTask(t, admin, &units.Task{
+		Title: "басейн",
+		Date:  sql.NullTime{Time: time.Date(2022, 5, 6, 18, 30, 0, 0, testLocation), Valid: true},
+	})
+	env.createTask(t, admin, &units.Task{Title: "подзвонити бабусі", Done: true})
+	env.createTask(t, admin, &units.Task{Title: "похід"})
+	env.tasks.AddTaskItems(context.Background(), 3, []string{"намет", "ліхтарик"})
+	env.tasks.ToggleTaskItem(context.Background(), 3, 1)
+
+	env.send(adminID, "/export csv")
+	records := env.messenger.Records()
+	if len(records) < 2 || records[len(records)-2].Text != "family_bot-2022-05-04.json" || records[len(records)-1].Text != "family_bot-2022-05-04.csv" {
+		t.Fatalf("records = %+v, want the backup and the spreadsheet", records)
+	}
+	backup := records[len(records)-2].Data
+	if csv := string(records[len(records)-1].Data); !strings.Contains(csv, "подзвонити бабусі") {
+		t.Errorf("spreadsheet %q has no tasks", csv)
+	}
+
+	// a backup restored into the family it came from adds nothing
+	env.send(adminID, "/import")
+	env.sendDocument(adminID, "backup.json", backup)
+	if summary := env.last(t, SentAction); !strings.Contains(summary.Text, "справ: 0") || !strings.Contains(summary.Text, "пропущено: 3") {
+		t.Errorf("summary %q, want every task skipped", summary.Text)
+	}
+
+	restored := newTestEnv(t)
+	restored.send(adminID, "/start")
+	restored.joinFamily(t, memberID)
+	restored.send(adminID, "/import")
+	restored.sendDocument(adminID, "backup.json", []byte("{}"))
+	if reply := restored.last(t, SentAction); reply.Text != uk.T(TextBackupFileInvalid) {
+		t.Errorf("reply %q, want the file rejected", reply.Text)
+	}
+
+	restored.sendDocument(adminID, "backup.json", backup)
+	summary := restored.last(t, SentAction)
+	if !strings.Contains(summary.Text, "справ: 3") || !hasButton(summary.Keyboard, CQBackupRestoreYes) {
+		t.Fatalf("summary %q, want three tasks to restore", summary.Text)
+	}
+	if tasks, _ := restored.tasks.Tasks(context.Background(), units.TaskFilter{}); len(tasks) != 0 {
+		t.Fatalf("dry run created %d tasks", len(tasks))
+	}
+
+	restored.press(adminID, summary.MessageID, CQBackupRestoreYes)
+	// the new family had no lists yet
+	if reply := restored.lastEdit(t, summary.MessageID); reply.Text != uk.T(TextBackupRestored, 1, 3) {
+		t.Errorf("reply %q, want three tasks restored", reply.Text)
+	}
+
+	tasks, _ := restored.tasks.Tasks(context.Background(), units.TaskFilter{})
+	if len(tasks) != 3 || !tasks[1].Done || !tasks[0].Date.Time.Equal(time.Date(2022, 5, 6, 18, 30, 0, 0, testLocation)) {
+		t.Fatalf("tasks = %+v, want the exported ones", tasks)
+	}
+	if items := tasks[2].Items; len(items) != 2 || !items[0].Done || items[1].Done {
+		t.Errorf("items = %+v, want the checklist with the tent packed", items)
+	}
+	if user, _ := restored.users.UserByTelegramID(context.Background(), adminID); user.Timezone != timezone {
+		t.Errorf("timezone = %q, want it restored", user.Timezone)
+	}
+	if user, _ := restored.users.UserByTelegramID(context.Background(), memberID); user.Timezone == memberTimezone {
+		t.Error("settings of the member were restored by the admin")
+	}
+
+	// the confirmation works once
+	restored.press(adminID, summary.MessageID, CQBackupRestoreYes)
+	if tasks, _ := restored.tasks.Tasks(context.Background(), units.TaskFilter{}); len(tasks) != 3 {
+		t.Errorf("pressing again restored %d tasks", len(tasks))
+	}
+}
+
+func TestTaskListOrder(t *testing.T) {
+	env := newTestEnv(t)
+	env.send(adminID, "/start")
+	admin, _ := env.users.UserByTelegramID(context.Background(), adminID)
+
+	for _, task := range []*units.Task{
+		{Title: "без дати"},
+		{Title: "завтра весь день", Date: sql.NullTime{Time: time.Date(2022, 5, 5, 0, 0, 0, 0, testLocation), Valid: true}},
+		{Title: "завтра о дев'ятій", Date: sql.NullTime{Time: time.Date(2022, 5, 5, 9, 0, 0, 0, testLocation), Valid: true}},
+		{Title: "сьогодні весь день", Date: sql.NullTime{Time: time.Date(2022, 5, 4, 0, 0, 0, 0, testLocation), Valid: true}},
+	} {
+		env.createTask(t, admin, task)
+	}
+
+	env.send(adminID, "/list")
+	list := env.last(t, SentAction).Text
+	var positions []int
+	for _, title := range []string{"сьогодні весь день", "завтра о дев'ятій", "завтра весь день", "без дати"} {
+		positions = append(positions, strings.Index(list, title))
+	}
+	if !sort.IntsAreSorted(positions) || positions[0] < 0 {
+		t.Errorf("list %q is out of order", list)
+	}
+}
+
+func TestDueNotifications(t *testing.T) {
+	env := newTestEnv(t)
+	env.send(adminID, "/start")
+	env.send(adminID, "/subscribe")
+	admin, _ := env.users.UserByTelegramID(context.Background(), adminID)
+
+	at := func(hour, minute int) sql.NullTime {
+		return sql.NullTime{Time: time.Date(2022, 5, 4, hour, minute, 0, 0, testLocation), Valid: true}
+	}
+	env.createTask(t, admin, &units.Task{Title: "за годину", Date: at(11, 15), Notifications: OneHourNotification})
+	env.createTask(t, admin, &units.Task{Title: "за пів години", Date: at(10, 45), Notifications: OneHourNotification})
+	env.createTask(t, admin, &units.Task{Title: "зроблено", Date: at(11, 15), Notifications: OneHourNotification, Done: true})
+	env.createTask(t, admin, &units.Task{Title: "пізніше", Date: at(12, 0), Notifications: OneHourNotification})
+	env.messenger.Reset()
+
+	env.bot.sendDueNotifications()
+
+	var reminders []string
+	for _, record := range env.messenger.Records() {
+		if record.Action == SentAction {
+			reminders = append(reminders, record.Text)
+		}
+	}
+	if len(reminders) != 1 || reminders[0] != getReminderText(uk, "за годину", 60) {
+		t.Errorf("reminders = %q, want the one of the task due in an hour", reminders)
+	}
+}
+
+func TestDueNotificationsOfAllDayTasks(t *testing.T) {
+	env := newTestEnv(t)
+	env.joinFamily(t, memberID)
+	env.send(adminID, "/subscribe")
+	env.send(memberID, "/subscribe")
+	env.press(memberID, 1, CQSetTimezone+":Europe/London")
+	admin, _ := env.users.UserByTelegramID(context.Background(), adminID)
+	member, _ := env.users.UserByTelegramID(context.Background(), memberID)
+
+	// midnight for the admin is 22:00 in London
+	midnight := time.Date(2022, 5, 5, 0, 0, 0, 0, testLocation)
+	env.bot.now = func() time.Time {
+		return midnight
+	}
+	date := sql.NullTime{Time: midnight, Valid: true}
+	env.createTask(t, admin, &units.Task{Title: "без часу", Date: date, Notifications: InstantlyNotification,
+		CreatedBy: sql.NullInt64{Int64: int64(admin.ID), Valid: true}})
+	env.createTask(t, member, &units.Task{Title: "о десятій", Date: date, Notifications: InstantlyNotification,
+		CreatedBy: sql.NullInt64{Int64: int64(member.ID), Valid: true}})
+	env.messenger.Reset()
+
+	env.bot.sendDueNotifications()
+
+	reminders := map[int64][]string{}
+	for _, record := range env.messenger.Records() {
+		if record.Action == SentAction {
+			reminders[record.ChatID] = append(reminders[record.ChatID], record.Text)
+		}
+	}
+	for _, id := range []int64{adminID, memberID} {
+		if got := reminders[id]; len(got) != 1 || got[0] != getReminderText(uk, "о десятій", 0) {
+			t.Errorf("reminders of %d = %q, want the one of the timed task", id, got)
+		}
+	}
+}
+
+func TestAgenda(t *testing.T) {
+	env := newTestEnv(t)
+	env.send(adminID, "/start")
+	admin, _ := env.users.UserByTelegramID(context.Background(), adminID)
+
+	at := func(day, hour int) sql.NullTime {
+		return sql.NullTime{Time: time.Date(2022, 5, day, hour, 0, 0, 0, testLocation), Valid: true}
+	}
+	for _, task := range []*units.Task{
+		{Title: "прибрати", Date: at(4, 0)},
+		{Title: "басейн", Date: at(4, 18)},
+		{Title: "лікар", Date: at(5, 9)},
+		{Title: "похід", Date: at(12, 0)},
+		{Title: "полагодити кран"},
+	} {
+		env.createTask(t, admin, task)
+	}
+
+	env.send(adminID, "/today")
+	today := env.last(t, SentAction)
+	if !strings.Contains(today.Text, "1. басейн (18:00)") || !strings.Contains(today.Text, "2. прибрати") || strings.Contains(today.Text, "лікар") {
+		t.Errorf("today %q, want today's tasks with times first", today.Text)
+	}
+	if !hasButton(today.Keyboard, CQDayComplete+":2:0") || !hasButton(today.Keyboard, CQTaskEdit+":2") {
+		t.Errorf("today has no buttons of the tasks: %+v", today.Keyboard)
+	}
+
+	env.press(adminID, today.MessageID, CQDayComplete+":2:0")
+	if edit := env.lastEdit(t, today.MessageID); !strings.Contains(edit.Text, "<s>1. басейн (18:00)</s>") {
+		t.Errorf("today %q, want the swimming done", edit.Text)
+	}
+
+	env.send(adminID, "/tomorrow")
+	if tomorrow := env.last(t, SentAction); !strings.Contains(tomorrow.Text, "1. лікар (09:00)") || strings.Contains(tomorrow.Text, "прибрати") {
+		t.Errorf("tomorrow %q, want tomorrow's tasks only", tomorrow.Text)
+	}
+
+	env.send(adminID, "/week")
+	week := env.last(t, SentAction)
+	for _, want := range []string{"<b>Середа</b>, 04.05.2022", "<b>Четвер</b>, 05.05.2022\n3. лікар (09:00)", "<b>Без дати</b>\n4. полагодити кран"} {
+		if !strings.Contains(week.Text, want) {
+			t.Errorf("week %q has no %q", week.Text, want)
+		}
+	}
+	if strings.Contains(week.Text, "похід") || !hasButton(week.Keyboard, CQWeekShow+":7") || !hasButton(week.Keyboard, CQWeekShow+":-7") {
+		t.Errorf("week %q, want this week only with the navigation", week.Text)
+	}
+
+	env.press(adminID, week.MessageID, CQWeekShow+":7")
+	if next := env.lastEdit(t, week.MessageID); !strings.Contains(next.Text, "<b>Четвер</b>, 12.05.2022\n1. похід") || strings.Contains(next.Text, "лікар") {
+		t.Errorf("next week %q, want the hike", next.Text)
+	}
+}
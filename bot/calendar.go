@@ -0,0 +1,226 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/maxwww/family_bot/ical"
+	"github.com/maxwww/family_bot/units"
+)
+
+const DefaultCalendarPath = "/calendar/"
+
+type CalendarConfig struct {
+	// URL is the public address the feed is served at, e.g.
+	// "https://example.com/calendar/", /calendar is disabled when it is empty.
+	URL string
+	// ListenAddr is the address of the HTTP server of the feed, e.g. ":8081".
+	ListenAddr string
+	// Path is the route the feeds are served under, the token and ".ics"
+	// follow it.
+	Path string
+}
+
+// SetCalendarConfig enables the calendar feed, it has to be called before
+// the bot starts.
+func (bot *Bot) SetCalendarConfig(config CalendarConfig) {
+	if config.Path == "" {
+		config.Path = DefaultCalendarPath
+	}
+	bot.calendar = config
+}
+
+// ServeCalendar serves calendar feeds of families until the server fails.
+func (bot *Bot) ServeCalendar() error {
+	mux := http.NewServeMux()
+	mux.Handle(bot.calendar.Path, bot.calendarHandler())
+
+	server := &http.Server{
+		Addr:              bot.calendar.ListenAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("serving calendars on %s%s", bot.calendar.ListenAddr, bot.calendar.Path)
+
+	return server.ListenAndServe()
+}
+
+// calendarHandler writes tasks of the family whose token is in the path,
+// unknown tokens are not found so feeds cannot be guessed.
+func (bot *Bot) calendarHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		token := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, bot.calendar.Path), ".ics")
+		if token == "" || strings.Contains(token, "/") {
+			http.NotFound(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		families, err := bot.familyService.Families(ctx, units.FamilyFilter{CalendarToken: &token, Limit: 1})
+		if err != nil {
+			log.Println(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if len(families) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+
+		tasks, err := bot.getCalendarTasks(ctx, families[0].ID, units.TaskFilter{})
+		if err != nil {
+			log.Println(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		var buf bytes.Buffer
+		if err := bot.calendarEncoder().Encode(&buf, families[0].Name, tasks); err != nil {
+			log.Println(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			log.Println(err)
+		}
+	})
+}
+
+// getCalendarTasks returns tasks of every list of the family but the
+// shopping ones which match the filter.
+func (bot *Bot) getCalendarTasks(ctx context.Context, familyID uint, filter units.TaskFilter) ([]*units.Task, error) {
+	lists, err := bot.listService.Lists(ctx, units.ListFilter{FamilyID: &familyID})
+	if err != nil {
+		return nil, err
+	}
+
+	filter.FamilyID = &familyID
+	tasks, err := bot.taskService.Tasks(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	shopping := map[uint]bool{}
+	for _, list := range lists {
+		shopping[list.ID] = list.Kind == units.ListKindShopping
+	}
+
+	var result []*units.Task
+	for _, task := range tasks {
+		if !shopping[task.ListID] {
+			result = append(result, task)
+		}
+	}
+
+	return result, nil
+}
+
+// calendarEncoder sees all-day tasks in the default location of the bot,
+// a feed is shared by the family so it cannot follow the zone of each user.
+func (bot *Bot) calendarEncoder() *ical.Encoder {
+	return &ical.Encoder{
+		Location: bot.loc,
+		Now:      bot.now,
+		Alarms: func(notifications int) []time.Duration {
+			var alarms []time.Duration
+			for _, notification := range []int{OneHourNotification, ThirtyMinutesNotification, FiveMinutesNotification, InstantlyNotification} {
+				if notifications&notification != 0 {
+					alarms = append(alarms, time.Duration(getMinutesFromNotificationType(notification))*time.Minute)
+				}
+			}
+			return alarms
+		},
+	}
+}
+
+func (bot *Bot) handleCalendarCommand(chatId int64, user *units.User) {
+	tr := bot.localizer(user)
+	if bot.calendar.URL == "" {
+		bot.sendMessage(chatId, tr.T(TextCalendarDisabled), nil, "")
+		return
+	}
+
+	family, err := bot.familyService.FamilyByID(context.Background(), uint(user.FamilyID.Int64))
+	if err == nil && !family.CalendarToken.Valid {
+		err = bot.resetCalendarToken(family)
+	}
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	message, keyboard := bot.getCalendar(user, family)
+	bot.sendMessage(chatId, message, keyboard, "")
+}
+
+// resetCalendarLink gives the family a new address of the feed, calendars
+// subscribed to the old one stop updating.
+func (bot *Bot) resetCalendarLink(chatId int64, messageId int, user *units.User) {
+	family, err := bot.familyService.FamilyByID(context.Background(), uint(user.FamilyID.Int64))
+	if err == nil {
+		err = bot.resetCalendarToken(family)
+	}
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	message, keyboard := bot.getCalendar(user, family)
+	bot.editMessage(chatId, messageId, message, keyboard, "")
+}
+
+func (bot *Bot) resetCalendarToken(family *units.Family) error {
+	token, err := generateCalendarToken()
+	if err != nil {
+		return err
+	}
+
+	if err := bot.familyService.SetCalendarToken(context.Background(), family.ID, token); err != nil {
+		return err
+	}
+	family.CalendarToken.String, family.CalendarToken.Valid = token, true
+
+	return nil
+}
+
+func (bot *Bot) getCalendar(user *units.User, family *units.Family) (string, *tgbotapi.InlineKeyboardMarkup) {
+	tr := bot.localizer(user)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(tr.T(TextActionResetCalendar), CQCalendarReset),
+	))
+
+	return tr.T(TextCalendar, bot.calendarURL(family)), &keyboard
+}
+
+// generateCalendarToken returns a random token long enough not to be guessed.
+func generateCalendarToken() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf)), nil
+}
+
+// calendarURL is the address of the feed of the family.
+func (bot *Bot) calendarURL(family *units.Family) string {
+	return fmt.Sprintf("%s/%s.ics", strings.TrimSuffix(bot.calendar.URL, "/"), family.CalendarToken.String)
+}
@@ -0,0 +1,269 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/maxwww/family_bot/i18n"
+	"github.com/maxwww/family_bot/ical"
+	st "github.com/maxwww/family_bot/state"
+	"github.com/maxwww/family_bot/units"
+)
+
+// importLimit is how many of the nearest events of a file are offered,
+// a school calendar may hold the whole year.
+const importLimit = 20
+
+// isCalendarDocument tells .ics files apart from other documents.
+func isCalendarDocument(document *tgbotapi.Document) bool {
+	if document == nil {
+		return false
+	}
+
+	return document.MimeType == "text/calendar" || strings.HasSuffix(strings.ToLower(document.FileName), ".ics")
+}
+
+// handleCalendarFile reads events of the file and offers to pick which of
+// them become tasks.
+func (bot *Bot) handleCalendarFile(chatId int64, user *units.User, fileId string) {
+	tr := bot.localizer(user)
+	data, err := bot.messenger.DownloadFile(fileId)
+	if err != nil {
+		log.Println(err)
+		bot.sendMessage(chatId, tr.T(TextCalendarFileInvalid), nil, "")
+		return
+	}
+
+	calendarEvents, err := ical.Decode(bytes.NewReader(data), bot.userLocation(user))
+	if err != nil {
+		log.Println(err)
+		bot.sendMessage(chatId, tr.T(TextCalendarFileInvalid), nil, "")
+		return
+	}
+
+	familyID := uint(user.FamilyID.Int64)
+	tasks, err := bot.taskService.Tasks(context.Background(), units.TaskFilter{FamilyID: &familyID})
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	events := getImportEvents(calendarEvents, tasks, bot.now().In(bot.userLocation(user)))
+	if len(events) == 0 {
+		bot.sendMessage(chatId, tr.T(TextCalendarFileEmpty), nil, "")
+		return
+	}
+
+	bot.stateService.SetUserState(int(user.TelegramID), st.State{
+		Status: st.STATUS_IMPORT_EVENTS,
+		Events: events,
+	})
+
+	message, keyboard := getImportPreview(tr, events)
+	bot.sendMessage(chatId, message, keyboard, "")
+}
+
+// getImportEvents returns the nearest events which have not passed yet,
+// repeating ones are moved to their next occurrence. Events with UIDs of
+// tasks are marked imported.
+func getImportEvents(calendarEvents []*ical.Event, tasks []*units.Task, now time.Time) []st.Event {
+	imported := map[string]bool{}
+	for _, task := range tasks {
+		if task.UID != "" {
+			imported[task.UID] = true
+		}
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	var events []st.Event
+	for _, calendarEvent := range calendarEvents {
+		event := st.Event{
+			UID:        calendarEvent.UID,
+			Title:      units.TruncateTitle(calendarEvent.Summary),
+			Notes:      calendarEvent.Description,
+			Recurrence: calendarEvent.Recurrence.Anchored(calendarEvent.Start.Day()),
+			Imported:   imported[calendarEvent.UID],
+		}
+
+		if !calendarEvent.Start.IsZero() {
+			date, ok := calendarEvent.Upcoming(today)
+			if !ok {
+				continue
+			}
+			event.Date = &date
+			if !calendarEvent.AllDay {
+				event.Notifications = getImportNotifications(calendarEvent.Alarms)
+			}
+		}
+		event.Selected = !event.Imported
+		events = append(events, event)
+	}
+
+	// undated to-dos go last
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].Date == nil || events[j].Date == nil {
+			return events[j].Date == nil && events[i].Date != nil
+		}
+		return events[i].Date.Before(*events[j].Date)
+	})
+	if len(events) > importLimit {
+		events = events[:importLimit]
+	}
+
+	return events
+}
+
+// getImportNotifications picks the reminders of the bot closest to the
+// alarms of the event, events without alarms get the default one.
+func getImportNotifications(alarms []time.Duration) int {
+	if len(alarms) == 0 {
+		return DeFaultNotification
+	}
+
+	notifications := 0
+	for _, alarm := range alarms {
+		best, bestDiff := 0, time.Duration(0)
+		for _, notification := range []int{OneHourNotification, ThirtyMinutesNotification, FiveMinutesNotification, InstantlyNotification} {
+			diff := alarm - time.Duration(getMinutesFromNotificationType(notification))*time.Minute
+			if diff < 0 {
+				diff = -diff
+			}
+			if best == 0 || diff < bestDiff {
+				best, bestDiff = notification, diff
+			}
+		}
+		notifications |= best
+	}
+
+	return notifications
+}
+
+// getImportPreview describes every event like a new task.
+func getImportPreview(tr *i18n.Localizer, events []st.Event) (string, *tgbotapi.InlineKeyboardMarkup) {
+	message := tr.T(TextImportEvents)
+
+	var buttons []tgbotapi.InlineKeyboardButton
+	selected := 0
+	for i, event := range events {
+		dayString, timeString := getDayAndTime(tr, event.Date)
+		message += fmt.Sprintf("\n\n%d. %s", i+1, getTaskDescription(tr, html.EscapeString(event.Title), dayString, timeString))
+		if event.Recurrence != units.RecurrenceNone {
+			message += tr.T(TextTaskRecurrence, getRecurrenceLabel(tr, event.Recurrence))
+		}
+		if event.Imported {
+			message += "\n" + tr.T(TextEventImported)
+			continue
+		}
+
+		checkBox := TextCheckbox
+		if event.Selected {
+			checkBox = TextComplete
+			selected++
+		}
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%s %d", checkBox, i+1), fmt.Sprintf(CQImportToggle+":%d", i)))
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for len(buttons) > 0 {
+		n := 4
+		if len(buttons) < n {
+			n = len(buttons)
+		}
+		rows = append(rows, buttons[:n])
+		buttons = buttons[n:]
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(tr.T(TextActionImport, selected), CQImportSave),
+		tgbotapi.NewInlineKeyboardButtonData(tr.T(TextActionCancel), CQImportCancel),
+	))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	return message, &keyboard
+}
+
+func (bot *Bot) toggleImportEvent(state *st.State, chatId int64, messageId int, user *units.User, index int) {
+	if state.Status != st.STATUS_IMPORT_EVENTS || index < 0 || index >= len(state.Events) || state.Events[index].Imported {
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	state.Events[index].Selected = !state.Events[index].Selected
+	bot.stateService.SetUserState(int(user.TelegramID), *state)
+
+	message, keyboard := getImportPreview(bot.localizer(user), state.Events)
+	bot.editMessage(chatId, messageId, message, keyboard, "")
+}
+
+// saveImportEvents adds the picked events to the active list, events
+// imported meanwhile are skipped.
+func (bot *Bot) saveImportEvents(state *st.State, chatId int64, messageId int, user *units.User) {
+	if state.Status != st.STATUS_IMPORT_EVENTS {
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	tr := bot.localizer(user)
+	list, err := bot.getActiveList(user)
+	if err == nil && list.Kind == units.ListKindShopping {
+		list, err = bot.getFamilyList(user, units.ListKindTasks)
+	}
+	familyID := uint(user.FamilyID.Int64)
+	var tasks []*units.Task
+	if err == nil {
+		tasks, err = bot.taskService.Tasks(context.Background(), units.TaskFilter{FamilyID: &familyID})
+	}
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	imported := map[string]bool{}
+	for _, task := range tasks {
+		if task.UID != "" {
+			imported[task.UID] = true
+		}
+	}
+
+	count := 0
+	for _, event := range state.Events {
+		if !event.Selected || event.Imported || imported[event.UID] {
+			continue
+		}
+
+		task := &units.Task{
+			Title:         event.Title,
+			Date:          getNullTime(event.Date),
+			Notifications: event.Notifications,
+			Recurrence:    event.Recurrence,
+			Notes:         event.Notes,
+			UID:           event.UID,
+			FamilyID:      familyID,
+			ListID:        list.ID,
+		}
+		if err := bot.taskService.CreateTask(actorContext(user), task); err != nil {
+			log.Println(err)
+			bot.sendGeneralError(chatId, user)
+			break
+		}
+		if event.UID != "" {
+			imported[event.UID] = true
+		}
+		count++
+	}
+
+	bot.stateService.SetUserState(int(user.TelegramID), st.State{
+		Status: st.STATUS_IDLE,
+	})
+
+	bot.editMessage(chatId, messageId, tr.N(TextEventsImported, count, count, html.EscapeString(list.Name)), nil, "")
+	bot.refreshListMessages(user, 0, 0)
+}
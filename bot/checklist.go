@@ -0,0 +1,233 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	st "github.com/maxwww/family_bot/state"
+	"github.com/maxwww/family_bot/units"
+)
+
+// editTaskNotes shows the note of the task, the next message replaces it.
+func (bot *Bot) editTaskNotes(chatId int64, messageId int, user *units.User, taskId int) {
+	task, err := bot.getFamilyTask(user, taskId)
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	bot.stateService.SetUserState(int(user.TelegramID), st.State{
+		Status: st.STATUS_EDIT_TASK_WAIT_NOTES,
+		Task: st.Task{
+			ID: taskId,
+		},
+	})
+
+	message, keyboard := bot.getTaskNotes(user, task)
+	bot.editMessage(chatId, messageId, message, keyboard, "")
+}
+
+func (bot *Bot) getTaskNotes(user *units.User, task *units.Task) (string, *tgbotapi.InlineKeyboardMarkup) {
+	tr := bot.localizer(user)
+	message := tr.T(TextTaskNotesEmpty, task.Title)
+	buttons := []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData(tr.T(TextActionBack), fmt.Sprintf(CQTaskEditDetailsDone+":%d", task.ID)),
+	}
+	if task.Notes != "" {
+		message = tr.T(TextTaskNotes, task.Title, html.EscapeString(task.Notes))
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(tr.T(TextActionRemoveNotes), fmt.Sprintf(CQTaskEditRemoveNotes+":%d", task.ID)))
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(buttons...))
+
+	return message, &keyboard
+}
+
+func (bot *Bot) handleEditTaskNotes(chatId int64, user *units.User, message string, taskId int) {
+	task, err := bot.getFamilyTask(user, taskId)
+	if err != nil {
+		log.Println(err)
+		bot.sendParseError(chatId, user)
+		return
+	}
+
+	notes := strings.TrimSpace(message)
+	if notes == "" {
+		bot.sendParseError(chatId, user)
+		return
+	}
+
+	bot.stateService.SetUserState(int(user.TelegramID), st.State{
+		Status: st.STATUS_IDLE,
+	})
+
+	if err := bot.taskService.UpdateTask(actorContext(user), task, units.TaskPatch{Notes: &notes}); err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	bot.sendEditingTask(chatId, user, task)
+	bot.refreshListMessages(user, 0, 0)
+}
+
+func (bot *Bot) removeTaskNotes(chatId int64, messageId int, user *units.User, taskId int) {
+	bot.stateService.SetUserState(int(user.TelegramID), st.State{
+		Status: st.STATUS_IDLE,
+	})
+
+	notes := ""
+	task, err := bot.getFamilyTask(user, taskId)
+	if err == nil {
+		err = bot.taskService.UpdateTask(actorContext(user), task, units.TaskPatch{Notes: &notes})
+	}
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	bot.editTask(chatId, messageId, user, taskId)
+	bot.refreshListMessages(user, chatId, messageId)
+}
+
+// editTaskItems shows the checklist of the task, every line of the next
+// messages becomes a new entry until the user goes back.
+func (bot *Bot) editTaskItems(chatId int64, messageId int, user *units.User, taskId int) {
+	task, err := bot.getFamilyTask(user, taskId)
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	bot.stateService.SetUserState(int(user.TelegramID), st.State{
+		Status: st.STATUS_EDIT_TASK_WAIT_ITEMS,
+		Task: st.Task{
+			ID: taskId,
+		},
+	})
+
+	message, keyboard := bot.getTaskItems(user, task)
+	bot.editMessage(chatId, messageId, message, keyboard, "")
+}
+
+func (bot *Bot) getTaskItems(user *units.User, task *units.Task) (string, *tgbotapi.InlineKeyboardMarkup) {
+	tr := bot.localizer(user)
+	message := tr.T(TextTaskItemsEmpty, task.Title)
+	var rows [][]tgbotapi.InlineKeyboardButton
+
+	if len(task.Items) > 0 {
+		message = tr.T(TextTaskItems, task.Title) + "\n"
+		for i, item := range task.Items {
+			checkBox := TextCheckbox
+			line := fmt.Sprintf("%d. %s", i+1, html.EscapeString(item.Title))
+			if item.Done {
+				checkBox = TextComplete
+				line = "<s>" + line + "</s>"
+			}
+			message += line + "\n"
+
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d %s", i+1, checkBox), fmt.Sprintf(CQTaskItemToggle+":%d:%d", task.ID, item.ID)),
+				tgbotapi.NewInlineKeyboardButtonData(tr.T(TextActionDelete), fmt.Sprintf(CQTaskItemRemove+":%d:%d", task.ID, item.ID)),
+			))
+		}
+	}
+	message += "\n" + tr.T(TextSendNewTaskItems)
+
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(tr.T(TextActionBack), fmt.Sprintf(CQTaskEditDetailsDone+":%d", task.ID)),
+	))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	return message, &keyboard
+}
+
+func (bot *Bot) handleEditTaskItems(chatId int64, user *units.User, message string, taskId int) {
+	task, err := bot.getFamilyTask(user, taskId)
+	if err != nil {
+		log.Println(err)
+		bot.sendParseError(chatId, user)
+		return
+	}
+
+	var titles []string
+	for _, line := range strings.Split(message, "\n") {
+		if title := trim(line); title != "" {
+			titles = append(titles, title)
+		}
+	}
+	if len(titles) == 0 {
+		bot.sendParseError(chatId, user)
+		return
+	}
+
+	err = bot.taskService.AddTaskItems(actorContext(user), task.ID, titles)
+	if err == nil {
+		task, err = bot.getFamilyTask(user, taskId)
+	}
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	text, keyboard := bot.getTaskItems(user, task)
+	bot.sendMessage(chatId, text, keyboard, "")
+	bot.refreshListMessages(user, 0, 0)
+}
+
+func (bot *Bot) toggleTaskItem(chatId int64, messageId int, user *units.User, taskId int, itemId int) {
+	bot.changeTaskItem(chatId, messageId, user, taskId, itemId, bot.taskService.ToggleTaskItem)
+}
+
+func (bot *Bot) removeTaskItem(chatId int64, messageId int, user *units.User, taskId int, itemId int) {
+	bot.changeTaskItem(chatId, messageId, user, taskId, itemId, bot.taskService.RemoveTaskItem)
+}
+
+// changeTaskItem applies change to the entry once the task is known to
+// belong to the user's family and shows the checklist again.
+func (bot *Bot) changeTaskItem(chatId int64, messageId int, user *units.User, taskId int, itemId int, change func(context.Context, uint, uint) error) {
+	task, err := bot.getFamilyTask(user, taskId)
+	if err == nil {
+		err = change(actorContext(user), task.ID, uint(itemId))
+	}
+	if err == nil {
+		task, err = bot.getFamilyTask(user, taskId)
+	}
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	message, keyboard := bot.getTaskItems(user, task)
+	bot.editMessage(chatId, messageId, message, keyboard, "")
+	bot.refreshListMessages(user, chatId, messageId)
+}
+
+// finishTaskDetails stops waiting for notes or entries and shows the task.
+func (bot *Bot) finishTaskDetails(chatId int64, messageId int, user *units.User, taskId int) {
+	bot.stateService.SetUserState(int(user.TelegramID), st.State{
+		Status: st.STATUS_IDLE,
+	})
+
+	bot.editTask(chatId, messageId, user, taskId)
+}
+
+// getItemsProgress returns the number of checked entries and all of them.
+func getItemsProgress(task *units.Task) (int, int) {
+	done := 0
+	for _, item := range task.Items {
+		if item.Done {
+			done++
+		}
+	}
+
+	return done, len(task.Items)
+}
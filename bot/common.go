@@ -5,177 +5,189 @@ import (
 	"database/sql"
 	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/maxwww/family_bot/dateparse"
+	"github.com/maxwww/family_bot/i18n"
 	"github.com/maxwww/family_bot/units"
 	"log"
 	"regexp"
-	"sort"
-	"strconv"
 	"strings"
 	"time"
 )
 
 const (
-	DateWithTimeFormat   = "2006-01-02 15:04"
-	TimeFormat           = "15:04"
-	DateWithTimeFormatUA = "02.01.2006 15:04"
-	DateFormatUA         = "02.01.2006"
+	DateWithTimeFormat = "2006-01-02 15:04"
+	TimeFormat         = "15:04"
 
 	DeFaultNotification = OneHourNotification
 )
 
-var DayNames = map[time.Weekday]string{
-	time.Monday:    "Понеділок",
-	time.Tuesday:   "Вівторок",
-	time.Wednesday: "Середа",
-	time.Thursday:  "Четвер",
-	time.Friday:    "П'ятниця",
-	time.Saturday:  "Субота",
-	time.Sunday:    "Неділя",
-}
-
-var daysMap = map[time.Weekday][]string{
-	time.Monday:    {"понеділок", "пн", "пон", "monday", "mon"},
-	time.Tuesday:   {"вівторок", "вт", "вів", "tuesday", "tue", "tues"},
-	time.Wednesday: {"середа", "ср", "сер", "wednesday", "wed"},
-	time.Thursday:  {"четвер", "чт", "чет", "thursday", "thu", "thur", "thurs"},
-	time.Friday:    {"п'ятниця", "пт", "п’ятниця", "пʼятниця", "пятниця", "п'ят", "п’ят", "пʼят", "пят", "friday", "fri"},
-	time.Saturday:  {"субота", "сб", "суб", "saturday", "sat"},
-	time.Sunday:    {"неділя", "нд", "нед", "sunday", "sun"},
-}
-
-var reMap map[time.Weekday][]*regexp.Regexp
 var spaceRe *regexp.Regexp
-var tomorrowRe *regexp.Regexp
-var todayRe *regexp.Regexp
-var dateRe *regexp.Regexp
-var timeRe *regexp.Regexp
 
 func init() {
 	spaceRe = regexp.MustCompile(`\s+`)
-	tomorrowRe = regexp.MustCompile(`(?i)(\s|^)завтра(\s|$)`)
-	todayRe = regexp.MustCompile(`(?i)(\s|^)сьогодні(\s|$)`)
-	dateRe = regexp.MustCompile(`([0123]?[0-9])(\/|\.)([01]?[0-9])((\/|\.)([0-9]{4}|[0-9]{2}))?`)
-	timeRe = regexp.MustCompile(`([012]?[0-9]):([012345]?[0-9])`)
-	reMap = make(map[time.Weekday][]*regexp.Regexp)
-	for day, value := range daysMap {
-		reMap[day] = []*regexp.Regexp{}
-		for _, word := range value {
-			r, err := regexp.Compile(`(?i)(\s|^)` + word + `(\s|$)`)
-			if err != nil {
-				panic(err)
-			}
-			reMap[day] = append(reMap[day], r)
-		}
-	}
 }
 
 func (bot *Bot) deleteMessage(chatId int64, messageId int) {
-	msg := tgbotapi.NewDeleteMessage(chatId, messageId)
-	_, err := bot.BotAPI.Request(msg)
+	err := bot.messenger.DeleteMessage(chatId, messageId)
 	if err != nil {
 		log.Println(err)
 	}
 }
 
 func (bot *Bot) sendMessage(chatId int64, message string, keyboard *tgbotapi.InlineKeyboardMarkup, parseMode string) {
-	msg := tgbotapi.NewMessage(chatId, message)
 	if parseMode == "" {
 		parseMode = "html"
 	}
-	msg.ParseMode = parseMode
-	if keyboard != nil && len((*keyboard).InlineKeyboard) > 0 {
-		msg.ReplyMarkup = keyboard
-	}
-	_, err := bot.BotAPI.Send(msg)
+	_, err := bot.messenger.SendMessage(chatId, message, keyboard, parseMode)
 	if err != nil {
 		log.Println(err)
 	}
 }
 
 func (bot *Bot) editMessage(chatId int64, messageId int, message string, keyboard *tgbotapi.InlineKeyboardMarkup, parseMode string) {
-	msg := tgbotapi.NewEditMessageText(chatId, messageId, message)
 	if parseMode == "" {
 		parseMode = "html"
 	}
-	msg.ParseMode = parseMode
-	if keyboard != nil && len((*keyboard).InlineKeyboard) > 0 {
-		msg.ReplyMarkup = keyboard
-	}
-	_, err := bot.BotAPI.Request(msg)
+	err := bot.messenger.EditMessage(chatId, messageId, message, keyboard, parseMode)
 	if err != nil {
 		log.Println(err)
 	}
 }
 
-func (bot *Bot) getMidnightFromDate(date *time.Time) *time.Time {
+// getMidnightFromDate returns the start of the day of the date in loc.
+func getMidnightFromDate(date *time.Time, loc *time.Location) *time.Time {
 	var newDate *time.Time
 	if date != nil {
-		tmp := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, bot.loc)
+		local := date.In(loc)
+		tmp := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
 		newDate = &tmp
 	}
 
 	return newDate
 }
 
-func (bot *Bot) getTasksListWithHeader() (string, *tgbotapi.InlineKeyboardMarkup) {
-	message := TextTasksListHeader
-	list, keyboard := bot.buildTasksList()
-	if list != "" {
-		message += "\n\n"
-		message += list
-	} else {
-		message = TextTasksListEmpty
+// getFamilyUsers returns members of the user's family, they are the
+// candidates for task assignees.
+func (bot *Bot) getFamilyUsers(user *units.User) []*units.User {
+	familyID := uint(user.FamilyID.Int64)
+	users, err := bot.userService.Users(context.Background(), units.UserFilter{FamilyID: &familyID})
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	return users
+}
+
+// getFamilyTask loads a task making sure it belongs to the user's family,
+// callback data comes from the client and cannot be trusted.
+func (bot *Bot) getFamilyTask(user *units.User, taskId int) (*units.Task, error) {
+	task, err := bot.taskService.TaskByID(context.Background(), uint(taskId))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(task.FamilyID) != user.FamilyID.Int64 {
+		return nil, units.ErrNotFound
+	}
+
+	return task, nil
+}
+
+// actorContext returns the context of changes made by the user, the task
+// history records the user as their author.
+func actorContext(user *units.User) context.Context {
+	return units.WithActor(context.Background(), user.ID)
+}
+
+// getFamilyList returns the list of the kind shared by the user's family,
+// it is created on first use.
+func (bot *Bot) getFamilyList(user *units.User, kind units.ListKind) (*units.List, error) {
+	name := TextListNameTasks
+	if kind == units.ListKindShopping {
+		name = TextListNameShopping
+	}
+
+	return bot.listService.DefaultList(context.Background(), uint(user.FamilyID.Int64), kind, bot.localizer(user).T(name))
+}
+
+// getActiveList returns the list new tasks of the user go to, lists of
+// other families and removed ones fall back to the family task list.
+func (bot *Bot) getActiveList(user *units.User) (*units.List, error) {
+	if user.ActiveListID.Valid {
+		list, err := bot.getFamilyListByID(user, uint(user.ActiveListID.Int64))
+		if err == nil {
+			return list, nil
+		}
+		if err != units.ErrNotFound {
+			return nil, err
+		}
+	}
+
+	return bot.getFamilyList(user, units.ListKindTasks)
+}
+
+// getFamilyListByID loads a list making sure it belongs to the user's family.
+func (bot *Bot) getFamilyListByID(user *units.User, listId uint) (*units.List, error) {
+	list, err := bot.listService.ListByID(context.Background(), listId)
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(list.FamilyID) != user.FamilyID.Int64 {
+		return nil, units.ErrNotFound
+	}
+
+	return list, nil
+}
+
+// getListWithHeader renders the list the way its kind is shown.
+func (bot *Bot) getListWithHeader(user *units.User, list *units.List) (string, *tgbotapi.InlineKeyboardMarkup) {
+	if list.Kind == units.ListKindShopping {
+		return bot.getShoppingListWithHeader(user, list)
+	}
+
+	return bot.getTasksListWithHeader(user, list)
+}
+
+// getTasksListWithHeader renders a list of to-dos, lists other than the
+// family task list are titled with their names.
+func (bot *Bot) getTasksListWithHeader(user *units.User, list *units.List) (string, *tgbotapi.InlineKeyboardMarkup) {
+	tr := bot.localizer(user)
+	header, empty := tr.T(TextTasksListHeader), tr.T(TextTasksListEmpty)
+	if defaultList, err := bot.getFamilyList(user, units.ListKindTasks); err != nil || defaultList.ID != list.ID {
+		header, empty = tr.T(TextListHeader, list.Name), tr.T(TextListEmpty, list.Name)
+	}
+
+	message := empty
+	tasks, keyboard := bot.buildTasksList(user, list)
+	if tasks != "" {
+		message = header + "\n\n" + tasks
 	}
 	message += "\n"
-	message += bot.buildToday()
+	message += bot.buildToday(user)
 
 	return message, keyboard
 }
 
-func (bot *Bot) buildTasksList() (string, *tgbotapi.InlineKeyboardMarkup) {
+func (bot *Bot) buildTasksList(user *units.User, taskList *units.List) (string, *tgbotapi.InlineKeyboardMarkup) {
 	list := ""
 	var keyboard tgbotapi.InlineKeyboardMarkup
-	tasks, _ := bot.taskService.Tasks(context.Background(), units.TaskFilter{})
+	tasks, err := bot.taskService.Tasks(context.Background(), units.TaskFilter{ListID: &taskList.ID, OrderBy: units.TaskOrderDate})
+	if err != nil {
+		log.Println(err)
+	}
 	if len(tasks) > 0 {
+		users := bot.getFamilyUsers(user)
 		var tasksButtons [][]tgbotapi.InlineKeyboardButton
 		var row []tgbotapi.InlineKeyboardButton
 		hasDone := false
-		now := time.Now().In(bot.loc)
+		tr := bot.localizer(user)
+		loc := bot.userLocation(user)
+		now := bot.now().In(loc)
 		message := ""
 
-		sort.Slice(tasks, func(i, j int) bool {
-			if !tasks[i].Date.Valid && !tasks[j].Date.Valid {
-				return tasks[i].ID < tasks[j].ID
-			}
-
-			if !tasks[i].Date.Valid {
-				return false
-			}
-
-			if !tasks[j].Date.Valid {
-				return true
-			}
-
-			iDate, _ := time.ParseInLocation(DateWithTimeFormat, tasks[i].Date.String[:10]+" "+tasks[i].Date.String[11:16], bot.loc)
-			jDate, _ := time.ParseInLocation(DateWithTimeFormat, tasks[j].Date.String[:10]+" "+tasks[j].Date.String[11:16], bot.loc)
-			isIDateWithTime := iDate.Hour() != 0 || iDate.Minute() != 0
-			isJDateWithTime := jDate.Hour() != 0 || jDate.Minute() != 0
-
-			if isIDateWithTime && !isJDateWithTime {
-				if iDate.Year() == jDate.Year() && iDate.Month() == jDate.Month() && iDate.Day() == jDate.Day() {
-					return true
-				}
-				return iDate.Before(jDate)
-			}
-			if !isIDateWithTime && isJDateWithTime {
-				if iDate.Year() == jDate.Year() && iDate.Month() == jDate.Month() && iDate.Day() == jDate.Day() {
-					return false
-				}
-				return iDate.Before(jDate)
-			}
-
-			return iDate.Before(jDate)
-		})
+		moveAllDayTasksLast(tasks, loc)
 
 		for i, v := range tasks {
 			checkBox := TextCheckbox
@@ -195,16 +207,16 @@ func (bot *Bot) buildTasksList() (string, *tgbotapi.InlineKeyboardMarkup) {
 			}
 			message += fmt.Sprintf("%d. %s", i+1, v.Title)
 			if v.Date.Valid {
-				date, _ := time.ParseInLocation(DateWithTimeFormat, v.Date.String[:10]+" "+v.Date.String[11:16], bot.loc)
+				date := v.Date.Time.In(loc)
 
-				format := DateWithTimeFormatUA
+				format := tr.T(TextFormatDateTime)
 				timeFormat := TimeFormat
 				if date.Hour() == 0 && date.Minute() == 0 {
-					format = DateFormatUA
+					format = tr.T(TextFormatDate)
 					timeFormat = ""
 				}
 				if date.Year() == now.Year() && date.Month() == now.Month() && date.Day() == now.Day() {
-					message += fmt.Sprintf(" (" + TextToday)
+					message += " (" + tr.T(TextToday)
 					if timeFormat != "" {
 						message += fmt.Sprintf(" %s", date.Format(timeFormat))
 					}
@@ -212,7 +224,7 @@ func (bot *Bot) buildTasksList() (string, *tgbotapi.InlineKeyboardMarkup) {
 				} else {
 					tomorrow := now.AddDate(0, 0, 1)
 					if date.Year() == tomorrow.Year() && date.Month() == tomorrow.Month() && date.Day() == tomorrow.Day() {
-						message += fmt.Sprintf(" (" + TextTomorrow)
+						message += " (" + tr.T(TextTomorrow)
 						if timeFormat != "" {
 							message += fmt.Sprintf(" %s", date.Format(timeFormat))
 						}
@@ -223,6 +235,7 @@ func (bot *Bot) buildTasksList() (string, *tgbotapi.InlineKeyboardMarkup) {
 				}
 
 			}
+			message += getTaskMarkers(v, users)
 			if v.Done {
 				message += "</s>"
 			}
@@ -233,7 +246,7 @@ func (bot *Bot) buildTasksList() (string, *tgbotapi.InlineKeyboardMarkup) {
 		}
 		if hasDone {
 			tasksButtons = append(tasksButtons, []tgbotapi.InlineKeyboardButton{
-				tgbotapi.NewInlineKeyboardButtonData(TextActionRemoveAllDoneTasks, CQTaskRemoveAllDone),
+				tgbotapi.NewInlineKeyboardButtonData(tr.T(TextActionRemoveAllDoneTasks), fmt.Sprintf(CQTaskRemoveAllDone+":%d", taskList.ID)),
 			})
 		}
 
@@ -244,143 +257,100 @@ func (bot *Bot) buildTasksList() (string, *tgbotapi.InlineKeyboardMarkup) {
 	return list, &keyboard
 }
 
-func (bot *Bot) buildToday() string {
-	now := time.Now().In(bot.loc)
-	return fmt.Sprintf(TextTodayDate, strings.ToLower(DayNames[now.Weekday()]), now.Format(DateFormatUA))
-}
-
-func (bot *Bot) createYesNoKeyboard(yesAction, noAction string) *tgbotapi.InlineKeyboardMarkup {
-	return &tgbotapi.InlineKeyboardMarkup{
-		InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{
-			{tgbotapi.NewInlineKeyboardButtonData(TextActionYes, yesAction), tgbotapi.NewInlineKeyboardButtonData(TextActionNo, noAction)},
-		},
+// getTaskMarkers returns the icons following the title of the task in lists.
+func getTaskMarkers(task *units.Task, users []*units.User) string {
+	markers := ""
+	if task.Recurrence != units.RecurrenceNone {
+		markers += " " + TextRecurring
 	}
-}
-
-func (bot *Bot) getDateFromNullString(str sql.NullString) *time.Time {
-	var date *time.Time
-	if str.Valid && str.String != "" {
-		date = bot.getDateFromString(str.String)
+	if done, total := getItemsProgress(task); total > 0 {
+		markers += fmt.Sprintf(" (%d/%d)", done, total)
+	}
+	if task.Notes != "" {
+		markers += " " + TextNotes
+	}
+	if len(task.Attachments) > 0 {
+		markers += " " + TextAttachment
+	}
+	if assignees := filterAssignees(users, task.Assignees); len(assignees) > 0 {
+		var initials []string
+		for _, user := range assignees {
+			initials = append(initials, getUserInitial(user))
+		}
+		markers += " " + TextAssignee + strings.Join(initials, ",")
 	}
 
-	return date
+	return markers
 }
 
-func (bot *Bot) getDateFromString(str string) *time.Time {
-	t, _ := time.ParseInLocation(DateWithTimeFormat, str[:10]+" "+str[11:16], bot.loc)
-
-	return &t
-}
+// moveAllDayTasksLast puts tasks without a time after the timed ones of the
+// same day. They are stored at midnight of the zone of the user, so the
+// service sorting by date puts them first. Tasks have to be ordered by date.
+func moveAllDayTasksLast(tasks []*units.Task, loc *time.Location) {
+	for start := 0; start < len(tasks); {
+		if !tasks[start].Date.Valid {
+			start++
+			continue
+		}
 
-func (bot *Bot) findDate(input string) (*time.Time, string, bool, error) {
-	input = trim(input)
-	var date *time.Time
-	isDateFound := false
-
-	var weekDay time.Weekday = -1
-	for day, s := range reMap {
-		for _, r := range s {
-			index := r.FindStringIndex(input)
-			if len(index) > 0 {
-				input = input[0:index[0]] + " " + input[index[1]:]
-				weekDay = day
+		day := tasks[start].Date.Time.In(loc)
+		end := start
+		var allDay, timed []*units.Task
+		for ; end < len(tasks) && tasks[end].Date.Valid && isSameDay(tasks[end].Date.Time.In(loc), day); end++ {
+			if date := tasks[end].Date.Time.In(loc); date.Hour() == 0 && date.Minute() == 0 {
+				allDay = append(allDay, tasks[end])
+			} else {
+				timed = append(timed, tasks[end])
 			}
 		}
+		copy(tasks[start:end], append(timed, allDay...))
+		start = end
 	}
+}
 
-	now := time.Now().In(bot.loc)
-	today := bot.getMidnightFromDate(&now)
-	parsedDate := bot.getMidnightFromDate(&now)
-
-	if weekDay != -1 {
-		todayWeekDay := today.Weekday()
-		delta := 0
-		if weekDay <= todayWeekDay {
-			delta = 7 - int(todayWeekDay) + int(weekDay)
-		} else {
-			delta = int(weekDay) - int(todayWeekDay)
-		}
-		*parsedDate = parsedDate.AddDate(0, 0, delta)
-
-		date = parsedDate
-	} else {
-		tomorrowIndex := tomorrowRe.FindStringIndex(input)
-		if len(tomorrowIndex) > 0 {
-			input = input[0:tomorrowIndex[0]] + " " + input[tomorrowIndex[1]:]
-			*parsedDate = parsedDate.AddDate(0, 0, 1)
-			date = parsedDate
-		} else {
-			todayIndex := todayRe.FindStringIndex(input)
-			if len(todayIndex) > 0 {
-				input = input[0:todayIndex[0]] + " " + input[todayIndex[1]:]
-				date = parsedDate
-			} else {
-				match := dateRe.FindStringSubmatch(input)
-				if len(match) > 0 {
-					input = dateRe.ReplaceAllString(input, "")
-					year := today.Year()
-					if match[6] != "" {
-						if len(match[6]) == 2 {
-							match[6] = "20" + match[6]
-						}
-						year_, err := strconv.Atoi(match[6])
-						if err != nil {
-							return nil, "", isDateFound, units.DataParsingError
-						}
-						year = year_
-					}
-
-					month, err := strconv.Atoi(match[3])
-					if err != nil {
-						return nil, "", isDateFound, units.DataParsingError
-					}
+func isSameDay(a, b time.Time) bool {
+	return a.Year() == b.Year() && a.Month() == b.Month() && a.Day() == b.Day()
+}
 
-					day, err := strconv.Atoi(match[1])
-					if err != nil {
-						return nil, "", isDateFound, units.DataParsingError
-					}
+func (bot *Bot) buildToday(user *units.User) string {
+	tr := bot.localizer(user)
+	now := bot.now().In(bot.userLocation(user))
+	return tr.T(TextTodayDate, strings.ToLower(getWeekdayName(tr, now.Weekday())), now.Format(tr.T(TextFormatDate)))
+}
 
-					tmpParsedDate := time.Date(year, time.Month(month), day, 0, 0, 0, 0, bot.loc)
-					if tmpParsedDate.Before(*today) {
-						if match[6] != "" {
-							return nil, "", isDateFound, units.DataParsingError
-						}
-						tmpParsedDate = tmpParsedDate.AddDate(1, 0, 0)
-					}
-					date = &tmpParsedDate
-				}
-			}
-		}
+func (bot *Bot) createYesNoKeyboard(tr *i18n.Localizer, yesAction, noAction string) *tgbotapi.InlineKeyboardMarkup {
+	return &tgbotapi.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{
+			{tgbotapi.NewInlineKeyboardButtonData(tr.T(TextActionYes), yesAction), tgbotapi.NewInlineKeyboardButtonData(tr.T(TextActionNo), noAction)},
+		},
 	}
+}
 
-	if date == nil {
-		date = today
-	} else {
-		isDateFound = true
+// getParsedDate returns the date found by the parser or nil when there is none.
+func getParsedDate(parsed dateparse.Result) *time.Time {
+	if !parsed.Found() {
+		return nil
 	}
 
-	timeMatch := timeRe.FindStringSubmatch(input)
-	if len(timeMatch) > 0 {
-		input = timeRe.ReplaceAllString(input, "")
+	date := parsed.Date
+	return &date
+}
 
-		hour, err := strconv.Atoi(timeMatch[1])
-		if err != nil {
-			return nil, "", isDateFound, units.DataParsingError
-		}
+// getDateFromNullTime returns the task date in the given location or nil when there is none.
+func getDateFromNullTime(date sql.NullTime, loc *time.Location) *time.Time {
+	if !date.Valid {
+		return nil
+	}
 
-		minutes, err := strconv.Atoi(timeMatch[2])
-		if err != nil {
-			return nil, "", isDateFound, units.DataParsingError
-		}
+	t := date.Time.In(loc)
+	return &t
+}
 
-		*date = date.Add(time.Duration(hour) * time.Hour)
-		*date = date.Add(time.Duration(minutes) * time.Minute)
-		if date.Before(now) {
-			*date = date.AddDate(0, 0, 1)
-		}
-	} else if !isDateFound {
-		date = nil
+// getNullTime converts a date for storing, nil clears the date.
+func getNullTime(date *time.Time) sql.NullTime {
+	if date == nil {
+		return sql.NullTime{}
 	}
 
-	return date, input, isDateFound, nil
+	return sql.NullTime{Time: *date, Valid: true}
 }
@@ -2,68 +2,222 @@ package bot
 
 import (
 	"context"
-	"fmt"
+	"github.com/maxwww/family_bot/i18n"
 	"github.com/maxwww/family_bot/units"
 	"github.com/robfig/cron/v3"
+	"log"
 	"time"
 )
 
+const (
+	notificationsJob = "notifications"
+
+	// MaxNotificationsCatchUp limits how far back missed reminders are delivered
+	// after a long downtime.
+	MaxNotificationsCatchUp = 6 * time.Hour
+
+	// maxNotificationLead is how long before the task the earliest reminder
+	// goes off.
+	maxNotificationLead = time.Hour
+
+	// DailyDigestHour and DailyDigestMinute are the local time of every user
+	// the task list is sent at.
+	DailyDigestHour   = 8
+	DailyDigestMinute = 30
+)
+
 func (bot *Bot) RegisterCrons() error {
 	c := cron.New(cron.WithLocation(bot.loc))
 
-	_, err := c.AddFunc("30 8 * * *", func() {
-		for _, userId := range bot.subscribers {
-			user, err := bot.userService.UserByTelegramID(context.Background(), uint(userId))
-			if err == nil && user.Notifications {
-				message, keyboard := bot.getTasksListWithHeader()
-				bot.sendMessage(userId, message, keyboard, "")
+	// zone offsets are multiples of 15 minutes, so checking every quarter
+	// of an hour meets the digest time in each of them
+	_, err := c.AddFunc("*/15 * * * *", bot.sendDailyDigests)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.AddFunc("* * * * *", bot.sendDueNotifications)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.AddFunc("0 4 * * *", bot.purgeDeletedTasks)
+	if err != nil {
+		return err
+	}
+
+	c.Start()
+	bot.cron = c
+
+	return nil
+}
+
+// sendDailyDigests sends the task list to subscribers whose local time is
+// the digest time.
+func (bot *Bot) sendDailyDigests() {
+	now := bot.now()
+	for _, members := range bot.getUsersByFamily() {
+		for _, user := range members {
+			local := now.In(bot.userLocation(user))
+			if user.Notifications && local.Hour() == DailyDigestHour && local.Minute() == DailyDigestMinute {
+				bot.sendTaskList(int64(user.TelegramID), user)
 			}
 		}
+	}
+}
+
+// stopCrons stops scheduling jobs and waits for the running ones to finish.
+func (bot *Bot) stopCrons() {
+	if bot.cron != nil {
+		<-bot.cron.Stop().Done()
+	}
+}
+
+// sendDueNotifications delivers every reminder which became due since the
+// previous successful run, so ticks lost to restarts or slow runs are caught up.
+// The ledger of sent reminders keeps each of them from being delivered twice.
+func (bot *Bot) sendDueNotifications() {
+	ctx := context.Background()
+	now := bot.now().Truncate(time.Minute)
+
+	from := now.Add(-time.Minute)
+	lastTick, err := bot.notificationService.LastTick(ctx, notificationsJob)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if lastTick != nil {
+		from = lastTick.Truncate(time.Minute)
+	}
+	if from.Before(now.Add(-MaxNotificationsCatchUp)) {
+		from = now.Add(-MaxNotificationsCatchUp)
+	}
+
+	// reminders go off an hour before the task at most, so only the tasks
+	// due up to an hour from now can have one due
+	done, to := false, now.Add(maxNotificationLead+time.Minute)
+	tasks, err := bot.taskService.Tasks(ctx, units.TaskFilter{
+		Done:    &done,
+		From:    &from,
+		To:      &to,
+		OrderBy: units.TaskOrderDate,
 	})
 	if err != nil {
-		return err
+		log.Println(err)
+		return
 	}
 
-	_, err = c.AddFunc("* * * * *", func() {
-		tasks, _ := bot.taskService.Tasks(context.Background(), units.TaskFilter{})
-		var taskForNotifications []struct {
-			task         *units.Task
-			notification int
-		}
-		now := time.Now().In(bot.loc)
+	var usersByFamily map[uint][]*units.User
+	for _, task := range tasks {
+		dueAt := task.Date.Time
 
 		for _, notification := range []int{OneHourNotification, ThirtyMinutesNotification, FiveMinutesNotification, InstantlyNotification} {
+			if (task.Notifications & notification) == 0 {
+				continue
+			}
+
 			minutes := getMinutesFromNotificationType(notification)
-			notificationTime := now.Add(time.Duration(minutes) * time.Minute)
-			for _, v := range tasks {
-				if (v.Notifications&notification) != 0 && v.Date.Valid && v.Date.String[:10]+" "+v.Date.String[11:16] == notificationTime.Format(DateWithTimeFormat) &&
-					(notificationTime.Hour() != 0 || notificationTime.Minute() != 0) {
-					taskForNotifications = append(taskForNotifications, struct {
-						task         *units.Task
-						notification int
-					}{task: v, notification: notification})
+			notifyAt := dueAt.Add(-time.Duration(minutes) * time.Minute)
+			if !notifyAt.After(from) || notifyAt.After(now) {
+				continue
+			}
+
+			if usersByFamily == nil {
+				usersByFamily = bot.getUsersByFamily()
+			}
+
+			// midnight means the task has no time and there is nothing to remind about,
+			// the task was dated in the zone of its creator
+			members := usersByFamily[task.FamilyID]
+			if local := dueAt.In(bot.creatorLocation(members, task)); local.Hour() == 0 && local.Minute() == 0 {
+				continue
+			}
+			recipients := getNotificationRecipients(members, task)
+			if len(recipients) == 0 {
+				continue
+			}
+
+			isNew, err := bot.notificationService.MarkSent(ctx, task.ID, minutes, dueAt)
+			if err != nil {
+				log.Println(err)
+				return
+			}
+			if !isNew {
+				continue
+			}
+
+			for _, user := range recipients {
+				tr := bot.localizer(user)
+				message := getReminderText(tr, task.Title, minutes)
+				if notifyAt.Before(now) {
+					message = tr.T(TextMissedNotification, task.Title, dueAt.In(bot.userLocation(user)).Format(TimeFormat))
 				}
+				bot.sendMessage(int64(user.TelegramID), message, buildReminderKeyboard(tr, task.ID), "")
 			}
 		}
+	}
 
-		for _, item := range taskForNotifications {
-			textFormat := getFormatFromNotificationType(item.notification)
-			message := fmt.Sprintf(textFormat, item.task.Title)
-			for _, userId := range bot.subscribers {
-				user, err := bot.userService.UserByTelegramID(context.Background(), uint(userId))
-				if err == nil && user.Notifications {
-					bot.sendMessage(userId, message, nil, "")
-				}
+	snoozes, err := bot.notificationService.DueSnoozes(ctx, now)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	for _, snooze := range snoozes {
+		isNew, err := bot.notificationService.MarkSnoozeSent(ctx, snooze.ID)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		if !isNew {
+			continue
+		}
+
+		// snoozed tasks may be due at any time, they are not among the tasks above
+		task, err := bot.taskService.TaskByID(ctx, snooze.TaskID)
+		if err != nil {
+			if err != units.ErrNotFound {
+				log.Println(err)
 			}
+			continue
 		}
-	})
+		if task.Done {
+			continue
+		}
+
+		if usersByFamily == nil {
+			usersByFamily = bot.getUsersByFamily()
+		}
+		for _, user := range getNotificationRecipients(usersByFamily[task.FamilyID], task) {
+			tr := bot.localizer(user)
+			message := tr.T(TextSnoozedNotification, task.Title)
+			bot.sendMessage(int64(user.TelegramID), message, buildReminderKeyboard(tr, task.ID), "")
+		}
+	}
+
+	if err := bot.notificationService.SetLastTick(ctx, notificationsJob, now); err != nil {
+		log.Println(err)
+	}
+}
+
+// getUsersByFamily returns members of every family keyed by family id,
+// users outside of families are skipped.
+func (bot *Bot) getUsersByFamily() map[uint][]*units.User {
+	users, err := bot.userService.Users(context.Background(), units.UserFilter{})
 	if err != nil {
-		return err
+		log.Println(err)
+		return nil
 	}
 
-	c.Start()
+	result := map[uint][]*units.User{}
+	for _, user := range users {
+		if user.FamilyID.Valid {
+			familyID := uint(user.FamilyID.Int64)
+			result[familyID] = append(result[familyID], user)
+		}
+	}
 
-	return nil
+	return result
 }
 
 func getMinutesFromNotificationType(notificationType int) int {
@@ -79,15 +233,44 @@ func getMinutesFromNotificationType(notificationType int) int {
 	return 0
 }
 
-func getFormatFromNotificationType(notificationType int) string {
-	switch notificationType {
-	case OneHourNotification:
-		return TextInOneHour
-	case ThirtyMinutesNotification:
-		return TextInThirtyMinutes
-	case FiveMinutesNotification:
-		return TextInFiveMinutes
+// getReminderText tells how soon the task starts.
+func getReminderText(tr *i18n.Localizer, title string, minutes int) string {
+	switch {
+	case minutes == 0:
+		return tr.T(TextReminderNow, title)
+	case minutes%60 == 0:
+		return tr.N(TextReminderInHours, minutes/60, title, minutes/60)
+	}
+
+	return tr.N(TextReminderInMinutes, minutes, title, minutes)
+}
+
+// getNotificationRecipients returns assignees of the task who have notifications
+// enabled, unassigned tasks notify the whole family.
+func getNotificationRecipients(users []*units.User, task *units.Task) []*units.User {
+	recipients := users
+	if len(task.Assignees) > 0 {
+		recipients = filterAssignees(users, task.Assignees)
+	}
+
+	var result []*units.User
+	for _, user := range recipients {
+		if user.Notifications {
+			result = append(result, user)
+		}
+	}
+
+	return result
+}
+
+// creatorLocation returns the zone of the family member who created the task,
+// the zone of the bot is used when the creator is not known.
+func (bot *Bot) creatorLocation(members []*units.User, task *units.Task) *time.Location {
+	for _, user := range members {
+		if task.CreatedBy.Valid && int64(user.ID) == task.CreatedBy.Int64 {
+			return bot.userLocation(user)
+		}
 	}
 
-	return TextInInstantly
+	return bot.loc
 }
@@ -5,9 +5,11 @@ import (
 	"database/sql"
 	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/maxwww/family_bot/i18n"
 	st "github.com/maxwww/family_bot/state"
 	"github.com/maxwww/family_bot/units"
 	"log"
+	"strings"
 	"time"
 )
 
@@ -18,6 +20,23 @@ const (
 	commandCancel      = "cancel"
 	commandSubscribe   = "subscribe"
 	commandUnsubscribe = "unsubscribe"
+	commandInvite      = "invite"
+	commandJoin        = "join"
+	commandPin         = "pin"
+	commandUnpin       = "unpin"
+	commandTimezone    = "timezone"
+	commandLanguage    = "language"
+	commandShop        = "shop"
+	commandLists       = "lists"
+	commandUndo        = "undo"
+	commandCalendar    = "calendar"
+	commandExport      = "export"
+	commandImport      = "import"
+	commandToday       = "today"
+	commandTomorrow    = "tomorrow"
+	commandWeek        = "week"
+
+	InviteTTL = 24 * time.Hour
 
 	CQNewTaskSave              = "new_task_save"
 	CQNewTaskEditTitle         = "new_task_edit_title"
@@ -27,6 +46,8 @@ const (
 	CQNewTaskRemoveTime        = "new_task_remove_time"
 	CQNewTaskCancel            = "new_task_cancel"
 	CQNewTaskSetNotifications  = "new_task_notifications"
+	CQNewTaskSetRecurrence     = "new_task_recurrence"
+	CQNewTaskToggleAssignee    = "new_task_assignee"
 	CQTaskComplete             = "task_complete"
 	CQTaskEdit                 = "task_edit"
 	CQTaskEditOk               = "edit_task_ok"
@@ -40,64 +61,237 @@ const (
 	CQTaskEditEditTitle        = "task_edit_edit_title"
 	CQTaskEditDeleteTask       = "task_edit_delete_task"
 	CQTaskEditSetNotifications = "task_edit_set_notifications"
+	CQTaskEditSetRecurrence    = "task_edit_set_recurrence"
+	CQTaskEditToggleAssignee   = "task_edit_assignee"
+	CQTaskEditNotes            = "task_edit_notes"
+	CQTaskEditRemoveNotes      = "task_edit_remove_notes"
+	CQTaskEditItems            = "task_edit_items"
+	CQTaskEditDetailsDone      = "task_edit_details_done"
+	CQTaskEditAttachments      = "task_edit_attachments"
+	CQTaskEditHistory          = "task_edit_history"
+	CQTaskItemToggle           = "task_item_toggle"
+	CQTaskItemRemove           = "task_item_remove"
+	CQReminderDone             = "reminder_done"
+	CQReminderSnooze           = "reminder_snooze"
+	CQSetTimezone              = "set_timezone"
+	CQSetLanguage              = "set_language"
+	CQShopItemComplete         = "shop_complete"
+	CQShopRemoveAllBought      = "shop_remove_all_bought"
+	CQShopRemoveAllBoughtYes   = "shop_remove_all_bought_yes"
+	CQShopRemoveAllBoughtNo    = "shop_remove_all_bought_no"
+	CQListsShow                = "lists_show"
+	CQListSwitch               = "list_switch"
+	CQListEdit                 = "list_edit"
+	CQListRename               = "list_rename"
+	CQListDelete               = "list_delete"
+	CQListDeleteYes            = "list_delete_yes"
+	CQUndo                     = "undo"
+	CQCalendarReset            = "calendar_reset"
+	CQImportToggle             = "import_toggle"
+	CQImportSave               = "import_save"
+	CQImportCancel             = "import_cancel"
+	CQBackupRestoreYes         = "backup_restore_yes"
+	CQBackupRestoreNo          = "backup_restore_no"
+	CQDayComplete              = "day_complete"
+	CQWeekComplete             = "week_complete"
+	CQWeekShow                 = "week_show"
 )
 
 // command handlers
-func (bot *Bot) handleStartCommand(chatId int64) {
-	bot.sendMessage(chatId, TextStartMessage, nil, "")
+func (bot *Bot) handleStartCommand(chatId int64, user *units.User) {
+	bot.sendMessage(chatId, bot.localizer(user).T(TextStartMessage), nil, "")
 }
 
-func (bot *Bot) handleListCommand(chatId int64) {
-	message, keyboard := bot.getTasksListWithHeader()
+func (bot *Bot) handleListCommand(chatId int64, user *units.User) {
+	bot.sendTaskList(chatId, user)
+}
 
-	bot.sendMessage(chatId, message, keyboard, "")
+// handlePinCommand sends the list and pins it, from now on every new list
+// message of the chat is pinned instead of the previous one.
+func (bot *Bot) handlePinCommand(chatId int64, user *units.User) {
+	listMessage, err := bot.getListMessage(chatId)
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	if !listMessage.Pinned {
+		listMessage.UserID = user.ID
+		listMessage.Pinned = true
+		listMessage.MessageID = sql.NullInt64{}
+		err = bot.listMessageService.SaveListMessage(context.Background(), listMessage)
+		if err != nil {
+			log.Println(err)
+			bot.sendGeneralError(chatId, user)
+			return
+		}
+	}
+
+	bot.sendTaskList(chatId, user)
+}
+
+func (bot *Bot) handleUnpinCommand(chatId int64, user *units.User) {
+	listMessage, err := bot.getListMessage(chatId)
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	if listMessage.Pinned {
+		if listMessage.MessageID.Valid {
+			bot.unpinMessage(chatId, int(listMessage.MessageID.Int64))
+		}
+
+		listMessage.UserID = user.ID
+		listMessage.Pinned = false
+		err = bot.listMessageService.SaveListMessage(context.Background(), listMessage)
+		if err != nil {
+			log.Println(err)
+			bot.sendGeneralError(chatId, user)
+			return
+		}
+	}
+
+	bot.sendMessage(chatId, bot.localizer(user).T(TextListUnpinned), nil, "")
 }
 
-func (bot *Bot) handleCancelCommand(chatId int64, userTelegramId int) {
-	bot.stateService.SetUserState(userTelegramId, st.State{
+func (bot *Bot) handleCancelCommand(chatId int64, user *units.User) {
+	bot.stateService.SetUserState(int(user.TelegramID), st.State{
 		Status: st.STATUS_IDLE,
 	})
 
-	bot.sendMessage(chatId, TextCancel, nil, "")
+	bot.sendMessage(chatId, bot.localizer(user).T(TextCancel), nil, "")
 }
 
 func (bot *Bot) handleSubscribeCommand(chatId int64, notifications bool, user *units.User) {
+	tr := bot.localizer(user)
 	err := bot.userService.UpdateUser(context.Background(), user, units.UserPatch{
 		Notifications: &notifications,
 	})
 
 	if err != nil {
-		bot.sendGeneralError(chatId)
+		bot.sendGeneralError(chatId, user)
 		return
 	}
 
-	message := TextSubscriptionsOn
+	message := tr.T(TextSubscriptionsOn)
 
 	if !user.Notifications {
-		message = TextSubscriptionsOff
+		message = tr.T(TextSubscriptionsOff)
 	}
 
 	bot.sendMessage(chatId, message, nil, "")
 }
 
-func (bot *Bot) handleUnknownCommand(chatId int64) {
-	bot.sendMessage(chatId, TextUnknownCommand, nil, "")
+func (bot *Bot) handleInviteCommand(chatId int64, user *units.User) {
+	code, err := generateInviteCode()
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	err = bot.familyService.CreateInvite(context.Background(), &units.FamilyInvite{
+		Code:      code,
+		FamilyID:  uint(user.FamilyID.Int64),
+		CreatedBy: user.ID,
+		ExpiresAt: time.Now().Add(InviteTTL),
+	})
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	bot.sendMessage(chatId, bot.localizer(user).T(TextInviteCreated, code, code), nil, "")
+}
+
+func (bot *Bot) handleJoinCommand(chatId int64, user *units.User, code string) {
+	tr := bot.localizer(user)
+	code = strings.ToUpper(trim(code))
+	if code == "" {
+		bot.sendMessage(chatId, tr.T(TextJoinUsage), nil, "")
+		return
+	}
+
+	family, err := bot.familyService.RedeemInvite(context.Background(), code, user)
+	if err != nil {
+		if err == units.ErrNotFound {
+			bot.sendMessage(chatId, tr.T(TextInviteInvalid), nil, "")
+			return
+		}
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	bot.stateService.SetUserState(int(user.TelegramID), st.State{
+		Status: st.STATUS_IDLE,
+	})
+
+	bot.sendMessage(chatId, tr.T(TextJoinedFamily, family.Name), nil, "")
+}
+
+func (bot *Bot) handleUnknownCommand(chatId int64, user *units.User) {
+	bot.sendMessage(chatId, bot.localizer(user).T(TextUnknownCommand), nil, "")
 }
 
 // message handlers
-func (bot *Bot) handleIdleMessage(chatId int64, user *units.User, message string) {
-	date, title, _, err := bot.findDate(message)
+// handleIdleMessage starts a new task in the active list or in the list
+// named with a "#tag" at the start, shopping lists take items right away.
+// A photo or a document comes with its caption as the message.
+func (bot *Bot) handleIdleMessage(chatId int64, user *units.User, message string, attachment *units.Attachment) {
+	tr := bot.localizer(user)
+	if attachment != nil && trim(message) == "" {
+		bot.sendMessage(chatId, tr.T(TextAttachmentCaptionRequired), nil, "")
+		return
+	}
+	tag, message := splitListTag(message)
+
+	var list *units.List
+	var err error
+	if tag != "" {
+		list = findListByName(bot.getFamilyLists(user), tag)
+		if list == nil {
+			bot.sendMessage(chatId, tr.T(TextListNotFound, tag, tag), nil, "")
+			return
+		}
+	} else if list, err = bot.getActiveList(user); err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	if list.Kind == units.ListKindShopping {
+		if err := bot.addShoppingItems(user, list, message); err != nil {
+			log.Println(err)
+			bot.sendGeneralError(chatId, user)
+			return
+		}
+		shoppingList, keyboard := bot.getShoppingListWithHeader(user, list)
+		bot.sendMessage(chatId, shoppingList, keyboard, "")
+		return
+	}
+
+	parsed, err := bot.getDateParser(user).Parse(message)
 	if err != nil {
 		log.Println(err)
-		bot.sendParseError(chatId)
+		bot.sendParseError(chatId, user)
 		return
 	}
 
-	title = trim(title)
+	date, title, recurrence := getParsedDate(parsed), parsed.Remainder, parsed.Recurrence
+	var attachments []*units.Attachment
+	if attachment != nil {
+		attachments = append(attachments, attachment)
+	}
 
 	if title != "" {
-		ms := getNewTaskInfo(title, date)
-		keyboard := buildEditTaskKeyboard(date, DeFaultNotification, 0)
+		users := bot.getFamilyUsers(user)
+		ms := getNewTaskInfo(tr, title, date, recurrence, nil)
+		keyboard := buildEditTaskKeyboard(tr, date, DeFaultNotification, recurrence, nil, users, 0)
 
 		bot.stateService.SetUserState(int(user.TelegramID), st.State{
 			Status: st.STATUS_ADD_TASK_PARSED,
@@ -105,56 +299,85 @@ func (bot *Bot) handleIdleMessage(chatId int64, user *units.User, message string
 				Title:         title,
 				Date:          date,
 				Notifications: DeFaultNotification,
+				Recurrence:    recurrence,
+				ListID:        list.ID,
+				Attachments:   attachments,
 			},
 		})
 
 		bot.sendMessage(chatId, ms, keyboard, "")
 	} else {
-		bot.sendParseError(chatId)
+		bot.sendParseError(chatId, user)
 	}
 }
 
 func (bot *Bot) handleNewTaskEditTitle(chatId int64, user *units.User, task st.Task, message string) {
+	tr := bot.localizer(user)
 	title := trim(message)
 
 	if title != "" {
-		ms := getNewTaskInfo(title, task.Date)
-		keyboard := buildEditTaskKeyboard(task.Date, task.Notifications, 0)
+		users := bot.getFamilyUsers(user)
+		ms := getNewTaskInfo(tr, title, task.Date, task.Recurrence, filterAssignees(users, task.Assignees))
+		keyboard := buildEditTaskKeyboard(tr, task.Date, task.Notifications, task.Recurrence, task.Assignees, users, 0)
 
 		bot.stateService.SetUserState(int(user.TelegramID), st.State{
 			Status: st.STATUS_ADD_TASK_PARSED,
 			Task: st.Task{
-				Title: title,
-				Date:  task.Date,
+				Title:       title,
+				Date:        task.Date,
+				Recurrence:  task.Recurrence,
+				Assignees:   task.Assignees,
+				ListID:      task.ListID,
+				Attachments: task.Attachments,
 			},
 		})
 
 		bot.sendMessage(chatId, ms, keyboard, "")
 	} else {
-		bot.sendParseError(chatId)
+		bot.sendParseError(chatId, user)
 	}
 }
 
 func (bot *Bot) handleNewTaskEditDate(chatId int64, user *units.User, task st.Task, message string) {
-	date, _, _, err := bot.findDate(message)
+	tr := bot.localizer(user)
+	parsed, err := bot.getDateParser(user).Parse(message)
 	if err != nil {
 		log.Println(err)
-		bot.sendParseError(chatId)
+		bot.sendParseError(chatId, user)
 		return
 	}
 
-	if task.Date != nil && (task.Date.Hour() != 0 || task.Date.Minute() != 0) && date.Hour() == 0 && date.Minute() == 0 {
-		*date = time.Date(date.Year(), date.Month(), date.Day(), task.Date.Hour(), task.Date.Minute(), task.Date.Second(), 0, bot.loc)
+	if !parsed.Found() {
+		bot.sendParseError(chatId, user)
+		return
+	}
+
+	date, recurrence := getParsedDate(parsed), parsed.Recurrence
+
+	if recurrence != units.RecurrenceNone {
+		task.Recurrence = recurrence
+	}
+
+	if task.Date != nil {
+		oldDate := task.Date.In(date.Location())
+		if (oldDate.Hour() != 0 || oldDate.Minute() != 0) && date.Hour() == 0 && date.Minute() == 0 {
+			*date = time.Date(date.Year(), date.Month(), date.Day(), oldDate.Hour(), oldDate.Minute(), oldDate.Second(), 0, date.Location())
+		}
 	}
 
-	ms := getNewTaskInfo(task.Title, date)
-	keyboard := buildEditTaskKeyboard(date, task.Notifications, 0)
+	users := bot.getFamilyUsers(user)
+	ms := getNewTaskInfo(tr, task.Title, date, task.Recurrence, filterAssignees(users, task.Assignees))
+	keyboard := buildEditTaskKeyboard(tr, date, task.Notifications, task.Recurrence, task.Assignees, users, 0)
 
 	bot.stateService.SetUserState(int(user.TelegramID), st.State{
 		Status: st.STATUS_ADD_TASK_PARSED,
 		Task: st.Task{
-			Title: task.Title,
-			Date:  date,
+			Title:       task.Title,
+			Date:        date,
+			Recurrence:  task.Recurrence,
+			Assignees:   task.Assignees,
+			ListID:      task.ListID,
+			Attachments: task.Attachments,
 		},
 	})
 
@@ -162,121 +385,149 @@ func (bot *Bot) handleNewTaskEditDate(chatId int64, user *units.User, task st.Ta
 }
 
 func (bot *Bot) handleNewTaskEditTime(chatId int64, user *units.User, task st.Task, message string) {
-	date, _, isDateFound, err := bot.findDate(message)
+	tr := bot.localizer(user)
+	parsed, err := bot.getDateParser(user).Parse(message)
 	if err != nil {
 		log.Println(err)
-		bot.sendParseError(chatId)
+		bot.sendParseError(chatId, user)
+		return
+	}
+
+	if !parsed.Found() {
+		bot.sendParseError(chatId, user)
 		return
 	}
 
+	date, isDateFound := getParsedDate(parsed), parsed.HasDate
+
 	var newDate *time.Time
 	if isDateFound {
 		newDate = date
 	} else {
-		tmp := time.Date(task.Date.Year(), task.Date.Month(), task.Date.Day(), date.Hour(), date.Minute(), date.Second(), 0, bot.loc)
+		oldDate := task.Date.In(date.Location())
+		tmp := time.Date(oldDate.Year(), oldDate.Month(), oldDate.Day(), date.Hour(), date.Minute(), date.Second(), 0, date.Location())
 		newDate = &tmp
 	}
 
-	ms := getNewTaskInfo(task.Title, newDate)
-	keyboard := buildEditTaskKeyboard(newDate, task.Notifications, 0)
+	users := bot.getFamilyUsers(user)
+	ms := getNewTaskInfo(tr, task.Title, newDate, task.Recurrence, filterAssignees(users, task.Assignees))
+	keyboard := buildEditTaskKeyboard(tr, newDate, task.Notifications, task.Recurrence, task.Assignees, users, 0)
 
 	bot.stateService.SetUserState(int(user.TelegramID), st.State{
 		Status: st.STATUS_ADD_TASK_PARSED,
 		Task: st.Task{
-			Title: task.Title,
-			Date:  newDate,
+			Title:       task.Title,
+			Date:        newDate,
+			Recurrence:  task.Recurrence,
+			Assignees:   task.Assignees,
+			ListID:      task.ListID,
+			Attachments: task.Attachments,
 		},
 	})
 
 	bot.sendMessage(chatId, ms, keyboard, "")
 }
 
-func (bot *Bot) handleEditTaskEditTitle(chatId int64, userTelegramId int, message string, taskId int) {
-	task, err := bot.taskService.TaskByID(context.Background(), uint(taskId))
+func (bot *Bot) handleEditTaskEditTitle(chatId int64, user *units.User, message string, taskId int) {
+	tr := bot.localizer(user)
+	task, err := bot.getFamilyTask(user, taskId)
 	if err != nil {
 		log.Println(err)
-		bot.sendParseError(chatId)
+		bot.sendParseError(chatId, user)
 		return
 	}
 
-	bot.stateService.SetUserState(userTelegramId, st.State{
+	bot.stateService.SetUserState(int(user.TelegramID), st.State{
 		Status: st.STATUS_IDLE,
 	})
 
 	title := trim(message)
-	err = bot.taskService.UpdateTask(context.Background(), task, units.TaskPatch{
+	err = bot.taskService.UpdateTask(actorContext(user), task, units.TaskPatch{
 		Title: &title,
 	})
 
 	if err != nil {
 		log.Println(err)
-		bot.sendGeneralError(chatId)
+		bot.sendGeneralError(chatId, user)
 		return
 	}
 
-	date := bot.getDateFromNullString(task.Date)
-	ms := getEditingTaskInfo(task.Title, date)
-	keyboard := buildEditTaskKeyboard(date, task.Notifications, int(task.ID))
+	date := getDateFromNullTime(task.Date, bot.userLocation(user))
+	users := bot.getFamilyUsers(user)
+	ms := getEditingTaskInfo(tr, task.Title, date, task.Recurrence, filterAssignees(users, task.Assignees))
+	keyboard := buildEditTaskKeyboard(tr, date, task.Notifications, task.Recurrence, task.Assignees, users, int(task.ID))
 
 	bot.sendMessage(chatId, ms, keyboard, "")
+	bot.refreshListMessages(user, 0, 0)
 }
 
-func (bot *Bot) handleEditTaskEditDate(chatId int64, userTelegramId int, message string, taskId int) {
-	date, _, isDateFound, err := bot.findDate(message)
+func (bot *Bot) handleEditTaskEditDate(chatId int64, user *units.User, message string, taskId int) {
+	tr := bot.localizer(user)
+	parsed, err := bot.getDateParser(user).Parse(message)
+	date, isDateFound, recurrence := getParsedDate(parsed), parsed.HasDate, parsed.Recurrence
 
 	if err != nil || !isDateFound {
-		bot.sendParseError(chatId)
+		bot.sendParseError(chatId, user)
 		return
 	}
 
-	bot.stateService.SetUserState(userTelegramId, st.State{
+	bot.stateService.SetUserState(int(user.TelegramID), st.State{
 		Status: st.STATUS_IDLE,
 	})
 
-	task, err := bot.taskService.TaskByID(context.Background(), uint(taskId))
+	task, err := bot.getFamilyTask(user, taskId)
 	if err != nil || !isDateFound {
-		bot.sendGeneralError(chatId)
+		bot.sendGeneralError(chatId, user)
 		return
 	}
 
-	newDate := sql.NullString{}
+	newDate := sql.NullTime{}
 	if isDateFound && date != nil {
-		oldDate := bot.getDateFromNullString(task.Date)
+		oldDate := getDateFromNullTime(task.Date, date.Location())
 		if oldDate != nil && (oldDate.Hour() != 0 || oldDate.Minute() != 0) && date.Hour() == 0 && date.Minute() == 0 {
-			*date = time.Date(date.Year(), date.Month(), date.Day(), oldDate.Hour(), oldDate.Minute(), oldDate.Second(), 0, bot.loc)
+			*date = time.Date(date.Year(), date.Month(), date.Day(), oldDate.Hour(), oldDate.Minute(), oldDate.Second(), 0, date.Location())
 		}
-		newDate.String = date.Format(DateWithTimeFormat)
-		newDate.Valid = true
+		newDate = getNullTime(date)
 	}
 
-	err = bot.taskService.UpdateTask(context.Background(), task, units.TaskPatch{
+	patch := units.TaskPatch{
 		Date: &newDate,
-	})
+	}
+	if recurrence != units.RecurrenceNone {
+		patch.Recurrence = &recurrence
+	}
+
+	err = bot.taskService.UpdateTask(actorContext(user), task, patch)
 	if err != nil || !isDateFound {
-		bot.sendGeneralError(chatId)
+		bot.sendGeneralError(chatId, user)
 		return
 	}
 
-	ms := getEditingTaskInfo(task.Title, date)
-	keyboard := buildEditTaskKeyboard(date, task.Notifications, int(task.ID))
+	users := bot.getFamilyUsers(user)
+	ms := getEditingTaskInfo(tr, task.Title, date, task.Recurrence, filterAssignees(users, task.Assignees))
+	keyboard := buildEditTaskKeyboard(tr, date, task.Notifications, task.Recurrence, task.Assignees, users, int(task.ID))
 
 	bot.sendMessage(chatId, ms, keyboard, "")
+	bot.refreshListMessages(user, 0, 0)
 }
 
-func (bot *Bot) handleEditTaskEditTime(chatId int64, userTelegramId int, message string, taskId int) {
-	date, _, isDateFound, err := bot.findDate(message)
-	if err != nil {
-		bot.sendParseError(chatId)
+func (bot *Bot) handleEditTaskEditTime(chatId int64, user *units.User, message string, taskId int) {
+	tr := bot.localizer(user)
+	parsed, err := bot.getDateParser(user).Parse(message)
+	if err != nil || !parsed.Found() {
+		bot.sendParseError(chatId, user)
 		return
 	}
 
-	bot.stateService.SetUserState(userTelegramId, st.State{
+	date, isDateFound := getParsedDate(parsed), parsed.HasDate
+
+	bot.stateService.SetUserState(int(user.TelegramID), st.State{
 		Status: st.STATUS_IDLE,
 	})
 
-	task, err := bot.taskService.TaskByID(context.Background(), uint(taskId))
+	task, err := bot.getFamilyTask(user, taskId)
 	if err != nil {
-		bot.sendGeneralError(chatId)
+		bot.sendGeneralError(chatId, user)
 		return
 	}
 
@@ -284,123 +535,146 @@ func (bot *Bot) handleEditTaskEditTime(chatId int64, userTelegramId int, message
 	if isDateFound {
 		newDate = date
 	} else {
-		oldDate := bot.getDateFromString(task.Date.String)
-		tmp := time.Date(oldDate.Year(), oldDate.Month(), oldDate.Day(), date.Hour(), date.Minute(), date.Second(), 0, bot.loc)
+		oldDate := task.Date.Time.In(date.Location())
+		tmp := time.Date(oldDate.Year(), oldDate.Month(), oldDate.Day(), date.Hour(), date.Minute(), date.Second(), 0, date.Location())
 		newDate = &tmp
 	}
 
-	dateForUpdate := sql.NullString{
-		String: newDate.Format(DateWithTimeFormat),
-		Valid:  true,
-	}
-	err = bot.taskService.UpdateTask(context.Background(), task, units.TaskPatch{
+	dateForUpdate := getNullTime(newDate)
+	err = bot.taskService.UpdateTask(actorContext(user), task, units.TaskPatch{
 		Date: &dateForUpdate,
 	})
 	if err != nil {
-		bot.sendGeneralError(chatId)
+		bot.sendGeneralError(chatId, user)
 		return
 	}
 
-	ms := getEditingTaskInfo(task.Title, newDate)
-	keyboard := buildEditTaskKeyboard(newDate, task.Notifications, int(task.ID))
+	users := bot.getFamilyUsers(user)
+	ms := getEditingTaskInfo(tr, task.Title, newDate, task.Recurrence, filterAssignees(users, task.Assignees))
+	keyboard := buildEditTaskKeyboard(tr, newDate, task.Notifications, task.Recurrence, task.Assignees, users, int(task.ID))
 
 	bot.sendMessage(chatId, ms, keyboard, "")
+	bot.refreshListMessages(user, 0, 0)
 }
 
 // callback handlers
-func (bot *Bot) saveNewTask(state *st.State, chatId int64, messageId int, userTelegramId int) {
+func (bot *Bot) saveNewTask(state *st.State, chatId int64, messageId int, user *units.User) {
 	if state.Status == st.STATUS_ADD_TASK_PARSED {
+		// tasks started before lists existed go to the family task list
+		list, err := bot.getFamilyList(user, units.ListKindTasks)
+		if state.Task.ListID != 0 {
+			list, err = bot.getFamilyListByID(user, state.Task.ListID)
+		}
+		if err != nil {
+			log.Println(err)
+			bot.sendGeneralError(chatId, user)
+			return
+		}
+
 		newTask := createTaskStateWithDate(&state.Task)
+		newTask.FamilyID = uint(user.FamilyID.Int64)
+		newTask.ListID = list.ID
 
-		err := bot.taskService.CreateTask(context.Background(), newTask)
+		err = bot.taskService.CreateTask(actorContext(user), newTask)
 		if err != nil {
-			bot.sendGeneralError(chatId)
+			bot.sendGeneralError(chatId, user)
 			return
 		}
 
-		bot.stateService.SetUserState(userTelegramId, st.State{
+		bot.stateService.SetUserState(int(user.TelegramID), st.State{
 			Status: st.STATUS_IDLE,
 		})
 
 		bot.deleteMessage(chatId, messageId)
 
-		message := getSavedTaskInfo(state.Task.Title, state.Task.Date)
+		users := bot.getFamilyUsers(user)
+		assignees := filterAssignees(users, state.Task.Assignees)
 
-		for _, id := range bot.subscribers {
-			user, err := bot.userService.UserByTelegramID(context.Background(), uint(id))
-			if err == nil && user.Notifications {
-				bot.sendMessage(id, message, nil, "")
+		for _, member := range users {
+			if member.Notifications {
+				date := getDateFromNullTime(newTask.Date, bot.userLocation(member))
+				message := getSavedTaskInfo(bot.localizer(member), state.Task.Title, date, state.Task.Recurrence, assignees)
+				bot.sendMessage(int64(member.TelegramID), message, nil, "")
 			}
 		}
+
+		bot.refreshListMessages(user, 0, 0)
 	} else {
-		bot.sendGeneralError(chatId)
+		bot.sendGeneralError(chatId, user)
 	}
 }
 
-func (bot *Bot) editTitleNewTask(state *st.State, chatId int64, messageId int, userTelegramId int) {
+func (bot *Bot) editTitleNewTask(state *st.State, chatId int64, messageId int, user *units.User) {
 	if state.Status == st.STATUS_ADD_TASK_PARSED {
-		bot.stateService.SetUserState(userTelegramId, st.State{
+		bot.stateService.SetUserState(int(user.TelegramID), st.State{
 			Status: st.STATUS_ADD_TASK_WAIT_TITLE,
 			Task:   state.Task,
 		})
 
 		bot.deleteMessage(chatId, messageId)
 
-		bot.sendMessage(chatId, fmt.Sprintf(TextSendNewTitle, state.Task.Title), nil, "MarkDown")
+		bot.sendMessage(chatId, bot.localizer(user).T(TextSendNewTitle, state.Task.Title), nil, "MarkDown")
 	} else {
-		bot.sendGeneralError(chatId)
+		bot.sendGeneralError(chatId, user)
 	}
 }
 
-func (bot *Bot) editDayNewTask(state *st.State, chatId int64, messageId int, userTelegramId int) {
+func (bot *Bot) editDayNewTask(state *st.State, chatId int64, messageId int, user *units.User) {
 	if state.Status == st.STATUS_ADD_TASK_PARSED {
-		bot.stateService.SetUserState(userTelegramId, st.State{
+		bot.stateService.SetUserState(int(user.TelegramID), st.State{
 			Status: st.STATUS_ADD_TASK_WAIT_DATE,
 			Task:   state.Task,
 		})
 
 		bot.deleteMessage(chatId, messageId)
 
-		bot.sendMessage(chatId, TextSendNewDay, nil, "")
+		bot.sendMessage(chatId, bot.localizer(user).T(TextSendNewDay), nil, "")
 	} else {
-		bot.sendGeneralError(chatId)
+		bot.sendGeneralError(chatId, user)
 	}
 }
 
-func (bot *Bot) removeDayNewTask(state *st.State, chatId int64, messageId int, userTelegramId int) {
+func (bot *Bot) removeDayNewTask(state *st.State, chatId int64, messageId int, user *units.User) {
+	tr := bot.localizer(user)
 	if state.Status == st.STATUS_ADD_TASK_PARSED {
-		bot.stateService.SetUserState(userTelegramId, st.State{
+		bot.stateService.SetUserState(int(user.TelegramID), st.State{
 			Status: st.STATUS_ADD_TASK_PARSED,
 			Task: st.Task{
-				Title: state.Task.Title,
+				Title:       state.Task.Title,
+				Recurrence:  state.Task.Recurrence,
+				Assignees:   state.Task.Assignees,
+				ListID:      state.Task.ListID,
+				Attachments: state.Task.Attachments,
 			},
 		})
 
-		message := getNewTaskInfo(state.Task.Title, nil)
-		keyboard := buildEditTaskKeyboard(nil, state.Task.Notifications, 0)
+		users := bot.getFamilyUsers(user)
+		message := getNewTaskInfo(tr, state.Task.Title, nil, state.Task.Recurrence, filterAssignees(users, state.Task.Assignees))
+		keyboard := buildEditTaskKeyboard(tr, nil, state.Task.Notifications, state.Task.Recurrence, state.Task.Assignees, users, 0)
 
 		bot.editMessage(chatId, messageId, message, keyboard, "")
 	} else {
-		bot.sendGeneralError(chatId)
+		bot.sendGeneralError(chatId, user)
 	}
 }
 
-func (bot *Bot) editTimeNewTask(state *st.State, chatId int64, messageId int, userTelegramId int) {
+func (bot *Bot) editTimeNewTask(state *st.State, chatId int64, messageId int, user *units.User) {
 	if state.Status == st.STATUS_ADD_TASK_PARSED {
-		bot.stateService.SetUserState(userTelegramId, st.State{
+		bot.stateService.SetUserState(int(user.TelegramID), st.State{
 			Status: st.STATUS_ADD_TASK_WAIT_TIME,
 			Task:   state.Task,
 		})
 
 		bot.deleteMessage(chatId, messageId)
 
-		bot.sendMessage(chatId, TextSendNewTime, nil, "")
+		bot.sendMessage(chatId, bot.localizer(user).T(TextSendNewTime), nil, "")
 	} else {
-		bot.sendGeneralError(chatId)
+		bot.sendGeneralError(chatId, user)
 	}
 }
 
-func (bot *Bot) setNotificationsNewTask(state *st.State, chatId int64, messageId int, notification int, userTelegramId int) {
+func (bot *Bot) setNotificationsNewTask(state *st.State, chatId int64, messageId int, notification int, user *units.User) {
+	tr := bot.localizer(user)
 	if state.Status == st.STATUS_ADD_TASK_PARSED {
 		if (state.Task.Notifications & notification) != 0 {
 			state.Task.Notifications -= notification
@@ -408,167 +682,224 @@ func (bot *Bot) setNotificationsNewTask(state *st.State, chatId int64, messageId
 			state.Task.Notifications += notification
 		}
 
-		bot.stateService.SetUserState(userTelegramId, st.State{
+		bot.stateService.SetUserState(int(user.TelegramID), st.State{
 			Status: state.Status,
 			Task:   state.Task,
 		})
 
-		message := getNewTaskInfo(state.Task.Title, state.Task.Date)
-		keyboard := buildEditTaskKeyboard(state.Task.Date, state.Task.Notifications, 0)
+		users := bot.getFamilyUsers(user)
+		message := getNewTaskInfo(tr, state.Task.Title, state.Task.Date, state.Task.Recurrence, filterAssignees(users, state.Task.Assignees))
+		keyboard := buildEditTaskKeyboard(tr, state.Task.Date, state.Task.Notifications, state.Task.Recurrence, state.Task.Assignees, users, 0)
 
 		bot.editMessage(chatId, messageId, message, keyboard, "")
 	} else {
-		bot.sendGeneralError(chatId)
+		bot.sendGeneralError(chatId, user)
 	}
 }
 
-func (bot *Bot) removeTimeNewTask(state *st.State, chatId int64, messageId int, userTelegramId int) {
+func (bot *Bot) setRecurrenceNewTask(state *st.State, chatId int64, messageId int, option int, user *units.User) {
+	tr := bot.localizer(user)
 	if state.Status == st.STATUS_ADD_TASK_PARSED {
-		newDate := bot.getMidnightFromDate(state.Task.Date)
+		state.Task.Recurrence = toggleRecurrence(state.Task.Recurrence, option)
+		if state.Task.Recurrence != units.RecurrenceNone && state.Task.Date == nil {
+			now := bot.now()
+			state.Task.Date = getMidnightFromDate(&now, bot.userLocation(user))
+		}
 
-		bot.stateService.SetUserState(userTelegramId, st.State{
+		bot.stateService.SetUserState(int(user.TelegramID), st.State{
+			Status: state.Status,
+			Task:   state.Task,
+		})
+
+		users := bot.getFamilyUsers(user)
+		message := getNewTaskInfo(tr, state.Task.Title, state.Task.Date, state.Task.Recurrence, filterAssignees(users, state.Task.Assignees))
+		keyboard := buildEditTaskKeyboard(tr, state.Task.Date, state.Task.Notifications, state.Task.Recurrence, state.Task.Assignees, users, 0)
+
+		bot.editMessage(chatId, messageId, message, keyboard, "")
+	} else {
+		bot.sendGeneralError(chatId, user)
+	}
+}
+
+func (bot *Bot) toggleAssigneeNewTask(state *st.State, chatId int64, messageId int, userId int, user *units.User) {
+	tr := bot.localizer(user)
+	users := bot.getFamilyUsers(user)
+	if state.Status == st.STATUS_ADD_TASK_PARSED && isFamilyUser(users, uint(userId)) {
+		state.Task.Assignees = toggleAssignee(state.Task.Assignees, uint(userId))
+
+		bot.stateService.SetUserState(int(user.TelegramID), st.State{
+			Status: state.Status,
+			Task:   state.Task,
+		})
+
+		message := getNewTaskInfo(tr, state.Task.Title, state.Task.Date, state.Task.Recurrence, filterAssignees(users, state.Task.Assignees))
+		keyboard := buildEditTaskKeyboard(tr, state.Task.Date, state.Task.Notifications, state.Task.Recurrence, state.Task.Assignees, users, 0)
+
+		bot.editMessage(chatId, messageId, message, keyboard, "")
+	} else {
+		bot.sendGeneralError(chatId, user)
+	}
+}
+
+func (bot *Bot) removeTimeNewTask(state *st.State, chatId int64, messageId int, user *units.User) {
+	tr := bot.localizer(user)
+	if state.Status == st.STATUS_ADD_TASK_PARSED {
+		newDate := getMidnightFromDate(state.Task.Date, bot.userLocation(user))
+
+		bot.stateService.SetUserState(int(user.TelegramID), st.State{
 			Status: st.STATUS_ADD_TASK_PARSED,
 			Task: st.Task{
-				Title: state.Task.Title,
-				Date:  newDate,
+				Title:       state.Task.Title,
+				Date:        newDate,
+				Recurrence:  state.Task.Recurrence,
+				Assignees:   state.Task.Assignees,
+				ListID:      state.Task.ListID,
+				Attachments: state.Task.Attachments,
 			},
 		})
 
-		message := getNewTaskInfo(state.Task.Title, newDate)
-		keyboard := buildEditTaskKeyboard(newDate, state.Task.Notifications, 0)
+		users := bot.getFamilyUsers(user)
+		message := getNewTaskInfo(tr, state.Task.Title, newDate, state.Task.Recurrence, filterAssignees(users, state.Task.Assignees))
+		keyboard := buildEditTaskKeyboard(tr, newDate, state.Task.Notifications, state.Task.Recurrence, state.Task.Assignees, users, 0)
 
 		bot.editMessage(chatId, messageId, message, keyboard, "")
 	} else {
-		bot.sendGeneralError(chatId)
+		bot.sendGeneralError(chatId, user)
 	}
 }
 
-func (bot *Bot) cancelNewTask(chatId int64, messageId int, userTelegramId int) {
-	bot.stateService.SetUserState(userTelegramId, st.State{
+func (bot *Bot) cancelNewTask(chatId int64, messageId int, user *units.User) {
+	bot.stateService.SetUserState(int(user.TelegramID), st.State{
 		Status: st.STATUS_IDLE,
 	})
 	bot.deleteMessage(chatId, messageId)
 }
 
-func (bot *Bot) editTaskTitle(chatId int64, messageId int, userTelegramId int, taskId int) {
-	bot.stateService.SetUserState(userTelegramId, st.State{
+func (bot *Bot) editTaskTitle(chatId int64, messageId int, user *units.User, taskId int) {
+	bot.stateService.SetUserState(int(user.TelegramID), st.State{
 		Status: st.STATUS_EDIT_TASK_WAIT_TITLE,
 		Task: st.Task{
 			ID: taskId,
 		},
 	})
 
-	task, err := bot.taskService.TaskByID(context.Background(), uint(taskId))
+	task, err := bot.getFamilyTask(user, taskId)
 	if err != nil {
 		log.Println(err)
-		bot.sendGeneralError(chatId)
+		bot.sendGeneralError(chatId, user)
 		return
 	}
 
-	bot.editMessage(chatId, messageId, fmt.Sprintf(TextSendNewTitle, task.Title), nil, "MarkDown")
+	bot.editMessage(chatId, messageId, bot.localizer(user).T(TextSendNewTitle, task.Title), nil, "MarkDown")
 }
 
-func (bot *Bot) editTaskDay(chatId int64, messageId int, userTelegramId int, taskId int) {
-	bot.stateService.SetUserState(userTelegramId, st.State{
+func (bot *Bot) editTaskDay(chatId int64, messageId int, user *units.User, taskId int) {
+	bot.stateService.SetUserState(int(user.TelegramID), st.State{
 		Status: st.STATUS_EDIT_TASK_WAIT_DATE,
 		Task: st.Task{
 			ID: taskId,
 		},
 	})
 
-	bot.editMessage(chatId, messageId, TextSendNewDay, nil, "")
+	bot.editMessage(chatId, messageId, bot.localizer(user).T(TextSendNewDay), nil, "")
 }
 
-func (bot *Bot) editTaskTime(chatId int64, messageId int, userTelegramId int, taskId int) {
-	bot.stateService.SetUserState(userTelegramId, st.State{
+func (bot *Bot) editTaskTime(chatId int64, messageId int, user *units.User, taskId int) {
+	bot.stateService.SetUserState(int(user.TelegramID), st.State{
 		Status: st.STATUS_EDIT_TASK_WAIT_TIME,
 		Task: st.Task{
 			ID: taskId,
 		},
 	})
 
-	bot.editMessage(chatId, messageId, TextSendNewTime, nil, "")
+	bot.editMessage(chatId, messageId, bot.localizer(user).T(TextSendNewTime), nil, "")
 }
 
-func (bot *Bot) removeTaskDay(chatId int64, messageId int, userTelegramId int, taskId int) {
-	bot.stateService.SetUserState(userTelegramId, st.State{
+func (bot *Bot) removeTaskDay(chatId int64, messageId int, user *units.User, taskId int) {
+	tr := bot.localizer(user)
+	bot.stateService.SetUserState(int(user.TelegramID), st.State{
 		Status: st.STATUS_IDLE,
 	})
-	task, err := bot.taskService.TaskByID(context.Background(), uint(taskId))
+	task, err := bot.getFamilyTask(user, taskId)
 	if err != nil {
 		log.Println(err)
-		bot.sendGeneralError(chatId)
+		bot.sendGeneralError(chatId, user)
 		return
 	}
 
-	newDate := sql.NullString{}
-	err = bot.taskService.UpdateTask(context.Background(), task, units.TaskPatch{
+	newDate := sql.NullTime{}
+	err = bot.taskService.UpdateTask(actorContext(user), task, units.TaskPatch{
 		Date: &newDate,
 	})
 	if err != nil {
 		log.Println(err)
-		bot.sendGeneralError(chatId)
+		bot.sendGeneralError(chatId, user)
 		return
 	}
 
-	message := getEditingTaskInfo(task.Title, nil)
-	keyboard := buildEditTaskKeyboard(nil, task.Notifications, taskId)
+	users := bot.getFamilyUsers(user)
+	message := getEditingTaskInfo(tr, task.Title, nil, task.Recurrence, filterAssignees(users, task.Assignees))
+	keyboard := buildEditTaskKeyboard(tr, nil, task.Notifications, task.Recurrence, task.Assignees, users, taskId)
 
 	bot.editMessage(chatId, messageId, message, keyboard, "")
+	bot.refreshListMessages(user, 0, 0)
 }
 
-func (bot *Bot) removeTaskTime(chatId int64, messageId int, userTelegramId int, taskId int) {
-	bot.stateService.SetUserState(userTelegramId, st.State{
+func (bot *Bot) removeTaskTime(chatId int64, messageId int, user *units.User, taskId int) {
+	tr := bot.localizer(user)
+	bot.stateService.SetUserState(int(user.TelegramID), st.State{
 		Status: st.STATUS_IDLE,
 	})
-	task, err := bot.taskService.TaskByID(context.Background(), uint(taskId))
+	task, err := bot.getFamilyTask(user, taskId)
 	if err != nil {
 		log.Println(err)
-		bot.sendGeneralError(chatId)
+		bot.sendGeneralError(chatId, user)
 		return
 	}
 
-	oldDate := bot.getDateFromNullString(task.Date)
-	midnight := bot.getMidnightFromDate(oldDate)
-	newDate := sql.NullString{
-		String: midnight.Format(DateWithTimeFormat),
-		Valid:  true,
-	}
-	err = bot.taskService.UpdateTask(context.Background(), task, units.TaskPatch{
+	loc := bot.userLocation(user)
+	midnight := getMidnightFromDate(getDateFromNullTime(task.Date, loc), loc)
+	newDate := getNullTime(midnight)
+	err = bot.taskService.UpdateTask(actorContext(user), task, units.TaskPatch{
 		Date: &newDate,
 	})
 	if err != nil {
 		log.Println(err)
-		bot.sendGeneralError(chatId)
+		bot.sendGeneralError(chatId, user)
 		return
 	}
 
-	message := getEditingTaskInfo(task.Title, midnight)
-	keyboard := buildEditTaskKeyboard(midnight, task.Notifications, taskId)
+	users := bot.getFamilyUsers(user)
+	message := getEditingTaskInfo(tr, task.Title, midnight, task.Recurrence, filterAssignees(users, task.Assignees))
+	keyboard := buildEditTaskKeyboard(tr, midnight, task.Notifications, task.Recurrence, task.Assignees, users, taskId)
 
 	bot.editMessage(chatId, messageId, message, keyboard, "")
+	bot.refreshListMessages(user, 0, 0)
 }
 
-func (bot *Bot) completeTask(chatId int64, messageId int, taskId int) {
-	_, err := bot.taskService.CompleteTask(context.Background(), taskId)
+func (bot *Bot) completeTask(chatId int64, messageId int, user *units.User, taskId int) {
+	task, err := bot.getFamilyTask(user, taskId)
 	if err == nil {
-		message, keyboard := bot.getTasksListWithHeader()
-
-		bot.editMessage(chatId, messageId, message, keyboard, "")
+		_, err = bot.taskService.CompleteTask(actorContext(user), taskId, bot.userLocation(user))
+	}
+	if err == nil {
+		bot.showTaskListInSameMessage(chatId, messageId, user, task.ListID)
+		bot.refreshListMessages(user, chatId, messageId)
 	} else {
 		log.Println(err)
-		bot.sendGeneralError(chatId)
+		bot.sendGeneralError(chatId, user)
 	}
 }
 
-func (bot *Bot) setNotifications(chatId int64, messageId int, userTelegramId int, taskId int, notifications int) {
-	bot.stateService.SetUserState(userTelegramId, st.State{
+func (bot *Bot) setNotifications(chatId int64, messageId int, user *units.User, taskId int, notifications int) {
+	tr := bot.localizer(user)
+	bot.stateService.SetUserState(int(user.TelegramID), st.State{
 		Status: st.STATUS_IDLE,
 	})
-	task, err := bot.taskService.TaskByID(context.Background(), uint(taskId))
+	task, err := bot.getFamilyTask(user, taskId)
 	if err != nil {
 		log.Println(err)
-		bot.sendGeneralError(chatId)
+		bot.sendGeneralError(chatId, user)
 		return
 	}
 
@@ -578,77 +909,267 @@ func (bot *Bot) setNotifications(chatId int64, messageId int, userTelegramId int
 		task.Notifications += notifications
 	}
 
-	err = bot.taskService.UpdateTask(context.Background(), task, units.TaskPatch{
+	err = bot.taskService.UpdateTask(actorContext(user), task, units.TaskPatch{
 		Notifications: &task.Notifications,
 	})
 	if err != nil {
 		log.Println(err)
-		bot.sendGeneralError(chatId)
+		bot.sendGeneralError(chatId, user)
 		return
 	}
 
-	date := bot.getDateFromNullString(task.Date)
-	message := getEditingTaskInfo(task.Title, date)
-	keyboard := buildEditTaskKeyboard(date, task.Notifications, taskId)
+	date := getDateFromNullTime(task.Date, bot.userLocation(user))
+	users := bot.getFamilyUsers(user)
+	message := getEditingTaskInfo(tr, task.Title, date, task.Recurrence, filterAssignees(users, task.Assignees))
+	keyboard := buildEditTaskKeyboard(tr, date, task.Notifications, task.Recurrence, task.Assignees, users, taskId)
 
 	bot.editMessage(chatId, messageId, message, keyboard, "")
 }
 
-func (bot *Bot) removeAllDoneTasks(chatId int64, messageId int) {
-	keyboard := bot.createRemoveAllDoneTasksConfirmationKeyboard()
-	bot.editMessage(chatId, messageId, TextRemoveAllDoneTasksConfirmation, keyboard, "")
+func (bot *Bot) setRecurrence(chatId int64, messageId int, user *units.User, taskId int, option int) {
+	tr := bot.localizer(user)
+	bot.stateService.SetUserState(int(user.TelegramID), st.State{
+		Status: st.STATUS_IDLE,
+	})
+	task, err := bot.getFamilyTask(user, taskId)
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	recurrence := toggleRecurrence(task.Recurrence, option)
+	patch := units.TaskPatch{
+		Recurrence: &recurrence,
+	}
+	if recurrence != units.RecurrenceNone && !task.Date.Valid {
+		// a recurring task needs a date to move forward from
+		now := bot.now()
+		midnight := getNullTime(getMidnightFromDate(&now, bot.userLocation(user)))
+		patch.Date = &midnight
+	}
+
+	err = bot.taskService.UpdateTask(actorContext(user), task, patch)
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	date := getDateFromNullTime(task.Date, bot.userLocation(user))
+	users := bot.getFamilyUsers(user)
+	message := getEditingTaskInfo(tr, task.Title, date, task.Recurrence, filterAssignees(users, task.Assignees))
+	keyboard := buildEditTaskKeyboard(tr, date, task.Notifications, task.Recurrence, task.Assignees, users, taskId)
+
+	bot.editMessage(chatId, messageId, message, keyboard, "")
+	bot.refreshListMessages(user, 0, 0)
 }
 
-func (bot *Bot) deleteTask(chatId int64, messageId int, taskId int) {
-	err := bot.taskService.RemoveByID(context.Background(), taskId)
+func (bot *Bot) toggleTaskAssignee(chatId int64, messageId int, user *units.User, taskId int, userId int) {
+	tr := bot.localizer(user)
+	bot.stateService.SetUserState(int(user.TelegramID), st.State{
+		Status: st.STATUS_IDLE,
+	})
+	task, err := bot.getFamilyTask(user, taskId)
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	users := bot.getFamilyUsers(user)
+	if !isFamilyUser(users, uint(userId)) {
+		log.Printf("user %d is not in the family of task %d", userId, taskId)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	assignees := toggleAssignee(task.Assignees, uint(userId))
+	err = bot.taskService.UpdateTask(actorContext(user), task, units.TaskPatch{
+		Assignees: &assignees,
+	})
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	date := getDateFromNullTime(task.Date, bot.userLocation(user))
+	message := getEditingTaskInfo(tr, task.Title, date, task.Recurrence, filterAssignees(users, task.Assignees))
+	keyboard := buildEditTaskKeyboard(tr, date, task.Notifications, task.Recurrence, task.Assignees, users, taskId)
+
+	bot.editMessage(chatId, messageId, message, keyboard, "")
+	bot.refreshListMessages(user, 0, 0)
+}
+
+func (bot *Bot) completeTaskFromReminder(chatId int64, messageId int, user *units.User, taskId int) {
+	task, err := bot.getFamilyTask(user, taskId)
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	if !task.Done {
+		if _, err := bot.taskService.CompleteTask(actorContext(user), taskId, bot.userLocation(user)); err != nil {
+			log.Println(err)
+			bot.sendGeneralError(chatId, user)
+			return
+		}
+	}
+
+	bot.editMessage(chatId, messageId, bot.localizer(user).T(TextReminderDone, task.Title), nil, "")
+	bot.refreshListMessages(user, 0, 0)
+}
+
+func (bot *Bot) snoozeReminder(chatId int64, messageId int, user *units.User, taskId int, minutes int, reminder string) {
+	tr := bot.localizer(user)
+	task, err := bot.getFamilyTask(user, taskId)
+	if err != nil || minutes <= 0 {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	loc := bot.userLocation(user)
+	remindAt := bot.now().In(loc).Truncate(time.Minute).Add(time.Duration(minutes) * time.Minute)
+	err = bot.notificationService.CreateSnooze(context.Background(), &units.Snooze{
+		TaskID:    task.ID,
+		CreatedBy: user.ID,
+		RemindAt:  remindAt,
+	})
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	when := tr.T(TextAtTime, remindAt.Format(TimeFormat))
+	if !isSameDay(remindAt, bot.now().In(loc)) {
+		when = tr.T(TextTomorrow) + " " + when
+	}
+
+	bot.editMessage(chatId, messageId, tr.T(TextReminderSnoozed, reminder, when), nil, "")
+}
+
+func (bot *Bot) removeAllDoneTasks(chatId int64, messageId int, user *units.User, listId int) {
+	tr := bot.localizer(user)
+	keyboard := bot.createRemoveAllDoneTasksConfirmationKeyboard(tr, listId)
+	bot.editMessage(chatId, messageId, tr.T(TextRemoveAllDoneTasksConfirmation), keyboard, "")
+	bot.forgetListMessage(chatId, messageId)
+}
+
+func (bot *Bot) deleteTask(chatId int64, messageId int, user *units.User, taskId int) {
+	task, err := bot.getFamilyTask(user, taskId)
+	if err == nil {
+		err = bot.taskService.RemoveByID(actorContext(user), taskId)
+	}
 	if err == nil {
-		bot.showTaskListInSameMessage(chatId, messageId)
+		bot.showTaskListWithUndo(chatId, messageId, user, task.ListID)
+		bot.refreshListMessages(user, chatId, messageId)
 	} else {
-		bot.sendGeneralError(chatId)
+		bot.sendGeneralError(chatId, user)
 	}
 }
 
-func (bot *Bot) showTaskListInSameMessage(chatId int64, messageId int) {
-	message, keyboard := bot.getTasksListWithHeader()
+// showTaskListInSameMessage turns the message into the list, zero shows the
+// active list of the user. Only the active list is kept live. Rows are added
+// below the buttons of the list.
+func (bot *Bot) showTaskListInSameMessage(chatId int64, messageId int, user *units.User, listId uint, rows ...[]tgbotapi.InlineKeyboardButton) {
+	active, err := bot.getActiveList(user)
+	list := active
+	if err == nil && listId != 0 && listId != active.ID {
+		list, err = bot.getFamilyListByID(user, listId)
+	}
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
 
+	message, keyboard := bot.getListWithHeader(user, list)
+	if len(rows) > 0 {
+		if keyboard == nil {
+			keyboard = &tgbotapi.InlineKeyboardMarkup{}
+		}
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, rows...)
+	}
 	bot.editMessage(chatId, messageId, message, keyboard, "")
-}
 
-func (bot *Bot) removeAllDoneTasksYes(chatId int64, messageId int) {
-	err := bot.taskService.RemoveCompete(context.Background())
-	if err == nil {
-		message, keyboard := bot.getTasksListWithHeader()
+	if list.ID == active.ID {
+		bot.rememberListMessage(chatId, messageId, user)
+	} else {
+		bot.forgetListMessage(chatId, messageId)
+	}
+}
 
-		bot.editMessage(chatId, messageId, message, keyboard, "")
+func (bot *Bot) removeAllDoneTasksYes(chatId int64, messageId int, user *units.User, listId int) {
+	list, err := bot.getFamilyListByID(user, uint(listId))
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+	err = bot.taskService.RemoveCompete(actorContext(user), list.ID)
+	if err == units.ErrNotFound {
+		// the done tasks have been removed already, e.g. by a second tap
+		bot.showTaskListInSameMessage(chatId, messageId, user, list.ID)
+	} else if err == nil {
+		bot.showTaskListWithUndo(chatId, messageId, user, list.ID)
+		bot.refreshListMessages(user, chatId, messageId)
 	} else {
 		log.Println(err)
-		bot.sendGeneralError(chatId)
+		bot.sendGeneralError(chatId, user)
 	}
 }
 
-func (bot *Bot) editTask(chatId int64, messageId int, taskId int) {
-	task, err := bot.taskService.TaskByID(context.Background(), uint(taskId))
+func (bot *Bot) editTask(chatId int64, messageId int, user *units.User, taskId int) {
+	tr := bot.localizer(user)
+	task, err := bot.getFamilyTask(user, taskId)
 	if err == nil {
-		date := bot.getDateFromNullString(task.Date)
-		message := getEditingTaskInfo(task.Title, date)
-		keyboard := buildEditTaskKeyboard(date, task.Notifications, taskId)
+		date := getDateFromNullTime(task.Date, bot.userLocation(user))
+		users := bot.getFamilyUsers(user)
+		message := getEditingTaskInfo(tr, task.Title, date, task.Recurrence, filterAssignees(users, task.Assignees))
+		keyboard := buildEditTaskKeyboard(tr, date, task.Notifications, task.Recurrence, task.Assignees, users, taskId)
 
 		bot.editMessage(chatId, messageId, message, keyboard, "")
+		bot.forgetListMessage(chatId, messageId)
 	} else {
 		log.Println(err)
-		bot.sendGeneralError(chatId)
+		bot.sendGeneralError(chatId, user)
+	}
+}
+
+// sendEditingTask sends the editing card of the task in a new message.
+func (bot *Bot) sendEditingTask(chatId int64, user *units.User, task *units.Task) {
+	tr := bot.localizer(user)
+	date := getDateFromNullTime(task.Date, bot.userLocation(user))
+	users := bot.getFamilyUsers(user)
+	message := getEditingTaskInfo(tr, task.Title, date, task.Recurrence, filterAssignees(users, task.Assignees))
+	keyboard := buildEditTaskKeyboard(tr, date, task.Notifications, task.Recurrence, task.Assignees, users, int(task.ID))
+
+	bot.sendMessage(chatId, message, keyboard, "")
+}
+
+// finishTaskEditing shows the list of the edited task again.
+func (bot *Bot) finishTaskEditing(chatId int64, messageId int, user *units.User, taskId int) {
+	var listId uint
+	if task, err := bot.getFamilyTask(user, taskId); err == nil {
+		listId = task.ListID
 	}
+
+	bot.showTaskListInSameMessage(chatId, messageId, user, listId)
 }
 
-func (bot *Bot) createRemoveAllDoneTasksConfirmationKeyboard() *tgbotapi.InlineKeyboardMarkup {
-	return bot.createYesNoKeyboard(CQTaskRemoveAllDoneYes, CQTaskRemoveAllDoneNo)
+func (bot *Bot) createRemoveAllDoneTasksConfirmationKeyboard(tr *i18n.Localizer, listId int) *tgbotapi.InlineKeyboardMarkup {
+	return bot.createYesNoKeyboard(tr, fmt.Sprintf(CQTaskRemoveAllDoneYes+":%d", listId), fmt.Sprintf(CQTaskRemoveAllDoneNo+":%d", listId))
 }
 
 // common handlers
-func (bot *Bot) sendGeneralError(chatId int64) {
-	bot.sendMessage(chatId, TextGeneralError, nil, "")
+func (bot *Bot) sendGeneralError(chatId int64, user *units.User) {
+	bot.sendMessage(chatId, bot.localizer(user).T(TextGeneralError), nil, "")
 }
 
-func (bot *Bot) sendParseError(chatId int64) {
-	bot.sendMessage(chatId, TextParseError, nil, "")
+func (bot *Bot) sendParseError(chatId int64, user *units.User) {
+	bot.sendMessage(chatId, bot.localizer(user).T(TextParseError), nil, "")
 }
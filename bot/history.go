@@ -0,0 +1,69 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/maxwww/family_bot/i18n"
+	"github.com/maxwww/family_bot/units"
+)
+
+// taskHistoryLimit is how many of the latest events the history shows.
+const taskHistoryLimit = 20
+
+// showTaskHistory shows who changed the task and when, newest first.
+func (bot *Bot) showTaskHistory(chatId int64, messageId int, user *units.User, taskId int) {
+	tr := bot.localizer(user)
+	task, err := bot.getFamilyTask(user, taskId)
+	var events []*units.TaskEvent
+	if err == nil {
+		events, err = bot.taskService.TaskEvents(context.Background(), units.TaskEventFilter{TaskID: &task.ID, Limit: taskHistoryLimit})
+	}
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	message := tr.T(TextTaskHistoryEmpty, task.Title)
+	if len(events) > 0 {
+		users := bot.getFamilyUsers(user)
+		loc := bot.userLocation(user)
+		message = tr.T(TextTaskHistory, task.Title) + "\n"
+		for _, event := range events {
+			name := tr.T(TextSomeone)
+			for _, member := range users {
+				if event.UserID.Valid && int64(member.ID) == event.UserID.Int64 {
+					name = html.EscapeString(member.FirstName)
+				}
+			}
+			message += fmt.Sprintf("\n%s %s: %s", event.CreatedAt.In(loc).Format(tr.T(TextFormatDateTime)), name, getTaskEventLabel(tr, event))
+		}
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(tr.T(TextActionBack), fmt.Sprintf(CQTaskEdit+":%d", task.ID)),
+	))
+	bot.editMessage(chatId, messageId, message, &keyboard, "")
+}
+
+// getTaskEventLabel describes the event, edits name the changed fields.
+func getTaskEventLabel(tr *i18n.Localizer, event *units.TaskEvent) string {
+	label := tr.T("task_event_" + string(event.Kind))
+	if event.Fields != "" {
+		var fields []string
+		for _, field := range strings.Split(event.Fields, ",") {
+			fields = append(fields, tr.T("task_field_"+field))
+		}
+		label = fmt.Sprintf("%s (%s)", label, strings.Join(fields, ", "))
+	}
+	if event.Undone {
+		label += fmt.Sprintf(" (%s)", tr.T(TextTaskEventUndone))
+	}
+
+	return label
+}
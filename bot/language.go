@@ -0,0 +1,61 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/maxwww/family_bot/i18n"
+	"github.com/maxwww/family_bot/units"
+)
+
+// localizer returns the catalog of the user's language, messages sent before
+// the user is known are in the default one.
+func (bot *Bot) localizer(user *units.User) *i18n.Localizer {
+	if user == nil {
+		return i18n.For(i18n.Default)
+	}
+
+	return i18n.For(user.Language)
+}
+
+func getWeekdayName(tr *i18n.Localizer, day time.Weekday) string {
+	return tr.T("weekday_" + strings.ToLower(day.String()))
+}
+
+func getShortWeekdayName(tr *i18n.Localizer, day time.Weekday) string {
+	return tr.T("weekday_short_" + strings.ToLower(day.String()))
+}
+
+func (bot *Bot) handleLanguageCommand(chatId int64, user *units.User) {
+	tr := bot.localizer(user)
+
+	var buttons []tgbotapi.InlineKeyboardButton
+	for _, lang := range i18n.Languages() {
+		label := i18n.For(lang).T(TextLanguageName)
+		if lang == tr.Lang() {
+			label = TextComplete + " " + label
+		}
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(label, CQSetLanguage+":"+lang))
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(buttons...))
+
+	bot.sendMessage(chatId, tr.T(TextLanguageChoose), &keyboard, "")
+}
+
+func (bot *Bot) setLanguage(chatId int64, messageId int, user *units.User, lang string) {
+	lang = i18n.Match(lang)
+	err := bot.userService.UpdateUser(context.Background(), user, units.UserPatch{
+		Language: &lang,
+	})
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	bot.editMessage(chatId, messageId, bot.localizer(user).T(TextLanguageSet), nil, "")
+	bot.refreshListMessages(user, 0, 0)
+}
@@ -0,0 +1,133 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/maxwww/family_bot/units"
+)
+
+// sendTaskList sends the active list of the user and makes it the live list
+// message of the chat, it is re-rendered whenever family tasks change.
+func (bot *Bot) sendTaskList(chatId int64, user *units.User) {
+	message, keyboard := bot.getActiveListWithHeader(user)
+
+	messageId, err := bot.messenger.SendMessage(chatId, message, keyboard, "html")
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	bot.rememberListMessage(chatId, messageId, user)
+}
+
+// rememberListMessage marks the message as the live list of the chat,
+// in chats with a pinned list the pin moves to the new message.
+func (bot *Bot) rememberListMessage(chatId int64, messageId int, user *units.User) {
+	listMessage, err := bot.getListMessage(chatId)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	previous := listMessage.MessageID
+	listMessage.UserID = user.ID
+	listMessage.MessageID = sql.NullInt64{Int64: int64(messageId), Valid: true}
+
+	err = bot.listMessageService.SaveListMessage(context.Background(), listMessage)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if listMessage.Pinned && previous.Int64 != int64(messageId) {
+		if previous.Valid {
+			bot.unpinMessage(chatId, int(previous.Int64))
+		}
+		bot.pinMessage(chatId, messageId)
+	}
+}
+
+// forgetListMessage stops refreshing the message when it is turned into
+// something else, e.g. into a task editing form.
+func (bot *Bot) forgetListMessage(chatId int64, messageId int) {
+	listMessage, err := bot.getListMessage(chatId)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if !listMessage.MessageID.Valid || listMessage.MessageID.Int64 != int64(messageId) {
+		return
+	}
+
+	listMessage.MessageID = sql.NullInt64{}
+	err = bot.listMessageService.SaveListMessage(context.Background(), listMessage)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// refreshListMessages re-renders live list messages in the chats of every
+// family member, the given message is skipped as it is already up to date.
+func (bot *Bot) refreshListMessages(user *units.User, chatId int64, messageId int) {
+	listMessages, err := bot.listMessageService.ListMessages(context.Background(), uint(user.FamilyID.Int64))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	members := make(map[uint]*units.User)
+	for _, member := range bot.getFamilyUsers(user) {
+		members[member.ID] = member
+	}
+
+	for _, listMessage := range listMessages {
+		if listMessage.ChatID == chatId && listMessage.MessageID.Int64 == int64(messageId) {
+			continue
+		}
+
+		member, ok := members[listMessage.UserID]
+		if !ok {
+			continue
+		}
+
+		message, keyboard := bot.getActiveListWithHeader(member)
+		bot.editMessage(listMessage.ChatID, int(listMessage.MessageID.Int64), message, keyboard, "")
+	}
+}
+
+func (bot *Bot) getActiveListWithHeader(user *units.User) (string, *tgbotapi.InlineKeyboardMarkup) {
+	list, err := bot.getActiveList(user)
+	if err != nil {
+		log.Println(err)
+		return bot.localizer(user).T(TextGeneralError), nil
+	}
+
+	return bot.getListWithHeader(user, list)
+}
+
+func (bot *Bot) getListMessage(chatId int64) (*units.ListMessage, error) {
+	listMessage, err := bot.listMessageService.ListMessageByChatID(context.Background(), chatId)
+	if err == units.ErrNotFound {
+		return &units.ListMessage{ChatID: chatId}, nil
+	}
+
+	return listMessage, err
+}
+
+func (bot *Bot) pinMessage(chatId int64, messageId int) {
+	err := bot.messenger.PinMessage(chatId, messageId)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func (bot *Bot) unpinMessage(chatId int64, messageId int) {
+	err := bot.messenger.UnpinMessage(chatId, messageId)
+	if err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,267 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	st "github.com/maxwww/family_bot/state"
+	"github.com/maxwww/family_bot/units"
+)
+
+// handleListsCommand shows lists of the family, a name in the arguments
+// creates a new list and makes it active.
+func (bot *Bot) handleListsCommand(chatId int64, user *units.User, args string) {
+	tr := bot.localizer(user)
+	name := trim(args)
+	if name == "" {
+		bot.sendMessage(chatId, tr.T(TextListsHeader), bot.buildListsKeyboard(user), "")
+		return
+	}
+
+	if findListByName(bot.getFamilyLists(user), name) != nil {
+		bot.sendMessage(chatId, tr.T(TextListExists, name), nil, "")
+		return
+	}
+
+	list := &units.List{
+		FamilyID: uint(user.FamilyID.Int64),
+		Name:     name,
+		Kind:     units.ListKindTasks,
+	}
+	if err := bot.listService.CreateList(context.Background(), list); err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	if err := bot.setActiveList(user, list.ID); err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	bot.sendTaskList(chatId, user)
+}
+
+// getFamilyLists returns lists of the user's family, the task list is
+// created when the family has none so there is always one to switch back to.
+func (bot *Bot) getFamilyLists(user *units.User) []*units.List {
+	if _, err := bot.getFamilyList(user, units.ListKindTasks); err != nil {
+		log.Println(err)
+	}
+
+	familyID := uint(user.FamilyID.Int64)
+	lists, err := bot.listService.Lists(context.Background(), units.ListFilter{FamilyID: &familyID})
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	return lists
+}
+
+func (bot *Bot) buildListsKeyboard(user *units.User) *tgbotapi.InlineKeyboardMarkup {
+	var activeID uint
+	if active, err := bot.getActiveList(user); err == nil {
+		activeID = active.ID
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, list := range bot.getFamilyLists(user) {
+		checkBox := TextCheckbox
+		if list.ID == activeID {
+			checkBox = TextComplete
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(checkBox+" "+list.Name, fmt.Sprintf(CQListSwitch+":%d", list.ID)),
+			tgbotapi.NewInlineKeyboardButtonData(TextSettings, fmt.Sprintf(CQListEdit+":%d", list.ID)),
+		))
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	return &keyboard
+}
+
+func (bot *Bot) setActiveList(user *units.User, listId uint) error {
+	activeListID := sql.NullInt64{Int64: int64(listId), Valid: true}
+
+	return bot.userService.UpdateUser(context.Background(), user, units.UserPatch{
+		ActiveListID: &activeListID,
+	})
+}
+
+func (bot *Bot) showLists(chatId int64, messageId int, user *units.User) {
+	bot.editMessage(chatId, messageId, bot.localizer(user).T(TextListsHeader), bot.buildListsKeyboard(user), "")
+}
+
+// switchList makes the list active and shows it in place of the lists keyboard.
+func (bot *Bot) switchList(chatId int64, messageId int, user *units.User, listId int) {
+	list, err := bot.getFamilyListByID(user, uint(listId))
+	if err == nil {
+		err = bot.setActiveList(user, list.ID)
+	}
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	bot.showTaskListInSameMessage(chatId, messageId, user, list.ID)
+	bot.refreshListMessages(user, chatId, messageId)
+}
+
+func (bot *Bot) editList(chatId int64, messageId int, user *units.User, listId int) {
+	tr := bot.localizer(user)
+	list, err := bot.getFamilyListByID(user, uint(listId))
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	buttons := []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData(tr.T(TextActionEditTitle), fmt.Sprintf(CQListRename+":%d", list.ID)),
+	}
+	if !bot.isFamilyTaskList(user, list) {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(tr.T(TextActionDelete), fmt.Sprintf(CQListDelete+":%d", list.ID)))
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(buttons...),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(tr.T(TextActionBack), CQListsShow)),
+	)
+
+	bot.editMessage(chatId, messageId, tr.T(TextListEditing, list.Name), &keyboard, "")
+	bot.forgetListMessage(chatId, messageId)
+}
+
+// isFamilyTaskList reports whether the list is the one tasks go to by default,
+// it cannot be removed.
+func (bot *Bot) isFamilyTaskList(user *units.User, list *units.List) bool {
+	defaultList, err := bot.getFamilyList(user, units.ListKindTasks)
+
+	return err == nil && defaultList.ID == list.ID
+}
+
+func (bot *Bot) renameList(chatId int64, messageId int, user *units.User, listId int) {
+	list, err := bot.getFamilyListByID(user, uint(listId))
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	bot.stateService.SetUserState(int(user.TelegramID), st.State{
+		Status: st.STATUS_EDIT_LIST_WAIT_NAME,
+		Task: st.Task{
+			ListID: list.ID,
+		},
+	})
+
+	bot.editMessage(chatId, messageId, bot.localizer(user).T(TextSendNewListName, list.Name), nil, "MarkDown")
+}
+
+func (bot *Bot) handleEditListName(chatId int64, user *units.User, message string, listId uint) {
+	tr := bot.localizer(user)
+	list, err := bot.getFamilyListByID(user, listId)
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	name := trim(message)
+	if name == "" {
+		bot.sendParseError(chatId, user)
+		return
+	}
+	if existing := findListByName(bot.getFamilyLists(user), name); existing != nil && existing.ID != list.ID {
+		bot.sendMessage(chatId, tr.T(TextListExists, name), nil, "")
+		return
+	}
+
+	bot.stateService.SetUserState(int(user.TelegramID), st.State{
+		Status: st.STATUS_IDLE,
+	})
+
+	err = bot.listService.UpdateList(context.Background(), list, units.ListPatch{
+		Name: &name,
+	})
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	bot.sendMessage(chatId, tr.T(TextListsHeader), bot.buildListsKeyboard(user), "")
+	bot.refreshListMessages(user, 0, 0)
+}
+
+func (bot *Bot) deleteList(chatId int64, messageId int, user *units.User, listId int) {
+	tr := bot.localizer(user)
+	list, err := bot.getFamilyListByID(user, uint(listId))
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	keyboard := bot.createYesNoKeyboard(tr, fmt.Sprintf(CQListDeleteYes+":%d", list.ID), fmt.Sprintf(CQListEdit+":%d", list.ID))
+	bot.editMessage(chatId, messageId, tr.T(TextRemoveListConfirmation, list.Name), keyboard, "")
+}
+
+func (bot *Bot) deleteListYes(chatId int64, messageId int, user *units.User, listId int) {
+	list, err := bot.getFamilyListByID(user, uint(listId))
+	if err == nil && bot.isFamilyTaskList(user, list) {
+		err = units.ErrNotFound
+	}
+	if err == nil {
+		err = bot.listService.RemoveByID(context.Background(), list.ID)
+	}
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	if user.ActiveListID.Valid && uint(user.ActiveListID.Int64) == list.ID {
+		if err := bot.userService.UpdateUser(context.Background(), user, units.UserPatch{ActiveListID: &sql.NullInt64{}}); err != nil {
+			log.Println(err)
+		}
+	}
+
+	bot.showLists(chatId, messageId, user)
+	bot.refreshListMessages(user, 0, 0)
+}
+
+// findListByName matches names ignoring the case, nil means there is no such list.
+func findListByName(lists []*units.List, name string) *units.List {
+	for _, list := range lists {
+		if strings.EqualFold(list.Name, name) {
+			return list
+		}
+	}
+
+	return nil
+}
+
+// splitListTag returns the list name of a "#tag" at the start of the message
+// and the rest of it, underscores of the tag stand for spaces.
+func splitListTag(message string) (string, string) {
+	message = strings.TrimSpace(message)
+	if !strings.HasPrefix(message, "#") {
+		return "", message
+	}
+
+	tag, rest := message[1:], ""
+	if i := strings.IndexFunc(tag, unicode.IsSpace); i >= 0 {
+		tag, rest = tag[:i], tag[i:]
+	}
+
+	return strings.ReplaceAll(tag, "_", " "), strings.TrimSpace(rest)
+}
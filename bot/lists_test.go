@@ -0,0 +1,23 @@
+package bot
+
+import "testing"
+
+func TestSplitListTag(t *testing.T) {
+	tests := []struct {
+		message string
+		tag     string
+		rest    string
+	}{
+		{"купити хліб", "", "купити хліб"},
+		{"#Дача полити квіти", "Дача", "полити квіти"},
+		{" #Ремонт_кухні  фарба ", "Ремонт кухні", "фарба"},
+		{"#Дача", "Дача", ""},
+	}
+
+	for _, tt := range tests {
+		tag, rest := splitListTag(tt.message)
+		if tag != tt.tag || rest != tt.rest {
+			t.Errorf("splitListTag(%q) = %q, %q, want %q, %q", tt.message, tag, rest, tt.tag, tt.rest)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/maxwww/family_bot/units"
+)
+
+// Messenger delivers the bot's output, it keeps handlers independent of Telegram.
+type Messenger interface {
+	// SendMessage returns the ID of the sent message.
+	SendMessage(chatId int64, text string, keyboard *tgbotapi.InlineKeyboardMarkup, parseMode string) (int, error)
+	EditMessage(chatId int64, messageId int, text string, keyboard *tgbotapi.InlineKeyboardMarkup, parseMode string) error
+	DeleteMessage(chatId int64, messageId int) error
+	AnswerCallback(callbackId string, text string) error
+	PinMessage(chatId int64, messageId int) error
+	UnpinMessage(chatId int64, messageId int) error
+	// SendFile sends a file Telegram already keeps again.
+	SendFile(chatId int64, kind units.AttachmentKind, fileId string) error
+	// SendDocument uploads the content as a file with the given name.
+	SendDocument(chatId int64, name string, data []byte) error
+	// DownloadFile returns the content of a file sent to the bot.
+	DownloadFile(fileId string) ([]byte, error)
+}
+
+// MaxDownloadSize limits files the bot reads, bigger ones are rejected.
+const MaxDownloadSize = 1 << 20
+
+var _ Messenger = (*TelegramMessenger)(nil)
+
+type TelegramMessenger struct {
+	api *tgbotapi.BotAPI
+}
+
+func NewTelegramMessenger(api *tgbotapi.BotAPI) *TelegramMessenger {
+	return &TelegramMessenger{api: api}
+}
+
+func (m *TelegramMessenger) SendMessage(chatId int64, text string, keyboard *tgbotapi.InlineKeyboardMarkup, parseMode string) (int, error) {
+	msg := tgbotapi.NewMessage(chatId, text)
+	msg.ParseMode = parseMode
+	if keyboard != nil && len(keyboard.InlineKeyboard) > 0 {
+		msg.ReplyMarkup = keyboard
+	}
+
+	sent, err := m.api.Send(msg)
+	if err != nil {
+		return 0, err
+	}
+
+	return sent.MessageID, nil
+}
+
+func (m *TelegramMessenger) EditMessage(chatId int64, messageId int, text string, keyboard *tgbotapi.InlineKeyboardMarkup, parseMode string) error {
+	msg := tgbotapi.NewEditMessageText(chatId, messageId, text)
+	msg.ParseMode = parseMode
+	if keyboard != nil && len(keyboard.InlineKeyboard) > 0 {
+		msg.ReplyMarkup = keyboard
+	}
+
+	_, err := m.api.Request(msg)
+	if err != nil && strings.Contains(err.Error(), "message is not modified") {
+		// re-rendering a list which did not change is not an error
+		return nil
+	}
+
+	return err
+}
+
+func (m *TelegramMessenger) DeleteMessage(chatId int64, messageId int) error {
+	_, err := m.api.Request(tgbotapi.NewDeleteMessage(chatId, messageId))
+
+	return err
+}
+
+func (m *TelegramMessenger) AnswerCallback(callbackId string, text string) error {
+	_, err := m.api.Request(tgbotapi.NewCallback(callbackId, text))
+
+	return err
+}
+
+func (m *TelegramMessenger) PinMessage(chatId int64, messageId int) error {
+	_, err := m.api.Request(tgbotapi.PinChatMessageConfig{
+		ChatID:              chatId,
+		MessageID:           messageId,
+		DisableNotification: true,
+	})
+
+	return err
+}
+
+func (m *TelegramMessenger) UnpinMessage(chatId int64, messageId int) error {
+	_, err := m.api.Request(tgbotapi.UnpinChatMessageConfig{
+		ChatID:    chatId,
+		MessageID: messageId,
+	})
+
+	return err
+}
+
+func (m *TelegramMessenger) SendFile(chatId int64, kind units.AttachmentKind, fileId string) error {
+	var msg tgbotapi.Chattable = tgbotapi.NewDocument(chatId, tgbotapi.FileID(fileId))
+	if kind == units.AttachmentPhoto {
+		msg = tgbotapi.NewPhoto(chatId, tgbotapi.FileID(fileId))
+	}
+
+	_, err := m.api.Send(msg)
+
+	return err
+}
+
+func (m *TelegramMessenger) SendDocument(chatId int64, name string, data []byte) error {
+	_, err := m.api.Send(tgbotapi.NewDocument(chatId, tgbotapi.FileBytes{Name: name, Bytes: data}))
+
+	return err
+}
+
+func (m *TelegramMessenger) DownloadFile(fileId string) ([]byte, error) {
+	address, err := m.api.GetFileDirectURL(fileId)
+	if err != nil {
+		return nil, err
+	}
+
+	// the address holds the bot token, errors must not carry it to the logs
+	resp, err := http.Get(address)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("download file %s: %w", fileId, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download file %s: %s", fileId, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxDownloadSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("download file %s: %w", fileId, err)
+	}
+	if len(data) > MaxDownloadSize {
+		return nil, fmt.Errorf("file is larger than %d bytes", MaxDownloadSize)
+	}
+
+	return data, nil
+}
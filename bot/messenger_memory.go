@@ -0,0 +1,201 @@
+package bot
+
+import (
+	"errors"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/maxwww/family_bot/units"
+)
+
+const (
+	SentAction     = "send"
+	EditedAction   = "edit"
+	DeletedAction  = "delete"
+	AnsweredAction = "answer"
+	PinnedAction   = "pin"
+	UnpinnedAction = "unpin"
+	FileAction     = "file"
+	DocumentAction = "document"
+)
+
+// RecordedMessage is a single call made to MemoryMessenger.
+type RecordedMessage struct {
+	Action     string
+	ChatID     int64
+	MessageID  int
+	Text       string
+	Keyboard   *tgbotapi.InlineKeyboardMarkup
+	ParseMode  string
+	CallbackID string
+	// Data is the content of an uploaded document.
+	Data []byte
+}
+
+var _ Messenger = (*MemoryMessenger)(nil)
+
+// MemoryMessenger records everything the bot outputs instead of sending it,
+// it is meant for tests and local runs.
+type MemoryMessenger struct {
+	mu            sync.Mutex
+	lastMessageID int
+	records       []RecordedMessage
+	files         map[string][]byte
+}
+
+func NewMemoryMessenger() *MemoryMessenger {
+	return &MemoryMessenger{}
+}
+
+func (m *MemoryMessenger) SendMessage(chatId int64, text string, keyboard *tgbotapi.InlineKeyboardMarkup, parseMode string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastMessageID++
+	m.records = append(m.records, RecordedMessage{
+		Action:    SentAction,
+		ChatID:    chatId,
+		MessageID: m.lastMessageID,
+		Text:      text,
+		Keyboard:  keyboard,
+		ParseMode: parseMode,
+	})
+
+	return m.lastMessageID, nil
+}
+
+func (m *MemoryMessenger) EditMessage(chatId int64, messageId int, text string, keyboard *tgbotapi.InlineKeyboardMarkup, parseMode string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.records = append(m.records, RecordedMessage{
+		Action:    EditedAction,
+		ChatID:    chatId,
+		MessageID: messageId,
+		Text:      text,
+		Keyboard:  keyboard,
+		ParseMode: parseMode,
+	})
+
+	return nil
+}
+
+func (m *MemoryMessenger) DeleteMessage(chatId int64, messageId int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.records = append(m.records, RecordedMessage{
+		Action:    DeletedAction,
+		ChatID:    chatId,
+		MessageID: messageId,
+	})
+
+	return nil
+}
+
+func (m *MemoryMessenger) AnswerCallback(callbackId string, text string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.records = append(m.records, RecordedMessage{
+		Action:     AnsweredAction,
+		Text:       text,
+		CallbackID: callbackId,
+	})
+
+	return nil
+}
+
+func (m *MemoryMessenger) PinMessage(chatId int64, messageId int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.records = append(m.records, RecordedMessage{
+		Action:    PinnedAction,
+		ChatID:    chatId,
+		MessageID: messageId,
+	})
+
+	return nil
+}
+
+func (m *MemoryMessenger) UnpinMessage(chatId int64, messageId int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.records = append(m.records, RecordedMessage{
+		Action:    UnpinnedAction,
+		ChatID:    chatId,
+		MessageID: messageId,
+	})
+
+	return nil
+}
+
+// SendFile records the file id as the text.
+func (m *MemoryMessenger) SendFile(chatId int64, kind units.AttachmentKind, fileId string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.records = append(m.records, RecordedMessage{
+		Action: FileAction,
+		ChatID: chatId,
+		Text:   fileId,
+	})
+
+	return nil
+}
+
+// SendDocument records the name of the document as the text.
+func (m *MemoryMessenger) SendDocument(chatId int64, name string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.records = append(m.records, RecordedMessage{
+		Action: DocumentAction,
+		ChatID: chatId,
+		Text:   name,
+		Data:   data,
+	})
+
+	return nil
+}
+
+// AddFile makes the content available to DownloadFile under the file id.
+func (m *MemoryMessenger) AddFile(fileId string, data []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.files == nil {
+		m.files = map[string][]byte{}
+	}
+	m.files[fileId] = data
+}
+
+func (m *MemoryMessenger) DownloadFile(fileId string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, ok := m.files[fileId]
+	if !ok {
+		return nil, errors.New("unknown file " + fileId)
+	}
+
+	return data, nil
+}
+
+// Records returns a copy of everything recorded so far.
+func (m *MemoryMessenger) Records() []RecordedMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]RecordedMessage(nil), m.records...)
+}
+
+// Reset forgets the recorded calls.
+func (m *MemoryMessenger) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.records = nil
+}
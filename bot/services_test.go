@@ -0,0 +1,991 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maxwww/family_bot/units"
+)
+
+// in-memory services used by the handler tests instead of Postgres
+
+var _ units.UserService = (*memoryUserService)(nil)
+
+type memoryUserService struct {
+	mu     sync.Mutex
+	lastID uint
+	users  map[uint]*units.User
+}
+
+func newMemoryUserService() *memoryUserService {
+	return &memoryUserService{users: map[uint]*units.User{}}
+}
+
+func (s *memoryUserService) CreateUser(_ context.Context, user *units.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.TelegramID == user.TelegramID {
+			return units.ErrDuplicateID
+		}
+	}
+
+	s.lastID++
+	user.ID = s.lastID
+	stored := *user
+	s.users[user.ID] = &stored
+
+	return nil
+}
+
+func (s *memoryUserService) UserByTelegramID(_ context.Context, telegramID uint) (*units.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.TelegramID == telegramID {
+			user := *u
+			return &user, nil
+		}
+	}
+
+	return nil, units.ErrNotFound
+}
+
+func (s *memoryUserService) Users(_ context.Context, filter units.UserFilter) ([]*units.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []*units.User
+	for _, u := range s.users {
+		if filter.TelegramID != nil && u.TelegramID != *filter.TelegramID {
+			continue
+		}
+		if filter.FamilyID != nil && (!u.FamilyID.Valid || uint(u.FamilyID.Int64) != *filter.FamilyID) {
+			continue
+		}
+		user := *u
+		result = append(result, &user)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+
+	return result, nil
+}
+
+func (s *memoryUserService) UpdateUser(_ context.Context, user *units.User, patch units.UserPatch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.users[user.ID]
+	if !ok {
+		return units.ErrNotFound
+	}
+
+	if v := patch.FirstName; v != nil {
+		user.FirstName = *v
+	}
+	if v := patch.LastName; v != nil {
+		user.LastName = *v
+	}
+	if v := patch.UserName; v != nil {
+		user.UserName = *v
+	}
+	if v := patch.Notifications; v != nil {
+		user.Notifications = *v
+	}
+	if v := patch.FamilyID; v != nil {
+		user.FamilyID = *v
+	}
+	if v := patch.Timezone; v != nil {
+		user.Timezone = *v
+	}
+	if v := patch.Language; v != nil {
+		user.Language = *v
+	}
+	if v := patch.ActiveListID; v != nil {
+		user.ActiveListID = *v
+	}
+	*stored = *user
+
+	return nil
+}
+
+var _ units.TaskService = (*memoryTaskService)(nil)
+
+type memoryTaskService struct {
+	mu         sync.Mutex
+	lastID     uint
+	lastItemID uint
+	lastFileID uint
+	tasks      map[uint]*units.Task
+	events     []*units.TaskEvent
+}
+
+func newMemoryTaskService() *memoryTaskService {
+	return &memoryTaskService{tasks: map[uint]*units.Task{}}
+}
+
+func (s *memoryTaskService) CreateTask(ctx context.Context, task *units.Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	task.ID = s.lastID
+	if userID, ok := units.ActorFromContext(ctx); ok {
+		task.CreatedBy = sql.NullInt64{Int64: int64(userID), Valid: true}
+	}
+	stored := *task
+	stored.Attachments = nil
+	for _, attachment := range task.Attachments {
+		s.lastFileID++
+		attachment.ID, attachment.TaskID = s.lastFileID, task.ID
+		copied := *attachment
+		stored.Attachments = append(stored.Attachments, &copied)
+	}
+	s.tasks[task.ID] = &stored
+	s.record(ctx, task, units.TaskEventCreated, nil)
+
+	return nil
+}
+
+// record adds an event of the task, the lock has to be held.
+func (s *memoryTaskService) record(ctx context.Context, task *units.Task, kind units.TaskEventKind, previous *units.TaskPatch, fields ...string) *units.TaskEvent {
+	event := &units.TaskEvent{
+		ID:        uint(len(s.events) + 1),
+		TaskID:    task.ID,
+		FamilyID:  task.FamilyID,
+		Kind:      kind,
+		Title:     task.Title,
+		Fields:    strings.Join(fields, ","),
+		CreatedAt: time.Now(),
+	}
+	if userID, ok := units.ActorFromContext(ctx); ok {
+		event.UserID = sql.NullInt64{Int64: int64(userID), Valid: true}
+	}
+	if previous != nil {
+		data, _ := json.Marshal(previous)
+		event.Previous = string(data)
+	}
+	s.events = append(s.events, event)
+
+	return event
+}
+
+func (s *memoryTaskService) TaskEvents(_ context.Context, filter units.TaskEventFilter) ([]*units.TaskEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []*units.TaskEvent
+	for i := len(s.events) - 1; i >= 0; i-- {
+		event := s.events[i]
+		if filter.TaskID != nil && event.TaskID != *filter.TaskID {
+			continue
+		}
+		if filter.FamilyID != nil && event.FamilyID != *filter.FamilyID {
+			continue
+		}
+		copied := *event
+		result = append(result, &copied)
+		if filter.Limit > 0 && len(result) == filter.Limit {
+			break
+		}
+	}
+
+	return result, nil
+}
+
+func (s *memoryTaskService) TaskByID(_ context.Context, id uint) (*units.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tasks[id]
+	if !ok || t.DeletedAt.Valid {
+		return nil, units.ErrNotFound
+	}
+
+	return copyTask(t), nil
+}
+
+// copyTask copies the checklist too so callers cannot change stored items.
+func copyTask(t *units.Task) *units.Task {
+	task := *t
+	task.Items = nil
+	for _, item := range t.Items {
+		copied := *item
+		task.Items = append(task.Items, &copied)
+	}
+
+	return &task
+}
+
+func (s *memoryTaskService) Tasks(_ context.Context, filter units.TaskFilter) ([]*units.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []*units.Task
+	for _, t := range s.tasks {
+		if t.DeletedAt.Valid {
+			continue
+		}
+		if filter.Id != nil && t.ID != *filter.Id {
+			continue
+		}
+		if filter.Done != nil && t.Done != *filter.Done {
+			continue
+		}
+		if filter.FamilyID != nil && t.FamilyID != *filter.FamilyID {
+			continue
+		}
+		if filter.ListID != nil && t.ListID != *filter.ListID {
+			continue
+		}
+		if filter.AssigneeID != nil && !containsID(t.Assignees, *filter.AssigneeID) {
+			continue
+		}
+		if (filter.From != nil || filter.To != nil || filter.HasDate != nil && *filter.HasDate) && !t.Date.Valid {
+			continue
+		}
+		if filter.HasDate != nil && !*filter.HasDate && t.Date.Valid {
+			continue
+		}
+		if filter.From != nil && t.Date.Time.Before(*filter.From) {
+			continue
+		}
+		if filter.To != nil && !t.Date.Time.Before(*filter.To) {
+			continue
+		}
+		if q := filter.Query; q != nil && !strings.Contains(strings.ToLower(t.Title+"\n"+t.Notes), strings.ToLower(*q)) {
+			continue
+		}
+		result = append(result, copyTask(t))
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		switch filter.OrderBy {
+		case units.TaskOrderDate:
+			if a.Date.Valid != b.Date.Valid {
+				return a.Date.Valid
+			}
+			if !a.Date.Time.Equal(b.Date.Time) {
+				return a.Date.Time.Before(b.Date.Time)
+			}
+		case units.TaskOrderTitle:
+			if !strings.EqualFold(a.Title, b.Title) {
+				return strings.ToLower(a.Title) < strings.ToLower(b.Title)
+			}
+		}
+		return a.ID < b.ID
+	})
+
+	if filter.Offset > 0 {
+		if filter.Offset > len(result) {
+			filter.Offset = len(result)
+		}
+		result = result[filter.Offset:]
+	}
+	if filter.Limit > 0 && filter.Limit < len(result) {
+		result = result[:filter.Limit]
+	}
+
+	return result, nil
+}
+
+func containsID(ids []uint, id uint) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *memoryTaskService) UpdateTask(ctx context.Context, task *units.Task, patch units.TaskPatch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.tasks[task.ID]
+	if !ok {
+		return units.ErrNotFound
+	}
+
+	previous, fields := applyTaskPatch(task, patch)
+	items, attachments := stored.Items, stored.Attachments
+	*stored = *task
+	stored.Items, stored.Attachments = items, attachments
+	s.record(ctx, stored, units.TaskEventEdited, &previous, fields...)
+
+	return nil
+}
+
+// applyTaskPatch returns the patch restoring the replaced values and names
+// of the changed fields.
+func applyTaskPatch(task *units.Task, patch units.TaskPatch) (units.TaskPatch, []string) {
+	var previous units.TaskPatch
+	var fields []string
+	if v := patch.Done; v != nil {
+		done := task.Done
+		previous.Done = &done
+		task.Done = *v
+		if !task.Done {
+			task.CompletedBy = sql.NullInt64{}
+		}
+		fields = append(fields, units.TaskFieldDone)
+	}
+	if v := patch.Title; v != nil {
+		title := task.Title
+		previous.Title = &title
+		task.Title = *v
+		fields = append(fields, units.TaskFieldTitle)
+	}
+	if v := patch.Date; v != nil {
+		date := task.Date
+		previous.Date = &date
+		task.Date = *v
+		fields = append(fields, units.TaskFieldDate)
+	}
+	if v := patch.Notifications; v != nil {
+		notifications := task.Notifications
+		previous.Notifications = &notifications
+		task.Notifications = *v
+		fields = append(fields, units.TaskFieldNotifications)
+	}
+	if v := patch.Recurrence; v != nil {
+		recurrence := task.Recurrence
+		previous.Recurrence = &recurrence
+		task.Recurrence = *v
+		fields = append(fields, units.TaskFieldRecurrence)
+	}
+	if v := patch.Assignees; v != nil {
+		assignees := append([]uint{}, task.Assignees...)
+		previous.Assignees = &assignees
+		task.Assignees = *v
+		fields = append(fields, units.TaskFieldAssignees)
+	}
+	if v := patch.Notes; v != nil {
+		notes := task.Notes
+		previous.Notes = &notes
+		task.Notes = *v
+		fields = append(fields, units.TaskFieldNotes)
+	}
+	if v := patch.DoneItems; v != nil {
+		doneItems := []uint{}
+		for _, item := range task.Items {
+			if item.Done {
+				doneItems = append(doneItems, item.ID)
+			}
+			item.Done = containsID(*v, item.ID)
+		}
+		previous.DoneItems = &doneItems
+		fields = append(fields, units.TaskFieldItems)
+	}
+
+	return previous, fields
+}
+
+func (s *memoryTaskService) CompleteTask(ctx context.Context, id int, _ *time.Location) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.tasks[uint(id)]
+	if !ok || task.DeletedAt.Valid {
+		return false, units.ErrNotFound
+	}
+	wasDone := task.Done
+	task.Done = !task.Done
+	task.CompletedBy = sql.NullInt64{}
+	kind := units.TaskEventReopened
+	if task.Done {
+		kind = units.TaskEventCompleted
+		if userID, ok := units.ActorFromContext(ctx); ok {
+			task.CompletedBy = sql.NullInt64{Int64: int64(userID), Valid: true}
+		}
+	}
+	s.record(ctx, task, kind, &units.TaskPatch{Done: &wasDone})
+
+	return task.Done, nil
+}
+
+func (s *memoryTaskService) RemoveCompete(ctx context.Context, listID uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// events of one change share the time like in a transaction
+	now := time.Now()
+	removed := 0
+	for _, t := range s.tasks {
+		if t.Done && t.ListID == listID && !t.DeletedAt.Valid {
+			s.record(ctx, t, units.TaskEventDeleted, nil).CreatedAt = now
+			t.DeletedAt = sql.NullTime{Time: now, Valid: true}
+			removed++
+		}
+	}
+	if removed == 0 {
+		return units.ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *memoryTaskService) removeList(listID uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, t := range s.tasks {
+		if t.ListID == listID {
+			delete(s.tasks, id)
+		}
+	}
+}
+
+func (s *memoryTaskService) RemoveByID(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.tasks[uint(id)]
+	if !ok || task.DeletedAt.Valid {
+		return units.ErrNotFound
+	}
+	s.record(ctx, task, units.TaskEventDeleted, nil)
+	task.DeletedAt = sql.NullTime{Time: time.Now(), Valid: true}
+
+	return nil
+}
+
+func (s *memoryTaskService) UndoLast(ctx context.Context, since time.Time) ([]*units.TaskEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	userID, ok := units.ActorFromContext(ctx)
+	if !ok {
+		return nil, units.ErrNotFound
+	}
+
+	var result []*units.TaskEvent
+	for i := len(s.events) - 1; i >= 0; i-- {
+		event := s.events[i]
+		if !event.UserID.Valid || event.UserID.Int64 != int64(userID) || event.Undone {
+			continue
+		}
+		if event.Kind != units.TaskEventCreated && event.Kind != units.TaskEventDeleted && event.Previous == "" {
+			continue
+		}
+		if len(result) > 0 && !event.CreatedAt.Equal(result[0].CreatedAt) {
+			break
+		}
+		if event.CreatedAt.Before(since) {
+			break
+		}
+
+		if task, ok := s.tasks[event.TaskID]; ok {
+			switch event.Kind {
+			case units.TaskEventCreated:
+				task.DeletedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			case units.TaskEventDeleted:
+				task.DeletedAt = sql.NullTime{}
+			default:
+				var previous units.TaskPatch
+				if err := json.Unmarshal([]byte(event.Previous), &previous); err != nil {
+					return nil, err
+				}
+				applyTaskPatch(task, previous)
+			}
+		}
+		copied := *event
+		result = append(result, &copied)
+		event.Undone = true
+	}
+	if len(result) == 0 {
+		return nil, units.ErrNotFound
+	}
+
+	return result, nil
+}
+
+func (s *memoryTaskService) PurgeDeleted(_ context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, t := range s.tasks {
+		if t.DeletedAt.Valid && t.DeletedAt.Time.Before(before) {
+			delete(s.tasks, id)
+		}
+	}
+
+	return nil
+}
+
+func (s *memoryTaskService) AddTaskAttachment(ctx context.Context, attachment *units.Attachment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.tasks[attachment.TaskID]
+	if !ok {
+		return units.ErrNotFound
+	}
+	s.lastFileID++
+	attachment.ID = s.lastFileID
+	stored := *attachment
+	task.Attachments = append(task.Attachments, &stored)
+	s.record(ctx, task, units.TaskEventEdited, nil, units.TaskFieldAttachments)
+
+	return nil
+}
+
+func (s *memoryTaskService) AddTaskItems(ctx context.Context, taskID uint, titles []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.tasks[taskID]
+	if !ok {
+		return units.ErrNotFound
+	}
+	for _, title := range titles {
+		s.lastItemID++
+		task.Items = append(task.Items, &units.TaskItem{
+			ID:       s.lastItemID,
+			TaskID:   taskID,
+			Title:    title,
+			Position: len(task.Items) + 1,
+		})
+	}
+	s.record(ctx, task, units.TaskEventEdited, nil, units.TaskFieldItems)
+
+	return nil
+}
+
+func (s *memoryTaskService) ToggleTaskItem(ctx context.Context, taskID uint, itemID uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if task, ok := s.tasks[taskID]; ok {
+		s.record(ctx, task, units.TaskEventEdited, nil, units.TaskFieldItems)
+		for _, item := range task.Items {
+			if item.ID == itemID {
+				item.Done = !item.Done
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *memoryTaskService) RemoveTaskItem(ctx context.Context, taskID uint, itemID uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if task, ok := s.tasks[taskID]; ok {
+		s.record(ctx, task, units.TaskEventEdited, nil, units.TaskFieldItems)
+		for i, item := range task.Items {
+			if item.ID == itemID {
+				task.Items = append(task.Items[:i], task.Items[i+1:]...)
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+var _ units.ListService = (*memoryListService)(nil)
+
+type memoryListService struct {
+	mu     sync.Mutex
+	lastID uint
+	lists  map[uint]*units.List
+	// tasks lose their list like the foreign key removes them in Postgres
+	tasks *memoryTaskService
+}
+
+func newMemoryListService(tasks *memoryTaskService) *memoryListService {
+	return &memoryListService{lists: map[uint]*units.List{}, tasks: tasks}
+}
+
+func (s *memoryListService) CreateList(_ context.Context, list *units.List) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.createList(list)
+
+	return nil
+}
+
+func (s *memoryListService) createList(list *units.List) {
+	s.lastID++
+	list.ID = s.lastID
+	list.CreatedAt = time.Now()
+	stored := *list
+	s.lists[list.ID] = &stored
+}
+
+func (s *memoryListService) ListByID(_ context.Context, id uint) (*units.List, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.lists[id]
+	if !ok {
+		return nil, units.ErrNotFound
+	}
+	list := *l
+
+	return &list, nil
+}
+
+func (s *memoryListService) Lists(_ context.Context, filter units.ListFilter) ([]*units.List, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.findLists(filter), nil
+}
+
+func (s *memoryListService) findLists(filter units.ListFilter) []*units.List {
+	var result []*units.List
+	for _, l := range s.lists {
+		if filter.Id != nil && l.ID != *filter.Id {
+			continue
+		}
+		if filter.FamilyID != nil && l.FamilyID != *filter.FamilyID {
+			continue
+		}
+		if filter.Kind != nil && l.Kind != *filter.Kind {
+			continue
+		}
+		list := *l
+		result = append(result, &list)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[:filter.Limit]
+	}
+
+	return result
+}
+
+func (s *memoryListService) UpdateList(_ context.Context, list *units.List, patch units.ListPatch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.lists[list.ID]
+	if !ok {
+		return units.ErrNotFound
+	}
+
+	if v := patch.Name; v != nil {
+		list.Name = *v
+	}
+	*stored = *list
+
+	return nil
+}
+
+func (s *memoryListService) RemoveByID(_ context.Context, id uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.lists, id)
+	if s.tasks != nil {
+		s.tasks.removeList(id)
+	}
+
+	return nil
+}
+
+func (s *memoryListService) DefaultList(_ context.Context, familyID uint, kind units.ListKind, name string) (*units.List, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if lists := s.findLists(units.ListFilter{FamilyID: &familyID, Kind: &kind, Limit: 1}); len(lists) > 0 {
+		return lists[0], nil
+	}
+
+	list := &units.List{FamilyID: familyID, Name: name, Kind: kind}
+	s.createList(list)
+
+	return list, nil
+}
+
+var _ units.FamilyService = (*memoryFamilyService)(nil)
+
+type memoryFamilyService struct {
+	mu       sync.Mutex
+	lastID   uint
+	families map[uint]*units.Family
+	invites  map[string]*units.FamilyInvite
+	users    *memoryUserService
+}
+
+func newMemoryFamilyService(users *memoryUserService) *memoryFamilyService {
+	return &memoryFamilyService{
+		families: map[uint]*units.Family{},
+		invites:  map[string]*units.FamilyInvite{},
+		users:    users,
+	}
+}
+
+func (s *memoryFamilyService) CreateFamily(_ context.Context, family *units.Family) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	family.ID = s.lastID
+	family.CreatedAt = time.Now()
+	stored := *family
+	s.families[family.ID] = &stored
+
+	return nil
+}
+
+func (s *memoryFamilyService) FamilyByID(_ context.Context, id uint) (*units.Family, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.families[id]
+	if !ok {
+		return nil, units.ErrNotFound
+	}
+	family := *f
+
+	return &family, nil
+}
+
+func (s *memoryFamilyService) Families(_ context.Context, filter units.FamilyFilter) ([]*units.Family, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []*units.Family
+	for _, f := range s.families {
+		if filter.Id != nil && f.ID != *filter.Id {
+			continue
+		}
+		if filter.CalendarToken != nil && (!f.CalendarToken.Valid || f.CalendarToken.String != *filter.CalendarToken) {
+			continue
+		}
+		family := *f
+		result = append(result, &family)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[:filter.Limit]
+	}
+
+	return result, nil
+}
+
+func (s *memoryFamilyService) CreateInvite(_ context.Context, invite *units.FamilyInvite) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *invite
+	s.invites[invite.Code] = &stored
+
+	return nil
+}
+
+func (s *memoryFamilyService) RedeemInvite(ctx context.Context, code string, user *units.User) (*units.Family, error) {
+	s.mu.Lock()
+	invite, ok := s.invites[code]
+	if ok {
+		delete(s.invites, code)
+	}
+	s.mu.Unlock()
+
+	if !ok || invite.ExpiresAt.Before(time.Now()) {
+		return nil, units.ErrNotFound
+	}
+
+	familyID := sql.NullInt64{Int64: int64(invite.FamilyID), Valid: true}
+	if err := s.users.UpdateUser(ctx, user, units.UserPatch{FamilyID: &familyID}); err != nil {
+		return nil, err
+	}
+
+	return s.FamilyByID(ctx, invite.FamilyID)
+}
+
+func (s *memoryFamilyService) SetCalendarToken(_ context.Context, familyID uint, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.families[familyID]
+	if !ok {
+		return units.ErrNotFound
+	}
+	f.CalendarToken = sql.NullString{String: token, Valid: true}
+
+	return nil
+}
+
+var _ units.NotificationService = (*memoryNotificationService)(nil)
+
+type memoryNotificationService struct {
+	mu      sync.Mutex
+	ticks   map[string]time.Time
+	sent    map[string]bool
+	lastID  uint
+	snoozes map[uint]*units.Snooze
+}
+
+func newMemoryNotificationService() *memoryNotificationService {
+	return &memoryNotificationService{
+		ticks:   map[string]time.Time{},
+		sent:    map[string]bool{},
+		snoozes: map[uint]*units.Snooze{},
+	}
+}
+
+func (s *memoryNotificationService) LastTick(_ context.Context, name string) (*time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tick, ok := s.ticks[name]
+	if !ok {
+		return nil, nil
+	}
+
+	return &tick, nil
+}
+
+func (s *memoryNotificationService) SetLastTick(_ context.Context, name string, tick time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ticks[name] = tick
+
+	return nil
+}
+
+func (s *memoryNotificationService) MarkSent(_ context.Context, taskID uint, offset int, dueAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := fmt.Sprintf("%d:%d:%s", taskID, offset, dueAt)
+	if s.sent[key] {
+		return false, nil
+	}
+	s.sent[key] = true
+
+	return true, nil
+}
+
+func (s *memoryNotificationService) CreateSnooze(_ context.Context, snooze *units.Snooze) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	snooze.ID = s.lastID
+	stored := *snooze
+	s.snoozes[snooze.ID] = &stored
+
+	return nil
+}
+
+func (s *memoryNotificationService) DueSnoozes(_ context.Context, until time.Time) ([]*units.Snooze, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []*units.Snooze
+	for _, snooze := range s.snoozes {
+		if !snooze.RemindAt.After(until) {
+			stored := *snooze
+			result = append(result, &stored)
+		}
+	}
+
+	return result, nil
+}
+
+func (s *memoryNotificationService) MarkSnoozeSent(_ context.Context, id uint) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.snoozes[id]; !ok {
+		return false, nil
+	}
+	delete(s.snoozes, id)
+
+	return true, nil
+}
+
+var _ units.ListMessageService = (*memoryListMessageService)(nil)
+
+type memoryListMessageService struct {
+	mu       sync.Mutex
+	messages map[int64]*units.ListMessage
+	users    *memoryUserService
+}
+
+func newMemoryListMessageService(users *memoryUserService) *memoryListMessageService {
+	return &memoryListMessageService{
+		messages: map[int64]*units.ListMessage{},
+		users:    users,
+	}
+}
+
+func (s *memoryListMessageService) ListMessageByChatID(_ context.Context, chatID int64) (*units.ListMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.messages[chatID]
+	if !ok {
+		return nil, units.ErrNotFound
+	}
+	message := *m
+
+	return &message, nil
+}
+
+func (s *memoryListMessageService) ListMessages(ctx context.Context, familyID uint) ([]*units.ListMessage, error) {
+	members, err := s.users.Users(ctx, units.UserFilter{FamilyID: &familyID})
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []*units.ListMessage
+	for _, m := range s.messages {
+		if !m.MessageID.Valid {
+			continue
+		}
+		for _, member := range members {
+			if member.ID == m.UserID {
+				message := *m
+				result = append(result, &message)
+			}
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ChatID < result[j].ChatID
+	})
+
+	return result, nil
+}
+
+func (s *memoryListMessageService) SaveListMessage(_ context.Context, message *units.ListMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	message.UpdatedAt = time.Now()
+	stored := *message
+	s.messages[message.ChatID] = &stored
+
+	return nil
+}
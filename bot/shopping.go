@@ -0,0 +1,164 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/maxwww/family_bot/i18n"
+	"github.com/maxwww/family_bot/shopping"
+	"github.com/maxwww/family_bot/units"
+)
+
+// handleShopCommand adds items of the arguments to the family shopping list
+// and shows it, without arguments the list is only shown.
+func (bot *Bot) handleShopCommand(chatId int64, user *units.User, args string) {
+	list, err := bot.getFamilyList(user, units.ListKindShopping)
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	if err := bot.addShoppingItems(user, list, args); err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	message, keyboard := bot.getShoppingListWithHeader(user, list)
+	bot.sendMessage(chatId, message, keyboard, "")
+}
+
+// addShoppingItems adds every item of the message to the list.
+func (bot *Bot) addShoppingItems(user *units.User, list *units.List, message string) error {
+	for _, text := range shopping.Split(message) {
+		item := shopping.Parse(text)
+		task := &units.Task{
+			Title:    item.Title,
+			FamilyID: list.FamilyID,
+			ListID:   list.ID,
+			Unit:     item.Unit,
+			Category: string(item.Category),
+		}
+		if item.Quantity > 0 {
+			task.Quantity = sql.NullFloat64{Float64: item.Quantity, Valid: true}
+		}
+
+		if err := bot.taskService.CreateTask(actorContext(user), task); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getShoppingListWithHeader renders items of the list grouped by store sections,
+// bought items stay crossed out until they are cleared.
+func (bot *Bot) getShoppingListWithHeader(user *units.User, list *units.List) (string, *tgbotapi.InlineKeyboardMarkup) {
+	tr := bot.localizer(user)
+	tasks, err := bot.taskService.Tasks(context.Background(), units.TaskFilter{ListID: &list.ID})
+	if err != nil {
+		log.Println(err)
+	}
+	if len(tasks) == 0 {
+		return tr.T(TextShoppingListEmpty), nil
+	}
+
+	message := tr.T(TextShoppingListHeader) + "\n"
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	hasDone := false
+	number := 0
+
+	for _, category := range shopping.Categories {
+		var items []*units.Task
+		for _, task := range tasks {
+			if getItemCategory(task) == category {
+				items = append(items, task)
+			}
+		}
+		if len(items) == 0 {
+			continue
+		}
+
+		message += fmt.Sprintf("\n<b>%s</b>\n", getCategoryLabel(tr, category))
+		for _, item := range items {
+			number++
+			checkBox := TextCheckbox
+			line := fmt.Sprintf("%d. %s", number, item.Title)
+			if quantity := shopping.FormatQuantity(item.Quantity.Float64, item.Unit); quantity != "" {
+				line += " " + quantity
+			}
+			if item.Done {
+				checkBox = TextComplete
+				hasDone = true
+				line = "<s>" + line + "</s>"
+			}
+			message += line + "\n"
+
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d %s", number, checkBox), fmt.Sprintf(CQShopItemComplete+":%d", item.ID)))
+			if len(row) == 5 {
+				buttons = append(buttons, row)
+				row = []tgbotapi.InlineKeyboardButton{}
+			}
+		}
+	}
+	if len(row) > 0 {
+		buttons = append(buttons, row)
+	}
+	if hasDone {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(tr.T(TextActionRemoveAllBought), fmt.Sprintf(CQShopRemoveAllBought+":%d", list.ID)),
+		))
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
+
+	return message, &keyboard
+}
+
+// getItemCategory returns the store section of the item, categories which
+// are no longer known fall back to the other one.
+func getItemCategory(task *units.Task) shopping.Category {
+	for _, category := range shopping.Categories {
+		if string(category) == task.Category {
+			return category
+		}
+	}
+
+	return shopping.CategoryOther
+}
+
+func getCategoryLabel(tr *i18n.Localizer, category shopping.Category) string {
+	if category == shopping.CategoryOther {
+		return tr.T(TextCategoryOther)
+	}
+
+	return tr.T("category_" + string(category))
+}
+
+func (bot *Bot) completeShoppingItem(chatId int64, messageId int, user *units.User, taskId int) {
+	task, err := bot.getFamilyTask(user, taskId)
+	if err == nil {
+		_, err = bot.taskService.CompleteTask(actorContext(user), taskId, bot.userLocation(user))
+	}
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	bot.showTaskListInSameMessage(chatId, messageId, user, task.ListID)
+}
+
+// removeAllBoughtItems asks before clearing, the answer is handled by the
+// flow of removing done tasks.
+func (bot *Bot) removeAllBoughtItems(chatId int64, messageId int, user *units.User, listId int) {
+	tr := bot.localizer(user)
+	keyboard := bot.createYesNoKeyboard(tr, fmt.Sprintf(CQShopRemoveAllBoughtYes+":%d", listId), fmt.Sprintf(CQShopRemoveAllBoughtNo+":%d", listId))
+	bot.editMessage(chatId, messageId, tr.T(TextRemoveAllBoughtConfirmation), keyboard, "")
+	bot.forgetListMessage(chatId, messageId)
+}
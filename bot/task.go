@@ -1,9 +1,11 @@
 package bot
 
 import (
-	"database/sql"
+	"crypto/rand"
+	"encoding/base32"
 	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/maxwww/family_bot/i18n"
 	st "github.com/maxwww/family_bot/state"
 	"github.com/maxwww/family_bot/units"
 	"strings"
@@ -17,46 +19,64 @@ const (
 	InstantlyNotification
 )
 
+// snoozeOptions are delays in minutes offered on reminder messages.
+var snoozeOptions = []int{10, 60, 24 * 60}
+
+var recurrenceOptions = []units.Recurrence{
+	units.RecurrenceDaily,
+	units.RecurrenceWeekly,
+	units.RecurrenceMonthly,
+	units.RecurrenceYearly,
+}
+
 func createTaskStateWithDate(task *st.Task) *units.Task {
 	newTask := units.Task{
 		Title:         task.Title,
-		Date:          sql.NullString{},
+		Date:          getNullTime(task.Date),
 		Done:          false,
 		Notifications: task.Notifications,
-	}
-
-	if task.Date != nil {
-		newTask.Date = sql.NullString{
-			String: task.Date.Format(DateWithTimeFormat),
-			Valid:  true,
-		}
+		Recurrence:    task.Recurrence,
+		Assignees:     task.Assignees,
+		Attachments:   task.Attachments,
 	}
 
 	return &newTask
 }
 
-func getSavedTaskInfo(title string, date *time.Time) string {
-	return getOneTaskInfo(TextNewTaskAdded, title, date)
+func getSavedTaskInfo(tr *i18n.Localizer, title string, date *time.Time, recurrence units.Recurrence, assignees []*units.User) string {
+	return getOneTaskInfo(tr, tr.T(TextNewTaskAdded), title, date, recurrence, assignees)
 }
 
-func getEditingTaskInfo(title string, date *time.Time) string {
-	return getOneTaskInfo(TextTaskEditing, title, date)
+func getEditingTaskInfo(tr *i18n.Localizer, title string, date *time.Time, recurrence units.Recurrence, assignees []*units.User) string {
+	return getOneTaskInfo(tr, tr.T(TextTaskEditing), title, date, recurrence, assignees)
 }
 
-func getNewTaskInfo(title string, date *time.Time) string {
-	return getOneTaskInfo(TextNewTaskEditing, title, date)
+func getNewTaskInfo(tr *i18n.Localizer, title string, date *time.Time, recurrence units.Recurrence, assignees []*units.User) string {
+	return getOneTaskInfo(tr, tr.T(TextNewTaskEditing), title, date, recurrence, assignees)
 }
 
-func getOneTaskInfo(header string, title string, date *time.Time) string {
-	dayString, timeString := getDayAndTime(date)
-	return fmt.Sprintf("%s\n\n%s", header, getTaskDescription(title, dayString, timeString))
+func getOneTaskInfo(tr *i18n.Localizer, header string, title string, date *time.Time, recurrence units.Recurrence, assignees []*units.User) string {
+	dayString, timeString := getDayAndTime(tr, date)
+	info := fmt.Sprintf("%s\n\n%s", header, getTaskDescription(tr, title, dayString, timeString))
+	if recurrence != units.RecurrenceNone {
+		info += tr.T(TextTaskRecurrence, getRecurrenceLabel(tr, recurrence))
+	}
+	if len(assignees) > 0 {
+		var names []string
+		for _, user := range assignees {
+			names = append(names, user.FirstName)
+		}
+		info += tr.T(TextTaskAssignees, strings.Join(names, ", "))
+	}
+
+	return info
 }
 
-func getDayAndTime(date *time.Time) (string, string) {
+func getDayAndTime(tr *i18n.Localizer, date *time.Time) (string, string) {
 	dayString := "-"
 	timeString := "-"
 	if date != nil {
-		dayString = date.Format(DateFormatUA)
+		dayString = date.Format(tr.T(TextFormatDate))
 		if date.Hour() != 0 || date.Minute() != 0 {
 			timeString = date.Format(TimeFormat)
 		}
@@ -65,20 +85,22 @@ func getDayAndTime(date *time.Time) (string, string) {
 	return dayString, timeString
 }
 
-func getTaskDescription(title, dayString, timeString string) string {
-	return fmt.Sprintf(TextTaskDescription, title, dayString, timeString)
+func getTaskDescription(tr *i18n.Localizer, title, dayString, timeString string) string {
+	return tr.T(TextTaskDescription, title, dayString, timeString)
 }
 
-func buildEditTaskKeyboard(date *time.Time, notifications int, taskId int) *tgbotapi.InlineKeyboardMarkup {
+func buildEditTaskKeyboard(tr *i18n.Localizer, date *time.Time, notifications int, recurrence units.Recurrence, assignees []uint, users []*units.User, taskId int) *tgbotapi.InlineKeyboardMarkup {
 	editDayData := fmt.Sprintf(CQTaskEditEditDay+":%d", taskId)
 	removeDayData := fmt.Sprintf(CQTaskEditRemoveDay+":%d", taskId)
 	editTimeData := fmt.Sprintf(CQTaskEditEditTime+":%d", taskId)
 	removeTimeData := fmt.Sprintf(CQTaskEditRemoveTime+":%d", taskId)
-	OKData := CQTaskEditOk
+	OKData := fmt.Sprintf(CQTaskEditOk+":%d", taskId)
 	editTitleData := fmt.Sprintf(CQTaskEditEditTitle+":%d", taskId)
 	cancelDeleteData := fmt.Sprintf(CQTaskEditDeleteTask+":%d", taskId)
 	setNotifications := fmt.Sprintf(CQTaskEditSetNotifications+":%d", taskId)
-	cancelDeleteAction := TextActionDelete
+	setRecurrence := fmt.Sprintf(CQTaskEditSetRecurrence+":%d", taskId)
+	toggleAssignee := fmt.Sprintf(CQTaskEditToggleAssignee+":%d", taskId)
+	cancelDeleteAction := tr.T(TextActionDelete)
 
 	if taskId == 0 {
 		editDayData = CQNewTaskEditDay
@@ -88,16 +110,18 @@ func buildEditTaskKeyboard(date *time.Time, notifications int, taskId int) *tgbo
 		OKData = CQNewTaskSave
 		editTitleData = CQNewTaskEditTitle
 		cancelDeleteData = CQNewTaskCancel
-		cancelDeleteAction = TextActionCancel
+		cancelDeleteAction = tr.T(TextActionCancel)
 		setNotifications = CQNewTaskSetNotifications + ":"
+		setRecurrence = CQNewTaskSetRecurrence + ":"
+		toggleAssignee = CQNewTaskToggleAssignee + ":"
 	}
 
-	dateButtons := []tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardButtonData(TextActionEditDay, editDayData)}
+	dateButtons := []tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardButtonData(tr.T(TextActionEditDay), editDayData)}
 	if date != nil {
-		dateButtons = append(dateButtons, tgbotapi.NewInlineKeyboardButtonData(TextActionRemoveDay, removeDayData))
-		dateButtons = append(dateButtons, tgbotapi.NewInlineKeyboardButtonData(TextActionEditTime, editTimeData))
+		dateButtons = append(dateButtons, tgbotapi.NewInlineKeyboardButtonData(tr.T(TextActionRemoveDay), removeDayData))
+		dateButtons = append(dateButtons, tgbotapi.NewInlineKeyboardButtonData(tr.T(TextActionEditTime), editTimeData))
 		if date.Hour() != 0 || date.Minute() != 0 {
-			dateButtons = append(dateButtons, tgbotapi.NewInlineKeyboardButtonData(TextActionRemoveTime, removeTimeData))
+			dateButtons = append(dateButtons, tgbotapi.NewInlineKeyboardButtonData(tr.T(TextActionRemoveTime), removeTimeData))
 		}
 	}
 	var notificationsButtons []tgbotapi.InlineKeyboardButton
@@ -107,13 +131,33 @@ func buildEditTaskKeyboard(date *time.Time, notifications int, taskId int) *tgbo
 		if (notifications & v) != 0 {
 			checkBox = TextComplete
 		}
-		notificationsButtons = append(notificationsButtons, tgbotapi.NewInlineKeyboardButtonData(checkBox+" "+getNotificationLabel(v), fmt.Sprintf(setNotifications+":%d", v)))
+		notificationsButtons = append(notificationsButtons, tgbotapi.NewInlineKeyboardButtonData(checkBox+" "+getNotificationLabel(tr, v), fmt.Sprintf(setNotifications+":%d", v)))
 	}
 
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+	var recurrenceButtons []tgbotapi.InlineKeyboardButton
+
+	for i, v := range recurrenceOptions {
+		checkBox := TextCheckbox
+		if recurrence.Base() == v {
+			checkBox = TextComplete
+		}
+		recurrenceButtons = append(recurrenceButtons, tgbotapi.NewInlineKeyboardButtonData(checkBox+" "+getRecurrenceLabel(tr, v), fmt.Sprintf(setRecurrence+":%d", i)))
+	}
+
+	var assigneeButtons []tgbotapi.InlineKeyboardButton
+
+	for _, user := range users {
+		checkBox := TextCheckbox
+		if isAssigned(assignees, user.ID) {
+			checkBox = TextComplete
+		}
+		assigneeButtons = append(assigneeButtons, tgbotapi.NewInlineKeyboardButtonData(checkBox+" "+user.FirstName, fmt.Sprintf(toggleAssignee+":%d", user.ID)))
+	}
+
+	rows := [][]tgbotapi.InlineKeyboardButton{
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(TextActionOk, OKData),
-			tgbotapi.NewInlineKeyboardButtonData(TextActionEditTitle, editTitleData),
+			tgbotapi.NewInlineKeyboardButtonData(tr.T(TextActionOk), OKData),
+			tgbotapi.NewInlineKeyboardButtonData(tr.T(TextActionEditTitle), editTitleData),
 		),
 		tgbotapi.NewInlineKeyboardRow(
 			dateButtons...,
@@ -122,13 +166,64 @@ func buildEditTaskKeyboard(date *time.Time, notifications int, taskId int) *tgbo
 			notificationsButtons...,
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(cancelDeleteAction, cancelDeleteData),
+			recurrenceButtons...,
 		),
+	}
+	if len(assigneeButtons) > 0 {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(assigneeButtons...))
+	}
+	// notes, checklist entries and files belong to a saved task
+	if taskId != 0 {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(tr.T(TextActionNotes), fmt.Sprintf(CQTaskEditNotes+":%d", taskId)),
+			tgbotapi.NewInlineKeyboardButtonData(tr.T(TextActionItems), fmt.Sprintf(CQTaskEditItems+":%d", taskId)),
+			tgbotapi.NewInlineKeyboardButtonData(tr.T(TextActionAttachments), fmt.Sprintf(CQTaskEditAttachments+":%d", taskId)),
+		))
+	}
+	lastRow := tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(cancelDeleteAction, cancelDeleteData),
 	)
+	if taskId != 0 {
+		lastRow = append(lastRow, tgbotapi.NewInlineKeyboardButtonData(tr.T(TextActionHistory), fmt.Sprintf(CQTaskEditHistory+":%d", taskId)))
+	}
+	rows = append(rows, lastRow)
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
 
 	return &keyboard
 }
 
+func buildReminderKeyboard(tr *i18n.Localizer, taskId uint) *tgbotapi.InlineKeyboardMarkup {
+	var snoozeButtons []tgbotapi.InlineKeyboardButton
+	for _, minutes := range snoozeOptions {
+		snoozeButtons = append(snoozeButtons, tgbotapi.NewInlineKeyboardButtonData(getSnoozeLabel(tr, minutes), fmt.Sprintf(CQReminderSnooze+":%d:%d", taskId, minutes)))
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(tr.T(TextActionReminderDone), fmt.Sprintf(CQReminderDone+":%d", taskId)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			snoozeButtons...,
+		),
+	)
+
+	return &keyboard
+}
+
+func getSnoozeLabel(tr *i18n.Localizer, minutes int) string {
+	switch minutes {
+	case 10:
+		return tr.T(TextActionSnoozeTenMinutes)
+	case 60:
+		return tr.T(TextActionSnoozeOneHour)
+	case 24 * 60:
+		return tr.T(TextActionSnoozeTomorrow)
+	}
+
+	return tr.T(TextActionSnoozeMinutes, minutes)
+}
+
 func trim(input string) (out string) {
 	out = strings.Trim(input, " ")
 	out = spaceRe.ReplaceAllString(out, " ")
@@ -136,17 +231,114 @@ func trim(input string) (out string) {
 	return
 }
 
-func getNotificationLabel(notification int) string {
+func getNotificationLabel(tr *i18n.Localizer, notification int) string {
 	switch notification {
 	case OneHourNotification:
-		return TextNotificationOneHour
+		return tr.T(TextNotificationOneHour)
 	case ThirtyMinutesNotification:
-		return TextNotificationThirtyMinutes
+		return tr.T(TextNotificationThirtyMinutes)
 	case FiveMinutesNotification:
-		return TextNotificationFiveMinutes
+		return tr.T(TextNotificationFiveMinutes)
 	case InstantlyNotification:
-		return TextNotificationInstantly
+		return tr.T(TextNotificationInstantly)
 	}
 
 	return ""
 }
+
+func getRecurrenceLabel(tr *i18n.Localizer, recurrence units.Recurrence) string {
+	switch recurrence.Base() {
+	case units.RecurrenceDaily:
+		return tr.T(TextRecurrenceDaily)
+	case units.RecurrenceWeekly:
+		return tr.T(TextRecurrenceWeekly)
+	case units.RecurrenceMonthly:
+		return tr.T(TextRecurrenceMonthly)
+	case units.RecurrenceYearly:
+		return tr.T(TextRecurrenceYearly)
+	}
+
+	var days []string
+	for _, day := range recurrence.Weekdays() {
+		days = append(days, getShortWeekdayName(tr, day))
+	}
+
+	return strings.Join(days, ", ")
+}
+
+// toggleRecurrence returns the recurrence option chosen on the keyboard,
+// tapping the already selected option switches recurrence off.
+func toggleRecurrence(current units.Recurrence, option int) units.Recurrence {
+	if option < 0 || option >= len(recurrenceOptions) || current.Base() == recurrenceOptions[option] {
+		return units.RecurrenceNone
+	}
+
+	return recurrenceOptions[option]
+}
+
+func isAssigned(assignees []uint, userId uint) bool {
+	for _, id := range assignees {
+		if id == userId {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isFamilyUser tells whether the user is one of the users of the family,
+// assignees come from callback data which cannot be trusted.
+func isFamilyUser(users []*units.User, userId uint) bool {
+	for _, user := range users {
+		if user.ID == userId {
+			return true
+		}
+	}
+
+	return false
+}
+
+// toggleAssignee adds the user to the assignees or removes them when already assigned.
+func toggleAssignee(assignees []uint, userId uint) []uint {
+	result := []uint{}
+	for _, id := range assignees {
+		if id != userId {
+			result = append(result, id)
+		}
+	}
+	if len(result) == len(assignees) {
+		result = append(result, userId)
+	}
+
+	return result
+}
+
+// filterAssignees returns users assigned to the task keeping the order of users.
+func filterAssignees(users []*units.User, assignees []uint) []*units.User {
+	var result []*units.User
+	for _, user := range users {
+		if isAssigned(assignees, user.ID) {
+			result = append(result, user)
+		}
+	}
+
+	return result
+}
+
+func getUserInitial(user *units.User) string {
+	for _, r := range user.FirstName {
+		return strings.ToUpper(string(r))
+	}
+
+	return TextAssignee
+}
+
+// generateInviteCode returns a random code which is easy to retype from a screen.
+func generateInviteCode() (string, error) {
+	buf := make([]byte, 5)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return base32.StdEncoding.EncodeToString(buf), nil
+}
@@ -1,53 +1,145 @@
 package bot
 
+// icons look the same in every language
 const (
-	TextActionOk                 = "✅ OK"
-	TextActionYes                = "✅ Так"
-	TextActionNo                 = "❌ Ні"
-	TextActionEditTitle          = "✏ назву"
-	TextActionCancel             = "❌ Відмінити"
-	TextActionDelete             = "❌ Видалити"
-	TextActionEditDay            = "✏ дату"
-	TextActionRemoveDay          = "❌ дату"
-	TextActionEditTime           = "✏ час"
-	TextActionRemoveTime         = "❌ час"
-	TextCheckbox                 = "☑"
-	TextComplete                 = "✅"
-	TextSettings                 = "⚙"
-	TextActionRemoveAllDoneTasks = "❌ Видалити всі виконані справи"
-
-	TextGeneralError                   = "Сталася помилка. Спробуйте пізніше."
-	TextParseError                     = "Вибач, але я не розумію."
-	TextNewTaskAdded                   = "Додано нову справу:"
-	TextTaskDescription                = "Назва: %s\nДень: %s\nЧас: %s"
-	TextSendNewTitle                   = "Стара назва - `%s`\nНапишіть мені нову назву справи"
-	TextSendNewDay                     = "Напишіть мені нову дату справи"
-	TextSendNewTime                    = "Напишіть мені новий час справи"
-	TextTasksListHeader                = "Ось список усіх справ:"
-	TextTasksListEmpty                 = "Задачі відсутні"
-	TextToday                          = "сьогодні"
-	TextTomorrow                       = "завтра"
-	TextTodayDate                      = "До речі сьогодні %s %s"
-	TextRemoveAllDoneTasksConfirmation = "Видалити всі виконані справи?"
-	TextTaskEditing                    = "Редагування справи:"
-	TextNewTaskEditing                 = "Нова справа. Перевірьте заповнені поля та натисніть OK:"
-	TextUnknownCommand                 = "На жаль, я не знаю такої команди. Скористайтеся меню або довідкою - /help"
-	TextInOneHour                      = "Справа \"%s\" через одну годину"
-	TextInThirtyMinutes                = "Справа \"%s\" через 30 хв"
-	TextInFiveMinutes                  = "Справа \"%s\" через 5 хв"
-	TextInInstantly                    = "Справа \"%s\" розпочалася"
-	TextCancel                         = "Охрана, отмєна"
-	TextSubscriptionsOn                = "Сповіщення увімкнено.\nАби вимкунити сповіщення скористайся /unsubscribe командою."
-	TextSubscriptionsOff               = "Сповіщення вимкнуто.\nАби увімкнути сповіщення скористайся /subscribe командою."
-	TextNotificationOneHour            = "1 год"
-	TextNotificationThirtyMinutes      = "30 хв"
-	TextNotificationFiveMinutes        = "5 хв"
-	TextNotificationInstantly          = "0 хв"
-	TextStartMessage                   = `Я, 🤖. Я можу допомагати тобі слідкувати за сімейними справами.
-
-Ось список моїх команд:
-/list - переглянути список сімейних справ
-/cancel - відмінити поточну операцію
+	TextCheckbox   = "☑"
+	TextComplete   = "✅"
+	TextSettings   = "⚙"
+	TextRecurring  = "🔁"
+	TextAssignee   = "👤"
+	TextNotes      = "📝"
+	TextAttachment = "📎"
+)
 
-Залишились питання чи є пропозиція? Звертайся до цього контакту - @msfilo`
+// message keys of the i18n catalogs
+const (
+	TextActionOk                       = "action_ok"
+	TextActionYes                      = "action_yes"
+	TextActionNo                       = "action_no"
+	TextActionEditTitle                = "action_edit_title"
+	TextActionCancel                   = "action_cancel"
+	TextActionDelete                   = "action_delete"
+	TextActionEditDay                  = "action_edit_day"
+	TextActionRemoveDay                = "action_remove_day"
+	TextActionEditTime                 = "action_edit_time"
+	TextActionRemoveTime               = "action_remove_time"
+	TextActionRemoveAllDoneTasks       = "action_remove_all_done_tasks"
+	TextActionReminderDone             = "action_reminder_done"
+	TextActionSnoozeTenMinutes         = "action_snooze_ten_minutes"
+	TextActionSnoozeOneHour            = "action_snooze_one_hour"
+	TextActionSnoozeTomorrow           = "action_snooze_tomorrow"
+	TextActionSnoozeMinutes            = "action_snooze_minutes"
+	TextGeneralError                   = "general_error"
+	TextParseError                     = "parse_error"
+	TextNewTaskAdded                   = "new_task_added"
+	TextTaskDescription                = "task_description"
+	TextTaskRecurrence                 = "task_recurrence"
+	TextTaskAssignees                  = "task_assignees"
+	TextSendNewTitle                   = "send_new_title"
+	TextSendNewDay                     = "send_new_day"
+	TextSendNewTime                    = "send_new_time"
+	TextTasksListHeader                = "tasks_list_header"
+	TextTasksListEmpty                 = "tasks_list_empty"
+	TextToday                          = "today"
+	TextTomorrow                       = "tomorrow"
+	TextAtTime                         = "at_time"
+	TextTodayDate                      = "today_date"
+	TextRemoveAllDoneTasksConfirmation = "remove_all_done_tasks_confirmation"
+	TextTaskEditing                    = "task_editing"
+	TextNewTaskEditing                 = "new_task_editing"
+	TextUnknownCommand                 = "unknown_command"
+	TextReminderNow                    = "reminder_now"
+	TextMissedNotification             = "missed_notification"
+	TextSnoozedNotification            = "snoozed_notification"
+	TextReminderDone                   = "reminder_done"
+	TextReminderSnoozed                = "reminder_snoozed"
+	TextCancel                         = "cancel"
+	TextSubscriptionsOn                = "subscriptions_on"
+	TextSubscriptionsOff               = "subscriptions_off"
+	TextDefaultFamilyName              = "default_family_name"
+	TextJoinRequired                   = "join_required"
+	TextJoinUsage                      = "join_usage"
+	TextInviteCreated                  = "invite_created"
+	TextInviteInvalid                  = "invite_invalid"
+	TextJoinedFamily                   = "joined_family"
+	TextListUnpinned                   = "list_unpinned"
+	TextTimezoneCurrent                = "timezone_current"
+	TextTimezoneChoose                 = "timezone_choose"
+	TextTimezoneNotFound               = "timezone_not_found"
+	TextTimezoneSet                    = "timezone_set"
+	TextNotificationOneHour            = "notification_one_hour"
+	TextNotificationThirtyMinutes      = "notification_thirty_minutes"
+	TextNotificationFiveMinutes        = "notification_five_minutes"
+	TextNotificationInstantly          = "notification_instantly"
+	TextRecurrenceDaily                = "recurrence_daily"
+	TextRecurrenceWeekly               = "recurrence_weekly"
+	TextRecurrenceMonthly              = "recurrence_monthly"
+	TextRecurrenceYearly               = "recurrence_yearly"
+	TextStartMessage                   = "start_message"
+	TextReminderInHours                = "reminder_in_hours"
+	TextReminderInMinutes              = "reminder_in_minutes"
+	TextLanguageName                   = "language_name"
+	TextLanguageChoose                 = "language_choose"
+	TextLanguageSet                    = "language_set"
+	TextFormatDate                     = "format_date"
+	TextFormatDateTime                 = "format_date_time"
+	TextListNameTasks                  = "list_name_tasks"
+	TextListNameShopping               = "list_name_shopping"
+	TextShoppingListHeader             = "shopping_list_header"
+	TextShoppingListEmpty              = "shopping_list_empty"
+	TextActionRemoveAllBought          = "action_remove_all_bought"
+	TextRemoveAllBoughtConfirmation    = "remove_all_bought_confirmation"
+	TextCategoryOther                  = "category_other"
+	TextListHeader                     = "list_header"
+	TextListEmpty                      = "list_empty"
+	TextListsHeader                    = "lists_header"
+	TextListEditing                    = "list_editing"
+	TextListExists                     = "list_exists"
+	TextListNotFound                   = "list_not_found"
+	TextSendNewListName                = "send_new_list_name"
+	TextRemoveListConfirmation         = "remove_list_confirmation"
+	TextActionBack                     = "action_back"
+	TextActionNotes                    = "action_notes"
+	TextActionItems                    = "action_items"
+	TextActionRemoveNotes              = "action_remove_notes"
+	TextTaskNotes                      = "task_notes"
+	TextTaskNotesEmpty                 = "task_notes_empty"
+	TextTaskItems                      = "task_items"
+	TextTaskItemsEmpty                 = "task_items_empty"
+	TextSendNewTaskItems               = "send_new_task_items"
+	TextActionAttachments              = "action_attachments"
+	TextTaskAttachments                = "task_attachments"
+	TextTaskAttachmentsEmpty           = "task_attachments_empty"
+	TextAttachmentCaptionRequired      = "attachment_caption_required"
+	TextActionHistory                  = "action_history"
+	TextTaskHistory                    = "task_history"
+	TextTaskHistoryEmpty               = "task_history_empty"
+	TextSomeone                        = "someone"
+	TextActionUndo                     = "action_undo"
+	TextUndoExpired                    = "undo_expired"
+	TextUndoNothing                    = "undo_nothing"
+	TextUndone                         = "undone"
+	TextTaskEventUndone                = "task_event_undone"
+	TextCalendar                       = "calendar"
+	TextCalendarDisabled               = "calendar_disabled"
+	TextActionResetCalendar            = "action_reset_calendar"
+	TextCalendarFileInvalid            = "calendar_file_invalid"
+	TextCalendarFileEmpty              = "calendar_file_empty"
+	TextImportEvents                   = "import_events"
+	TextEventImported                  = "event_imported"
+	TextActionImport                   = "action_import"
+	TextEventsImported                 = "events_imported"
+	TextSendBackupFile                 = "send_backup_file"
+	TextBackupFileInvalid              = "backup_file_invalid"
+	TextBackupSummary                  = "backup_summary"
+	TextBackupRestored                 = "backup_restored"
+	TextBackupRestoreFailed            = "backup_restore_failed"
+	TextAgendaToday                    = "agenda_today"
+	TextAgendaTomorrow                 = "agenda_tomorrow"
+	TextAgendaWeek                     = "agenda_week"
+	TextAgendaDay                      = "agenda_day"
+	TextAgendaUndated                  = "agenda_undated"
+	TextAgendaEmpty                    = "agenda_empty"
+	TextActionPreviousWeek             = "action_previous_week"
+	TextActionNextWeek                 = "action_next_week"
 )
@@ -0,0 +1,207 @@
+package bot
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/maxwww/family_bot/dateparse"
+	"github.com/maxwww/family_bot/units"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxTimezoneMatches limits the keyboard of zones offered for a search.
+const MaxTimezoneMatches = 8
+
+// zoneTabFiles list canonical zones of the tz database, zone1970.tab is
+// preferred as it has no duplicates for countries sharing a zone.
+var zoneTabFiles = []string{"zone1970.tab", "zone.tab"}
+
+var (
+	timezonesOnce sync.Once
+	timezones     []string
+
+	// locations caches loaded zones, time.LoadLocation reads a file every time
+	locations sync.Map
+)
+
+// loadTimezone returns the location of an IANA zone name.
+func loadTimezone(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+
+	return loc, nil
+}
+
+// userLocation returns the location dates are shown and parsed in for the user.
+func (bot *Bot) userLocation(user *units.User) *time.Location {
+	if user == nil || user.Timezone == "" {
+		return bot.loc
+	}
+
+	loc, err := loadTimezone(user.Timezone)
+	if err != nil {
+		log.Println(err)
+		return bot.loc
+	}
+
+	return loc
+}
+
+// getDateParser returns a parser which understands dates in the user's zone.
+func (bot *Bot) getDateParser(user *units.User) *dateparse.Parser {
+	return &dateparse.Parser{Location: bot.userLocation(user), Now: bot.now}
+}
+
+// getTimezones returns names of the zones in the tz database, it is empty when
+// the database is not installed and only exact zone names can be used then.
+func getTimezones() []string {
+	timezonesOnce.Do(func() {
+		for _, dir := range zoneinfoDirs() {
+			for _, name := range zoneTabFiles {
+				zones, err := readZoneTab(filepath.Join(dir, name))
+				if err == nil && len(zones) > 0 {
+					timezones = zones
+					return
+				}
+			}
+		}
+	})
+
+	return timezones
+}
+
+func zoneinfoDirs() []string {
+	var dirs []string
+	if dir := os.Getenv("ZONEINFO"); dir != "" {
+		dirs = append(dirs, dir)
+	}
+
+	return append(dirs, "/usr/share/zoneinfo", "/usr/share/lib/zoneinfo", "/usr/lib/locale/TZ")
+}
+
+// readZoneTab reads zone names from the third column of a zone.tab file.
+func readZoneTab(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	var zones []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) >= 3 {
+			zones = append(zones, fields[2])
+		}
+	}
+	sort.Strings(zones)
+
+	return zones, scanner.Err()
+}
+
+// searchTimezones finds zones by a city name, "new york" matches America/New_York,
+// cities starting with the query go first.
+func searchTimezones(zones []string, query string) []string {
+	query = strings.ToLower(strings.ReplaceAll(trim(query), " ", "_"))
+	if query == "" {
+		return nil
+	}
+
+	var prefixed, contained []string
+	for _, zone := range zones {
+		city := strings.ToLower(zone[strings.LastIndex(zone, "/")+1:])
+		switch {
+		case strings.ToLower(zone) == query:
+			return []string{zone}
+		case strings.HasPrefix(city, query):
+			prefixed = append(prefixed, zone)
+		case strings.Contains(city, query) || strings.Contains(strings.ToLower(zone), query):
+			contained = append(contained, zone)
+		}
+	}
+
+	matches := append(prefixed, contained...)
+	if len(matches) > MaxTimezoneMatches {
+		matches = matches[:MaxTimezoneMatches]
+	}
+
+	return matches
+}
+
+func (bot *Bot) handleTimezoneCommand(chatId int64, user *units.User, query string) {
+	tr := bot.localizer(user)
+	if trim(query) == "" {
+		loc := bot.userLocation(user)
+		bot.sendMessage(chatId, tr.T(TextTimezoneCurrent, loc.String(), bot.now().In(loc).Format(TimeFormat)), nil, "")
+		return
+	}
+
+	matches := searchTimezones(getTimezones(), query)
+	if len(matches) == 0 {
+		// zones missing from zone.tab like UTC or Etc/GMT+3 can be set by the exact name
+		if zone := trim(query); zone != "Local" {
+			if _, err := loadTimezone(zone); err == nil {
+				matches = []string{zone}
+			}
+		}
+	}
+
+	if len(matches) == 0 {
+		bot.sendMessage(chatId, tr.T(TextTimezoneNotFound), nil, "")
+		return
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, zone := range matches {
+		label := zone
+		if loc, err := loadTimezone(zone); err == nil {
+			label = fmt.Sprintf("%s (%s)", zone, bot.now().In(loc).Format(TimeFormat))
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, CQSetTimezone+":"+zone),
+		))
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	bot.sendMessage(chatId, tr.T(TextTimezoneChoose), &keyboard, "")
+}
+
+func (bot *Bot) setTimezone(chatId int64, messageId int, user *units.User, zone string) {
+	loc, err := loadTimezone(zone)
+	if err != nil || zone == "" || zone == "Local" {
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	err = bot.userService.UpdateUser(context.Background(), user, units.UserPatch{
+		Timezone: &zone,
+	})
+	if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	bot.editMessage(chatId, messageId, bot.localizer(user).T(TextTimezoneSet, zone, bot.now().In(loc).Format(TimeFormat)), nil, "")
+	bot.refreshListMessages(user, 0, 0)
+}
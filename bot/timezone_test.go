@@ -0,0 +1,38 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSearchTimezones(t *testing.T) {
+	zones := []string{
+		"America/Argentina/Buenos_Aires",
+		"America/New_York",
+		"America/North_Dakota/New_Salem",
+		"Europe/Kyiv",
+		"Europe/London",
+		"Europe/Warsaw",
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"kyiv", []string{"Europe/Kyiv"}},
+		{"  Warsaw ", []string{"Europe/Warsaw"}},
+		{"new york", []string{"America/New_York"}},
+		{"new", []string{"America/New_York", "America/North_Dakota/New_Salem"}},
+		{"buenos", []string{"America/Argentina/Buenos_Aires"}},
+		{"europe/london", []string{"Europe/London"}},
+		{"europe", []string{"Europe/Kyiv", "Europe/London", "Europe/Warsaw"}},
+		{"paris", nil},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		if got := searchTimezones(zones, tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("searchTimezones(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/maxwww/family_bot/units"
+)
+
+// UndoConfig sets how long removed tasks can be restored.
+type UndoConfig struct {
+	// Grace is how long the undo button under the list works, /undo has
+	// no limit.
+	Grace time.Duration
+	// PurgeAfter is how long removed tasks are kept before they are gone
+	// for good.
+	PurgeAfter time.Duration
+}
+
+var DefaultUndoConfig = UndoConfig{
+	Grace:      10 * time.Minute,
+	PurgeAfter: 30 * 24 * time.Hour,
+}
+
+// SetUndoConfig replaces DefaultUndoConfig, it has to be called before the
+// bot starts.
+func (bot *Bot) SetUndoConfig(config UndoConfig) {
+	bot.undo = config
+}
+
+// showTaskListWithUndo shows the list after a removal with the button
+// restoring the removed tasks.
+func (bot *Bot) showTaskListWithUndo(chatId int64, messageId int, user *units.User, listId uint) {
+	tr := bot.localizer(user)
+	bot.showTaskListInSameMessage(chatId, messageId, user, listId, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(tr.T(TextActionUndo), fmt.Sprintf(CQUndo+":%d", listId)),
+	))
+}
+
+// undoFromList reverts the latest change of the user if it was made within
+// the grace period and shows the list again.
+func (bot *Bot) undoFromList(chatId int64, messageId int, user *units.User, listId int) {
+	_, err := bot.taskService.UndoLast(actorContext(user), bot.now().Add(-bot.undo.Grace))
+	if err == units.ErrNotFound {
+		bot.sendMessage(chatId, bot.localizer(user).T(TextUndoExpired), nil, "")
+	} else if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	bot.showTaskListInSameMessage(chatId, messageId, user, uint(listId))
+	bot.refreshListMessages(user, chatId, messageId)
+}
+
+func (bot *Bot) handleUndoCommand(chatId int64, user *units.User) {
+	tr := bot.localizer(user)
+	events, err := bot.taskService.UndoLast(actorContext(user), time.Time{})
+	if err == units.ErrNotFound {
+		bot.sendMessage(chatId, tr.T(TextUndoNothing), nil, "")
+		return
+	} else if err != nil {
+		log.Println(err)
+		bot.sendGeneralError(chatId, user)
+		return
+	}
+
+	message := tr.T(TextUndone)
+	for _, event := range events {
+		message += fmt.Sprintf("\n%s: %s", html.EscapeString(event.Title), getTaskEventLabel(tr, event))
+	}
+	bot.sendMessage(chatId, message, nil, "")
+	bot.refreshListMessages(user, 0, 0)
+}
+
+// purgeDeletedTasks removes tasks which have been removed for long enough
+// to be restored.
+func (bot *Bot) purgeDeletedTasks() {
+	if err := bot.taskService.PurgeDeleted(context.Background(), bot.now().Add(-bot.undo.PurgeAfter)); err != nil {
+		log.Println(err)
+	}
+}
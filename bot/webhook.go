@@ -0,0 +1,153 @@
+package bot
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+	// ShutdownTimeout bounds how long in-flight updates are awaited on shutdown.
+	ShutdownTimeout = 10 * time.Second
+)
+
+type WebhookConfig struct {
+	// URL is the public address Telegram posts updates to, the webhook is not
+	// registered when it is empty which is handy for local testing.
+	URL string
+	// ListenAddr is the address of the embedded HTTP server, e.g. ":8080".
+	ListenAddr string
+	// Path is the route updates are accepted on.
+	Path string
+	// SecretToken is sent by Telegram in the X-Telegram-Bot-Api-Secret-Token header.
+	SecretToken string
+}
+
+// StartWebhook receives updates over HTTP until the context is cancelled,
+// then stops accepting requests and waits for the updates being handled.
+func (bot *Bot) StartWebhook(ctx context.Context, config WebhookConfig) error {
+	if config.URL != "" {
+		if err := bot.setWebhook(config); err != nil {
+			return err
+		}
+	}
+
+	if config.SecretToken == "" {
+		log.Println("webhook secret token is not set, updates are not verified")
+	}
+
+	err := bot.RegisterCrons()
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	path := config.Path
+	if path == "" {
+		path = "/"
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(path, bot.webhookHandler(config.SecretToken, func(update tgbotapi.Update) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bot.handleUpdate(update)
+		}()
+	}))
+
+	server := &http.Server{
+		Addr:              config.ListenAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("listening for webhook updates on %s%s", config.ListenAddr, path)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		bot.stopCrons()
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+
+	err = server.Shutdown(shutdownCtx)
+	bot.stopCrons()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-shutdownCtx.Done():
+		log.Println("shutdown timed out while handling updates")
+	}
+
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// webhookHandler decodes updates posted by Telegram and passes them to handle,
+// requests without the expected secret token are rejected.
+func (bot *Bot) webhookHandler(secretToken string, handle func(update tgbotapi.Update)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		if secretToken != "" {
+			got := r.Header.Get(secretTokenHeader)
+			if subtle.ConstantTimeCompare([]byte(got), []byte(secretToken)) != 1 {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+		}
+
+		var update tgbotapi.Update
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&update); err != nil {
+			log.Println(err)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+
+		handle(update)
+
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// setWebhook registers the webhook, the library's WebhookConfig has no
+// secret_token field so the request is made by hand.
+func (bot *Bot) setWebhook(config WebhookConfig) error {
+	params := tgbotapi.Params{}
+	params["url"] = config.URL
+	params.AddNonEmpty("secret_token", config.SecretToken)
+
+	_, err := bot.BotAPI.MakeRequest("setWebhook", params)
+
+	return err
+}
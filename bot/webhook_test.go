@@ -0,0 +1,56 @@
+package bot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const recordedUpdate = `{"update_id":10001,"message":{"message_id":7,"from":{"id":42,"is_bot":false,"first_name":"Max"},"chat":{"id":42,"type":"private"},"date":1651648500,"text":"купити хліб завтра"}}`
+
+func TestWebhookHandler(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		secret  string
+		body    string
+		status  int
+		handled bool
+	}{
+		{name: "valid update", method: http.MethodPost, secret: "s3cret", body: recordedUpdate, status: http.StatusOK, handled: true},
+		{name: "wrong secret", method: http.MethodPost, secret: "wrong", body: recordedUpdate, status: http.StatusUnauthorized},
+		{name: "missing secret", method: http.MethodPost, body: recordedUpdate, status: http.StatusUnauthorized},
+		{name: "not a post", method: http.MethodGet, secret: "s3cret", status: http.StatusMethodNotAllowed},
+		{name: "broken json", method: http.MethodPost, secret: "s3cret", body: `{"update_id":`, status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var handled []tgbotapi.Update
+			handler := (&Bot{}).webhookHandler("s3cret", func(update tgbotapi.Update) {
+				handled = append(handled, update)
+			})
+
+			req := httptest.NewRequest(tt.method, "/telegram", strings.NewReader(tt.body))
+			if tt.secret != "" {
+				req.Header.Set(secretTokenHeader, tt.secret)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.handled != (len(handled) == 1) {
+				t.Fatalf("handled %d updates, want handled = %v", len(handled), tt.handled)
+			}
+			if tt.handled && (handled[0].UpdateID != 10001 || handled[0].Message.Text != "купити хліб завтра") {
+				t.Errorf("decoded update = %+v", handled[0])
+			}
+		})
+	}
+}
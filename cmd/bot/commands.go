@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/maxwww/family_bot/backup"
+	"github.com/maxwww/family_bot/postgres"
+)
+
+const usage = `usage:
+  bot                       start the bot
+  bot migrate up            apply pending migrations
+  bot migrate down [N]      revert the last N migrations (default 1)
+  bot migrate status        list migrations and their state
+  bot export [-csv] [FILE]  back up every family as JSON (or tasks as CSV) to FILE or stdout
+  bot import [-dry-run] FILE
+                            restore a backup, families are matched by name`
+
+// runCommand executes a maintenance subcommand instead of starting the bot.
+func runCommand(postgresURL string, args []string) {
+	switch args[0] {
+	case "migrate":
+		runMigrateCommand(postgresURL, args[1:])
+	case "export":
+		runExportCommand(postgresURL, args[1:])
+	case "import":
+		runImportCommand(postgresURL, args[1:])
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func runMigrateCommand(postgresURL string, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	loc, err := loadLocation()
+	if err != nil {
+		log.Fatalf("invalid LOCATION: %v", err)
+	}
+
+	db, err := postgres.Open(postgresURL)
+	if err != nil {
+		log.Fatalf("cannot open database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		if err := postgres.Migrate(ctx, db, loc); err != nil {
+			log.Fatal(err)
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				log.Fatalf("invalid number of steps %q", args[1])
+			}
+		}
+		if err := postgres.MigrateDown(ctx, db, steps, loc); err != nil {
+			log.Fatal(err)
+		}
+	case "status":
+		statuses, err := postgres.MigrationsStatus(ctx, db)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.AppliedAt != nil {
+				state = "applied"
+				if !status.AppliedAt.IsZero() {
+					state += " " + status.AppliedAt.Format("2006-01-02 15:04:05")
+				}
+			}
+			fmt.Printf("%06d %-24s %s\n", status.Version, status.Name, state)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func backupServices(db *postgres.DB) backup.Services {
+	return backup.Services{
+		Users:    postgres.NewUserService(db),
+		Tasks:    postgres.NewTaskService(db),
+		Families: postgres.NewFamilyService(db),
+		Lists:    postgres.NewListService(db),
+	}
+}
+
+func runExportCommand(postgresURL string, args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	csv := flags.Bool("csv", false, "write tasks as CSV")
+	flags.Parse(args)
+	if flags.NArg() > 1 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	db, err := postgres.Open(postgresURL)
+	if err != nil {
+		log.Fatalf("cannot open database: %v", err)
+	}
+	defer db.Close()
+
+	data, err := backup.Export(context.Background(), backupServices(db))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	write := backup.WriteJSON
+	if *csv {
+		write = backup.WriteCSV
+	}
+
+	if flags.NArg() == 0 {
+		err = write(os.Stdout, data)
+	} else {
+		err = writeFile(flags.Arg(0), func(w io.Writer) error {
+			return write(w, data)
+		})
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// writeFile writes a temporary file next to the named one and renames it
+// into place, a failed write leaves no truncated file behind.
+func writeFile(name string, write func(w io.Writer) error) error {
+	file, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(file.Name())
+
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), name)
+}
+
+func runImportCommand(postgresURL string, args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "only print what would be restored")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	data, err := backup.ReadJSON(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := postgres.Open(postgresURL)
+	if err != nil {
+		log.Fatalf("cannot open database: %v", err)
+	}
+	defer db.Close()
+
+	summary, err := backup.Restore(context.Background(), backupServices(db), data, *dryRun)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	verb := "restored"
+	if *dryRun {
+		verb = "would restore"
+	}
+	fmt.Printf("%s %d families, %d lists, %d tasks and settings of %d users, %d tasks exist already\n",
+		verb, summary.Families, summary.Lists, summary.Tasks, summary.Users, summary.Skipped)
+}
@@ -1,19 +1,36 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/joho/godotenv"
 	"github.com/maxwww/family_bot/bot"
 	"github.com/maxwww/family_bot/postgres"
+	st "github.com/maxwww/family_bot/state"
 	"log"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 )
 
 const (
 	KievLocation = "Europe/Kiev"
+
+	StateBackendMemory   = "memory"
+	StateBackendPostgres = "postgres"
+	StateBackendRedis    = "redis"
+
+	UpdatesModePolling = "polling"
+	UpdatesModeWebhook = "webhook"
+
+	DefaultWebhookListen = ":8080"
+	DefaultWebhookPath   = "/telegram"
+
+	DefaultCalendarListen = ":8081"
 )
 
 func main() {
@@ -24,22 +41,27 @@ func main() {
 	token := os.Getenv("TOKEN")
 	postgresURL := os.Getenv("POSTGRESQL_URL")
 
-	subscribersIdsString := os.Getenv("SUBSCRIBERS_IDS")
-	subscribersIds := strings.Split(subscribersIdsString, ",")
-	var subscribers []int64
-	for _, v := range subscribersIds {
-		id, _ := strconv.Atoi(v)
-		subscribers = append(subscribers, int64(id))
+	if len(os.Args) > 1 {
+		runCommand(postgresURL, os.Args[1:])
+		return
 	}
-	if len(subscribers) == 0 {
+
+	// SUBSCRIBERS_IDS are bootstrap admins, everyone else joins a family by invite
+	adminsIdsString := os.Getenv("SUBSCRIBERS_IDS")
+	adminsIds := strings.Split(adminsIdsString, ",")
+	var admins []int64
+	for _, v := range adminsIds {
+		id, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			continue
+		}
+		admins = append(admins, int64(id))
+	}
+	if len(admins) == 0 {
 		panic("it needs to specify SUBSCRIBERS_IDS")
 	}
 
-	location := os.Getenv("LOCATION")
-	if location == "" {
-		location = KievLocation
-	}
-	loc, err := time.LoadLocation(location)
+	loc, err := loadLocation()
 	if err != nil {
 		panic("it needs valid location")
 	}
@@ -54,10 +76,143 @@ func main() {
 		log.Fatalf("cannot open database: %v", err)
 	}
 
-	b := bot.NewBot(botApi, db, subscribers, loc)
+	if err := postgres.Migrate(context.Background(), db, loc); err != nil {
+		log.Fatalf("cannot migrate database: %v", err)
+	}
 
-	err = b.Start()
+	stateService, err := newStateService(db)
+	if err != nil {
+		log.Fatalf("cannot create state service: %v", err)
+	}
+
+	b := bot.NewBot(botApi, bot.NewTelegramMessenger(botApi), bot.Services{
+		Users:         postgres.NewUserService(db),
+		Tasks:         postgres.NewTaskService(db),
+		Families:      postgres.NewFamilyService(db),
+		Notifications: postgres.NewNotificationService(db),
+		ListMessages:  postgres.NewListMessageService(db),
+		Lists:         postgres.NewListService(db),
+		State:         stateService,
+	}, admins, loc)
+
+	undo, err := undoConfig()
+	if err != nil {
+		log.Fatalf("cannot configure undo: %v", err)
+	}
+	b.SetUndoConfig(undo)
+
+	// the feed has its own server so it works in both update modes
+	if calendar := calendarConfig(); calendar.URL != "" {
+		b.SetCalendarConfig(calendar)
+		go func() {
+			log.Fatal(b.ServeCalendar())
+		}()
+	}
+
+	switch mode := os.Getenv("UPDATES_MODE"); mode {
+	case "", UpdatesModePolling:
+		err = b.Start()
+	case UpdatesModeWebhook:
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		err = b.StartWebhook(ctx, webhookConfig())
+	default:
+		err = fmt.Errorf("unknown UPDATES_MODE %q", mode)
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
 }
+
+// loadLocation returns the default location of the bot, users who have not
+// chosen a timezone see dates in it.
+func loadLocation() (*time.Location, error) {
+	location := os.Getenv("LOCATION")
+	if location == "" {
+		location = KievLocation
+	}
+
+	return time.LoadLocation(location)
+}
+
+func webhookConfig() bot.WebhookConfig {
+	config := bot.WebhookConfig{
+		URL:         os.Getenv("WEBHOOK_URL"),
+		ListenAddr:  os.Getenv("WEBHOOK_LISTEN"),
+		Path:        os.Getenv("WEBHOOK_PATH"),
+		SecretToken: os.Getenv("WEBHOOK_SECRET"),
+	}
+	if config.ListenAddr == "" {
+		config.ListenAddr = DefaultWebhookListen
+	}
+	if config.Path == "" {
+		config.Path = DefaultWebhookPath
+	}
+
+	return config
+}
+
+func calendarConfig() bot.CalendarConfig {
+	config := bot.CalendarConfig{
+		URL:        os.Getenv("CALENDAR_URL"),
+		ListenAddr: os.Getenv("CALENDAR_LISTEN"),
+		Path:       os.Getenv("CALENDAR_PATH"),
+	}
+	if config.ListenAddr == "" {
+		config.ListenAddr = DefaultCalendarListen
+	}
+
+	return config
+}
+
+// undoConfig reads UNDO_GRACE as a duration like 10m and PURGE_AFTER_DAYS,
+// unset ones keep the defaults.
+func undoConfig() (bot.UndoConfig, error) {
+	config := bot.DefaultUndoConfig
+	if v := os.Getenv("UNDO_GRACE"); v != "" {
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return config, err
+		}
+		config.Grace = parsed
+	}
+	if v := os.Getenv("PURGE_AFTER_DAYS"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil {
+			return config, err
+		}
+		config.PurgeAfter = time.Duration(days) * 24 * time.Hour
+	}
+
+	return config, nil
+}
+
+func newStateService(db *postgres.DB) (st.StateServiceI, error) {
+	ttl := st.DefaultTTL
+	if v := os.Getenv("STATE_TTL"); v != "" {
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, err
+		}
+		ttl = parsed
+	}
+
+	switch backend := os.Getenv("STATE_BACKEND"); backend {
+	case "", StateBackendMemory:
+		return st.NewMemoryStateService(ttl), nil
+	case StateBackendPostgres:
+		return postgres.NewStateService(db, ttl), nil
+	case StateBackendRedis:
+		redisDB := 0
+		if v := os.Getenv("REDIS_DB"); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, err
+			}
+			redisDB = parsed
+		}
+		return st.NewRedisStateService(os.Getenv("REDIS_ADDR"), os.Getenv("REDIS_PASSWORD"), redisDB, ttl)
+	default:
+		return nil, fmt.Errorf("unknown STATE_BACKEND %q", backend)
+	}
+}
@@ -0,0 +1,388 @@
+// Package dateparse finds dates, times and recurrence rules written in
+// natural language, e.g. "купити хліб завтра о 18:00" or "gym every monday 7pm".
+package dateparse
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maxwww/family_bot/units"
+)
+
+var ErrInvalidDate = errors.New("invalid date")
+
+type SpanKind string
+
+const (
+	SpanDate       SpanKind = "date"
+	SpanTime       SpanKind = "time"
+	SpanRecurrence SpanKind = "recurrence"
+)
+
+// Span is a part of the input recognised by the parser, Start and End
+// are byte offsets in the original input.
+type Span struct {
+	Start int
+	End   int
+	Kind  SpanKind
+	Text  string
+}
+
+type Result struct {
+	// Date is the found moment, it is midnight when only the day is known
+	// and today when only the time is known.
+	Date       time.Time
+	HasDate    bool
+	HasTime    bool
+	Recurrence units.Recurrence
+	// Remainder is the input without recognised phrases, usually the task title.
+	Remainder string
+	Spans     []Span
+}
+
+// Found reports whether the input mentioned any date or time.
+func (r Result) Found() bool {
+	return r.HasDate || r.HasTime
+}
+
+type Parser struct {
+	Location *time.Location
+	// Now returns the current time, time.Now is used when it is nil.
+	Now    func() time.Time
+	Locale Locale
+}
+
+func (p *Parser) location() *time.Location {
+	if p.Location == nil {
+		return time.Local
+	}
+
+	return p.Location
+}
+
+func (p *Parser) now() time.Time {
+	if p.Now == nil {
+		return time.Now().In(p.location())
+	}
+
+	return p.Now().In(p.location())
+}
+
+func (p *Parser) Parse(input string) (Result, error) {
+	g := grammarFor(p.Locale)
+	s := &scanner{input: input, text: input}
+	result := Result{}
+
+	now := p.now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, p.location())
+	date := today
+
+	result.Recurrence = g.findRecurrence(s)
+
+	var weekDay time.Weekday = -1
+	for _, r := range g.weekdays {
+		for _, re := range r.res {
+			if s.find(re, SpanDate) != nil {
+				weekDay = r.key
+			}
+		}
+	}
+
+	if weekDay == -1 && len(result.Recurrence.Weekdays()) > 0 {
+		// without an explicit day the task starts on the nearest recurring weekday
+		weekDay = result.Recurrence.Next(today).Weekday()
+	}
+
+	if weekDay != -1 {
+		delta := int(weekDay) - int(today.Weekday())
+		if delta <= 0 {
+			delta += 7
+		}
+		date = today.AddDate(0, 0, delta)
+		result.HasDate = true
+	} else if m := s.find(g.relative, SpanDate); m != nil {
+		// "через 2 години" points to an exact moment, so no other time is looked for
+		amount := 1
+		if m[6] >= 0 {
+			number := strings.ToLower(input[m[6]:m[7]])
+			if n, ok := g.numbers[number]; ok {
+				amount = n
+			} else if n, err := strconv.Atoi(number); err == nil {
+				amount = n
+			}
+		}
+
+		result.HasDate = true
+		switch g.units[strings.ToLower(input[m[8]:m[9]])] {
+		case unitMinutes:
+			result.Date = now.Truncate(time.Minute).Add(time.Duration(amount) * time.Minute)
+			result.HasTime = true
+		case unitHours:
+			result.Date = now.Truncate(time.Minute).Add(time.Duration(amount) * time.Hour)
+			result.HasTime = true
+		case unitDays:
+			date = today.AddDate(0, 0, amount)
+		case unitWeeks:
+			date = today.AddDate(0, 0, 7*amount)
+		case unitMonths:
+			date = today.AddDate(0, amount, 0)
+		}
+
+		if result.HasTime {
+			s.spans[len(s.spans)-1].Kind = SpanTime
+			result.Remainder = s.remainder()
+			result.Spans = s.spans
+
+			return result, nil
+		}
+	} else if s.find(g.dayAfterTomorrow, SpanDate) != nil {
+		date = today.AddDate(0, 0, 2)
+		result.HasDate = true
+	} else if s.find(g.nextWeek, SpanDate) != nil {
+		// next week starts on the nearest Monday after the current week
+		delta := int(time.Monday) - int(today.Weekday())
+		if delta <= 0 {
+			delta += 7
+		}
+		date = today.AddDate(0, 0, delta)
+		result.HasDate = true
+	} else if s.find(g.tomorrow, SpanDate) != nil {
+		date = today.AddDate(0, 0, 1)
+		result.HasDate = true
+	} else if s.find(g.today, SpanDate) != nil {
+		result.HasDate = true
+	} else {
+		var day, month, year string
+		if m := s.find(g.dayMonthName, SpanDate); m != nil {
+			day, month, year = group(input, m, 2), strconv.Itoa(int(g.months[strings.ToLower(group(input, m, 3))])), group(input, m, 4)
+		} else if m := s.find(g.monthNameDay, SpanDate); m != nil {
+			day, month, year = group(input, m, 3), strconv.Itoa(int(g.months[strings.ToLower(group(input, m, 2))])), group(input, m, 4)
+		} else if m := s.find(dateRe, SpanDate); m != nil {
+			day, month, year = group(input, m, 1), group(input, m, 3), group(input, m, 6)
+		}
+
+		if day != "" {
+			parsed, err := dateFromParts(day, month, year, today)
+			if err != nil {
+				return result, err
+			}
+			date = parsed
+			result.HasDate = true
+		}
+	}
+
+	if result.Recurrence != units.RecurrenceNone {
+		result.HasDate = true
+	}
+
+	hour, minutes, isTimeFound, err := g.findTime(s)
+	if err != nil {
+		return result, err
+	}
+
+	if isTimeFound {
+		date = date.Add(time.Duration(hour)*time.Hour + time.Duration(minutes)*time.Minute)
+		if date.Before(now) {
+			date = date.AddDate(0, 0, 1)
+		}
+		result.HasTime = true
+	}
+
+	if result.Found() {
+		result.Date = date
+	}
+	result.Remainder = s.remainder()
+	result.Spans = s.spans
+
+	return result, nil
+}
+
+// findRecurrence strips recurrence phrases like "щопонеділка" or "every day"
+// from the input and returns the rule they describe.
+func (g *grammar) findRecurrence(s *scanner) units.Recurrence {
+	var days []time.Weekday
+	for _, r := range g.recurrenceDays {
+		for _, re := range r.res {
+			if s.find(re, SpanRecurrence) != nil {
+				days = append(days, r.key)
+			}
+		}
+	}
+	if len(days) > 0 {
+		return units.NewWeekdaysRecurrence(days...)
+	}
+
+	recurrence := units.RecurrenceNone
+	for _, r := range g.recurrences {
+		for _, re := range r.res {
+			if s.find(re, SpanRecurrence) != nil {
+				recurrence = r.key
+			}
+		}
+	}
+
+	return recurrence
+}
+
+// findTime looks for "19:30", "7pm" or a part of the day like "ввечері".
+func (g *grammar) findTime(s *scanner) (int, int, bool, error) {
+	if g.twelveHour != nil {
+		if m := s.find(g.twelveHour, SpanTime); m != nil {
+			hour, err := strconv.Atoi(group(s.input, m, 2))
+			if err != nil {
+				return 0, 0, false, ErrInvalidDate
+			}
+
+			minutes := 0
+			if m[6] >= 0 {
+				minutes, err = strconv.Atoi(group(s.input, m, 3))
+				if err != nil {
+					return 0, 0, false, ErrInvalidDate
+				}
+			}
+
+			hour = hour % 12
+			if strings.HasPrefix(strings.ToLower(group(s.input, m, 4)), "p") {
+				hour += 12
+			}
+
+			return hour, minutes, true, nil
+		}
+	}
+
+	if m := s.find(timeRe, SpanTime); m != nil {
+		hour, err := strconv.Atoi(group(s.input, m, 1))
+		if err != nil {
+			return 0, 0, false, ErrInvalidDate
+		}
+
+		minutes, err := strconv.Atoi(group(s.input, m, 2))
+		if err != nil {
+			return 0, 0, false, ErrInvalidDate
+		}
+
+		return hour, minutes, true, nil
+	}
+
+	for _, r := range g.partsOfDay {
+		for _, re := range r.res {
+			if s.find(re, SpanTime) != nil {
+				return r.key, 0, true, nil
+			}
+		}
+	}
+
+	for _, r := range g.dayParts {
+		for _, re := range r.res {
+			if s.findNextToSpan(re, SpanTime) != nil {
+				return r.key, 0, true, nil
+			}
+		}
+	}
+
+	return 0, 0, false, nil
+}
+
+// dateFromParts builds a date from matched day, month and optional year,
+// dates in the past without a year are moved to the next year.
+func dateFromParts(dayString, monthString, yearString string, today time.Time) (time.Time, error) {
+	year := today.Year()
+	if yearString != "" {
+		if len(yearString) == 2 {
+			yearString = "20" + yearString
+		}
+		year_, err := strconv.Atoi(yearString)
+		if err != nil {
+			return time.Time{}, ErrInvalidDate
+		}
+		year = year_
+	}
+
+	month, err := strconv.Atoi(monthString)
+	if err != nil || month < 1 || month > 12 {
+		return time.Time{}, ErrInvalidDate
+	}
+
+	day, err := strconv.Atoi(dayString)
+	if err != nil {
+		return time.Time{}, ErrInvalidDate
+	}
+
+	parsedDate := time.Date(year, time.Month(month), day, 0, 0, 0, 0, today.Location())
+	if parsedDate.Before(today) {
+		if yearString != "" {
+			return time.Time{}, ErrInvalidDate
+		}
+		parsedDate = parsedDate.AddDate(1, 0, 0)
+	}
+
+	return parsedDate, nil
+}
+
+// scanner blanks out recognised phrases with spaces, so offsets of the
+// remaining text keep pointing into the original input.
+type scanner struct {
+	input string
+	text  string
+	spans []Span
+}
+
+// find returns submatch indexes of the first match and blanks it out.
+func (s *scanner) find(re *regexp.Regexp, kind SpanKind) []int {
+	m := re.FindStringSubmatchIndex(s.text)
+	if m == nil {
+		return nil
+	}
+	s.take(m, kind)
+
+	return m
+}
+
+// findNextToSpan is find for matches right before or after a span found
+// earlier, other matches are left in the text.
+func (s *scanner) findNextToSpan(re *regexp.Regexp, kind SpanKind) []int {
+	for _, m := range re.FindAllStringSubmatchIndex(s.text, -1) {
+		for _, span := range s.spans {
+			if (span.End <= m[0] && strings.TrimSpace(s.input[span.End:m[0]]) == "") ||
+				(m[1] <= span.Start && strings.TrimSpace(s.input[m[1]:span.Start]) == "") {
+				s.take(m, kind)
+				return m
+			}
+		}
+	}
+
+	return nil
+}
+
+// take blanks out the match and records it as a span.
+func (s *scanner) take(m []int, kind SpanKind) {
+	start, end := m[0], m[1]
+	for start < end && isSpace(s.text[start]) {
+		start++
+	}
+	for end > start && isSpace(s.text[end-1]) {
+		end--
+	}
+
+	s.text = s.text[:m[0]] + strings.Repeat(" ", m[1]-m[0]) + s.text[m[1]:]
+	s.spans = append(s.spans, Span{Start: start, End: end, Kind: kind, Text: s.input[start:end]})
+}
+
+func (s *scanner) remainder() string {
+	return strings.Join(strings.Fields(s.text), " ")
+}
+
+func group(input string, m []int, n int) string {
+	if m[2*n] < 0 {
+		return ""
+	}
+
+	return input[m[2*n]:m[2*n+1]]
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
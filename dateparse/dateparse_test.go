@@ -0,0 +1,226 @@
+package dateparse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/maxwww/family_bot/units"
+)
+
+const testLayout = "2006-01-02 15:04"
+
+var testLocation = time.FixedZone("EEST", 3*60*60)
+
+func testNow(value string) func() time.Time {
+	return func() time.Time {
+		t, err := time.ParseInLocation(testLayout, value, testLocation)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+}
+
+func TestParse(t *testing.T) {
+	// 2022-05-04 is a Wednesday
+	const now = "2022-05-04 10:15"
+
+	tests := []struct {
+		name       string
+		input      string
+		now        string
+		locale     Locale
+		date       string
+		hasDate    bool
+		hasTime    bool
+		recurrence units.Recurrence
+		remainder  string
+		err        bool
+	}{
+		{name: "no date", input: "купити хліб", remainder: "купити хліб"},
+		{name: "empty", input: "   ", remainder: ""},
+		{name: "extra spaces", input: "  купити   хліб  ", remainder: "купити хліб"},
+
+		{name: "today", input: "купити хліб сьогодні", date: "2022-05-04 00:00", hasDate: true, remainder: "купити хліб"},
+		{name: "today capitalized", input: "Сьогодні купити хліб", date: "2022-05-04 00:00", hasDate: true, remainder: "купити хліб"},
+		{name: "today en", input: "today buy bread", date: "2022-05-04 00:00", hasDate: true, remainder: "buy bread"},
+		{name: "tomorrow", input: "завтра прибрати", date: "2022-05-05 00:00", hasDate: true, remainder: "прибрати"},
+		{name: "tomorrow en", input: "clean up tomorrow", date: "2022-05-05 00:00", hasDate: true, remainder: "clean up"},
+		{name: "tomorrow inside a word", input: "післязавтрашній план", remainder: "післязавтрашній план"},
+		{name: "day after tomorrow", input: "післязавтра тест", date: "2022-05-06 00:00", hasDate: true, remainder: "тест"},
+		{name: "day after tomorrow en", input: "day after tomorrow test", date: "2022-05-06 00:00", hasDate: true, remainder: "test"},
+		{name: "next week", input: "наступного тижня зустріч", date: "2022-05-09 00:00", hasDate: true, remainder: "зустріч"},
+		{name: "next week alt", input: "зустріч на наступному тижні", date: "2022-05-09 00:00", hasDate: true, remainder: "зустріч"},
+		{name: "next week en", input: "next week meeting", date: "2022-05-09 00:00", hasDate: true, remainder: "meeting"},
+
+		{name: "monday", input: "зустріч понеділок", date: "2022-05-09 00:00", hasDate: true, remainder: "зустріч"},
+		{name: "same weekday is next week", input: "середа", date: "2022-05-11 00:00", hasDate: true, remainder: ""},
+		{name: "short weekday", input: "пт бассейн", date: "2022-05-06 00:00", hasDate: true, remainder: "бассейн"},
+		{name: "weekday typographic apostrophe", input: "п’ятниця бассейн", date: "2022-05-06 00:00", hasDate: true, remainder: "бассейн"},
+		{name: "weekday without apostrophe", input: "пятниця бассейн", date: "2022-05-06 00:00", hasDate: true, remainder: "бассейн"},
+		{name: "weekday en", input: "sunday picnic", date: "2022-05-08 00:00", hasDate: true, remainder: "picnic"},
+		{name: "weekday en short", input: "thurs dentist", date: "2022-05-05 00:00", hasDate: true, remainder: "dentist"},
+		{name: "weekday with time", input: "пт 9:00 бассейн", date: "2022-05-06 09:00", hasDate: true, hasTime: true, remainder: "бассейн"},
+
+		{name: "in hours", input: "купити хліб через 2 години", date: "2022-05-04 12:15", hasDate: true, hasTime: true, remainder: "купити хліб"},
+		{name: "in an hour", input: "через годину", date: "2022-05-04 11:15", hasDate: true, hasTime: true, remainder: ""},
+		{name: "in an hour en", input: "in an hour", date: "2022-05-04 11:15", hasDate: true, hasTime: true, remainder: ""},
+		{name: "in minutes en", input: "in 30 min call mom", date: "2022-05-04 10:45", hasDate: true, hasTime: true, remainder: "call mom"},
+		{name: "in minutes without space", input: "через 10хв чайник", date: "2022-05-04 10:25", hasDate: true, hasTime: true, remainder: "чайник"},
+		{name: "in minutes word number", input: "через дві хвилини", date: "2022-05-04 10:17", hasDate: true, hasTime: true, remainder: ""},
+		{name: "in hours past midnight", input: "через годину", now: "2022-05-04 23:30", date: "2022-05-05 00:30", hasDate: true, hasTime: true, remainder: ""},
+		{name: "in days", input: "через 3 дні прибрати", date: "2022-05-07 00:00", hasDate: true, remainder: "прибрати"},
+		{name: "in days many", input: "через 5 днів", date: "2022-05-09 00:00", hasDate: true, remainder: ""},
+		{name: "in a day", input: "через день", date: "2022-05-05 00:00", hasDate: true, remainder: ""},
+		{name: "in a week", input: "in a week", date: "2022-05-11 00:00", hasDate: true, remainder: ""},
+		{name: "in two weeks", input: "in two weeks", date: "2022-05-18 00:00", hasDate: true, remainder: ""},
+		{name: "in a week uk", input: "через тиждень", date: "2022-05-11 00:00", hasDate: true, remainder: ""},
+		{name: "in a month", input: "через місяць", date: "2022-06-04 00:00", hasDate: true, remainder: ""},
+		{name: "in days with time", input: "через 2 дні 18:00", date: "2022-05-06 18:00", hasDate: true, hasTime: true, remainder: ""},
+		{name: "in without unit", input: "in the garden", remainder: "in the garden"},
+
+		{name: "day month name", input: "5 травня день народження", date: "2022-05-05 00:00", hasDate: true, remainder: "день народження"},
+		{name: "day month nominative", input: "20 червень", date: "2022-06-20 00:00", hasDate: true, remainder: ""},
+		{name: "day month name past", input: "1 травня", date: "2023-05-01 00:00", hasDate: true, remainder: ""},
+		{name: "day month name with year", input: "1 травня 2023", date: "2023-05-01 00:00", hasDate: true, remainder: ""},
+		{name: "day month name past year", input: "1 травня 2022", err: true},
+		{name: "day month name en", input: "may 5th party", date: "2022-05-05 00:00", hasDate: true, remainder: "party"},
+		{name: "month name day en with year", input: "March 3, 2030 x", date: "2030-03-03 00:00", hasDate: true, remainder: "x"},
+		{name: "day month name en with year", input: "3 march 2030", date: "2030-03-03 00:00", hasDate: true, remainder: ""},
+		{name: "month short name", input: "sept 1 school", date: "2022-09-01 00:00", hasDate: true, remainder: "school"},
+		{name: "month name with time", input: "5 травня 19:00 концерт", date: "2022-05-05 19:00", hasDate: true, hasTime: true, remainder: "концерт"},
+
+		{name: "numeric date", input: "15.05 тест", date: "2022-05-15 00:00", hasDate: true, remainder: "тест"},
+		{name: "numeric date slash", input: "15/05/2023", date: "2023-05-15 00:00", hasDate: true, remainder: ""},
+		{name: "numeric date short year", input: "15.05.23", date: "2023-05-15 00:00", hasDate: true, remainder: ""},
+		{name: "numeric date today", input: "04.05", date: "2022-05-04 00:00", hasDate: true, remainder: ""},
+		{name: "numeric date rolls over", input: "03.05", date: "2023-05-03 00:00", hasDate: true, remainder: ""},
+		{name: "numeric date rolls over to february", input: "15.02 тест", date: "2023-02-15 00:00", hasDate: true, remainder: "тест"},
+		{name: "numeric date past year", input: "03.05.2022", err: true},
+		{name: "numeric date invalid month", input: "13.13", err: true},
+		{name: "numeric date with time", input: "15.05 18:30 лікар", date: "2022-05-15 18:30", hasDate: true, hasTime: true, remainder: "лікар"},
+
+		{name: "time later today", input: "18:00 gym", date: "2022-05-04 18:00", hasTime: true, remainder: "gym"},
+		{name: "time passed today", input: "9:00 gym", date: "2022-05-05 09:00", hasTime: true, remainder: "gym"},
+		{name: "tomorrow with time", input: "tomorrow 18:00 gym", date: "2022-05-05 18:00", hasDate: true, hasTime: true, remainder: "gym"},
+		{name: "today with passed time", input: "сьогодні 9:00", date: "2022-05-05 09:00", hasDate: true, hasTime: true, remainder: ""},
+		{name: "twelve hour pm", input: "завтра о 7pm", date: "2022-05-05 19:00", hasDate: true, hasTime: true, remainder: "о"},
+		{name: "twelve hour minutes", input: "10:30am call", date: "2022-05-04 10:30", hasTime: true, remainder: "call"},
+		{name: "twelve hour passed", input: "9am call", date: "2022-05-05 09:00", hasTime: true, remainder: "call"},
+		{name: "twelve hour space", input: "call 3 pm", date: "2022-05-04 15:00", hasTime: true, remainder: "call"},
+		{name: "twelve noon", input: "12pm lunch", date: "2022-05-04 12:00", hasTime: true, remainder: "lunch"},
+		{name: "twelve midnight", input: "12am backup", date: "2022-05-05 00:00", hasTime: true, remainder: "backup"},
+
+		{name: "evening", input: "ввечері кіно", date: "2022-05-04 19:00", hasTime: true, remainder: "кіно"},
+		{name: "morning passed", input: "вранці біг", date: "2022-05-05 09:00", hasTime: true, remainder: "біг"},
+		{name: "tomorrow morning", input: "завтра вранці біг", date: "2022-05-05 09:00", hasDate: true, hasTime: true, remainder: "біг"},
+		{name: "afternoon", input: "вдень", date: "2022-05-04 14:00", hasTime: true, remainder: ""},
+		{name: "night", input: "вночі бекап", date: "2022-05-04 22:00", hasTime: true, remainder: "бекап"},
+		{name: "today tonight", input: "movie today tonight", date: "2022-05-04 19:00", hasDate: true, hasTime: true, remainder: "movie"},
+		{name: "tomorrow morning en", input: "run tomorrow morning", date: "2022-05-05 09:00", hasDate: true, hasTime: true, remainder: "run"},
+		{name: "part of day before date", input: "dinner evening friday", date: "2022-05-06 19:00", hasDate: true, hasTime: true, remainder: "dinner"},
+		{name: "at night", input: "backup at night", date: "2022-05-04 22:00", hasTime: true, remainder: "backup"},
+		{name: "night without date", input: "buy night cream", remainder: "buy night cream"},
+		{name: "afternoon without date", input: "afternoon tea set", remainder: "afternoon tea set"},
+		{name: "tonight without date", input: "tonight show tickets", remainder: "tonight show tickets"},
+		{name: "part of day away from date", input: "buy night cream tomorrow", date: "2022-05-05 00:00", hasDate: true, remainder: "buy night cream"},
+		{name: "in the morning", input: "run tomorrow in the morning", date: "2022-05-05 09:00", hasDate: true, hasTime: true, remainder: "run"},
+		{name: "exact time wins over part of day", input: "ввечері 20:30 кіно", date: "2022-05-04 20:30", hasTime: true, remainder: "ввечері кіно"},
+
+		{name: "weekly days", input: "щопонеділка о 9:00", date: "2022-05-09 09:00", hasDate: true, hasTime: true, recurrence: "days:1", remainder: "о"},
+		{name: "several weekly days", input: "щопонеділка і щоп'ятниці прибирання", date: "2022-05-06 00:00", hasDate: true, recurrence: "days:1,5", remainder: "прибирання"},
+		{name: "every weekday en", input: "gym every monday 7pm", date: "2022-05-09 19:00", hasDate: true, hasTime: true, recurrence: "days:1", remainder: "gym"},
+		{name: "daily", input: "щодня 8:00 таблетки", date: "2022-05-05 08:00", hasDate: true, hasTime: true, recurrence: units.RecurrenceDaily, remainder: "таблетки"},
+		{name: "weekly", input: "every week review", date: "2022-05-04 00:00", hasDate: true, recurrence: units.RecurrenceWeekly, remainder: "review"},
+		{name: "monthly with date", input: "щомісяця 15.05 оплата", date: "2022-05-15 00:00", hasDate: true, recurrence: units.RecurrenceMonthly, remainder: "оплата"},
+		{name: "yearly with month name", input: "yearly 1 травня", date: "2023-05-01 00:00", hasDate: true, recurrence: units.RecurrenceYearly, remainder: ""},
+		{name: "recurrence with weekday", input: "щотижня пт", date: "2022-05-06 00:00", hasDate: true, recurrence: units.RecurrenceWeekly, remainder: ""},
+
+		{name: "rollover numeric date", input: "05.01", now: "2022-12-30 10:00", date: "2023-01-05 00:00", hasDate: true},
+		{name: "rollover today", input: "30.12", now: "2022-12-30 10:00", date: "2022-12-30 00:00", hasDate: true},
+		{name: "rollover yesterday", input: "29.12", now: "2022-12-30 10:00", date: "2023-12-29 00:00", hasDate: true},
+		{name: "rollover explicit past year", input: "29.12.2022", now: "2022-12-30 10:00", err: true},
+		{name: "rollover short year", input: "01.01.23", now: "2022-12-30 10:00", date: "2023-01-01 00:00", hasDate: true},
+		{name: "rollover month name", input: "5 січня", now: "2022-12-30 10:00", date: "2023-01-05 00:00", hasDate: true},
+		{name: "rollover month name en", input: "jan 2", now: "2022-12-30 10:00", date: "2023-01-02 00:00", hasDate: true},
+		{name: "rollover relative days", input: "через 3 дні", now: "2022-12-30 10:00", date: "2023-01-02 00:00", hasDate: true},
+		{name: "rollover next week", input: "наступного тижня", now: "2022-12-30 10:00", date: "2023-01-02 00:00", hasDate: true},
+		{name: "rollover weekday", input: "понеділок", now: "2022-12-30 10:00", date: "2023-01-02 00:00", hasDate: true},
+		{name: "rollover passed time", input: "9:00", now: "2022-12-31 10:00", date: "2023-01-01 09:00", hasTime: true},
+		{name: "rollover tomorrow", input: "завтра", now: "2022-12-31 10:00", date: "2023-01-01 00:00", hasDate: true},
+
+		{name: "uk locale ignores en", input: "tomorrow 7pm", locale: LocaleUK, remainder: "tomorrow 7pm"},
+		{name: "uk locale", input: "завтра 19:00", locale: LocaleUK, date: "2022-05-05 19:00", hasDate: true, hasTime: true},
+		{name: "en locale ignores uk", input: "завтра", locale: LocaleEN, remainder: "завтра"},
+		{name: "en locale", input: "tomorrow 7pm", locale: LocaleEN, date: "2022-05-05 19:00", hasDate: true, hasTime: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value := now
+			if tt.now != "" {
+				value = tt.now
+			}
+			p := &Parser{Location: testLocation, Now: testNow(value), Locale: tt.locale}
+
+			result, err := p.Parse(tt.input)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("Parse(%q) expected an error, got %+v", tt.input, result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q) unexpected error: %v", tt.input, err)
+			}
+
+			date := ""
+			if result.Found() {
+				date = result.Date.Format(testLayout)
+			}
+			if date != tt.date {
+				t.Errorf("Parse(%q) date = %q, want %q", tt.input, date, tt.date)
+			}
+			if result.HasDate != tt.hasDate {
+				t.Errorf("Parse(%q) HasDate = %v, want %v", tt.input, result.HasDate, tt.hasDate)
+			}
+			if result.HasTime != tt.hasTime {
+				t.Errorf("Parse(%q) HasTime = %v, want %v", tt.input, result.HasTime, tt.hasTime)
+			}
+			if result.Recurrence != tt.recurrence {
+				t.Errorf("Parse(%q) Recurrence = %q, want %q", tt.input, result.Recurrence, tt.recurrence)
+			}
+			if result.Remainder != tt.remainder {
+				t.Errorf("Parse(%q) Remainder = %q, want %q", tt.input, result.Remainder, tt.remainder)
+			}
+		})
+	}
+}
+
+func TestParseSpans(t *testing.T) {
+	p := &Parser{Location: testLocation, Now: testNow("2022-05-04 10:15")}
+	input := "купити хліб завтра о 18:00 щодня"
+
+	result, err := p.Parse(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Span{
+		{Kind: SpanRecurrence, Text: "щодня"},
+		{Kind: SpanDate, Text: "завтра"},
+		{Kind: SpanTime, Text: "18:00"},
+	}
+	if len(result.Spans) != len(want) {
+		t.Fatalf("got %d spans %+v, want %d", len(result.Spans), result.Spans, len(want))
+	}
+
+	for i, span := range result.Spans {
+		if span.Kind != want[i].Kind || span.Text != want[i].Text {
+			t.Errorf("span %d = %+v, want %s %q", i, span, want[i].Kind, want[i].Text)
+		}
+		if input[span.Start:span.End] != span.Text {
+			t.Errorf("span %d offsets point to %q, want %q", i, input[span.Start:span.End], span.Text)
+		}
+	}
+}
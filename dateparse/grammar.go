@@ -0,0 +1,163 @@
+package dateparse
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maxwww/family_bot/units"
+)
+
+var (
+	dateRe = regexp.MustCompile(`([0123]?[0-9])(\/|\.)([01]?[0-9])((\/|\.)([0-9]{4}|[0-9]{2}))?`)
+	timeRe = regexp.MustCompile(`([012]?[0-9]):([012345]?[0-9])`)
+)
+
+// rule ties a key like a weekday to the expressions of its words,
+// rules are kept sorted so the parser behaves the same on every run.
+type rule[K ~int | ~string] struct {
+	key K
+	res []*regexp.Regexp
+}
+
+// grammar is a vocabulary compiled to regular expressions.
+type grammar struct {
+	weekdays         []rule[time.Weekday]
+	recurrenceDays   []rule[time.Weekday]
+	recurrences      []rule[units.Recurrence]
+	partsOfDay       []rule[int]
+	dayParts         []rule[int]
+	today            *regexp.Regexp
+	tomorrow         *regexp.Regexp
+	dayAfterTomorrow *regexp.Regexp
+	nextWeek         *regexp.Regexp
+	relative         *regexp.Regexp
+	dayMonthName     *regexp.Regexp
+	monthNameDay     *regexp.Regexp
+	twelveHour       *regexp.Regexp
+	months           map[string]time.Month
+	numbers          map[string]int
+	units            map[string]unit
+}
+
+var (
+	grammarsMu sync.Mutex
+	grammars   = map[Locale]*grammar{}
+)
+
+// grammarFor compiles the vocabulary of the locale once and reuses it afterwards.
+func grammarFor(locale Locale) *grammar {
+	grammarsMu.Lock()
+	defer grammarsMu.Unlock()
+
+	if g, ok := grammars[locale]; ok {
+		return g
+	}
+
+	var v *vocabulary
+	if vocab, ok := vocabularies[locale]; ok {
+		v = vocab
+	} else {
+		v = mergeVocabularies(vocabularies[LocaleUK], vocabularies[LocaleEN])
+	}
+
+	g := compileGrammar(v)
+	grammars[locale] = g
+
+	return g
+}
+
+func compileGrammar(v *vocabulary) *grammar {
+	g := &grammar{
+		weekdays: compileWords(v.weekdays, ""),
+		// "щопонеділка і щоп'ятниці" should not leave the conjunction in the title
+		recurrenceDays:   compileWords(v.recurrenceDays, `((`+alternation(v.conjunctions)+`)\s+)?`),
+		recurrences:      compileWords(v.recurrences, ""),
+		partsOfDay:       compileWords(v.partsOfDay, ""),
+		dayParts:         compileWords(v.dayParts, ""),
+		today:            compilePhrase(alternation(v.today)),
+		tomorrow:         compilePhrase(alternation(v.tomorrow)),
+		dayAfterTomorrow: compilePhrase(alternation(v.dayAfterTomorrow)),
+		nextWeek:         compilePhrase(alternation(v.nextWeek)),
+		months:           make(map[string]time.Month),
+		numbers:          v.numbers,
+		units:            make(map[string]unit),
+	}
+
+	var numbers []string
+	for word := range v.numbers {
+		numbers = append(numbers, word)
+	}
+	numbers = append(numbers, `\d+`)
+
+	var unitWords []string
+	for u, words := range v.units {
+		for _, word := range words {
+			g.units[word] = u
+			unitWords = append(unitWords, word)
+		}
+	}
+
+	g.relative = compilePhrase(`(` + alternation(v.relative) + `)\s+(?:(` + alternation(numbers) + `)\s*)?(` + alternation(unitWords) + `)`)
+
+	var months []string
+	for month, names := range v.months {
+		for _, name := range names {
+			g.months[name] = month
+			months = append(months, name)
+		}
+	}
+	monthNames := alternation(months)
+
+	g.dayMonthName = compilePhrase(`([0123]?[0-9])(?:st|nd|rd|th)?\s+(` + monthNames + `)(?:\s+([0-9]{4}))?`)
+	g.monthNameDay = compilePhrase(`(` + monthNames + `)\s+([0123]?[0-9])(?:st|nd|rd|th)?(?:,?\s+([0-9]{4}))?`)
+
+	if len(v.meridiems) > 0 {
+		g.twelveHour = compilePhrase(`(1[0-2]|0?[1-9])(?::([0-5][0-9]))?\s*(` + alternation(v.meridiems) + `)`)
+	}
+
+	return g
+}
+
+// compilePhrase matches the expression as a separate phrase, the first
+// and the last groups of the result are the surrounding spaces.
+func compilePhrase(expr string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)(\s|^)(?:` + expr + `)(\s|$)`)
+}
+
+func compileWords[K ~int | ~string](words map[K][]string, prefix string) []rule[K] {
+	var result []rule[K]
+	for key, value := range words {
+		r := rule[K]{key: key}
+		for _, word := range value {
+			r.res = append(r.res, regexp.MustCompile(`(?i)(\s|^)`+prefix+regexp.QuoteMeta(word)+`(\s|$)`))
+		}
+		result = append(result, r)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].key < result[j].key
+	})
+
+	return result
+}
+
+// alternation joins words into a regexp alternation, longer words go first
+// so "sept" is not cut to "sep".
+func alternation(words []string) string {
+	sorted := make([]string, len(words))
+	copy(sorted, words)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i]) > len(sorted[j])
+	})
+
+	for i, word := range sorted {
+		if word != `\d+` {
+			sorted[i] = regexp.QuoteMeta(word)
+		}
+	}
+
+	return strings.Join(sorted, "|")
+}
@@ -0,0 +1,232 @@
+package dateparse
+
+import (
+	"time"
+
+	"github.com/maxwww/family_bot/units"
+)
+
+type Locale string
+
+const (
+	// LocaleAny understands phrases of every known locale
+	LocaleAny Locale = ""
+	LocaleUK  Locale = "uk"
+	LocaleEN  Locale = "en"
+)
+
+type unit string
+
+const (
+	unitMinutes unit = "minutes"
+	unitHours   unit = "hours"
+	unitDays    unit = "days"
+	unitWeeks   unit = "weeks"
+	unitMonths  unit = "months"
+)
+
+// vocabulary holds the words of a single language the parser looks for.
+type vocabulary struct {
+	weekdays         map[time.Weekday][]string
+	recurrenceDays   map[time.Weekday][]string
+	recurrences      map[units.Recurrence][]string
+	months           map[time.Month][]string
+	partsOfDay       map[int][]string
+	units            map[unit][]string
+	numbers          map[string]int
+	today            []string
+	tomorrow         []string
+	dayAfterTomorrow []string
+	nextWeek         []string
+	relative         []string
+	conjunctions     []string
+	meridiems        []string
+	// dayParts are parts of the day which are times only next to a date,
+	// so "tomorrow morning" has a time and "night cream" does not.
+	dayParts map[int][]string
+}
+
+var vocabularies = map[Locale]*vocabulary{
+	LocaleUK: {
+		weekdays: map[time.Weekday][]string{
+			time.Monday:    {"понеділок", "пн", "пон"},
+			time.Tuesday:   {"вівторок", "вт", "вів"},
+			time.Wednesday: {"середа", "ср", "сер"},
+			time.Thursday:  {"четвер", "чт", "чет"},
+			time.Friday:    {"п'ятниця", "пт", "п’ятниця", "пʼятниця", "пятниця", "п'ят", "п’ят", "пʼят", "пят"},
+			time.Saturday:  {"субота", "сб", "суб"},
+			time.Sunday:    {"неділя", "нд", "нед"},
+		},
+		recurrenceDays: map[time.Weekday][]string{
+			time.Monday:    {"щопонеділка", "щопонеділок", "кожного понеділка", "кожен понеділок", "кожний понеділок"},
+			time.Tuesday:   {"щовівторка", "щовівторок", "кожного вівторка", "кожен вівторок", "кожний вівторок"},
+			time.Wednesday: {"щосереди", "щосереду", "кожної середи", "кожну середу"},
+			time.Thursday:  {"щочетверга", "щочетвер", "кожного четверга", "кожен четвер", "кожний четвер"},
+			time.Friday:    {"щоп'ятниці", "щоп’ятниці", "щопʼятниці", "щопятниці", "кожної п'ятниці", "кожної п’ятниці", "кожну п'ятницю", "кожну п’ятницю"},
+			time.Saturday:  {"щосуботи", "щосуботу", "кожної суботи", "кожну суботу"},
+			time.Sunday:    {"щонеділі", "щонеділю", "кожної неділі", "кожну неділю"},
+		},
+		recurrences: map[units.Recurrence][]string{
+			units.RecurrenceDaily:   {"щодня", "щоденно", "кожного дня", "кожен день", "кожний день"},
+			units.RecurrenceWeekly:  {"щотижня", "щотижнево", "кожного тижня", "кожен тиждень"},
+			units.RecurrenceMonthly: {"щомісяця", "щомісячно", "кожного місяця", "кожен місяць"},
+			units.RecurrenceYearly:  {"щороку", "щорічно", "кожного року", "кожен рік"},
+		},
+		months: map[time.Month][]string{
+			time.January:   {"січня", "січень"},
+			time.February:  {"лютого", "лютий"},
+			time.March:     {"березня", "березень"},
+			time.April:     {"квітня", "квітень"},
+			time.May:       {"травня", "травень"},
+			time.June:      {"червня", "червень"},
+			time.July:      {"липня", "липень"},
+			time.August:    {"серпня", "серпень"},
+			time.September: {"вересня", "вересень"},
+			time.October:   {"жовтня", "жовтень"},
+			time.November:  {"листопада", "листопад"},
+			time.December:  {"грудня", "грудень"},
+		},
+		partsOfDay: map[int][]string{
+			9:  {"вранці", "зранку", "уранці"},
+			12: {"опівдні", "в обід"},
+			14: {"вдень", "удень", "по обіді"},
+			19: {"ввечері", "увечері", "звечора"},
+			22: {"вночі", "уночі"},
+		},
+		units: map[unit][]string{
+			unitMinutes: {"хвилину", "хвилини", "хвилин", "хв"},
+			unitHours:   {"годину", "години", "годин", "год"},
+			unitDays:    {"день", "дні", "днів", "дня"},
+			unitWeeks:   {"тиждень", "тижні", "тижнів", "тижня"},
+			unitMonths:  {"місяць", "місяці", "місяців", "місяця"},
+		},
+		numbers: map[string]int{
+			"одну":   1,
+			"один":   1,
+			"одна":   1,
+			"два":    2,
+			"дві":    2,
+			"три":    3,
+			"чотири": 4,
+		},
+		today:            []string{"сьогодні"},
+		tomorrow:         []string{"завтра"},
+		dayAfterTomorrow: []string{"післязавтра"},
+		nextWeek:         []string{"наступного тижня", "на наступному тижні"},
+		relative:         []string{"через"},
+		conjunctions:     []string{"і", "й", "та"},
+	},
+	LocaleEN: {
+		weekdays: map[time.Weekday][]string{
+			time.Monday:    {"monday", "mon"},
+			time.Tuesday:   {"tuesday", "tue", "tues"},
+			time.Wednesday: {"wednesday", "wed"},
+			time.Thursday:  {"thursday", "thu", "thur", "thurs"},
+			time.Friday:    {"friday", "fri"},
+			time.Saturday:  {"saturday", "sat"},
+			time.Sunday:    {"sunday", "sun"},
+		},
+		recurrenceDays: map[time.Weekday][]string{
+			time.Monday:    {"every monday", "mondays"},
+			time.Tuesday:   {"every tuesday", "tuesdays"},
+			time.Wednesday: {"every wednesday", "wednesdays"},
+			time.Thursday:  {"every thursday", "thursdays"},
+			time.Friday:    {"every friday", "fridays"},
+			time.Saturday:  {"every saturday", "saturdays"},
+			time.Sunday:    {"every sunday", "sundays"},
+		},
+		recurrences: map[units.Recurrence][]string{
+			units.RecurrenceDaily:   {"every day", "everyday", "daily"},
+			units.RecurrenceWeekly:  {"every week", "weekly"},
+			units.RecurrenceMonthly: {"every month", "monthly"},
+			units.RecurrenceYearly:  {"every year", "yearly", "annually"},
+		},
+		months: map[time.Month][]string{
+			time.January:   {"january", "jan"},
+			time.February:  {"february", "feb"},
+			time.March:     {"march", "mar"},
+			time.April:     {"april", "apr"},
+			time.May:       {"may"},
+			time.June:      {"june", "jun"},
+			time.July:      {"july", "jul"},
+			time.August:    {"august", "aug"},
+			time.September: {"september", "sept", "sep"},
+			time.October:   {"october", "oct"},
+			time.November:  {"november", "nov"},
+			time.December:  {"december", "dec"},
+		},
+		partsOfDay: map[int][]string{
+			9:  {"in the morning"},
+			12: {"at noon", "at midday"},
+			14: {"in the afternoon"},
+			19: {"in the evening"},
+			22: {"at night"},
+		},
+		dayParts: map[int][]string{
+			9:  {"morning"},
+			12: {"noon", "midday"},
+			14: {"afternoon"},
+			19: {"evening", "tonight"},
+			22: {"night"},
+		},
+		units: map[unit][]string{
+			unitMinutes: {"minutes", "minute", "mins", "min"},
+			unitHours:   {"hours", "hour", "hrs", "hr"},
+			unitDays:    {"days", "day"},
+			unitWeeks:   {"weeks", "week"},
+			unitMonths:  {"months", "month"},
+		},
+		numbers: map[string]int{
+			"a":     1,
+			"an":    1,
+			"one":   1,
+			"two":   2,
+			"three": 3,
+			"four":  4,
+		},
+		today:            []string{"today"},
+		tomorrow:         []string{"tomorrow"},
+		dayAfterTomorrow: []string{"day after tomorrow"},
+		nextWeek:         []string{"next week"},
+		relative:         []string{"in"},
+		conjunctions:     []string{"and"},
+		meridiems:        []string{"am", "pm"},
+	},
+}
+
+// mergeVocabularies joins the words of several locales into one vocabulary.
+func mergeVocabularies(list ...*vocabulary) *vocabulary {
+	result := &vocabulary{numbers: map[string]int{}}
+	for _, v := range list {
+		result.weekdays = mergeWords(result.weekdays, v.weekdays)
+		result.recurrenceDays = mergeWords(result.recurrenceDays, v.recurrenceDays)
+		result.recurrences = mergeWords(result.recurrences, v.recurrences)
+		result.months = mergeWords(result.months, v.months)
+		result.partsOfDay = mergeWords(result.partsOfDay, v.partsOfDay)
+		result.dayParts = mergeWords(result.dayParts, v.dayParts)
+		result.units = mergeWords(result.units, v.units)
+		for word, n := range v.numbers {
+			result.numbers[word] = n
+		}
+		result.today = append(result.today, v.today...)
+		result.tomorrow = append(result.tomorrow, v.tomorrow...)
+		result.dayAfterTomorrow = append(result.dayAfterTomorrow, v.dayAfterTomorrow...)
+		result.nextWeek = append(result.nextWeek, v.nextWeek...)
+		result.relative = append(result.relative, v.relative...)
+		result.conjunctions = append(result.conjunctions, v.conjunctions...)
+		result.meridiems = append(result.meridiems, v.meridiems...)
+	}
+
+	return result
+}
+
+func mergeWords[K comparable](dst, src map[K][]string) map[K][]string {
+	if dst == nil {
+		dst = make(map[K][]string)
+	}
+	for key, words := range src {
+		dst[key] = append(dst[key], words...)
+	}
+
+	return dst
+}
@@ -0,0 +1,175 @@
+// Package i18n holds translations of the bot messages. Catalogs are JSON files
+// embedded from the locales directory, one per language, mapping message keys
+// either to a format string or to plural forms of it.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	Ukrainian = "uk"
+	English   = "en"
+
+	// Default is used for users whose language has no catalog.
+	Default = Ukrainian
+)
+
+//go:embed locales/*.json
+var localesFS embed.FS
+
+// catalogs are loaded once, the files are embedded so a broken one is a bug
+// which the tests catch.
+var catalogs = mustLoadCatalogs()
+
+// Message is a translation, Forms is set for messages depending on a number.
+type Message struct {
+	Text  string
+	Forms map[PluralForm]string
+}
+
+func (m *Message) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &m.Text); err == nil {
+		return nil
+	}
+
+	return json.Unmarshal(data, &m.Forms)
+}
+
+type Catalog map[string]Message
+
+// Localizer formats messages in one language, keys missing from its catalog
+// fall back to the default language.
+type Localizer struct {
+	lang    string
+	catalog Catalog
+}
+
+// For returns the localizer of a language, Telegram codes like "en-GB" are
+// matched by the language part and unknown languages get the default one.
+func For(lang string) *Localizer {
+	lang = Match(lang)
+
+	return &Localizer{lang: lang, catalog: catalogs[lang]}
+}
+
+// Match returns the supported language closest to the code.
+func Match(code string) string {
+	code = strings.ToLower(code)
+	if i := strings.IndexAny(code, "-_"); i >= 0 {
+		code = code[:i]
+	}
+	if _, ok := catalogs[code]; ok {
+		return code
+	}
+
+	return Default
+}
+
+// Languages returns codes of every language with a catalog.
+func Languages() []string {
+	languages := make([]string, 0, len(catalogs))
+	for lang := range catalogs {
+		languages = append(languages, lang)
+	}
+	sort.Strings(languages)
+
+	return languages
+}
+
+func (l *Localizer) Lang() string {
+	return l.lang
+}
+
+// T returns the message, args are applied to it with fmt.Sprintf.
+func (l *Localizer) T(key string, args ...interface{}) string {
+	message, ok := l.lookup(key)
+	if !ok {
+		return key
+	}
+
+	text := message.Text
+	if message.Forms != nil {
+		text = message.Forms[Other]
+	}
+
+	return format(text, args)
+}
+
+// N returns the plural form of the message matching n, n is not passed to the
+// format by itself so it has to be among args when the message shows it.
+func (l *Localizer) N(key string, n int, args ...interface{}) string {
+	message, ok := l.lookup(key)
+	if !ok {
+		return key
+	}
+
+	if message.Forms == nil {
+		return format(message.Text, args)
+	}
+
+	text, ok := message.Forms[pluralForm(l.lang, n)]
+	if !ok {
+		text = message.Forms[Other]
+	}
+
+	return format(text, args)
+}
+
+func (l *Localizer) lookup(key string) (Message, bool) {
+	if message, ok := l.catalog[key]; ok {
+		return message, true
+	}
+	if message, ok := catalogs[Default][key]; ok {
+		return message, true
+	}
+	log.Printf("missing message %q", key)
+
+	return Message{}, false
+}
+
+func format(text string, args []interface{}) string {
+	if len(args) == 0 {
+		return text
+	}
+
+	return fmt.Sprintf(text, args...)
+}
+
+func mustLoadCatalogs() map[string]Catalog {
+	result, err := loadCatalogs()
+	if err != nil {
+		panic(err)
+	}
+
+	return result
+}
+
+func loadCatalogs() (map[string]Catalog, error) {
+	files, err := localesFS.ReadDir("locales")
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]Catalog{}
+	for _, file := range files {
+		content, err := localesFS.ReadFile(path.Join("locales", file.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		var catalog Catalog
+		if err := json.Unmarshal(content, &catalog); err != nil {
+			return nil, fmt.Errorf("locale %s: %w", file.Name(), err)
+		}
+		result[strings.TrimSuffix(file.Name(), ".json")] = catalog
+	}
+
+	return result, nil
+}
@@ -0,0 +1,135 @@
+package i18n
+
+import (
+	"regexp"
+	"testing"
+)
+
+var verbRe = regexp.MustCompile(`%[-+# 0]*\d*(?:\.\d+)?[a-zA-Z%]`)
+
+func TestEveryKeyExistsInEveryLocale(t *testing.T) {
+	loaded, err := loadCatalogs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, lang := range []string{Ukrainian, English} {
+		if _, ok := loaded[lang]; !ok {
+			t.Errorf("no catalog for %s", lang)
+		}
+	}
+
+	for lang, catalog := range loaded {
+		for otherLang, other := range loaded {
+			for key := range other {
+				if _, ok := catalog[key]; !ok {
+					t.Errorf("%s: missing %q which exists in %s", lang, key, otherLang)
+				}
+			}
+		}
+	}
+}
+
+func TestPluralMessagesHaveEveryForm(t *testing.T) {
+	loaded, err := loadCatalogs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for lang, catalog := range loaded {
+		forms, ok := pluralForms[lang]
+		if !ok {
+			t.Errorf("%s: no plural rules", lang)
+			continue
+		}
+		for key, message := range catalog {
+			if message.Forms == nil {
+				continue
+			}
+			for _, form := range forms {
+				if message.Forms[form] == "" {
+					t.Errorf("%s: %q has no %q form", lang, key, form)
+				}
+			}
+		}
+	}
+}
+
+func TestFormatVerbsMatchDefaultLocale(t *testing.T) {
+	loaded, err := loadCatalogs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	texts := func(message Message) []string {
+		if message.Forms == nil {
+			return []string{message.Text}
+		}
+		var result []string
+		for _, text := range message.Forms {
+			result = append(result, text)
+		}
+		return result
+	}
+
+	for key, base := range loaded[Default] {
+		want := verbRe.FindAllString(base.Text, -1)
+		if base.Forms != nil {
+			want = verbRe.FindAllString(base.Forms[Other], -1)
+		}
+		for lang, catalog := range loaded {
+			for _, text := range texts(catalog[key]) {
+				if got := verbRe.FindAllString(text, -1); len(got) != len(want) {
+					t.Errorf("%s: %q has verbs %v, want %v", lang, key, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestPluralForm(t *testing.T) {
+	tests := []struct {
+		lang string
+		n    int
+		want PluralForm
+	}{
+		{Ukrainian, 1, One},
+		{Ukrainian, 21, One},
+		{Ukrainian, 11, Many},
+		{Ukrainian, 2, Few},
+		{Ukrainian, 4, Few},
+		{Ukrainian, 12, Many},
+		{Ukrainian, 22, Few},
+		{Ukrainian, 5, Many},
+		{Ukrainian, 0, Many},
+		{Ukrainian, 111, Many},
+		{English, 1, One},
+		{English, 0, Other},
+		{English, 5, Other},
+	}
+
+	for _, tt := range tests {
+		if got := pluralForm(tt.lang, tt.n); got != tt.want {
+			t.Errorf("pluralForm(%s, %d) = %s, want %s", tt.lang, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestLocalizer(t *testing.T) {
+	uk := For("uk")
+	if got := uk.N("reminder_in_minutes", 5, "чай", 5); got != `Справа "чай" через 5 хвилин` {
+		t.Errorf("got %q", got)
+	}
+	if got := uk.N("reminder_in_minutes", 2, "чай", 2); got != `Справа "чай" через 2 хвилини` {
+		t.Errorf("got %q", got)
+	}
+	if got := For("en-GB").N("reminder_in_minutes", 1, "tea", 1); got != `Task "tea" in 1 minute` {
+		t.Errorf("got %q", got)
+	}
+	if got := For("de").Lang(); got != Default {
+		t.Errorf("unknown language = %s, want %s", got, Default)
+	}
+	if got := uk.T("no_such_key"); got != "no_such_key" {
+		t.Errorf("missing key = %q, want the key", got)
+	}
+}
@@ -0,0 +1,44 @@
+package i18n
+
+// PluralForm is a CLDR plural category.
+type PluralForm string
+
+const (
+	One   PluralForm = "one"
+	Few   PluralForm = "few"
+	Many  PluralForm = "many"
+	Other PluralForm = "other"
+)
+
+// pluralForms lists the categories a catalog has to define for every
+// plural message of the language.
+var pluralForms = map[string][]PluralForm{
+	Ukrainian: {One, Few, Many, Other},
+	English:   {One, Other},
+}
+
+// pluralForm picks the category of an integer, the rules are the CLDR ones
+// restricted to integers.
+func pluralForm(lang string, n int) PluralForm {
+	if n < 0 {
+		n = -n
+	}
+
+	switch lang {
+	case Ukrainian:
+		switch {
+		case n%10 == 1 && n%100 != 11:
+			return One
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+			return Few
+		default:
+			return Many
+		}
+	case English:
+		if n == 1 {
+			return One
+		}
+	}
+
+	return Other
+}
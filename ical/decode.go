@@ -0,0 +1,355 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maxwww/family_bot/units"
+)
+
+var ErrInvalidCalendar = errors.New("invalid calendar")
+
+// Event is an event or a to-do read from a calendar.
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	// Start is zero for to-dos without a date.
+	Start time.Time
+	// AllDay events start at midnight of the location given to Decode.
+	AllDay bool
+	// Recurrence is the rule of events the bot can repeat, series with an
+	// end or with rules it cannot follow are one-offs.
+	Recurrence units.Recurrence
+	// Alarms are how long before the start the reminders go off.
+	Alarms []time.Duration
+
+	rrule string
+	// repeat, until and count are the rule of a series the bot can follow
+	// to its upcoming occurrence, zero until and count mean it has no end.
+	repeat units.Recurrence
+	until  time.Time
+	count  int
+}
+
+// Upcoming returns the first occurrence of the event starting at from or
+// later, false means the event is over by then.
+func (e *Event) Upcoming(from time.Time) (time.Time, bool) {
+	date := e.Start
+	repeat := e.repeat.Anchored(date.Day())
+	for n := 1; date.Before(from); n++ {
+		if repeat == units.RecurrenceNone || (e.count > 0 && n >= e.count) {
+			return time.Time{}, false
+		}
+		next := repeat.Next(date)
+		if !next.After(date) || (!e.until.IsZero() && next.After(e.until)) {
+			return time.Time{}, false
+		}
+		date = next
+	}
+
+	return date, true
+}
+
+// Decode reads events and to-dos of the calendar. Times with a TZID are
+// read in that zone, floating times and dates in loc. Cancelled events and
+// changed occurrences of repeating ones are skipped.
+func Decode(r io.Reader, loc *time.Location) ([]*Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []*Event
+	var event *Event
+	skip, inAlarm, found := false, false, false
+	for _, raw := range lines {
+		name, params, value, ok := parseLine(raw)
+		if !ok {
+			continue
+		}
+
+		switch name {
+		case "BEGIN":
+			switch strings.ToUpper(value) {
+			case "VCALENDAR":
+				found = true
+			case "VEVENT", "VTODO":
+				event, skip = &Event{}, false
+			case "VALARM":
+				inAlarm = true
+			}
+			continue
+		case "END":
+			switch strings.ToUpper(value) {
+			case "VEVENT", "VTODO":
+				if event != nil && !skip && event.Summary != "" {
+					// the rule may come before the start it is counted from
+					if event.rrule != "" && !event.Start.IsZero() {
+						event.repeat, event.until, event.count = parseRRule(event.rrule, event.Start)
+						if event.until.IsZero() && event.count == 0 {
+							event.Recurrence = event.repeat
+						}
+					}
+					events = append(events, event)
+				}
+				event = nil
+			case "VALARM":
+				inAlarm = false
+			}
+			continue
+		}
+
+		if event == nil {
+			continue
+		}
+
+		if inAlarm {
+			if name == "TRIGGER" && params["RELATED"] != "END" && params["VALUE"] != "DATE-TIME" {
+				if before, ok := parseTrigger(value); ok {
+					event.Alarms = append(event.Alarms, before)
+				}
+			}
+			continue
+		}
+
+		switch name {
+		case "UID":
+			event.UID = value
+		case "SUMMARY":
+			event.Summary = strings.TrimSpace(unescapeText(value))
+		case "DESCRIPTION":
+			event.Description = strings.TrimSpace(unescapeText(value))
+		case "DTSTART", "DUE":
+			// to-dos may have only the due time
+			if name == "DUE" && !event.Start.IsZero() {
+				continue
+			}
+			start, allDay, err := parseTime(value, params, loc)
+			if err != nil {
+				skip = true
+				continue
+			}
+			event.Start, event.AllDay = start, allDay
+		case "RRULE":
+			event.rrule = value
+		case "STATUS":
+			if strings.EqualFold(value, "CANCELLED") {
+				skip = true
+			}
+		case "RECURRENCE-ID":
+			skip = true
+		}
+	}
+
+	if !found {
+		return nil, ErrInvalidCalendar
+	}
+
+	return events, nil
+}
+
+// unfold joins continuation lines to the lines they continue.
+func unfold(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	return lines, scanner.Err()
+}
+
+// parseLine splits a content line into its upper case name, parameters and
+// value, colons and semicolons in quoted parameter values are kept.
+func parseLine(line string) (string, map[string]string, string, bool) {
+	quoted := false
+	end := -1
+	for i, c := range line {
+		if c == '"' {
+			quoted = !quoted
+		} else if c == ':' && !quoted {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return "", nil, "", false
+	}
+
+	var parts []string
+	start := 0
+	quoted = false
+	for i, c := range line[:end] {
+		if c == '"' {
+			quoted = !quoted
+		} else if c == ';' && !quoted {
+			parts = append(parts, line[start:i])
+			start = i + 1
+		}
+	}
+	parts = append(parts, line[start:end])
+
+	params := map[string]string{}
+	for _, param := range parts[1:] {
+		if key, value, ok := strings.Cut(param, "="); ok {
+			params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+		}
+	}
+
+	return strings.ToUpper(parts[0]), params, line[end+1:], true
+}
+
+// parseTime reads a date or a date with time, dates are midnight of loc.
+func parseTime(value string, params map[string]string, loc *time.Location) (time.Time, bool, error) {
+	if params["VALUE"] == "DATE" || len(value) == len(dateFormat) {
+		t, err := time.ParseInLocation(dateFormat, value, loc)
+		return t, true, err
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(dateTimeFormat, value)
+		return t, false, err
+	}
+
+	zone := loc
+	if tzid := params["TZID"]; tzid != "" {
+		// unknown zones such as Windows names fall back to the default one
+		if tz, err := time.LoadLocation(strings.TrimPrefix(tzid, "/")); err == nil {
+			zone = tz
+		}
+	}
+	t, err := time.ParseInLocation(strings.TrimSuffix(dateTimeFormat, "Z"), value, zone)
+
+	return t, false, err
+}
+
+// parseRRule reads the rules the bot can repeat tasks by together with the
+// end of the series, other rules make the event a one-off. A date UNTIL
+// includes the whole day.
+func parseRRule(value string, start time.Time) (units.Recurrence, time.Time, int) {
+	rule := map[string]string{}
+	for _, part := range strings.Split(value, ";") {
+		if key, value, ok := strings.Cut(part, "="); ok {
+			rule[strings.ToUpper(key)] = strings.ToUpper(value)
+		}
+	}
+
+	var until time.Time
+	var count int
+	for key, value := range rule {
+		switch key {
+		case "FREQ", "WKST":
+		case "INTERVAL":
+			if value != "1" {
+				return units.RecurrenceNone, until, count
+			}
+		case "UNTIL":
+			end, allDay, err := parseTime(value, nil, start.Location())
+			if err != nil {
+				return units.RecurrenceNone, until, count
+			}
+			if allDay {
+				end = end.AddDate(0, 0, 1).Add(-time.Nanosecond)
+			}
+			until = end
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return units.RecurrenceNone, until, count
+			}
+			count = n
+		case "BYDAY":
+			// only weekly rules repeat on plain weekdays, e.g. "2TU" is not one
+			if rule["FREQ"] != "WEEKLY" {
+				return units.RecurrenceNone, until, count
+			}
+		default:
+			return units.RecurrenceNone, until, count
+		}
+	}
+
+	switch rule["FREQ"] {
+	case "DAILY":
+		return units.RecurrenceDaily, until, count
+	case "WEEKLY":
+		byDay := rule["BYDAY"]
+		if byDay == "" {
+			return units.RecurrenceWeekly, until, count
+		}
+		var days []time.Weekday
+		for _, name := range strings.Split(byDay, ",") {
+			day, ok := parseWeekday(name)
+			if !ok {
+				return units.RecurrenceNone, until, count
+			}
+			days = append(days, day)
+		}
+		sort.Slice(days, func(i, j int) bool { return days[i] < days[j] })
+		return units.NewWeekdaysRecurrence(days...), until, count
+	case "MONTHLY":
+		return units.RecurrenceMonthly, until, count
+	case "YEARLY":
+		return units.RecurrenceYearly, until, count
+	}
+
+	return units.RecurrenceNone, until, count
+}
+
+func parseWeekday(name string) (time.Weekday, bool) {
+	for day, dayName := range weekdayNames {
+		if name == dayName {
+			return day, true
+		}
+	}
+
+	return 0, false
+}
+
+var triggerRe = regexp.MustCompile(`^([+-]?)P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseTrigger reads the duration of an alarm before the start, alarms
+// after the start are skipped.
+func parseTrigger(value string) (time.Duration, bool) {
+	match := triggerRe.FindStringSubmatch(strings.ToUpper(value))
+	if match == nil {
+		return 0, false
+	}
+
+	var d time.Duration
+	for i, unit := range []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		if n, err := strconv.Atoi(match[i+2]); err == nil {
+			d += time.Duration(n) * unit
+		}
+	}
+	if match[1] != "-" && d != 0 {
+		return 0, false
+	}
+
+	return d, true
+}
+
+var textUnescaper = strings.NewReplacer(
+	`\\`, `\`,
+	`\;`, ";",
+	`\,`, ",",
+	`\n`, "\n",
+	`\N`, "\n",
+)
+
+func unescapeText(s string) string {
+	return textUnescaper.Replace(s)
+}
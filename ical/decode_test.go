@@ -0,0 +1,166 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maxwww/family_bot/units"
+)
+
+const schoolCalendar = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VTIMEZONE\r\nTZID:Europe/Kyiv\r\nBEGIN:STANDARD\r\nDTSTART:19701025T040000\r\nEND:STANDARD\r\nEND:VTIMEZONE\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:lesson-1@school\r\n" +
+	"SUMMARY:Батьківські збори\\, 5-А\r\n" +
+	"DESCRIPTION:Кабінет 12\\nНе запізнюйтесь\r\n" +
+	"DTSTART;TZID=\"Europe/Kyiv\":20220510T183000\r\n" +
+	"BEGIN:VALARM\r\nTRIGGER:-PT30M\r\nACTION:DISPLAY\r\nEND:VALARM\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:holiday@school\r\n" +
+	"SUMMARY:Канікули починаються і це дуже довга назва яка не вміщається в один рядок фай\r\n" +
+	" лу\r\n" +
+	"DTSTART;VALUE=DATE:20220601\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:swim@club\r\n" +
+	"SUMMARY:Басейн\r\n" +
+	"DTSTART:20220506T150000Z\r\n" +
+	"RRULE:FREQ=WEEKLY;BYDAY=FR,MO\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:swim@club\r\n" +
+	"RECURRENCE-ID:20220513T150000Z\r\n" +
+	"SUMMARY:Басейн перенесено\r\n" +
+	"DTSTART:20220514T150000Z\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:cancelled@club\r\n" +
+	"SUMMARY:Скасовано\r\n" +
+	"STATUS:CANCELLED\r\n" +
+	"DTSTART:20220507T150000Z\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VTODO\r\n" +
+	"UID:todo@school\r\n" +
+	"SUMMARY:Здати гроші на екскурсію\r\n" +
+	"END:VTODO\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestDecode(t *testing.T) {
+	events, err := Decode(strings.NewReader(schoolCalendar), kyiv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 4 {
+		t.Fatalf("decoded %d events, want 4: %+v", len(events), events)
+	}
+
+	meeting := events[0]
+	if meeting.UID != "lesson-1@school" || meeting.Summary != "Батьківські збори, 5-А" || meeting.Description != "Кабінет 12\nНе запізнюйтесь" {
+		t.Errorf("meeting = %+v", meeting)
+	}
+	if want := time.Date(2022, 5, 10, 15, 30, 0, 0, time.UTC); !meeting.Start.Equal(want) || meeting.AllDay {
+		t.Errorf("meeting starts at %v, want %v", meeting.Start, want)
+	}
+	if len(meeting.Alarms) != 1 || meeting.Alarms[0] != 30*time.Minute {
+		t.Errorf("meeting alarms = %v", meeting.Alarms)
+	}
+
+	holiday := events[1]
+	if !strings.HasSuffix(holiday.Summary, "файлу") || !holiday.AllDay || !holiday.Start.Equal(time.Date(2022, 6, 1, 0, 0, 0, 0, kyiv)) {
+		t.Errorf("holiday = %+v", holiday)
+	}
+
+	if swim := events[2]; swim.Recurrence != units.NewWeekdaysRecurrence(time.Monday, time.Friday) {
+		t.Errorf("swimming repeats %q", swim.Recurrence)
+	}
+
+	if todo := events[3]; todo.UID != "todo@school" || !todo.Start.IsZero() {
+		t.Errorf("to-do = %+v", todo)
+	}
+}
+
+func TestDecodeOwnCalendar(t *testing.T) {
+	events, err := Decode(strings.NewReader(encode(t, &units.Task{ID: 7, Title: strings.Repeat("довга назва; ", 10)})), kyiv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].UID != "task-7@family_bot" || events[0].Summary != strings.TrimSpace(strings.Repeat("довга назва; ", 10)) {
+		t.Errorf("events = %+v", events)
+	}
+}
+
+func TestDecodeRepeats(t *testing.T) {
+	from := time.Date(2022, 5, 4, 0, 0, 0, 0, kyiv)
+	tests := []struct {
+		name       string
+		start      string
+		rrule      string
+		recurrence units.Recurrence
+		upcoming   time.Time
+	}{
+		{name: "weekly days", start: "20220404T060000Z", rrule: "FREQ=WEEKLY;BYDAY=MO,FR;WKST=MO", recurrence: "days:1,5", upcoming: time.Date(2022, 5, 6, 6, 0, 0, 0, time.UTC)},
+		{name: "monthly keeps the day", start: "20220131T060000Z", rrule: "FREQ=MONTHLY", recurrence: units.RecurrenceMonthly, upcoming: time.Date(2022, 5, 31, 6, 0, 0, 0, time.UTC)},
+		{name: "future start", start: "20220510T060000Z", rrule: "FREQ=DAILY", recurrence: units.RecurrenceDaily, upcoming: time.Date(2022, 5, 10, 6, 0, 0, 0, time.UTC)},
+		{name: "until passed", start: "20220301T060000Z", rrule: "FREQ=WEEKLY;UNTIL=20220401T000000Z"},
+		{name: "until ahead", start: "20220501T060000Z", rrule: "FREQ=DAILY;UNTIL=20220510T000000Z", upcoming: time.Date(2022, 5, 4, 6, 0, 0, 0, time.UTC)},
+		{name: "until date includes the day", start: "20220501T150000Z", rrule: "FREQ=DAILY;UNTIL=20220504", upcoming: time.Date(2022, 5, 4, 15, 0, 0, 0, time.UTC)},
+		{name: "count passed", start: "20220501T060000Z", rrule: "FREQ=DAILY;COUNT=3"},
+		{name: "count ahead", start: "20220501T060000Z", rrule: "FREQ=DAILY;COUNT=5", upcoming: time.Date(2022, 5, 4, 6, 0, 0, 0, time.UTC)},
+		{name: "nth weekday of month", start: "20220412T060000Z", rrule: "FREQ=MONTHLY;BYDAY=2TU"},
+		{name: "days of daily rule", start: "20220401T060000Z", rrule: "FREQ=DAILY;BYDAY=MO,WE"},
+		{name: "nth weekday of week", start: "20220404T060000Z", rrule: "FREQ=WEEKLY;BYDAY=1MO"},
+		{name: "interval", start: "20220404T060000Z", rrule: "FREQ=WEEKLY;INTERVAL=2"},
+		{name: "month day", start: "20220415T060000Z", rrule: "FREQ=MONTHLY;BYMONTHDAY=15"},
+		{name: "one-off of unknown rule ahead", start: "20220601T060000Z", rrule: "FREQ=MONTHLY;BYMONTHDAY=1,15", upcoming: time.Date(2022, 6, 1, 6, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calendar := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:test\r\nSUMMARY:тест\r\n" +
+				"RRULE:" + tt.rrule + "\r\nDTSTART:" + tt.start + "\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+			events, err := Decode(strings.NewReader(calendar), kyiv)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != 1 {
+				t.Fatalf("decoded %d events, want 1", len(events))
+			}
+
+			event := events[0]
+			if event.Recurrence != tt.recurrence {
+				t.Errorf("recurrence = %q, want %q", event.Recurrence, tt.recurrence)
+			}
+			upcoming, ok := event.Upcoming(from)
+			if ok != !tt.upcoming.IsZero() || !upcoming.Equal(tt.upcoming) {
+				t.Errorf("Upcoming() = %v, %v, want %v", upcoming, ok, tt.upcoming)
+			}
+		})
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	if _, err := Decode(strings.NewReader("hello"), kyiv); err != ErrInvalidCalendar {
+		t.Errorf("err = %v, want ErrInvalidCalendar", err)
+	}
+}
+
+func TestParseTrigger(t *testing.T) {
+	tests := map[string]time.Duration{
+		"-PT15M":   15 * time.Minute,
+		"-PT1H30M": 90 * time.Minute,
+		"-P1D":     24 * time.Hour,
+		"PT0S":     0,
+	}
+
+	for value, want := range tests {
+		if got, ok := parseTrigger(value); !ok || got != want {
+			t.Errorf("parseTrigger(%q) = %v, %v, want %v", value, got, ok, want)
+		}
+	}
+	if _, ok := parseTrigger("PT5M"); ok {
+		t.Error("alarm after the start was accepted")
+	}
+}
@@ -0,0 +1,248 @@
+// Package ical writes tasks as iCalendar (RFC 5545) so phone calendars can
+// show them, e.g. through a subscribed feed.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/maxwww/family_bot/units"
+)
+
+const (
+	ProdID = "-//family_bot//tasks//UK"
+
+	dateFormat     = "20060102"
+	dateTimeFormat = "20060102T150405Z"
+
+	// lines are folded after 75 octets, continuation lines start with a space
+	maxLineLength = 75
+)
+
+// Encoder writes calendars of tasks.
+type Encoder struct {
+	// Location decides which dated tasks are all-day ones, tasks at its
+	// midnight have no time.
+	Location *time.Location
+	// Alarms returns how long before the task its reminders go off for the
+	// Notifications of the task, nil means the task has no alarms.
+	Alarms func(notifications int) []time.Duration
+	// Now is the time the calendar is written at.
+	Now func() time.Time
+}
+
+// UID returns the unique id of the task in calendars.
+func UID(task *units.Task) string {
+	return fmt.Sprintf("task-%d@family_bot", task.ID)
+}
+
+// Encode writes the calendar with the given name. Undated tasks become
+// to-dos, dated ones become events with their alarms and recurrence.
+func (e *Encoder) Encode(w io.Writer, name string, tasks []*units.Task) error {
+	cw := &writer{w: bufio.NewWriter(w)}
+
+	cw.line("BEGIN:VCALENDAR")
+	cw.line("VERSION:2.0")
+	cw.line("PRODID:" + ProdID)
+	cw.line("CALSCALE:GREGORIAN")
+	cw.line("METHOD:PUBLISH")
+	cw.line("X-WR-CALNAME:" + escapeText(name))
+	for _, task := range tasks {
+		e.encodeTask(cw, task)
+	}
+	cw.line("END:VCALENDAR")
+
+	return cw.flush()
+}
+
+func (e *Encoder) encodeTask(cw *writer, task *units.Task) {
+	component := "VTODO"
+	if task.Date.Valid {
+		component = "VEVENT"
+	}
+
+	stamp := task.UpdatedAt
+	if stamp.IsZero() {
+		stamp = e.now()
+	}
+
+	cw.line("BEGIN:" + component)
+	cw.line("UID:" + UID(task))
+	cw.line("DTSTAMP:" + formatDateTime(stamp))
+	if !task.CreatedAt.IsZero() {
+		cw.line("CREATED:" + formatDateTime(task.CreatedAt))
+		cw.line("LAST-MODIFIED:" + formatDateTime(stamp))
+	}
+	cw.line("SUMMARY:" + escapeText(task.Title))
+	if task.Notes != "" {
+		cw.line("DESCRIPTION:" + escapeText(task.Notes))
+	}
+
+	allDay := false
+	if task.Date.Valid {
+		local := task.Date.Time.In(e.location())
+		allDay = local.Hour() == 0 && local.Minute() == 0
+		if allDay {
+			cw.line("DTSTART;VALUE=DATE:" + local.Format(dateFormat))
+			cw.line("DTEND;VALUE=DATE:" + local.AddDate(0, 0, 1).Format(dateFormat))
+		} else {
+			cw.line("DTSTART:" + formatDateTime(task.Date.Time))
+		}
+		if rule := RRule(task.Recurrence); rule != "" {
+			cw.line("RRULE:" + rule)
+		}
+	}
+
+	if component == "VTODO" {
+		if task.Done {
+			cw.line("STATUS:COMPLETED")
+			if task.CompletedAt.Valid {
+				cw.line("COMPLETED:" + formatDateTime(task.CompletedAt.Time))
+			}
+		} else {
+			cw.line("STATUS:NEEDS-ACTION")
+		}
+	} else if task.Done {
+		// events cannot be completed, a done one is shown as cancelled and
+		// does not keep the time busy
+		cw.line("STATUS:CANCELLED")
+		cw.line("TRANSP:TRANSPARENT")
+	}
+
+	// all-day tasks have no time to remind about, the bot skips them too
+	if task.Date.Valid && !allDay && !task.Done && e.Alarms != nil {
+		for _, before := range e.Alarms(task.Notifications) {
+			cw.line("BEGIN:VALARM")
+			cw.line("ACTION:DISPLAY")
+			cw.line("DESCRIPTION:" + escapeText(task.Title))
+			cw.line("TRIGGER:" + formatTrigger(before))
+			cw.line("END:VALARM")
+		}
+	}
+
+	cw.line("END:" + component)
+}
+
+func (e *Encoder) location() *time.Location {
+	if e.Location == nil {
+		return time.UTC
+	}
+
+	return e.Location
+}
+
+func (e *Encoder) now() time.Time {
+	if e.Now == nil {
+		return time.Now()
+	}
+
+	return e.Now()
+}
+
+// RRule returns the RRULE value of the recurrence, empty for tasks which
+// do not repeat.
+func RRule(r units.Recurrence) string {
+	switch r.Base() {
+	case units.RecurrenceDaily:
+		return "FREQ=DAILY"
+	case units.RecurrenceWeekly:
+		return "FREQ=WEEKLY"
+	case units.RecurrenceMonthly:
+		return "FREQ=MONTHLY"
+	case units.RecurrenceYearly:
+		return "FREQ=YEARLY"
+	}
+
+	days := r.Weekdays()
+	if len(days) == 0 {
+		return ""
+	}
+
+	var names []string
+	for _, day := range days {
+		names = append(names, weekdayNames[day])
+	}
+
+	return "FREQ=WEEKLY;BYDAY=" + strings.Join(names, ",")
+}
+
+var weekdayNames = map[time.Weekday]string{
+	time.Sunday:    "SU",
+	time.Monday:    "MO",
+	time.Tuesday:   "TU",
+	time.Wednesday: "WE",
+	time.Thursday:  "TH",
+	time.Friday:    "FR",
+	time.Saturday:  "SA",
+}
+
+func formatDateTime(t time.Time) string {
+	return t.UTC().Format(dateTimeFormat)
+}
+
+// formatTrigger returns the duration of an alarm before the start,
+// e.g. -PT30M.
+func formatTrigger(before time.Duration) string {
+	if before <= 0 {
+		return "PT0S"
+	}
+
+	return fmt.Sprintf("-PT%dM", int(before/time.Minute))
+}
+
+var textEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+)
+
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// writer ends lines with CRLF and folds long ones without splitting
+// UTF-8 characters.
+type writer struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (cw *writer) line(s string) {
+	if cw.err != nil {
+		return
+	}
+
+	for len(s) > maxLineLength {
+		cut := maxLineLength
+		for cut > 0 && !isCharStart(s[cut]) {
+			cut--
+		}
+		cw.write(s[:cut] + "\r\n")
+		// the leading space of the continuation counts towards its length
+		s = " " + s[cut:]
+	}
+	cw.write(s + "\r\n")
+}
+
+func (cw *writer) write(s string) {
+	if cw.err == nil {
+		_, cw.err = cw.w.WriteString(s)
+	}
+}
+
+func (cw *writer) flush() error {
+	if cw.err != nil {
+		return cw.err
+	}
+
+	return cw.w.Flush()
+}
+
+func isCharStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
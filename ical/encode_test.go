@@ -0,0 +1,132 @@
+package ical
+
+import (
+	"bytes"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maxwww/family_bot/units"
+)
+
+var kyiv = time.FixedZone("EEST", 3*60*60)
+
+func encode(t *testing.T, tasks ...*units.Task) string {
+	t.Helper()
+
+	encoder := &Encoder{
+		Location: kyiv,
+		Alarms: func(notifications int) []time.Duration {
+			if notifications == 0 {
+				return nil
+			}
+			return []time.Duration{time.Hour, 0}
+		},
+		Now: func() time.Time {
+			return time.Date(2022, 5, 4, 7, 15, 0, 0, time.UTC)
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := encoder.Encode(&buf, "Сім'я", tasks); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.String()
+}
+
+func TestEncode(t *testing.T) {
+	out := encode(t,
+		&units.Task{ID: 1, Title: "купити хліб, молоко", Notes: "два\nбатони"},
+		&units.Task{
+			ID:            2,
+			Title:         "басейн",
+			Date:          sql.NullTime{Time: time.Date(2022, 5, 6, 18, 30, 0, 0, kyiv), Valid: true},
+			Notifications: 1,
+			Recurrence:    units.NewWeekdaysRecurrence(time.Monday, time.Friday),
+		},
+		&units.Task{
+			ID:            3,
+			Title:         "день народження",
+			Date:          sql.NullTime{Time: time.Date(2022, 6, 1, 0, 0, 0, 0, kyiv), Valid: true},
+			Notifications: 1,
+			Recurrence:    units.RecurrenceYearly,
+		},
+	)
+
+	for _, line := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"X-WR-CALNAME:Сім'я\r\n",
+		"BEGIN:VTODO\r\nUID:task-1@family_bot\r\nDTSTAMP:20220504T071500Z\r\n",
+		"SUMMARY:купити хліб\\, молоко\r\n",
+		"DESCRIPTION:два\\nбатони\r\n",
+		"STATUS:NEEDS-ACTION\r\n",
+		"BEGIN:VEVENT\r\nUID:task-2@family_bot\r\n",
+		"DTSTART:20220506T153000Z\r\nRRULE:FREQ=WEEKLY;BYDAY=MO,FR\r\n",
+		"TRIGGER:-PT60M\r\n",
+		"TRIGGER:PT0S\r\n",
+		"DTSTART;VALUE=DATE:20220601\r\nDTEND;VALUE=DATE:20220602\r\nRRULE:FREQ=YEARLY\r\nEND:VEVENT\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("calendar has no %q:\n%s", line, out)
+		}
+	}
+	if n := strings.Count(out, "BEGIN:VALARM"); n != 2 {
+		t.Errorf("calendar has %d alarms, want 2 of the timed task", n)
+	}
+}
+
+func TestEncodeDoneTasks(t *testing.T) {
+	out := encode(t,
+		&units.Task{ID: 1, Title: "купити хліб", Done: true, CompletedAt: sql.NullTime{Time: time.Date(2022, 5, 4, 6, 0, 0, 0, time.UTC), Valid: true}},
+		&units.Task{
+			ID:            2,
+			Title:         "лікар",
+			Date:          sql.NullTime{Time: time.Date(2022, 5, 4, 9, 30, 0, 0, kyiv), Valid: true},
+			Notifications: 1,
+			Done:          true,
+		},
+	)
+
+	for _, line := range []string{
+		"STATUS:COMPLETED\r\nCOMPLETED:20220504T060000Z\r\nEND:VTODO\r\n",
+		"STATUS:CANCELLED\r\nTRANSP:TRANSPARENT\r\nEND:VEVENT\r\n",
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("calendar has no %q:\n%s", line, out)
+		}
+	}
+	if strings.Contains(out, "BEGIN:VALARM") {
+		t.Errorf("done event has alarms:\n%s", out)
+	}
+}
+
+func TestEncodeFoldsLongLines(t *testing.T) {
+	out := encode(t, &units.Task{ID: 1, Title: strings.Repeat("дуже довга назва ", 10)})
+
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		if len(line) > maxLineLength {
+			t.Errorf("line %q is %d octets long", line, len(line))
+		}
+	}
+	if !strings.Contains(strings.ReplaceAll(out, "\r\n ", ""), "SUMMARY:"+strings.Repeat("дуже довга назва ", 10)) {
+		t.Errorf("unfolded calendar lost the title:\n%s", out)
+	}
+}
+
+func TestRRule(t *testing.T) {
+	tests := map[units.Recurrence]string{
+		units.RecurrenceNone:    "",
+		units.RecurrenceDaily:   "FREQ=DAILY",
+		units.RecurrenceMonthly: "FREQ=MONTHLY",
+		units.NewWeekdaysRecurrence(time.Sunday, time.Wednesday): "FREQ=WEEKLY;BYDAY=SU,WE",
+	}
+
+	for recurrence, want := range tests {
+		if got := RRule(recurrence); got != want {
+			t.Errorf("RRule(%q) = %q, want %q", recurrence, got, want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/maxwww/family_bot/units"
+	"log"
+)
+
+var _ units.FamilyService = (*FamilyService)(nil)
+
+type FamilyService struct {
+	db *DB
+}
+
+func NewFamilyService(db *DB) *FamilyService {
+	return &FamilyService{db}
+}
+
+func (fs *FamilyService) CreateFamily(ctx context.Context, family *units.Family) error {
+	tx, err := fs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO families (name)
+	VALUES ($1) RETURNING id, created_at;
+	`
+	if err := tx.QueryRowxContext(ctx, query, family.Name).Scan(&family.ID, &family.CreatedAt); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (fs *FamilyService) FamilyByID(ctx context.Context, familyId uint) (*units.Family, error) {
+	tx, err := fs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	family, err := findOneFamily(ctx, tx, units.FamilyFilter{Id: &familyId})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return family, nil
+}
+
+func (fs *FamilyService) Families(ctx context.Context, ff units.FamilyFilter) ([]*units.Family, error) {
+	tx, err := fs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	families, err := findFamilies(ctx, tx, ff)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return families, tx.Commit()
+}
+
+func (fs *FamilyService) CreateInvite(ctx context.Context, invite *units.FamilyInvite) error {
+	tx, err := fs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO family_invites (code, family_id, created_by, expires_at)
+	VALUES ($1, $2, $3, $4);
+	`
+	args := []interface{}{invite.Code, invite.FamilyID, invite.CreatedBy, invite.ExpiresAt}
+	if err := execQuery(ctx, tx, query, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (fs *FamilyService) RedeemInvite(ctx context.Context, code string, user *units.User) (*units.Family, error) {
+	tx, err := fs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Println(err)
+		return nil, units.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	UPDATE family_invites
+	SET used_by = $1, used_at = now()
+	WHERE code = $2 AND used_at IS NULL AND expires_at > now()
+	RETURNING family_id;`
+
+	var familyId uint
+	if err := tx.QueryRowxContext(ctx, query, user.ID, code).Scan(&familyId); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, units.ErrNotFound
+		}
+		log.Println(err)
+		return nil, units.ErrInternal
+	}
+
+	family, err := findOneFamily(ctx, tx, units.FamilyFilter{Id: &familyId})
+	if err != nil {
+		log.Println(err)
+		return nil, units.ErrInternal
+	}
+
+	familyID := sql.NullInt64{Int64: int64(family.ID), Valid: true}
+	if err := updateUser(ctx, tx, user, units.UserPatch{FamilyID: &familyID}); err != nil {
+		log.Println(err)
+		return nil, units.ErrInternal
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return nil, units.ErrInternal
+	}
+
+	return family, nil
+}
+
+func (fs *FamilyService) SetCalendarToken(ctx context.Context, familyId uint, token string) error {
+	tx, err := fs.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := execQuery(ctx, tx, `UPDATE families SET calendar_token = $1 WHERE id = $2`, token, familyId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func findOneFamily(ctx context.Context, tx *sqlx.Tx, filter units.FamilyFilter) (*units.Family, error) {
+	fs, err := findFamilies(ctx, tx, filter)
+
+	if err != nil {
+		return nil, err
+	} else if len(fs) == 0 {
+		return nil, units.ErrNotFound
+	}
+
+	return fs[0], nil
+}
+
+func findFamilies(ctx context.Context, tx *sqlx.Tx, filter units.FamilyFilter) ([]*units.Family, error) {
+	where, args := []string{}, []interface{}{}
+	argPosition := 0
+
+	if v := filter.Id; v != nil {
+		argPosition++
+		where, args = append(where, fmt.Sprintf("id = $%d", argPosition)), append(args, *v)
+	}
+
+	if v := filter.CalendarToken; v != nil {
+		argPosition++
+		where, args = append(where, fmt.Sprintf("calendar_token = $%d", argPosition)), append(args, *v)
+	}
+
+	query := "SELECT * from families" + formatWhereClause(where) +
+		" ORDER BY id ASC" + formatLimitOffset(filter.Limit, filter.Offset)
+
+	families := make([]*units.Family, 0)
+
+	if err := findMany(ctx, tx, &families, query, args...); err != nil {
+		return nil, err
+	}
+
+	return families, nil
+}
@@ -0,0 +1,208 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/maxwww/family_bot/units"
+	"log"
+)
+
+var _ units.ListService = (*ListService)(nil)
+
+type ListService struct {
+	db *DB
+}
+
+func NewListService(db *DB) *ListService {
+	return &ListService{db}
+}
+
+func (ls *ListService) CreateList(ctx context.Context, list *units.List) error {
+	tx, err := ls.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := createList(ctx, tx, list); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func createList(ctx context.Context, tx *sqlx.Tx, list *units.List) error {
+	query := `
+	INSERT INTO lists (family_id, name, kind)
+	VALUES ($1, $2, $3) RETURNING id, created_at;
+	`
+
+	return tx.QueryRowxContext(ctx, query, list.FamilyID, list.Name, list.Kind).Scan(&list.ID, &list.CreatedAt)
+}
+
+func (ls *ListService) ListByID(ctx context.Context, listId uint) (*units.List, error) {
+	tx, err := ls.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	list, err := findOneList(ctx, tx, units.ListFilter{Id: &listId})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (ls *ListService) Lists(ctx context.Context, lf units.ListFilter) ([]*units.List, error) {
+	tx, err := ls.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	lists, err := findLists(ctx, tx, lf)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return lists, tx.Commit()
+}
+
+func (ls *ListService) UpdateList(ctx context.Context, list *units.List, patch units.ListPatch) error {
+	tx, err := ls.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Println(err)
+		return units.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	if v := patch.Name; v != nil {
+		list.Name = *v
+	}
+
+	if err := execQuery(ctx, tx, `UPDATE lists SET name = $1 WHERE id = $2`, list.Name, list.ID); err != nil {
+		log.Println(err)
+		return units.ErrInternal
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return units.ErrInternal
+	}
+
+	return nil
+}
+
+func (ls *ListService) RemoveByID(ctx context.Context, listId uint) error {
+	tx, err := ls.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Println(err)
+		return units.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	// tasks of the list are removed by the foreign key
+	if err := execQuery(ctx, tx, `DELETE FROM lists WHERE id = $1`, listId); err != nil {
+		log.Println(err)
+		return units.ErrInternal
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return units.ErrInternal
+	}
+
+	return nil
+}
+
+func (ls *ListService) DefaultList(ctx context.Context, familyId uint, kind units.ListKind, name string) (*units.List, error) {
+	tx, err := ls.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	// the family row is locked so concurrent updates do not create the list twice
+	if err := execQuery(ctx, tx, `SELECT id FROM families WHERE id = $1 FOR UPDATE`, familyId); err != nil {
+		return nil, err
+	}
+
+	list, err := findOneList(ctx, tx, units.ListFilter{FamilyID: &familyId, Kind: &kind, Limit: 1})
+	if err == units.ErrNotFound {
+		list = &units.List{FamilyID: familyId, Name: name, Kind: kind}
+		err = createList(ctx, tx, list)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func findOneList(ctx context.Context, tx *sqlx.Tx, filter units.ListFilter) (*units.List, error) {
+	ls, err := findLists(ctx, tx, filter)
+
+	if err != nil {
+		return nil, err
+	} else if len(ls) == 0 {
+		return nil, units.ErrNotFound
+	}
+
+	return ls[0], nil
+}
+
+func findLists(ctx context.Context, tx *sqlx.Tx, filter units.ListFilter) ([]*units.List, error) {
+	where, args := []string{}, []interface{}{}
+	argPosition := 0
+
+	if v := filter.Id; v != nil {
+		argPosition++
+		where, args = append(where, fmt.Sprintf("id = $%d", argPosition)), append(args, *v)
+	}
+
+	if v := filter.FamilyID; v != nil {
+		argPosition++
+		where, args = append(where, fmt.Sprintf("family_id = $%d", argPosition)), append(args, *v)
+	}
+
+	if v := filter.Kind; v != nil {
+		argPosition++
+		where, args = append(where, fmt.Sprintf("kind = $%d", argPosition)), append(args, *v)
+	}
+
+	query := "SELECT * from lists" + formatWhereClause(where) +
+		" ORDER BY id ASC" + formatLimitOffset(filter.Limit, filter.Offset)
+
+	lists := make([]*units.List, 0)
+
+	if err := findMany(ctx, tx, &lists, query, args...); err != nil {
+		return nil, err
+	}
+
+	return lists, nil
+}
@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/maxwww/family_bot/units"
+)
+
+var _ units.ListMessageService = (*ListMessageService)(nil)
+
+type ListMessageService struct {
+	db *DB
+}
+
+func NewListMessageService(db *DB) *ListMessageService {
+	return &ListMessageService{db}
+}
+
+func (ls *ListMessageService) ListMessageByChatID(ctx context.Context, chatID int64) (*units.ListMessage, error) {
+	query := `SELECT * FROM list_messages WHERE chat_id = $1;`
+
+	var message units.ListMessage
+	if err := ls.db.GetContext(ctx, &message, query, chatID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, units.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &message, nil
+}
+
+func (ls *ListMessageService) ListMessages(ctx context.Context, familyID uint) ([]*units.ListMessage, error) {
+	query := `
+	SELECT lm.* FROM list_messages lm
+	JOIN users u ON u.id = lm.user_id
+	WHERE u.family_id = $1 AND lm.message_id IS NOT NULL
+	ORDER BY lm.chat_id;`
+
+	messages := make([]*units.ListMessage, 0)
+	if err := ls.db.SelectContext(ctx, &messages, query, familyID); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+func (ls *ListMessageService) SaveListMessage(ctx context.Context, message *units.ListMessage) error {
+	query := `
+	INSERT INTO list_messages (chat_id, user_id, message_id, pinned, updated_at)
+	VALUES ($1, $2, $3, $4, now())
+	ON CONFLICT (chat_id) DO UPDATE
+	SET user_id = excluded.user_id, message_id = excluded.message_id,
+	    pinned = excluded.pinned, updated_at = excluded.updated_at
+	RETURNING updated_at;`
+
+	args := []interface{}{message.ChatID, message.UserID, message.MessageID, message.Pinned}
+
+	return ls.db.QueryRowxContext(ctx, query, args...).Scan(&message.UpdatedAt)
+}
@@ -0,0 +1,377 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationsLockKey is an arbitrary key of the advisory lock which keeps
+// several bot instances from migrating the schema at the same time.
+const migrationsLockKey = 7283910
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+type Migration struct {
+	Version uint
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus tells whether the migration is applied, AppliedAt is nil
+// for pending migrations and zero for the ones applied before their time
+// was recorded.
+type MigrationStatus struct {
+	Version   uint
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Migrate applies all pending up-migrations. Older versions stored naive
+// timestamps in the bot's location, loc tells the migrations which one it was.
+func Migrate(ctx context.Context, db *DB, loc *time.Location) error {
+	return withMigrationsLock(ctx, db, func(conn *sql.Conn) error {
+		migrations, err := loadMigrations()
+		if err != nil {
+			return err
+		}
+
+		if err := ensureMigrationsTable(ctx, conn, migrations); err != nil {
+			return err
+		}
+
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+
+			insert := `INSERT INTO schema_migrations (version, name) VALUES ($1, $2);`
+			if err := runMigration(ctx, conn, loc, m.Up, insert, m.Version, m.Name); err != nil {
+				return fmt.Errorf("migration %06d_%s: %w", m.Version, m.Name, err)
+			}
+			log.Printf("applied migration %06d_%s", m.Version, m.Name)
+		}
+
+		return nil
+	})
+}
+
+// MigrateDown rolls back the given number of the most recent migrations.
+func MigrateDown(ctx context.Context, db *DB, steps int, loc *time.Location) error {
+	return withMigrationsLock(ctx, db, func(conn *sql.Conn) error {
+		migrations, err := loadMigrations()
+		if err != nil {
+			return err
+		}
+
+		if err := ensureMigrationsTable(ctx, conn, migrations); err != nil {
+			return err
+		}
+
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+
+			remove := `DELETE FROM schema_migrations WHERE version = $1;`
+			if err := runMigration(ctx, conn, loc, m.Down, remove, m.Version); err != nil {
+				return fmt.Errorf("migration %06d_%s: %w", m.Version, m.Name, err)
+			}
+			log.Printf("reverted migration %06d_%s", m.Version, m.Name)
+			steps--
+		}
+
+		return nil
+	})
+}
+
+// MigrationsStatus lists every embedded migration with the time it was applied.
+// It only reads the database, tables left by golang-migrate or made by hand
+// are reported the way Migrate would convert them.
+func MigrationsStatus(ctx context.Context, db *DB) ([]MigrationStatus, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	return migrationsStatus(ctx, conn)
+}
+
+func migrationsStatus(ctx context.Context, conn *sql.Conn) ([]MigrationStatus, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	version, convert, err := existingMigrations(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := map[uint]time.Time{}
+	if convert {
+		for _, m := range migrations {
+			if int64(m.Version) <= version {
+				applied[m.Version] = time.Time{}
+			}
+		}
+	} else if applied, err = appliedMigrations(ctx, conn); err != nil {
+		return nil, err
+	}
+
+	result := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status := MigrationStatus{Version: m.Version, Name: m.Name}
+		if appliedAt, ok := applied[m.Version]; ok {
+			status.AppliedAt = &appliedAt
+		}
+		result = append(result, status)
+	}
+
+	return result, nil
+}
+
+func withMigrationsLock(ctx context.Context, db *DB, fn func(conn *sql.Conn) error) error {
+	// advisory locks belong to a session, so everything runs on one connection
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationsLockKey); err != nil {
+		return err
+	}
+
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationsLockKey); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	return fn(conn)
+}
+
+// ensureMigrationsTable creates the table of applied versions, the versions
+// found by existingMigrations are recorded in it.
+func ensureMigrationsTable(ctx context.Context, conn *sql.Conn, migrations []Migration) error {
+	version, convert, err := existingMigrations(ctx, conn)
+	if err != nil || !convert {
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	DROP TABLE IF EXISTS schema_migrations;
+	CREATE TABLE schema_migrations
+	(
+		version    bigint       not null primary key,
+		name       varchar(255) not null,
+		applied_at timestamp    not null default now()
+	);`
+
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if int64(m.Version) > version {
+			break
+		}
+		insert := `INSERT INTO schema_migrations (version, name) VALUES ($1, $2);`
+		if _, err := tx.ExecContext(ctx, insert, m.Version, m.Name); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// existingMigrations finds the latest version applied before the table of
+// applied versions was made, convert is false when the table is there already.
+// The golang-migrate CLI kept a single (version, dirty) row, and databases set
+// up by hand have the tables of the first migration without any record.
+func existingMigrations(ctx context.Context, conn *sql.Conn) (version int64, convert bool, err error) {
+	query := `
+	SELECT to_regclass('schema_migrations') IS NOT NULL, EXISTS (
+		SELECT 1 FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'schema_migrations' AND column_name = 'dirty'
+	), to_regclass('users') IS NOT NULL;`
+
+	var exists, legacy, initialized bool
+	if err := conn.QueryRowContext(ctx, query).Scan(&exists, &legacy, &initialized); err != nil {
+		return 0, false, err
+	}
+
+	switch {
+	case exists && !legacy:
+		return 0, false, nil
+	case !exists && initialized:
+		return 1, true, nil
+	case !exists:
+		return 0, true, nil
+	}
+
+	var dirty bool
+	err = conn.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if err == sql.ErrNoRows {
+		return 0, true, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+	if dirty {
+		return 0, false, fmt.Errorf("golang-migrate left version %d dirty, repair the schema and force a clean version first", version)
+	}
+
+	return version, true, nil
+}
+
+func appliedMigrations(ctx context.Context, conn *sql.Conn) (map[uint]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	applied := map[uint]time.Time{}
+	for rows.Next() {
+		var version uint
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+func runMigration(ctx context.Context, conn *sql.Conn, loc *time.Location, script string, bookkeeping string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	// converting between timestamp and timestamptz uses the session time zone,
+	// it is set for the transaction only so pooled connections are not affected
+	if loc == nil {
+		loc = time.UTC
+	}
+	if _, err := tx.ExecContext(ctx, `SELECT set_config('TimeZone', $1, true)`, loc.String()); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// loadMigrations reads the embedded migrations.
+func loadMigrations() ([]Migration, error) {
+	return readMigrations(migrationsFS)
+}
+
+// readMigrations reads NNNNNN_name.up.sql/NNNNNN_name.down.sql pairs of the
+// migrations directory ordered by version, every migration needs both files.
+func readMigrations(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[uint]*Migration{}
+	for _, file := range files {
+		base := path.Base(file)
+		parts := strings.SplitN(base, "_", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid migration file name %s", base)
+		}
+
+		version, err := strconv.ParseUint(parts[0], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration file name %s", base)
+		}
+
+		var name string
+		var up bool
+		switch {
+		case strings.HasSuffix(parts[1], ".up.sql"):
+			name, up = strings.TrimSuffix(parts[1], ".up.sql"), true
+		case strings.HasSuffix(parts[1], ".down.sql"):
+			name = strings.TrimSuffix(parts[1], ".down.sql")
+		default:
+			return nil, fmt.Errorf("invalid migration file name %s", base)
+		}
+
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[uint(version)]
+		if !ok {
+			m = &Migration{Version: uint(version), Name: name}
+			byVersion[uint(version)] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration %06d is named both %s and %s", version, m.Name, name)
+		}
+
+		if up {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %06d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
@@ -0,0 +1,205 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestReadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/000010_lists.down.sql": {Data: []byte("DROP TABLE lists;")},
+		"migrations/000002_tasks.up.sql":   {Data: []byte("CREATE TABLE tasks ();")},
+		"migrations/000010_lists.up.sql":   {Data: []byte("CREATE TABLE lists ();")},
+		"migrations/000002_tasks.down.sql": {Data: []byte("DROP TABLE tasks;")},
+		"migrations/README.md":             {Data: []byte("not a migration")},
+	}
+
+	migrations, err := readMigrations(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Migration{
+		{Version: 2, Name: "tasks", Up: "CREATE TABLE tasks ();", Down: "DROP TABLE tasks;"},
+		{Version: 10, Name: "lists", Up: "CREATE TABLE lists ();", Down: "DROP TABLE lists;"},
+	}
+	if len(migrations) != len(want) {
+		t.Fatalf("migrations = %+v, want %+v", migrations, want)
+	}
+	for i := range want {
+		if migrations[i] != want[i] {
+			t.Errorf("migration %d = %+v, want %+v", i, migrations[i], want[i])
+		}
+	}
+}
+
+func TestReadMigrationsRejectsBrokenFiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+	}{
+		{"missing down", []string{"000001_init.up.sql"}},
+		{"missing up", []string{"000001_init.down.sql"}},
+		{"different names", []string{"000001_init.up.sql", "000001_users.down.sql"}},
+		{"no version", []string{"init.up.sql", "init.down.sql"}},
+		{"no direction", []string{"000001_init.sql"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{}
+			for _, file := range tt.files {
+				fsys["migrations/"+file] = &fstest.MapFile{Data: []byte("SELECT 1;")}
+			}
+			if migrations, err := readMigrations(fsys); err == nil {
+				t.Errorf("migrations = %+v, want an error", migrations)
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, m := range migrations {
+		if m.Version != uint(i+1) {
+			t.Errorf("migration %06d_%s follows version %d", m.Version, m.Name, i)
+		}
+	}
+}
+
+// testSchema returns a connection to an empty schema of the test database,
+// it is dropped when the test ends.
+func testSchema(t *testing.T) *sql.Conn {
+	t.Helper()
+
+	db := testDB(t)
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema := fmt.Sprintf("test_migrations_%d", time.Now().UnixNano())
+	if _, err := conn.ExecContext(ctx, "CREATE SCHEMA "+schema+"; SET search_path TO "+schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := conn.ExecContext(ctx, "RESET search_path; DROP SCHEMA "+schema+" CASCADE"); err != nil {
+			t.Error(err)
+		}
+		conn.Close()
+	})
+
+	return conn
+}
+
+func testLegacyMigrations(t *testing.T, conn *sql.Conn, version int, dirty bool) {
+	t.Helper()
+
+	query := `
+	CREATE TABLE schema_migrations (version bigint not null primary key, dirty boolean not null);
+	INSERT INTO schema_migrations (version, dirty) VALUES ($1, $2);`
+
+	if _, err := conn.ExecContext(context.Background(), query, version, dirty); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConvertLegacyMigrations(t *testing.T) {
+	conn := testSchema(t)
+	ctx := context.Background()
+	testLegacyMigrations(t, conn, 3, false)
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ensureMigrationsTable(ctx, conn, migrations); err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 3 {
+		t.Errorf("applied = %v, want versions 1 to 3", applied)
+	}
+	for version := uint(1); version <= 3; version++ {
+		if _, ok := applied[version]; !ok {
+			t.Errorf("version %d is not applied", version)
+		}
+	}
+}
+
+func TestDirtyLegacyMigrationsAreRefused(t *testing.T) {
+	conn := testSchema(t)
+	ctx := context.Background()
+	testLegacyMigrations(t, conn, 3, true)
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ensureMigrationsTable(ctx, conn, migrations); err == nil {
+		t.Fatal("a dirty version was converted")
+	}
+
+	var dirty bool
+	if err := conn.QueryRowContext(ctx, `SELECT dirty FROM schema_migrations`).Scan(&dirty); err != nil || !dirty {
+		t.Errorf("legacy table changed: dirty = %v, err = %v", dirty, err)
+	}
+}
+
+func TestExistingTablesAreRecorded(t *testing.T) {
+	conn := testSchema(t)
+	ctx := context.Background()
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE users (id serial not null unique)`); err != nil {
+		t.Fatal(err)
+	}
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ensureMigrationsTable(ctx, conn, migrations); err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := applied[1]; len(applied) != 1 || !ok {
+		t.Errorf("applied = %v, want the first migration only", applied)
+	}
+}
+
+func TestMigrationsStatusIsReadOnly(t *testing.T) {
+	conn := testSchema(t)
+	ctx := context.Background()
+	testLegacyMigrations(t, conn, 2, false)
+
+	statuses, err := migrationsStatus(ctx, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if applied := status.AppliedAt != nil; applied != (status.Version <= 2) {
+			t.Errorf("migration %06d applied = %v", status.Version, applied)
+		}
+	}
+
+	var dirty bool
+	if err := conn.QueryRowContext(ctx, `SELECT dirty FROM schema_migrations`).Scan(&dirty); err != nil {
+		t.Errorf("legacy table was converted: %v", err)
+	}
+}
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS task_assignees
+(
+    task_id integer not null references tasks (id) on delete cascade,
+    user_id integer not null references users (id) on delete cascade,
+    primary key (task_id, user_id)
+);
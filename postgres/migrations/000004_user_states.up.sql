@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS user_states
+(
+    telegram_id bigint    not null primary key,
+    payload     jsonb     not null,
+    updated_at  timestamp not null default now()
+);
@@ -0,0 +1,9 @@
+ALTER TABLE tasks
+    DROP COLUMN IF EXISTS family_id;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS family_id;
+
+DROP TABLE IF EXISTS family_invites;
+
+DROP TABLE IF EXISTS families;
@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS families
+(
+    id         serial       not null primary key,
+    name       varchar(255) not null,
+    created_at timestamp    not null default now()
+);
+
+CREATE TABLE IF NOT EXISTS family_invites
+(
+    code       varchar(32) not null primary key,
+    family_id  integer     not null references families (id) on delete cascade,
+    created_by integer references users (id) on delete set null,
+    expires_at timestamp   not null,
+    used_by    integer references users (id) on delete set null,
+    used_at    timestamp
+);
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS family_id integer references families (id) on delete set null;
+
+ALTER TABLE tasks
+    ADD COLUMN IF NOT EXISTS family_id integer references families (id) on delete cascade;
+
+-- tasks created before families existed belong to the first family,
+-- bootstrap admins join it on their next message
+INSERT INTO families (name)
+SELECT 'Сім''я'
+WHERE NOT EXISTS(SELECT 1 FROM families)
+  AND EXISTS(SELECT 1 FROM tasks);
+
+UPDATE tasks
+SET family_id = (SELECT min(id) FROM families)
+WHERE family_id IS NULL;
+
+ALTER TABLE tasks
+    ALTER COLUMN family_id SET NOT NULL;
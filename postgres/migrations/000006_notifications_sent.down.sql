@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS cron_ticks;
+
+DROP TABLE IF EXISTS notifications_sent;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS notifications_sent
+(
+    task_id        integer   not null references tasks (id) on delete cascade,
+    offset_minutes integer   not null,
+    due_at         timestamp not null,
+    sent_at        timestamp not null default now(),
+    primary key (task_id, offset_minutes, due_at)
+);
+
+CREATE TABLE IF NOT EXISTS cron_ticks
+(
+    name    varchar(64) not null primary key,
+    tick_at timestamp   not null
+);
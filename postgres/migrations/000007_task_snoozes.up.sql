@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS task_snoozes
+(
+    id         serial    not null primary key,
+    task_id    integer   not null references tasks (id) on delete cascade,
+    created_by integer references users (id) on delete set null,
+    remind_at  timestamp not null,
+    sent_at    timestamp
+);
+
+CREATE INDEX IF NOT EXISTS task_snoozes_pending_idx ON task_snoozes (remind_at) WHERE sent_at IS NULL;
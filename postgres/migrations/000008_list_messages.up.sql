@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS list_messages
+(
+    chat_id    bigint    not null primary key,
+    user_id    integer   not null references users (id) on delete cascade,
+    message_id integer,
+    pinned     boolean   not null default false,
+    updated_at timestamp not null default now()
+);
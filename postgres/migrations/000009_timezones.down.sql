@@ -0,0 +1,17 @@
+ALTER TABLE family_invites
+    ALTER COLUMN expires_at TYPE timestamp USING expires_at::timestamp;
+
+ALTER TABLE cron_ticks
+    ALTER COLUMN tick_at TYPE timestamp USING tick_at::timestamp;
+
+ALTER TABLE notifications_sent
+    ALTER COLUMN due_at TYPE timestamp USING due_at::timestamp;
+
+ALTER TABLE task_snoozes
+    ALTER COLUMN remind_at TYPE timestamp USING remind_at::timestamp;
+
+ALTER TABLE tasks
+    ALTER COLUMN date TYPE timestamp USING date::timestamp;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS timezone;
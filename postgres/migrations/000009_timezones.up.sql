@@ -0,0 +1,19 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS timezone varchar(64) not null default '';
+
+-- naive timestamps were written in the bot's location, the migration runs
+-- with the session time zone set to it so they keep pointing at the same instants
+ALTER TABLE tasks
+    ALTER COLUMN date TYPE timestamptz USING date::timestamptz;
+
+ALTER TABLE task_snoozes
+    ALTER COLUMN remind_at TYPE timestamptz USING remind_at::timestamptz;
+
+ALTER TABLE notifications_sent
+    ALTER COLUMN due_at TYPE timestamptz USING due_at::timestamptz;
+
+ALTER TABLE cron_ticks
+    ALTER COLUMN tick_at TYPE timestamptz USING tick_at::timestamptz;
+
+ALTER TABLE family_invites
+    ALTER COLUMN expires_at TYPE timestamptz USING expires_at::timestamptz;
@@ -0,0 +1,3 @@
+-- empty means the language is taken from the user's Telegram client
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS language varchar(8) not null default '';
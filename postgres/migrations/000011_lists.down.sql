@@ -0,0 +1,14 @@
+-- shopping items would turn into to-dos without their lists
+DELETE FROM tasks
+WHERE list_id IN (SELECT id FROM lists WHERE kind <> 'tasks');
+
+ALTER TABLE tasks
+    DROP COLUMN IF EXISTS category;
+ALTER TABLE tasks
+    DROP COLUMN IF EXISTS unit;
+ALTER TABLE tasks
+    DROP COLUMN IF EXISTS quantity;
+ALTER TABLE tasks
+    DROP COLUMN IF EXISTS list_id;
+
+DROP TABLE IF EXISTS lists;
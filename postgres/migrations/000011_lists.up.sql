@@ -0,0 +1,35 @@
+CREATE TABLE IF NOT EXISTS lists
+(
+    id         serial       not null primary key,
+    family_id  integer      not null references families (id) on delete cascade,
+    name       varchar(255) not null,
+    kind       varchar(16)  not null default 'tasks',
+    created_at timestamptz  not null default now()
+);
+
+CREATE INDEX IF NOT EXISTS lists_family_id_kind_idx ON lists (family_id, kind);
+
+ALTER TABLE tasks
+    ADD COLUMN IF NOT EXISTS list_id integer references lists (id) on delete cascade;
+ALTER TABLE tasks
+    ADD COLUMN IF NOT EXISTS quantity numeric(12, 3);
+ALTER TABLE tasks
+    ADD COLUMN IF NOT EXISTS unit varchar(16) not null default '';
+ALTER TABLE tasks
+    ADD COLUMN IF NOT EXISTS category varchar(32) not null default '';
+
+-- existing tasks move to a task list of their family
+INSERT INTO lists (family_id, name, kind)
+SELECT f.id, 'Завдання', 'tasks'
+FROM families f
+WHERE EXISTS(SELECT 1 FROM tasks t WHERE t.family_id = f.id)
+  AND NOT EXISTS(SELECT 1 FROM lists l WHERE l.family_id = f.id AND l.kind = 'tasks');
+
+UPDATE tasks t
+SET list_id = (SELECT min(l.id) FROM lists l WHERE l.family_id = t.family_id AND l.kind = 'tasks')
+WHERE list_id IS NULL;
+
+ALTER TABLE tasks
+    ALTER COLUMN list_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS tasks_list_id_idx ON tasks (list_id);
@@ -0,0 +1,3 @@
+-- the list new tasks of the user go to, empty means the family task list
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS active_list_id integer references lists (id) on delete set null;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS task_items;
+
+ALTER TABLE tasks
+    DROP COLUMN IF EXISTS notes;
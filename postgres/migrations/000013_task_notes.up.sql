@@ -0,0 +1,13 @@
+ALTER TABLE tasks
+    ADD COLUMN IF NOT EXISTS notes text not null default '';
+
+CREATE TABLE IF NOT EXISTS task_items
+(
+    id       serial       not null primary key,
+    task_id  integer      not null references tasks (id) on delete cascade,
+    title    varchar(255) not null,
+    done     boolean      not null default false,
+    position integer      not null default 0
+);
+
+CREATE INDEX IF NOT EXISTS task_items_task_id_idx ON task_items (task_id, position);
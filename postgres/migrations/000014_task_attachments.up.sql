@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS task_attachments
+(
+    id         serial       not null primary key,
+    task_id    integer      not null references tasks (id) on delete cascade,
+    kind       varchar(16)  not null,
+    file_id    varchar(255) not null,
+    created_at timestamptz  not null default now()
+);
+
+CREATE INDEX IF NOT EXISTS task_attachments_task_id_idx ON task_attachments (task_id);
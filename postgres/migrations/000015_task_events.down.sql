@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS task_events;
+
+ALTER TABLE tasks
+    DROP COLUMN IF EXISTS created_by,
+    DROP COLUMN IF EXISTS created_at,
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS completed_by,
+    DROP COLUMN IF EXISTS completed_at;
@@ -0,0 +1,21 @@
+ALTER TABLE tasks
+    ADD COLUMN IF NOT EXISTS created_by   integer references users (id) on delete set null,
+    ADD COLUMN IF NOT EXISTS created_at   timestamptz not null default now(),
+    ADD COLUMN IF NOT EXISTS updated_at   timestamptz not null default now(),
+    ADD COLUMN IF NOT EXISTS completed_by integer references users (id) on delete set null,
+    ADD COLUMN IF NOT EXISTS completed_at timestamptz;
+
+-- events outlive their tasks so there is no foreign key on task_id
+CREATE TABLE IF NOT EXISTS task_events
+(
+    id         serial       not null primary key,
+    task_id    integer      not null,
+    family_id  integer      not null references families (id) on delete cascade,
+    user_id    integer      references users (id) on delete set null,
+    kind       varchar(16)  not null,
+    title      varchar(255) not null,
+    fields     varchar(255) not null default '',
+    created_at timestamptz  not null default now()
+);
+
+CREATE INDEX IF NOT EXISTS task_events_task_id_idx ON task_events (task_id, id);
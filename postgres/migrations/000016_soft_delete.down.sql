@@ -0,0 +1,10 @@
+DELETE FROM tasks WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS task_events_user_id_idx;
+
+ALTER TABLE task_events
+    DROP COLUMN IF EXISTS previous,
+    DROP COLUMN IF EXISTS undone;
+
+ALTER TABLE tasks
+    DROP COLUMN IF EXISTS deleted_at;
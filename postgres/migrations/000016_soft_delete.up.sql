@@ -0,0 +1,11 @@
+ALTER TABLE tasks
+    ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+
+CREATE INDEX IF NOT EXISTS tasks_deleted_at_idx ON tasks (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- previous keeps the values an edit replaced so it can be undone
+ALTER TABLE task_events
+    ADD COLUMN IF NOT EXISTS previous text    not null default '',
+    ADD COLUMN IF NOT EXISTS undone   boolean not null default false;
+
+CREATE INDEX IF NOT EXISTS task_events_user_id_idx ON task_events (user_id, id);
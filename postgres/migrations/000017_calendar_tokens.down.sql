@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS families_calendar_token_idx;
+
+ALTER TABLE families
+    DROP COLUMN IF EXISTS calendar_token;
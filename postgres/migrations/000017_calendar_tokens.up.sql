@@ -0,0 +1,5 @@
+-- the secret part of the address of the family calendar feed
+ALTER TABLE families
+    ADD COLUMN IF NOT EXISTS calendar_token text;
+
+CREATE UNIQUE INDEX IF NOT EXISTS families_calendar_token_idx ON families (calendar_token);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS tasks_family_id_uid_idx;
+
+ALTER TABLE tasks
+    DROP COLUMN IF EXISTS uid;
@@ -0,0 +1,6 @@
+-- the UID of the calendar event the task was imported from, empty for
+-- tasks created in the bot
+ALTER TABLE tasks
+    ADD COLUMN IF NOT EXISTS uid text not null default '';
+
+CREATE INDEX IF NOT EXISTS tasks_family_id_uid_idx ON tasks (family_id, uid) WHERE uid <> '';
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS tasks_notes_trgm_idx;
+
+DROP INDEX IF EXISTS tasks_title_trgm_idx;
+
+DROP INDEX IF EXISTS task_assignees_user_id_idx;
+
+DROP INDEX IF EXISTS tasks_list_id_date_idx;
+
+DROP INDEX IF EXISTS tasks_due_idx;
@@ -0,0 +1,14 @@
+-- reminders look for undone tasks due soon
+CREATE INDEX IF NOT EXISTS tasks_due_idx ON tasks (date) WHERE deleted_at IS NULL AND NOT done AND date IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS tasks_list_id_date_idx ON tasks (list_id, date) WHERE deleted_at IS NULL;
+
+-- the primary key starts with the task, filtering by assignee needs the user first
+CREATE INDEX IF NOT EXISTS task_assignees_user_id_idx ON task_assignees (user_id, task_id);
+
+-- trigram indexes serve ILIKE searches with wildcards on both sides
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS tasks_title_trgm_idx ON tasks USING gin (title gin_trgm_ops);
+
+CREATE INDEX IF NOT EXISTS tasks_notes_trgm_idx ON tasks USING gin (notes gin_trgm_ops);
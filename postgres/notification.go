@@ -0,0 +1,104 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/maxwww/family_bot/units"
+	"time"
+)
+
+var _ units.NotificationService = (*NotificationService)(nil)
+
+type NotificationService struct {
+	db *DB
+}
+
+func NewNotificationService(db *DB) *NotificationService {
+	return &NotificationService{db}
+}
+
+func (ns *NotificationService) LastTick(ctx context.Context, name string) (*time.Time, error) {
+	query := `SELECT tick_at FROM cron_ticks WHERE name = $1;`
+
+	var tick time.Time
+	if err := ns.db.QueryRowxContext(ctx, query, name).Scan(&tick); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &tick, nil
+}
+
+func (ns *NotificationService) SetLastTick(ctx context.Context, name string, tick time.Time) error {
+	query := `
+	INSERT INTO cron_ticks (name, tick_at)
+	VALUES ($1, $2)
+	ON CONFLICT (name) DO UPDATE SET tick_at = excluded.tick_at;`
+
+	_, err := ns.db.ExecContext(ctx, query, name, tick)
+
+	return err
+}
+
+func (ns *NotificationService) MarkSent(ctx context.Context, taskID uint, offset int, dueAt time.Time) (bool, error) {
+	query := `
+	INSERT INTO notifications_sent (task_id, offset_minutes, due_at)
+	VALUES ($1, $2, $3)
+	ON CONFLICT DO NOTHING;`
+
+	result, err := ns.db.ExecContext(ctx, query, taskID, offset, dueAt)
+	if err != nil {
+		return false, err
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return inserted > 0, nil
+}
+
+func (ns *NotificationService) CreateSnooze(ctx context.Context, snooze *units.Snooze) error {
+	query := `
+	INSERT INTO task_snoozes (task_id, created_by, remind_at)
+	VALUES ($1, $2, $3) RETURNING id;`
+
+	args := []interface{}{snooze.TaskID, snooze.CreatedBy, snooze.RemindAt}
+
+	return ns.db.QueryRowxContext(ctx, query, args...).Scan(&snooze.ID)
+}
+
+func (ns *NotificationService) DueSnoozes(ctx context.Context, until time.Time) ([]*units.Snooze, error) {
+	query := `
+	SELECT id, task_id, coalesce(created_by, 0) AS created_by, remind_at FROM task_snoozes
+	WHERE sent_at IS NULL AND remind_at <= $1
+	ORDER BY remind_at ASC;`
+
+	snoozes := make([]*units.Snooze, 0)
+	if err := ns.db.SelectContext(ctx, &snoozes, query, until); err != nil {
+		return nil, err
+	}
+
+	return snoozes, nil
+}
+
+func (ns *NotificationService) MarkSnoozeSent(ctx context.Context, snoozeId uint) (bool, error) {
+	query := `
+	UPDATE task_snoozes SET sent_at = now()
+	WHERE id = $1 AND sent_at IS NULL;`
+
+	result, err := ns.db.ExecContext(ctx, query, snoozeId)
+	if err != nil {
+		return false, err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return updated > 0, nil
+}
@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	st "github.com/maxwww/family_bot/state"
+)
+
+var _ st.StateServiceI = (*StateService)(nil)
+
+// StateService keeps conversation states in the user_states table so
+// half-finished wizards survive restarts.
+type StateService struct {
+	db  *DB
+	ttl time.Duration
+}
+
+func NewStateService(db *DB, ttl time.Duration) *StateService {
+	return &StateService{db, ttl}
+}
+
+func (ss *StateService) GetUserState(userId int) *st.State {
+	query := `
+	SELECT payload FROM user_states
+	WHERE telegram_id = $1 AND ($2 = 0 OR updated_at > now() - $2 * interval '1 second');`
+
+	var payload []byte
+	err := ss.db.QueryRowxContext(context.Background(), query, userId, int(ss.ttl.Seconds())).Scan(&payload)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Println(err)
+		}
+		return &st.State{Status: st.STATUS_IDLE}
+	}
+
+	var state st.State
+	if err := json.Unmarshal(payload, &state); err != nil {
+		log.Println(err)
+		return &st.State{Status: st.STATUS_IDLE}
+	}
+
+	return &state
+}
+
+func (ss *StateService) SetUserState(userId int, state st.State) {
+	payload, err := json.Marshal(state)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	query := `
+	INSERT INTO user_states (telegram_id, payload, updated_at)
+	VALUES ($1, $2, now())
+	ON CONFLICT (telegram_id) DO UPDATE SET payload = excluded.payload, updated_at = excluded.updated_at;`
+
+	if _, err := ss.db.ExecContext(context.Background(), query, userId, string(payload)); err != nil {
+		log.Println(err)
+	}
+}
@@ -2,10 +2,15 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"fmt"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/maxwww/family_bot/units"
 	"log"
+	"strings"
+	"time"
 )
 
 var _ units.TaskService = (*TaskService)(nil)
@@ -36,15 +41,15 @@ func (us *TaskService) CreateTask(ctx context.Context, task *units.Task) error {
 
 func createTask(ctx context.Context, tx *sqlx.Tx, task *units.Task) error {
 	query := `
-	INSERT INTO tasks (title, date, done, notifications)
-	VALUES ($1, $2, $3, $4) RETURNING id;
+	INSERT INTO tasks (title, date, done, notifications, recurrence, family_id, list_id, quantity, unit, category, notes, uid, created_by)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id, created_at, updated_at;
 	`
-	var date interface{} = nil
-	if task.Date.Valid && task.Date.String != "" {
-		date = task.Date.String
+	task.CreatedBy = actorID(ctx)
+	args := []interface{}{
+		task.Title, task.Date, false, task.Notifications, task.Recurrence, task.FamilyID,
+		task.ListID, task.Quantity, task.Unit, task.Category, task.Notes, task.UID, task.CreatedBy,
 	}
-	args := []interface{}{task.Title, date, false, task.Notifications}
-	err := tx.QueryRowxContext(ctx, query, args...).Scan(&task.ID)
+	err := tx.QueryRowxContext(ctx, query, args...).Scan(&task.ID, &task.CreatedAt, &task.UpdatedAt)
 
 	if err != nil {
 		switch {
@@ -53,7 +58,18 @@ func createTask(ctx context.Context, tx *sqlx.Tx, task *units.Task) error {
 		}
 	}
 
-	return nil
+	if err := replaceTaskAssignees(ctx, tx, task.ID, task.Assignees); err != nil {
+		return err
+	}
+
+	for _, attachment := range task.Attachments {
+		attachment.TaskID = task.ID
+		if err := createTaskAttachment(ctx, tx, attachment); err != nil {
+			return err
+		}
+	}
+
+	return createTaskEvent(ctx, tx, task.ID, units.TaskEventCreated, nil)
 }
 
 func (us *TaskService) TaskByID(ctx context.Context, taskId uint) (*units.Task, error) {
@@ -94,8 +110,6 @@ func (us *TaskService) Tasks(ctx context.Context, tf units.TaskFilter) ([]*units
 	}
 
 	return tasks, tx.Commit()
-
-	return []*units.Task{}, nil
 }
 
 func (us *TaskService) UpdateTask(ctx context.Context, task *units.Task, patch units.TaskPatch) error {
@@ -121,7 +135,7 @@ func (us *TaskService) UpdateTask(ctx context.Context, task *units.Task, patch u
 	return nil
 }
 
-func (us *TaskService) CompleteTask(ctx context.Context, taskId int) (bool, error) {
+func (us *TaskService) CompleteTask(ctx context.Context, taskId int, loc *time.Location) (bool, error) {
 	tx, err := us.db.BeginTxx(ctx, nil)
 
 	if err != nil {
@@ -131,14 +145,56 @@ func (us *TaskService) CompleteTask(ctx context.Context, taskId int) (bool, erro
 
 	defer tx.Rollback()
 
-	query := `
-	UPDATE tasks 
-	SET done = not done
-	WHERE id = $1
-	RETURNING done;`
+	id := uint(taskId)
+	task, err := findOneTask(ctx, tx, units.TaskFilter{Id: &id})
+	if err != nil {
+		log.Println(err)
+		return false, units.ErrInternal
+	}
 
 	var done bool
-	tx.QueryRowxContext(ctx, query, taskId).Scan(&done)
+	wasDone := task.Done
+	previous := units.TaskPatch{Done: &wasDone}
+	if !task.Done && task.Recurrence != units.RecurrenceNone && task.Date.Valid {
+		date, recurrence := task.Date, task.Recurrence
+		doneItems := []uint{}
+		for _, item := range task.Items {
+			if item.Done {
+				doneItems = append(doneItems, item.ID)
+			}
+		}
+		previous = units.TaskPatch{Date: &date, Recurrence: &recurrence, DoneItems: &doneItems}
+
+		// recurring tasks are never marked as done, they move to the next occurrence instead
+		if err := rollTaskForward(ctx, tx, task, loc); err != nil {
+			log.Println(err)
+			return false, units.ErrInternal
+		}
+	} else {
+		// the right side sees the old value of done
+		query := `
+		UPDATE tasks 
+		SET done = not done,
+		    completed_by = CASE WHEN done THEN NULL ELSE $2::integer END,
+		    completed_at = CASE WHEN done THEN NULL ELSE now() END,
+		    updated_at = now()
+		WHERE id = $1
+		RETURNING done;`
+
+		if err := tx.QueryRowxContext(ctx, query, taskId, actorID(ctx)).Scan(&done); err != nil {
+			log.Println(err)
+			return false, units.ErrInternal
+		}
+	}
+
+	kind := units.TaskEventCompleted
+	if task.Done {
+		kind = units.TaskEventReopened
+	}
+	if err := createTaskEvent(ctx, tx, task.ID, kind, &previous); err != nil {
+		log.Println(err)
+		return false, units.ErrInternal
+	}
 
 	if err := tx.Commit(); err != nil {
 		log.Println(err)
@@ -148,7 +204,7 @@ func (us *TaskService) CompleteTask(ctx context.Context, taskId int) (bool, erro
 	return done, nil
 }
 
-func (us *TaskService) RemoveCompete(ctx context.Context) error {
+func (us *TaskService) RemoveCompete(ctx context.Context, listId uint) error {
 	tx, err := us.db.BeginTxx(ctx, nil)
 
 	if err != nil {
@@ -158,11 +214,25 @@ func (us *TaskService) RemoveCompete(ctx context.Context) error {
 
 	defer tx.Rollback()
 
+	// removed tasks are kept until PurgeDeleted so the removal can be undone
 	query := `
-	DELETE FROM tasks 
-	WHERE done  = true;`
-
-	tx.QueryRowxContext(ctx, query)
+	WITH removed AS (
+		UPDATE tasks 
+		SET deleted_at = now()
+		WHERE done = true AND list_id = $1 AND deleted_at IS NULL
+		RETURNING id, family_id, title
+	)
+	INSERT INTO task_events (task_id, family_id, user_id, kind, title)
+	SELECT id, family_id, $2, $3, title FROM removed`
+
+	removed, err := execQueryRows(ctx, tx, query, listId, actorID(ctx), units.TaskEventDeleted)
+	if err != nil {
+		log.Println(err)
+		return units.ErrInternal
+	}
+	if removed == 0 {
+		return units.ErrNotFound
+	}
 
 	if err := tx.Commit(); err != nil {
 		log.Println(err)
@@ -182,12 +252,333 @@ func (us *TaskService) RemoveByID(ctx context.Context, taskId int) error {
 
 	defer tx.Rollback()
 
-	args := []interface{}{taskId}
+	// the event is recorded only when the task is there to remove
 	query := `
-	DELETE FROM tasks 
-	WHERE id  = $1;`
+	WITH removed AS (
+		UPDATE tasks 
+		SET deleted_at = now()
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING id, family_id, title
+	)
+	INSERT INTO task_events (task_id, family_id, user_id, kind, title)
+	SELECT id, family_id, $2, $3, title FROM removed`
+
+	removed, err := execQueryRows(ctx, tx, query, taskId, actorID(ctx), units.TaskEventDeleted)
+	if err != nil {
+		log.Println(err)
+		return units.ErrInternal
+	}
+	if removed == 0 {
+		return units.ErrNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return units.ErrInternal
+	}
+
+	return nil
+}
+
+func (us *TaskService) AddTaskItems(ctx context.Context, taskID uint, titles []string) error {
+	tx, err := us.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Println(err)
+		return units.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	for _, title := range titles {
+		query := `
+		INSERT INTO task_items (task_id, title, position)
+		SELECT $1, $2, COALESCE(MAX(position), 0) + 1 FROM task_items WHERE task_id = $1`
+
+		if err := execQuery(ctx, tx, query, taskID, title); err != nil {
+			log.Println(err)
+			return units.ErrInternal
+		}
+	}
+
+	if err := recordTaskEdit(ctx, tx, taskID, units.TaskFieldItems); err != nil {
+		log.Println(err)
+		return units.ErrInternal
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return units.ErrInternal
+	}
+
+	return nil
+}
+
+func (us *TaskService) ToggleTaskItem(ctx context.Context, taskID uint, itemID uint) error {
+	tx, err := us.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Println(err)
+		return units.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	UPDATE task_items
+	SET done = not done
+	WHERE id = $1 AND task_id = $2`
+
+	if err := execQuery(ctx, tx, query, itemID, taskID); err != nil {
+		log.Println(err)
+		return units.ErrInternal
+	}
+
+	if err := recordTaskEdit(ctx, tx, taskID, units.TaskFieldItems); err != nil {
+		log.Println(err)
+		return units.ErrInternal
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return units.ErrInternal
+	}
+
+	return nil
+}
+
+func (us *TaskService) RemoveTaskItem(ctx context.Context, taskID uint, itemID uint) error {
+	tx, err := us.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Println(err)
+		return units.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	if err := execQuery(ctx, tx, `DELETE FROM task_items WHERE id = $1 AND task_id = $2`, itemID, taskID); err != nil {
+		log.Println(err)
+		return units.ErrInternal
+	}
+
+	if err := recordTaskEdit(ctx, tx, taskID, units.TaskFieldItems); err != nil {
+		log.Println(err)
+		return units.ErrInternal
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return units.ErrInternal
+	}
+
+	return nil
+}
+
+func (us *TaskService) AddTaskAttachment(ctx context.Context, attachment *units.Attachment) error {
+	tx, err := us.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Println(err)
+		return units.ErrInternal
+	}
 
-	tx.QueryRowxContext(ctx, query, args...)
+	defer tx.Rollback()
+
+	if err := createTaskAttachment(ctx, tx, attachment); err != nil {
+		log.Println(err)
+		return units.ErrInternal
+	}
+
+	if err := recordTaskEdit(ctx, tx, attachment.TaskID, units.TaskFieldAttachments); err != nil {
+		log.Println(err)
+		return units.ErrInternal
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return units.ErrInternal
+	}
+
+	return nil
+}
+
+func createTaskAttachment(ctx context.Context, tx *sqlx.Tx, attachment *units.Attachment) error {
+	query := `
+	INSERT INTO task_attachments (task_id, kind, file_id)
+	VALUES ($1, $2, $3) RETURNING id;
+	`
+
+	return tx.QueryRowxContext(ctx, query, attachment.TaskID, attachment.Kind, attachment.FileID).Scan(&attachment.ID)
+}
+
+func (us *TaskService) TaskEvents(ctx context.Context, filter units.TaskEventFilter) ([]*units.TaskEvent, error) {
+	tx, err := us.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	where, args := []string{}, []interface{}{}
+	argPosition := 0
+
+	if v := filter.TaskID; v != nil {
+		argPosition++
+		where, args = append(where, fmt.Sprintf("task_id = $%d", argPosition)), append(args, *v)
+	}
+
+	if v := filter.FamilyID; v != nil {
+		argPosition++
+		where, args = append(where, fmt.Sprintf("family_id = $%d", argPosition)), append(args, *v)
+	}
+
+	query := "SELECT * from task_events" + formatWhereClause(where) +
+		" ORDER BY id DESC" + formatLimitOffset(filter.Limit, filter.Offset)
+
+	events := make([]*units.TaskEvent, 0)
+
+	if err := findMany(ctx, tx, &events, query, args...); err != nil {
+		return nil, err
+	}
+
+	return events, tx.Commit()
+}
+
+// createTaskEvent records the change made by the user of the context,
+// it reads the task so it has to run before the task is removed. previous
+// restores what the change replaced, nil means there is nothing to restore.
+func createTaskEvent(ctx context.Context, tx *sqlx.Tx, taskID uint, kind units.TaskEventKind, previous *units.TaskPatch, fields ...string) error {
+	var data []byte
+	if previous != nil {
+		var err error
+		if data, err = json.Marshal(previous); err != nil {
+			return err
+		}
+	}
+
+	query := `
+	INSERT INTO task_events (task_id, family_id, user_id, kind, title, fields, previous)
+	SELECT id, family_id, $2, $3, title, $4, $5 FROM tasks
+	WHERE id = $1 AND deleted_at IS NULL`
+
+	return execQuery(ctx, tx, query, taskID, actorID(ctx), kind, strings.Join(fields, ","), string(data))
+}
+
+// recordTaskEdit records a change of a field kept outside of the tasks table.
+func recordTaskEdit(ctx context.Context, tx *sqlx.Tx, taskID uint, field string) error {
+	if err := execQuery(ctx, tx, `UPDATE tasks SET updated_at = now() WHERE id = $1`, taskID); err != nil {
+		return err
+	}
+
+	return createTaskEvent(ctx, tx, taskID, units.TaskEventEdited, nil, field)
+}
+
+func actorID(ctx context.Context) sql.NullInt64 {
+	userID, ok := units.ActorFromContext(ctx)
+
+	return sql.NullInt64{Int64: int64(userID), Valid: ok}
+}
+
+func (us *TaskService) UndoLast(ctx context.Context, since time.Time) ([]*units.TaskEvent, error) {
+	userID, ok := units.ActorFromContext(ctx)
+	if !ok {
+		return nil, units.ErrNotFound
+	}
+
+	tx, err := us.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Println(err)
+		return nil, units.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	// events of one change share the time of its transaction, creations and
+	// removals are reverted with deleted_at and other events need previous
+	undoable := "user_id = $1 AND undone = false AND (kind IN ($2, $3) OR previous <> '')"
+	query := `
+	SELECT * FROM task_events
+	WHERE ` + undoable + ` AND created_at >= $4 AND created_at = (
+		SELECT created_at FROM task_events
+		WHERE ` + undoable + `
+		ORDER BY id DESC LIMIT 1
+	)
+	ORDER BY id DESC`
+
+	events := make([]*units.TaskEvent, 0)
+	if err := findMany(ctx, tx, &events, query, userID, units.TaskEventCreated, units.TaskEventDeleted, since); err != nil {
+		log.Println(err)
+		return nil, units.ErrInternal
+	}
+	if len(events) == 0 {
+		return nil, units.ErrNotFound
+	}
+
+	ids := make([]int64, 0, len(events))
+	for _, event := range events {
+		if err := undoTaskEvent(ctx, tx, event); err != nil {
+			log.Println(err)
+			return nil, units.ErrInternal
+		}
+		ids = append(ids, int64(event.ID))
+	}
+
+	if err := execQuery(ctx, tx, `UPDATE task_events SET undone = true WHERE id = ANY($1)`, pq.Array(ids)); err != nil {
+		log.Println(err)
+		return nil, units.ErrInternal
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return nil, units.ErrInternal
+	}
+
+	return events, nil
+}
+
+func undoTaskEvent(ctx context.Context, tx *sqlx.Tx, event *units.TaskEvent) error {
+	switch event.Kind {
+	case units.TaskEventCreated:
+		return execQuery(ctx, tx, `UPDATE tasks SET deleted_at = now() WHERE id = $1`, event.TaskID)
+	case units.TaskEventDeleted:
+		return execQuery(ctx, tx, `UPDATE tasks SET deleted_at = NULL WHERE id = $1`, event.TaskID)
+	}
+
+	var previous units.TaskPatch
+	if err := json.Unmarshal([]byte(event.Previous), &previous); err != nil {
+		return err
+	}
+
+	task, err := findOneTask(ctx, tx, units.TaskFilter{Id: &event.TaskID})
+	if err == units.ErrNotFound {
+		// the task has been removed since, there is nothing to revert
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	_, _, err = patchTask(ctx, tx, task, previous)
+
+	return err
+}
+
+func (us *TaskService) PurgeDeleted(ctx context.Context, before time.Time) error {
+	tx, err := us.db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		log.Println(err)
+		return units.ErrInternal
+	}
+
+	defer tx.Rollback()
+
+	if err := execQuery(ctx, tx, `DELETE FROM tasks WHERE deleted_at < $1`, before); err != nil {
+		log.Println(err)
+		return units.ErrInternal
+	}
 
 	if err := tx.Commit(); err != nil {
 		log.Println(err)
@@ -210,7 +601,7 @@ func findOneTask(ctx context.Context, tx *sqlx.Tx, filter units.TaskFilter) (*un
 }
 
 func findTasks(ctx context.Context, tx *sqlx.Tx, filter units.TaskFilter) ([]*units.Task, error) {
-	where, args := []string{}, []interface{}{}
+	where, args := []string{"deleted_at IS NULL"}, []interface{}{}
 	argPosition := 0
 
 	if v := filter.Id; v != nil {
@@ -218,8 +609,52 @@ func findTasks(ctx context.Context, tx *sqlx.Tx, filter units.TaskFilter) ([]*un
 		where, args = append(where, fmt.Sprintf("id = $%d", argPosition)), append(args, *v)
 	}
 
+	if v := filter.FamilyID; v != nil {
+		argPosition++
+		where, args = append(where, fmt.Sprintf("family_id = $%d", argPosition)), append(args, *v)
+	}
+
+	if v := filter.ListID; v != nil {
+		argPosition++
+		where, args = append(where, fmt.Sprintf("list_id = $%d", argPosition)), append(args, *v)
+	}
+
+	if v := filter.Done; v != nil {
+		argPosition++
+		where, args = append(where, fmt.Sprintf("done = $%d", argPosition)), append(args, *v)
+	}
+
+	if v := filter.AssigneeID; v != nil {
+		argPosition++
+		where, args = append(where, fmt.Sprintf("EXISTS (SELECT 1 FROM task_assignees WHERE task_id = tasks.id AND user_id = $%d)", argPosition)), append(args, *v)
+	}
+
+	if v := filter.From; v != nil {
+		argPosition++
+		where, args = append(where, fmt.Sprintf("date >= $%d", argPosition)), append(args, *v)
+	}
+
+	if v := filter.To; v != nil {
+		argPosition++
+		where, args = append(where, fmt.Sprintf("date < $%d", argPosition)), append(args, *v)
+	}
+
+	if v := filter.HasDate; v != nil {
+		if *v {
+			where = append(where, "date IS NOT NULL")
+		} else {
+			where = append(where, "date IS NULL")
+		}
+	}
+
+	if v := filter.Query; v != nil {
+		argPosition++
+		where = append(where, fmt.Sprintf("(title ILIKE $%d OR notes ILIKE $%d)", argPosition, argPosition))
+		args = append(args, "%"+likeEscaper.Replace(*v)+"%")
+	}
+
 	query := "SELECT * from tasks" + formatWhereClause(where) +
-		" ORDER BY id ASC" + formatLimitOffset(filter.Limit, filter.Offset)
+		" ORDER BY " + formatTaskOrder(filter.OrderBy) + formatLimitOffset(filter.Limit, filter.Offset)
 
 	tasks, err := queryTasks(ctx, tx, query, args...)
 
@@ -227,9 +662,35 @@ func findTasks(ctx context.Context, tx *sqlx.Tx, filter units.TaskFilter) ([]*un
 		return nil, err
 	}
 
+	if err := attachTaskAssignees(ctx, tx, tasks); err != nil {
+		return nil, err
+	}
+
+	if err := attachTaskItems(ctx, tx, tasks); err != nil {
+		return nil, err
+	}
+
+	if err := attachTaskAttachments(ctx, tx, tasks); err != nil {
+		return nil, err
+	}
+
 	return tasks, nil
 }
 
+// likeEscaper keeps wildcards of a search query literal.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func formatTaskOrder(order units.TaskOrder) string {
+	switch order {
+	case units.TaskOrderDate:
+		return "date ASC NULLS LAST, id ASC"
+	case units.TaskOrderTitle:
+		return "lower(title) ASC, id ASC"
+	}
+
+	return "id ASC"
+}
+
 func queryTasks(ctx context.Context, tx *sqlx.Tx, query string, args ...interface{}) ([]*units.Task, error) {
 	tasks := make([]*units.Task, 0)
 
@@ -241,38 +702,279 @@ func queryTasks(ctx context.Context, tx *sqlx.Tx, query string, args ...interfac
 }
 
 func updateTask(ctx context.Context, tx *sqlx.Tx, task *units.Task, patch units.TaskPatch) error {
+	previous, fields, err := patchTask(ctx, tx, task, patch)
+	if err != nil {
+		return err
+	}
+
+	return createTaskEvent(ctx, tx, task.ID, units.TaskEventEdited, &previous, fields...)
+}
+
+// patchTask applies the patch, it returns the patch restoring the replaced
+// values and names of the changed fields.
+func patchTask(ctx context.Context, tx *sqlx.Tx, task *units.Task, patch units.TaskPatch) (units.TaskPatch, []string, error) {
+	var previous units.TaskPatch
+	var fields []string
 	if v := patch.Done; v != nil {
+		done := task.Done
+		previous.Done = &done
 		task.Done = *v
+		fields = append(fields, units.TaskFieldDone)
 	}
 	if v := patch.Title; v != nil {
+		title := task.Title
+		previous.Title = &title
 		task.Title = *v
+		fields = append(fields, units.TaskFieldTitle)
 	}
-	var dateValue interface{} = task.Date
 	if v := patch.Date; v != nil {
+		date := task.Date
+		previous.Date = &date
 		task.Date = *v
-		dateValue = *v
-		if !task.Date.Valid || task.Date.String == "" {
-			dateValue = nil
+		fields = append(fields, units.TaskFieldDate)
+		// the new date is the day the rule is anchored to from now on
+		if base := task.Recurrence.Base(); patch.Recurrence == nil && base != task.Recurrence {
+			recurrence := task.Recurrence
+			previous.Recurrence = &recurrence
+			task.Recurrence = base
+			fields = append(fields, units.TaskFieldRecurrence)
 		}
 	}
 	if v := patch.Notifications; v != nil {
+		notifications := task.Notifications
+		previous.Notifications = &notifications
 		task.Notifications = *v
+		fields = append(fields, units.TaskFieldNotifications)
+	}
+	if v := patch.Recurrence; v != nil {
+		recurrence := task.Recurrence
+		previous.Recurrence = &recurrence
+		task.Recurrence = *v
+		fields = append(fields, units.TaskFieldRecurrence)
+	}
+	if v := patch.Assignees; v != nil {
+		assignees := append([]uint{}, task.Assignees...)
+		previous.Assignees = &assignees
+		task.Assignees = *v
+		fields = append(fields, units.TaskFieldAssignees)
+		if err := replaceTaskAssignees(ctx, tx, task.ID, task.Assignees); err != nil {
+			return previous, nil, err
+		}
+	}
+	if v := patch.Notes; v != nil {
+		notes := task.Notes
+		previous.Notes = &notes
+		task.Notes = *v
+		fields = append(fields, units.TaskFieldNotes)
+	}
+	if v := patch.DoneItems; v != nil {
+		doneItems := []uint{}
+		for _, item := range task.Items {
+			if item.Done {
+				doneItems = append(doneItems, item.ID)
+			}
+			item.Done = containsID(*v, item.ID)
+		}
+		previous.DoneItems = &doneItems
+		fields = append(fields, units.TaskFieldItems)
+
+		ids := make([]int64, 0, len(*v))
+		for _, id := range *v {
+			ids = append(ids, int64(id))
+		}
+		query := `UPDATE task_items SET done = (id = ANY($2)) WHERE task_id = $1`
+		if err := execQuery(ctx, tx, query, task.ID, pq.Array(ids)); err != nil {
+			return previous, nil, err
+		}
 	}
 
 	args := []interface{}{
 		task.Done,
 		task.Title,
-		dateValue,
+		task.Date,
 		task.Notifications,
+		task.Recurrence,
+		task.Notes,
 		task.ID,
 	}
 
+	// tasks which are not done have nobody who completed them
 	query := `
 	UPDATE tasks 
-	SET done = $1, title = $2, date = $3, notifications = $4
-	WHERE id = $5`
+	SET done = $1, title = $2, date = $3, notifications = $4, recurrence = $5, notes = $6, updated_at = now(),
+	    completed_by = CASE WHEN $1 THEN completed_by END,
+	    completed_at = CASE WHEN $1 THEN completed_at END
+	WHERE id = $7`
+
+	if err := execQuery(ctx, tx, query, args...); err != nil {
+		return previous, nil, err
+	}
+
+	return previous, fields, nil
+}
+
+func attachTaskAssignees(ctx context.Context, tx *sqlx.Tx, tasks []*units.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(tasks))
+	byID := make(map[uint]*units.Task, len(tasks))
+	for _, task := range tasks {
+		ids = append(ids, int64(task.ID))
+		byID[task.ID] = task
+	}
+
+	query := `
+	SELECT task_id, user_id FROM task_assignees
+	WHERE task_id = ANY($1)
+	ORDER BY user_id ASC`
+
+	rows, err := tx.QueryxContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var taskID, userID uint
+		if err := rows.Scan(&taskID, &userID); err != nil {
+			return err
+		}
+		if task, ok := byID[taskID]; ok {
+			task.Assignees = append(task.Assignees, userID)
+		}
+	}
+
+	return rows.Err()
+}
+
+func attachTaskItems(ctx context.Context, tx *sqlx.Tx, tasks []*units.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(tasks))
+	byID := make(map[uint]*units.Task, len(tasks))
+	for _, task := range tasks {
+		ids = append(ids, int64(task.ID))
+		byID[task.ID] = task
+	}
+
+	query := `
+	SELECT * FROM task_items
+	WHERE task_id = ANY($1)
+	ORDER BY position ASC, id ASC`
+
+	items := make([]*units.TaskItem, 0)
+	if err := findMany(ctx, tx, &items, query, pq.Array(ids)); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if task, ok := byID[item.TaskID]; ok {
+			task.Items = append(task.Items, item)
+		}
+	}
+
+	return nil
+}
+
+func attachTaskAttachments(ctx context.Context, tx *sqlx.Tx, tasks []*units.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(tasks))
+	byID := make(map[uint]*units.Task, len(tasks))
+	for _, task := range tasks {
+		ids = append(ids, int64(task.ID))
+		byID[task.ID] = task
+	}
+
+	query := `
+	SELECT id, task_id, kind, file_id FROM task_attachments
+	WHERE task_id = ANY($1)
+	ORDER BY id ASC`
+
+	attachments := make([]*units.Attachment, 0)
+	if err := findMany(ctx, tx, &attachments, query, pq.Array(ids)); err != nil {
+		return err
+	}
 
-	tx.QueryRowxContext(ctx, query, args...)
+	for _, attachment := range attachments {
+		if task, ok := byID[attachment.TaskID]; ok {
+			task.Attachments = append(task.Attachments, attachment)
+		}
+	}
 
 	return nil
 }
+
+func replaceTaskAssignees(ctx context.Context, tx *sqlx.Tx, taskID uint, assignees []uint) error {
+	if err := execQuery(ctx, tx, `DELETE FROM task_assignees WHERE task_id = $1`, taskID); err != nil {
+		return err
+	}
+
+	for _, userID := range assignees {
+		query := `
+		INSERT INTO task_assignees (task_id, user_id)
+		VALUES ($1, $2) ON CONFLICT DO NOTHING`
+
+		if err := execQuery(ctx, tx, query, taskID, userID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rollTaskForward moves a recurring task to its next occurrence after now,
+// occurrences missed while the task was overdue are skipped. The wall
+// clock time is kept in loc so daylight saving does not shift it. Monthly
+// and yearly rules are anchored to the day they are first rolled from, so
+// the 31st clamped to February is back on the 31st in March. The checklist
+// starts over for the next occurrence.
+func rollTaskForward(ctx context.Context, tx *sqlx.Tx, task *units.Task, loc *time.Location) error {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	date := task.Date.Time.In(loc)
+	if task.Recurrence.AnchorDay() == 0 {
+		task.Recurrence = task.Recurrence.Anchored(date.Day())
+	}
+
+	now := time.Now()
+	next := task.Recurrence.Next(date)
+	for !next.After(now) {
+		following := task.Recurrence.Next(next)
+		if !following.After(next) {
+			break
+		}
+		next = following
+	}
+	task.Date.Time = next
+
+	query := `
+	UPDATE tasks 
+	SET date = $1, recurrence = $4, done = false, completed_by = $3, completed_at = now(), updated_at = now()
+	WHERE id = $2`
+
+	if err := execQuery(ctx, tx, query, next, task.ID, actorID(ctx), task.Recurrence); err != nil {
+		return err
+	}
+
+	return execQuery(ctx, tx, `UPDATE task_items SET done = false WHERE task_id = $1`, task.ID)
+}
+
+func containsID(ids []uint, id uint) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,262 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maxwww/family_bot/units"
+)
+
+// testDB connects to the database of TEST_POSTGRESQL_URL and migrates it,
+// tests skip without one. The database gets test families, do not point it
+// at the bot's database.
+func testDB(t *testing.T) *DB {
+	t.Helper()
+
+	url := os.Getenv("TEST_POSTGRESQL_URL")
+	if url == "" {
+		t.Skip("TEST_POSTGRESQL_URL is not set")
+	}
+
+	db, err := Open(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := Migrate(context.Background(), db, time.UTC); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// testTask creates a task in a new family, the returned context is of a
+// new user making the changes.
+func testTask(t *testing.T, db *DB) (context.Context, *units.Task) {
+	t.Helper()
+
+	ctx := context.Background()
+	family := &units.Family{Name: "test " + t.Name()}
+	if err := NewFamilyService(db).CreateFamily(ctx, family); err != nil {
+		t.Fatal(err)
+	}
+
+	user := &units.User{TelegramID: uint(time.Now().UnixNano() % 1e9), FirstName: "Test"}
+	if err := NewUserService(db).CreateUser(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+
+	list := &units.List{FamilyID: family.ID, Name: "Справи", Kind: units.ListKindTasks}
+	if err := NewListService(db).CreateList(ctx, list); err != nil {
+		t.Fatal(err)
+	}
+
+	task := &units.Task{Title: "купити хліб", FamilyID: family.ID, ListID: list.ID}
+	if err := NewTaskService(db).CreateTask(ctx, task); err != nil {
+		t.Fatal(err)
+	}
+
+	return units.WithActor(ctx, user.ID), task
+}
+
+func TestUpdateTaskRecordsEvent(t *testing.T) {
+	db := testDB(t)
+	ts := NewTaskService(db)
+	ctx, task := testTask(t, db)
+	since := time.Now().Add(-time.Minute)
+
+	title := "купити молоко"
+	if err := ts.UpdateTask(ctx, task, units.TaskPatch{Title: &title}); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := ts.TaskEvents(ctx, units.TaskEventFilter{TaskID: &task.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Kind != units.TaskEventEdited || events[0].Fields != units.TaskFieldTitle {
+		t.Fatalf("events = %+v, want the edit after the creation", events)
+	}
+
+	if _, err := ts.UndoLast(ctx, since); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := ts.TaskByID(ctx, task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Title != "купити хліб" {
+		t.Errorf("title after undo = %q, want the original", stored.Title)
+	}
+}
+
+func TestRemoveByIDRecordsOneEvent(t *testing.T) {
+	db := testDB(t)
+	ts := NewTaskService(db)
+	ctx, task := testTask(t, db)
+
+	if err := ts.RemoveByID(ctx, int(task.ID)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.RemoveByID(ctx, int(task.ID)); err != units.ErrNotFound {
+		t.Errorf("removing the task again: err = %v, want ErrNotFound", err)
+	}
+	if err := ts.RemoveCompete(ctx, task.ListID); err != units.ErrNotFound {
+		t.Errorf("removing done tasks of a list without them: err = %v, want ErrNotFound", err)
+	}
+
+	events, err := ts.TaskEvents(ctx, units.TaskEventFilter{TaskID: &task.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Kind != units.TaskEventDeleted {
+		t.Errorf("events = %+v, want one removal after the creation", events)
+	}
+}
+
+func TestUndoCompletingRecurringTask(t *testing.T) {
+	db := testDB(t)
+	ts := NewTaskService(db)
+	ctx, task := testTask(t, db)
+	since := time.Now().Add(-time.Minute)
+
+	date := sql.NullTime{Time: time.Date(2022, 1, 31, 9, 0, 0, 0, time.UTC), Valid: true}
+	recurrence := units.RecurrenceMonthly
+	if err := ts.UpdateTask(ctx, task, units.TaskPatch{Date: &date, Recurrence: &recurrence}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.AddTaskItems(ctx, task.ID, []string{"молоко", "хліб"}); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := ts.TaskByID(ctx, task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.ToggleTaskItem(ctx, task.ID, stored.Items[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ts.CompleteTask(ctx, int(task.ID), time.UTC); err != nil {
+		t.Fatal(err)
+	}
+	rolled, err := ts.TaskByID(ctx, task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rolled.Recurrence != units.RecurrenceMonthly.Anchored(31) || rolled.Items[0].Done {
+		t.Fatalf("rolled task = %+v, want an anchored rule and the checklist started over", rolled)
+	}
+
+	if _, err := ts.UndoLast(ctx, since); err != nil {
+		t.Fatal(err)
+	}
+	undone, err := ts.TaskByID(ctx, task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !undone.Date.Time.Equal(date.Time) || undone.Recurrence != units.RecurrenceMonthly {
+		t.Errorf("task after undo = %v %q, want %v monthly", undone.Date.Time, undone.Recurrence, date.Time)
+	}
+	if !undone.Items[0].Done || undone.Items[1].Done {
+		t.Errorf("checklist after undo = %+v %+v, want the first item done", undone.Items[0], undone.Items[1])
+	}
+}
+
+func TestDateChangeRecordsRecurrenceReset(t *testing.T) {
+	db := testDB(t)
+	ts := NewTaskService(db)
+	ctx, task := testTask(t, db)
+	since := time.Now().Add(-time.Minute)
+
+	date := sql.NullTime{Time: time.Date(2022, 1, 31, 9, 0, 0, 0, time.UTC), Valid: true}
+	anchored := units.RecurrenceMonthly.Anchored(31)
+	if err := ts.UpdateTask(ctx, task, units.TaskPatch{Date: &date, Recurrence: &anchored}); err != nil {
+		t.Fatal(err)
+	}
+
+	moved := sql.NullTime{Time: time.Date(2022, 2, 10, 9, 0, 0, 0, time.UTC), Valid: true}
+	if err := ts.UpdateTask(ctx, task, units.TaskPatch{Date: &moved}); err != nil {
+		t.Fatal(err)
+	}
+	events, err := ts.TaskEvents(ctx, units.TaskEventFilter{TaskID: &task.ID, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := units.TaskFieldDate + "," + units.TaskFieldRecurrence; len(events) != 1 || events[0].Fields != want {
+		t.Fatalf("events = %+v, want fields %q", events, want)
+	}
+
+	if _, err := ts.UndoLast(ctx, since); err != nil {
+		t.Fatal(err)
+	}
+	undone, err := ts.TaskByID(ctx, task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !undone.Date.Time.Equal(date.Time) || undone.Recurrence != anchored {
+		t.Errorf("task after undo = %v %q, want %v %q", undone.Date.Time, undone.Recurrence, date.Time, anchored)
+	}
+}
+
+func TestTasksFilters(t *testing.T) {
+	db := testDB(t)
+	ts := NewTaskService(db)
+	ctx, bread := testTask(t, db)
+	userID, _ := units.ActorFromContext(ctx)
+
+	day := time.Date(2022, 5, 4, 9, 0, 0, 0, time.UTC)
+	at := func(hours int) sql.NullTime {
+		return sql.NullTime{Time: day.Add(time.Duration(hours) * time.Hour), Valid: true}
+	}
+	for _, task := range []*units.Task{
+		{Title: "100% juice", Date: at(0)},
+		{Title: "Apples_2", Date: at(1), Assignees: []uint{userID}},
+		{Title: "berries", Date: at(2), Notes: "Apples 2 kg"},
+	} {
+		task.FamilyID, task.ListID = bread.FamilyID, bread.ListID
+		if err := ts.CreateTask(ctx, task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	from, to := day.Add(time.Hour), day.Add(2*time.Hour)
+	query := func(q string) *string { return &q }
+	tests := []struct {
+		name   string
+		filter units.TaskFilter
+		want   []string
+	}{
+		{"from is inclusive", units.TaskFilter{From: &from}, []string{"Apples_2", "berries"}},
+		{"to is exclusive", units.TaskFilter{To: &from}, []string{"100% juice"}},
+		{"from and to", units.TaskFilter{From: &from, To: &to}, []string{"Apples_2"}},
+		{"assignee", units.TaskFilter{AssigneeID: &userID}, []string{"Apples_2"}},
+		{"title or notes ignoring the case", units.TaskFilter{Query: query("APPLES")}, []string{"Apples_2", "berries"}},
+		{"percent is literal", units.TaskFilter{Query: query("%")}, []string{"100% juice"}},
+		{"underscore is literal", units.TaskFilter{Query: query("s_2")}, []string{"Apples_2"}},
+		{"date order puts undated last", units.TaskFilter{OrderBy: units.TaskOrderDate}, []string{"100% juice", "Apples_2", "berries", bread.Title}},
+		{"title order ignores the case", units.TaskFilter{OrderBy: units.TaskOrderTitle}, []string{"100% juice", "Apples_2", "berries", bread.Title}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.filter.FamilyID = &bread.FamilyID
+			tasks, err := ts.Tasks(ctx, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var titles []string
+			for _, task := range tasks {
+				titles = append(titles, task.Title)
+			}
+			if strings.Join(titles, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("titles = %q, want %q", titles, tt.want)
+			}
+		})
+	}
+}
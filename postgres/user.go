@@ -72,8 +72,6 @@ func (us *UserService) Users(ctx context.Context, uf units.UserFilter) ([]*units
 	}
 
 	return users, tx.Commit()
-
-	return []*units.User{}, nil
 }
 
 func (us *UserService) UpdateUser(ctx context.Context, user *units.User, patch units.UserPatch) error {
@@ -101,10 +99,10 @@ func (us *UserService) UpdateUser(ctx context.Context, user *units.User, patch u
 
 func createUser(ctx context.Context, tx *sqlx.Tx, user *units.User) error {
 	query := `
-	INSERT INTO users (telegram_id, first_name, last_name, user_name)
-	VALUES ($1, $2, $3, $4) RETURNING id;
+	INSERT INTO users (telegram_id, first_name, last_name, user_name, language)
+	VALUES ($1, $2, $3, $4, $5) RETURNING id;
 	`
-	args := []interface{}{user.TelegramID, user.FirstName, user.LastName, user.UserName}
+	args := []interface{}{user.TelegramID, user.FirstName, user.LastName, user.UserName, user.Language}
 	err := tx.QueryRowxContext(ctx, query, args...).Scan(&user.ID)
 
 	if err != nil {
@@ -140,6 +138,11 @@ func findUsers(ctx context.Context, tx *sqlx.Tx, filter units.UserFilter) ([]*un
 		where, args = append(where, fmt.Sprintf("telegram_id = $%d", argPosition)), append(args, *v)
 	}
 
+	if v := filter.FamilyID; v != nil {
+		argPosition++
+		where, args = append(where, fmt.Sprintf("family_id = $%d", argPosition)), append(args, *v)
+	}
+
 	query := "SELECT * from users" + formatWhereClause(where) +
 		" ORDER BY id ASC" + formatLimitOffset(filter.Limit, filter.Offset)
 
@@ -179,18 +182,39 @@ func updateUser(ctx context.Context, tx *sqlx.Tx, user *units.User, patch units.
 		user.Notifications = *v
 	}
 
+	if v := patch.FamilyID; v != nil {
+		user.FamilyID = *v
+	}
+
+	if v := patch.Timezone; v != nil {
+		user.Timezone = *v
+	}
+
+	if v := patch.Language; v != nil {
+		user.Language = *v
+	}
+
+	if v := patch.ActiveListID; v != nil {
+		user.ActiveListID = *v
+	}
+
 	args := []interface{}{
 		user.FirstName,
 		user.LastName,
 		user.UserName,
 		user.Notifications,
+		user.FamilyID,
+		user.Timezone,
+		user.Language,
+		user.ActiveListID,
 		user.ID,
 	}
 
 	query := `
 	UPDATE users 
-	SET first_name = $1, last_name = $2, user_name = $3, notifications = $4
-	WHERE id = $5`
+	SET first_name = $1, last_name = $2, user_name = $3, notifications = $4, family_id = $5, timezone = $6, language = $7,
+	    active_list_id = $8
+	WHERE id = $9`
 
 	tx.QueryRowxContext(ctx, query, args...)
 
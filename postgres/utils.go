@@ -12,11 +12,11 @@ import (
 
 func formatLimitOffset(limit, offset int) string {
 	if limit > 0 && offset > 0 {
-		return fmt.Sprintf("LIMIT %d OFFSET %d", limit, offset)
+		return fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
 	} else if limit > 0 {
-		return fmt.Sprintf("LIMIT %d", limit)
+		return fmt.Sprintf(" LIMIT %d", limit)
 	} else if offset > 0 {
-		return fmt.Sprintf("OFFSET %d", offset)
+		return fmt.Sprintf(" OFFSET %d", offset)
 	}
 	return ""
 }
@@ -119,3 +119,13 @@ func execQuery(ctx context.Context, tx *sqlx.Tx, query string, args ...interface
 
 	return err
 }
+
+// execQueryRows runs the query and returns the number of affected rows.
+func execQueryRows(ctx context.Context, tx *sqlx.Tx, query string, args ...interface{}) (int64, error) {
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,78 @@
+package shopping
+
+import "strings"
+
+type Category string
+
+const (
+	CategoryOther      Category = ""
+	CategoryVegetables Category = "veg"
+	CategoryFruit      Category = "fruit"
+	CategoryDairy      Category = "dairy"
+	CategoryBakery     Category = "bakery"
+	CategoryMeat       Category = "meat"
+	CategoryHousehold  Category = "household"
+)
+
+// Categories are listed in the order store sections are usually walked,
+// items without a category go last.
+var Categories = []Category{
+	CategoryVegetables,
+	CategoryFruit,
+	CategoryDairy,
+	CategoryBakery,
+	CategoryMeat,
+	CategoryHousehold,
+	CategoryOther,
+}
+
+// stems are beginnings of words which put an item into the category.
+var stems = map[Category][]string{
+	CategoryVegetables: {
+		"картопл", "цибул", "морк", "помідор", "томат", "огір", "огірк", "капуст", "буряк", "часник",
+		"перец", "перц", "кабач", "баклажан", "салат", "зелен", "кріп", "петрушк", "гриб", "печериц",
+		"potato", "onion", "carrot", "tomato", "cucumber", "cabbage", "beet", "garlic", "pepper",
+		"zucchini", "lettuce", "mushroom", "greens", "dill", "parsley",
+	},
+	CategoryFruit: {
+		"яблук", "яблуч", "банан", "апельсин", "мандарин", "лимон", "груш", "виноград", "ківі", "персик",
+		"слив", "полуниц", "ягід", "ягод",
+		"apple", "banana", "orange", "tangerine", "lemon", "pear", "grape", "kiwi", "peach", "plum",
+		"strawberr", "berr",
+	},
+	CategoryDairy: {
+		"молок", "кефір", "ряжанк", "йогурт", "сметан", "вершк", "масл", "сир", "яйц", "яєць",
+		"milk", "kefir", "yogurt", "yoghurt", "cream", "butter", "cheese", "egg",
+	},
+	CategoryBakery: {
+		"хліб", "батон", "булк", "булоч", "багет", "лаваш", "печив", "круасан",
+		"bread", "loaf", "bun", "baguette", "cookie", "croissant",
+	},
+	CategoryMeat: {
+		"м'яс", "мʼяс", "м’яс", "курк", "куряч", "курин", "свинин", "яловичин", "фарш", "ковбас",
+		"сосиск", "сардельк", "бекон", "риб", "філе",
+		"meat", "chicken", "pork", "beef", "mince", "sausage", "bacon", "ham", "fish", "fillet",
+	},
+	CategoryHousehold: {
+		"мил", "шампун", "порош", "туалетн", "папір", "серветк", "губк", "зубн", "засіб", "засоб",
+		"пакет", "фольг", "плівк", "батарейк", "лампоч",
+		"soap", "shampoo", "detergent", "toilet", "paper", "napkin", "sponge", "toothpaste", "foil",
+		"bags", "batter", "bulb",
+	},
+}
+
+// Categorize finds the store section of the item by the first of its words
+// which is known, unknown items are CategoryOther.
+func Categorize(title string) Category {
+	for _, word := range words(title) {
+		for _, category := range Categories {
+			for _, stem := range stems[category] {
+				if strings.HasPrefix(word, stem) {
+					return category
+				}
+			}
+		}
+	}
+
+	return CategoryOther
+}
@@ -0,0 +1,103 @@
+// Package shopping reads shopping list items written in natural language,
+// e.g. "молоко 2л" or "яйця x10", and sorts them into store sections.
+package shopping
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type Item struct {
+	Title string
+	// Quantity is zero when the item has no amount.
+	Quantity float64
+	// Unit is lowercase as written, empty for a plain count.
+	Unit     string
+	Category Category
+}
+
+const (
+	amount   = `(\d+(?:[.,]\d+)?)`
+	measures = `(л|мл|кг|г|гр|шт|уп|пач|бут|l|ml|kg|g|pcs|pc|pack)\.?`
+	times    = `[xх×]`
+)
+
+var (
+	// the amount is written either after the title or before it,
+	// a count may be marked with x on either side of the number
+	suffixRe = regexp.MustCompile(`(?i)\s+(?:` + times + `\s?` + amount + `|` + amount + `\s?` + times + `|` + amount + `\s?(?:` + measures + `)?)$`)
+	prefixRe = regexp.MustCompile(`(?i)^(?:` + times + `\s?` + amount + `|` + amount + `\s?` + times + `|` + amount + `\s?(?:` + measures + `)?)\s+`)
+	spaceRe  = regexp.MustCompile(`\s+`)
+)
+
+// Parse splits the amount off the item title and finds its category.
+func Parse(input string) Item {
+	item := Item{Title: strings.TrimSpace(spaceRe.ReplaceAllString(input, " "))}
+
+	for _, re := range []*regexp.Regexp{suffixRe, prefixRe} {
+		match := re.FindStringSubmatchIndex(item.Title)
+		if match == nil {
+			continue
+		}
+
+		groups := re.FindStringSubmatch(item.Title)
+		title := strings.TrimSpace(item.Title[:match[0]] + item.Title[match[1]:])
+		if title == "" {
+			continue
+		}
+
+		for _, value := range groups[1:4] {
+			if value != "" {
+				item.Quantity, _ = strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+			}
+		}
+		item.Unit = strings.ToLower(groups[4])
+		item.Title = title
+		break
+	}
+
+	item.Category = Categorize(item.Title)
+
+	return item
+}
+
+// Split returns items of a message, several of them may be separated by
+// commas, semicolons or new lines.
+func Split(input string) []string {
+	fields := strings.FieldsFunc(input, func(r rune) bool {
+		return r == ',' || r == ';' || r == '\n'
+	})
+
+	var result []string
+	for _, field := range fields {
+		if field = strings.TrimSpace(field); field != "" {
+			result = append(result, field)
+		}
+	}
+
+	return result
+}
+
+// FormatQuantity renders the amount of the item, e.g. "2 л" or "×10".
+func FormatQuantity(quantity float64, unit string) string {
+	if quantity == 0 {
+		return ""
+	}
+
+	value := strconv.FormatFloat(quantity, 'f', -1, 64)
+	if unit == "" {
+		return "×" + value
+	}
+
+	return value + " " + unit
+}
+
+// words returns lowercase words of the text, apostrophes are kept as
+// they are part of Ukrainian words.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\'' && r != 'ʼ' && r != '’'
+	})
+}
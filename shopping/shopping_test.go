@@ -0,0 +1,67 @@
+package shopping
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		title    string
+		quantity float64
+		unit     string
+		category Category
+	}{
+		{input: "хліб", title: "хліб", category: CategoryBakery},
+		{input: "молоко 2л", title: "молоко", quantity: 2, unit: "л", category: CategoryDairy},
+		{input: "молоко 2 Л.", title: "молоко", quantity: 2, unit: "л", category: CategoryDairy},
+		{input: "яйця x10", title: "яйця", quantity: 10, category: CategoryDairy},
+		{input: "яйця х10", title: "яйця", quantity: 10, category: CategoryDairy},
+		{input: "яйця 10х", title: "яйця", quantity: 10, category: CategoryDairy},
+		{input: "сир 0,5 кг", title: "сир", quantity: 0.5, unit: "кг", category: CategoryDairy},
+		{input: "2 кг картоплі", title: "картоплі", quantity: 2, unit: "кг", category: CategoryVegetables},
+		{input: "3 гарбузи", title: "гарбузи", quantity: 3},
+		{input: "туалетний папір 4 шт", title: "туалетний папір", quantity: 4, unit: "шт", category: CategoryHousehold},
+		{input: "куряче філе", title: "куряче філе", category: CategoryMeat},
+		{input: "apples 1.5 kg", title: "apples", quantity: 1.5, unit: "kg", category: CategoryFruit},
+		{input: "вітамін d3", title: "вітамін d3"},
+		{input: "10", title: "10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			item := Parse(tt.input)
+			want := Item{Title: tt.title, Quantity: tt.quantity, Unit: tt.unit, Category: tt.category}
+			if item != want {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.input, item, want)
+			}
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	got := Split("молоко 2л, яйця x10;\nхліб,, ")
+	want := []string{"молоко 2л", "яйця x10", "хліб"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Split() = %q, want %q", got, want)
+	}
+}
+
+func TestFormatQuantity(t *testing.T) {
+	tests := []struct {
+		quantity float64
+		unit     string
+		want     string
+	}{
+		{0, "", ""},
+		{10, "", "×10"},
+		{0.5, "кг", "0.5 кг"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatQuantity(tt.quantity, tt.unit); got != tt.want {
+			t.Errorf("FormatQuantity(%v, %q) = %q, want %q", tt.quantity, tt.unit, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package state
+
+import (
+	"sync"
+	"time"
+)
+
+var _ StateServiceI = (*MemoryStateService)(nil)
+
+type memoryEntry struct {
+	state     State
+	expiresAt time.Time
+}
+
+// MemoryStateService keeps states in process memory, they are lost on restart.
+type MemoryStateService struct {
+	mu    sync.Mutex
+	store map[int]memoryEntry
+	ttl   time.Duration
+	now   func() time.Time
+}
+
+func NewMemoryStateService(ttl time.Duration) *MemoryStateService {
+	return &MemoryStateService{
+		store: map[int]memoryEntry{},
+		ttl:   ttl,
+		now:   time.Now,
+	}
+}
+
+func (ms *MemoryStateService) GetUserState(userId int) *State {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	entry, ok := ms.store[userId]
+	if !ok {
+		return idleState()
+	}
+	if ms.ttl > 0 && ms.now().After(entry.expiresAt) {
+		delete(ms.store, userId)
+		return idleState()
+	}
+
+	state := entry.state
+
+	return &state
+}
+
+func (ms *MemoryStateService) SetUserState(userId int, state State) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.store[userId] = memoryEntry{
+		state:     state,
+		expiresAt: ms.now().Add(ms.ttl),
+	}
+}
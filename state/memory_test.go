@@ -0,0 +1,43 @@
+package state
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryStateExpires(t *testing.T) {
+	now := time.Date(2022, 5, 4, 10, 15, 0, 0, time.UTC)
+	ms := NewMemoryStateService(time.Hour)
+	ms.now = func() time.Time { return now }
+
+	ms.SetUserState(1, State{Status: STATUS_ADD_TASK_WAIT_TITLE})
+
+	now = now.Add(59 * time.Minute)
+	if got := ms.GetUserState(1).Status; got != STATUS_ADD_TASK_WAIT_TITLE {
+		t.Errorf("status before expiry = %q, want %q", got, STATUS_ADD_TASK_WAIT_TITLE)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if got := ms.GetUserState(1).Status; got != STATUS_IDLE {
+		t.Errorf("status after expiry = %q, want %q", got, STATUS_IDLE)
+	}
+	if _, ok := ms.store[1]; ok {
+		t.Error("expired state was kept")
+	}
+}
+
+func TestMemoryStateWithoutTTL(t *testing.T) {
+	now := time.Date(2022, 5, 4, 10, 15, 0, 0, time.UTC)
+	ms := NewMemoryStateService(0)
+	ms.now = func() time.Time { return now }
+
+	ms.SetUserState(1, State{Status: STATUS_ADD_TASK_WAIT_TITLE})
+	now = now.Add(365 * 24 * time.Hour)
+
+	if got := ms.GetUserState(1).Status; got != STATUS_ADD_TASK_WAIT_TITLE {
+		t.Errorf("status = %q, want %q", got, STATUS_ADD_TASK_WAIT_TITLE)
+	}
+	if got := ms.GetUserState(2).Status; got != STATUS_IDLE {
+		t.Errorf("unknown user status = %q, want %q", got, STATUS_IDLE)
+	}
+}
@@ -1,64 +1,242 @@
 package state
 
 import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
 const (
-	STATUS_IDLE                 Status = "idle"
-	STATUS_EDIT_TASK_WAIT_TITLE Status = "edit_task_wait_title"
-	STATUS_EDIT_TASK_WAIT_DATE  Status = "edit_task_wait_date"
-	STATUS_EDIT_TASK_WAIT_TIME  Status = "edit_task_wait_time"
-	STATUS_ADD_TASK_PARSED      Status = "add_task_parsed"
-	STATUS_ADD_TASK_WAIT_TITLE  Status = "add_task_wait_title"
-	STATUS_ADD_TASK_WAIT_DATE   Status = "add_task_wait_date"
-	STATUS_ADD_TASK_WAIT_TIME   Status = "add_task_wait_time"
+	redisKeyPrefix   = "family_bot:state:"
+	redisDialTimeout = 5 * time.Second
+	redisIOTimeout   = 5 * time.Second
 )
 
-type StateService struct {
-	store map[int]State
+var errRedisNil = errors.New("redis: nil reply")
+
+var _ StateServiceI = (*RedisStateService)(nil)
+
+// RedisStateService stores states in Redis speaking the RESP protocol directly,
+// expiry is delegated to Redis with SET ... EX.
+type RedisStateService struct {
+	addr     string
+	password string
+	db       int
+	ttl      time.Duration
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
 }
 
-type Status string
+func NewRedisStateService(addr, password string, db int, ttl time.Duration) (*RedisStateService, error) {
+	rs := &RedisStateService{
+		addr:     addr,
+		password: password,
+		db:       db,
+		ttl:      ttl,
+	}
 
-type Task struct {
-	ID            int
-	Title         string
-	Notifications int
-	Date          *time.Time
+	if _, err := rs.do("PING"); err != nil {
+		return nil, err
+	}
+
+	log.Println("successfully connected to redis")
+	return rs, nil
 }
 
-var _ StateServiceI = (*StateService)(nil)
+func (rs *RedisStateService) GetUserState(userId int) *State {
+	reply, err := rs.do("GET", redisKey(userId))
+	if err != nil {
+		if err != errRedisNil {
+			log.Println(err)
+		}
+		return idleState()
+	}
 
-type State struct {
-	Status Status
-	Task   Task
+	payload, ok := reply.(string)
+	if !ok {
+		return idleState()
+	}
+
+	var state State
+	if err := json.Unmarshal([]byte(payload), &state); err != nil {
+		log.Println(err)
+		return idleState()
+	}
+
+	return &state
 }
 
-func NewStateService() *StateService {
-	return &StateService{
-		store: map[int]State{},
+func (rs *RedisStateService) SetUserState(userId int, state State) {
+	payload, err := json.Marshal(state)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	args := []string{"SET", redisKey(userId), string(payload)}
+	if rs.ttl > 0 {
+		args = append(args, "EX", strconv.Itoa(int(rs.ttl.Seconds())))
+	}
+
+	if _, err := rs.do(args...); err != nil {
+		log.Println(err)
 	}
 }
 
-type StateServiceI interface {
-	GetUserState(userId int) *State
-	SetUserState(userId int, state State)
+func redisKey(userId int) string {
+	return redisKeyPrefix + strconv.Itoa(userId)
 }
 
-func (rs *StateService) GetUserState(userId int) *State {
-	state, ok := rs.store[userId]
-	if !ok {
-		rs.store[userId] = State{
-			Status: STATUS_IDLE,
-			Task:   Task{},
+// do sends a single command and reads its reply, a broken connection is
+// dropped and re-established once before giving up.
+func (rs *RedisStateService) do(args ...string) (interface{}, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	reply, err := rs.roundTrip(args)
+	if err != nil && err != errRedisNil && rs.conn == nil {
+		reply, err = rs.roundTrip(args)
+	}
+
+	return reply, err
+}
+
+func (rs *RedisStateService) roundTrip(args []string) (interface{}, error) {
+	if rs.conn == nil {
+		if err := rs.connect(); err != nil {
+			return nil, err
 		}
 	}
 
-	state = rs.store[userId]
+	reply, err := rs.command(args)
+	if err != nil {
+		var redisErr redisError
+		if err != errRedisNil && !errors.As(err, &redisErr) {
+			rs.close()
+		}
+		return nil, err
+	}
 
-	return &state
+	return reply, nil
 }
-func (rs *StateService) SetUserState(userId int, state State) {
-	rs.store[userId] = state
+
+func (rs *RedisStateService) connect() error {
+	conn, err := net.DialTimeout("tcp", rs.addr, redisDialTimeout)
+	if err != nil {
+		return err
+	}
+
+	rs.conn = conn
+	rs.reader = bufio.NewReader(conn)
+
+	if rs.password != "" {
+		if _, err := rs.command([]string{"AUTH", rs.password}); err != nil {
+			rs.close()
+			return err
+		}
+	}
+
+	if rs.db != 0 {
+		if _, err := rs.command([]string{"SELECT", strconv.Itoa(rs.db)}); err != nil {
+			rs.close()
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (rs *RedisStateService) close() {
+	if rs.conn != nil {
+		rs.conn.Close()
+	}
+	rs.conn = nil
+	rs.reader = nil
+}
+
+func (rs *RedisStateService) command(args []string) (interface{}, error) {
+	if err := rs.conn.SetDeadline(time.Now().Add(redisIOTimeout)); err != nil {
+		return nil, err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("*%d\r\n", len(args)))
+	for _, arg := range args {
+		sb.WriteString(fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg))
+	}
+
+	if _, err := io.WriteString(rs.conn, sb.String()); err != nil {
+		return nil, err
+	}
+
+	return readRedisReply(rs.reader)
+}
+
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+func readRedisReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, errRedisNil
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:size]), nil
+	case '*':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, errRedisNil
+		}
+		items := make([]interface{}, 0, size)
+		for i := 0; i < size; i++ {
+			item, err := readRedisReply(r)
+			if err != nil && err != errRedisNil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	}
+
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
 }
@@ -0,0 +1,160 @@
+package state
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReadRedisReply(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  interface{}
+		err   error
+	}{
+		{name: "simple string", input: "+OK\r\n", want: "OK"},
+		{name: "bulk string", input: "$5\r\nhello\r\n", want: "hello"},
+		{name: "empty bulk string", input: "$0\r\n\r\n", want: ""},
+		{name: "bulk string with newline", input: "$4\r\na\r\nb\r\n", want: "a\r\nb"},
+		{name: "nil bulk string", input: "$-1\r\n", err: errRedisNil},
+		{name: "error", input: "-ERR unknown command\r\n", err: redisError("ERR unknown command")},
+		{name: "integer", input: ":42\r\n", want: int64(42)},
+		{name: "negative integer", input: ":-2\r\n", want: int64(-2)},
+		{name: "array", input: "*3\r\n$3\r\nGET\r\n$-1\r\n:1\r\n", want: []interface{}{"GET", nil, int64(1)}},
+		{name: "nil array", input: "*-1\r\n", err: errRedisNil},
+		{name: "truncated line", input: "+OK", err: io.EOF},
+		{name: "truncated bulk string", input: "$5\r\nhel", err: io.ErrUnexpectedEOF},
+		{name: "truncated array", input: "*2\r\n:1\r\n", err: io.EOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readRedisReply(bufio.NewReader(strings.NewReader(tt.input)))
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("readRedisReply(%q) error = %v, want %v", tt.input, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readRedisReply(%q) error = %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readRedisReply(%q) = %#v, want %#v", tt.input, got, tt.want)
+			}
+		})
+	}
+
+	for _, input := range []string{"\r\n", "?what\r\n", ":x\r\n", "$x\r\n"} {
+		if _, err := readRedisReply(bufio.NewReader(strings.NewReader(input))); err == nil {
+			t.Errorf("readRedisReply(%q) error = nil, want an error", input)
+		}
+	}
+}
+
+// fakeRedis serves GET, SET and PING from memory and records the commands.
+type fakeRedis struct {
+	mu       sync.Mutex
+	values   map[string]string
+	commands [][]string
+}
+
+func startFakeRedis(t *testing.T) (*fakeRedis, string) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	fake := &fakeRedis{values: map[string]string{}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go fake.serve(conn)
+		}
+	}()
+
+	return fake, listener.Addr().String()
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	for {
+		reply, err := readRedisReply(reader)
+		if err != nil {
+			return
+		}
+
+		var args []string
+		for _, arg := range reply.([]interface{}) {
+			args = append(args, arg.(string))
+		}
+
+		f.mu.Lock()
+		f.commands = append(f.commands, args)
+		response := "-ERR unknown command\r\n"
+		switch strings.ToUpper(args[0]) {
+		case "PING":
+			response = "+PONG\r\n"
+		case "SET":
+			f.values[args[1]] = args[2]
+			response = "+OK\r\n"
+		case "GET":
+			response = "$-1\r\n"
+			if value, ok := f.values[args[1]]; ok {
+				response = "$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n"
+			}
+		}
+		f.mu.Unlock()
+
+		if _, err := io.WriteString(conn, response); err != nil {
+			return
+		}
+	}
+}
+
+func TestRedisStateService(t *testing.T) {
+	fake, addr := startFakeRedis(t)
+
+	rs, err := NewRedisStateService(addr, "", 0, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := rs.GetUserState(1).Status; got != STATUS_IDLE {
+		t.Errorf("status of a new user = %q, want %q", got, STATUS_IDLE)
+	}
+
+	rs.SetUserState(1, State{Status: STATUS_EDIT_TASK_WAIT_TITLE, Task: Task{ID: 7, Title: "купити хліб"}})
+	state := rs.GetUserState(1)
+	if state.Status != STATUS_EDIT_TASK_WAIT_TITLE || state.Task.ID != 7 || state.Task.Title != "купити хліб" {
+		t.Errorf("state = %+v, want the stored one", state)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	var set []string
+	for _, command := range fake.commands {
+		if command[0] == "SET" {
+			set = command
+		}
+	}
+	if len(set) != 5 || set[1] != redisKey(1) || set[3] != "EX" || set[4] != "3600" {
+		t.Errorf("SET command = %q, want the key with an expiry of an hour", set)
+	}
+}
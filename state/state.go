@@ -0,0 +1,77 @@
+package state
+
+import (
+	"time"
+
+	"github.com/maxwww/family_bot/units"
+)
+
+const (
+	STATUS_IDLE                    Status = "idle"
+	STATUS_EDIT_TASK_WAIT_TITLE    Status = "edit_task_wait_title"
+	STATUS_EDIT_TASK_WAIT_DATE     Status = "edit_task_wait_date"
+	STATUS_EDIT_TASK_WAIT_TIME     Status = "edit_task_wait_time"
+	STATUS_ADD_TASK_PARSED         Status = "add_task_parsed"
+	STATUS_ADD_TASK_WAIT_TITLE     Status = "add_task_wait_title"
+	STATUS_ADD_TASK_WAIT_DATE      Status = "add_task_wait_date"
+	STATUS_ADD_TASK_WAIT_TIME      Status = "add_task_wait_time"
+	STATUS_EDIT_LIST_WAIT_NAME     Status = "edit_list_wait_name"
+	STATUS_EDIT_TASK_WAIT_NOTES    Status = "edit_task_wait_notes"
+	STATUS_EDIT_TASK_WAIT_ITEMS    Status = "edit_task_wait_items"
+	STATUS_EDIT_TASK_WAIT_FILE     Status = "edit_task_wait_file"
+	STATUS_IMPORT_EVENTS           Status = "import_events"
+	STATUS_IMPORT_BACKUP_WAIT_FILE Status = "import_backup_wait_file"
+	STATUS_IMPORT_BACKUP           Status = "import_backup"
+
+	// DefaultTTL is how long an untouched conversation state lives,
+	// abandoned wizards fall back to idle after it.
+	DefaultTTL = 24 * time.Hour
+)
+
+type Status string
+
+type Task struct {
+	ID            int
+	Title         string
+	Notifications int
+	Date          *time.Time
+	Recurrence    units.Recurrence
+	Assignees     []uint
+	// ListID is the list the new task goes to or the list being renamed.
+	ListID      uint
+	Attachments []*units.Attachment
+}
+
+// Event is a calendar event offered to be added as a task.
+type Event struct {
+	UID           string
+	Title         string
+	Notes         string
+	Date          *time.Time
+	Recurrence    units.Recurrence
+	Notifications int
+	Selected      bool
+	// Imported events are tasks of the family already.
+	Imported bool
+}
+
+type State struct {
+	Status Status
+	Task   Task
+	// Events are read from a calendar file being imported.
+	Events []Event
+	// FileID is the backup waiting for the restore to be confirmed.
+	FileID string
+}
+
+type StateServiceI interface {
+	GetUserState(userId int) *State
+	SetUserState(userId int, state State)
+}
+
+func idleState() *State {
+	return &State{
+		Status: STATUS_IDLE,
+		Task:   Task{},
+	}
+}
@@ -0,0 +1,49 @@
+package units
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type Family struct {
+	ID        uint
+	Name      string    `db:"name"`
+	CreatedAt time.Time `db:"created_at"`
+	// CalendarToken is the secret part of the address of the calendar feed,
+	// the family has no feed until it is set.
+	CalendarToken sql.NullString `db:"calendar_token"`
+}
+
+type FamilyInvite struct {
+	Code      string    `db:"code"`
+	FamilyID  uint      `db:"family_id"`
+	CreatedBy uint      `db:"created_by"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+type FamilyFilter struct {
+	Id            *uint
+	CalendarToken *string
+
+	Limit  int
+	Offset int
+}
+
+type FamilyService interface {
+	CreateFamily(context.Context, *Family) error
+
+	FamilyByID(context.Context, uint) (*Family, error)
+
+	Families(context.Context, FamilyFilter) ([]*Family, error)
+
+	CreateInvite(context.Context, *FamilyInvite) error
+
+	// RedeemInvite adds the user to the family of an unused and unexpired
+	// invite, unknown codes return ErrNotFound.
+	RedeemInvite(context.Context, string, *User) (*Family, error)
+
+	// SetCalendarToken replaces the token of the calendar feed, the old
+	// address stops working.
+	SetCalendarToken(ctx context.Context, familyID uint, token string) error
+}
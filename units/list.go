@@ -0,0 +1,57 @@
+package units
+
+import (
+	"context"
+	"time"
+)
+
+type ListKind string
+
+const (
+	ListKindTasks    ListKind = "tasks"
+	ListKindShopping ListKind = "shopping"
+)
+
+func (k ListKind) IsValid() bool {
+	return k == ListKindTasks || k == ListKindShopping
+}
+
+// List groups tasks of a family, shopping lists keep items to buy
+// which have no dates.
+type List struct {
+	ID        uint
+	FamilyID  uint      `db:"family_id"`
+	Name      string    `db:"name"`
+	Kind      ListKind  `db:"kind"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+type ListPatch struct {
+	Name *string
+}
+
+type ListFilter struct {
+	Id       *uint
+	FamilyID *uint
+	Kind     *ListKind
+
+	Limit  int
+	Offset int
+}
+
+type ListService interface {
+	CreateList(context.Context, *List) error
+
+	ListByID(context.Context, uint) (*List, error)
+
+	Lists(context.Context, ListFilter) ([]*List, error)
+
+	UpdateList(context.Context, *List, ListPatch) error
+
+	// RemoveByID removes the list together with its tasks.
+	RemoveByID(context.Context, uint) error
+
+	// DefaultList returns the first list of the kind in the family,
+	// it is created with the given name when the family has none.
+	DefaultList(ctx context.Context, familyID uint, kind ListKind, name string) (*List, error)
+}
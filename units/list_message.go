@@ -0,0 +1,25 @@
+package units
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// ListMessage is the message of a chat which is kept showing the current
+// task list, MessageID is not valid while the message shows something else.
+type ListMessage struct {
+	ChatID    int64         `db:"chat_id"`
+	UserID    uint          `db:"user_id"`
+	MessageID sql.NullInt64 `db:"message_id"`
+	Pinned    bool          `db:"pinned"`
+	UpdatedAt time.Time     `db:"updated_at"`
+}
+
+type ListMessageService interface {
+	ListMessageByChatID(context.Context, int64) (*ListMessage, error)
+	// ListMessages returns the shown list messages of the family members.
+	ListMessages(ctx context.Context, familyID uint) ([]*ListMessage, error)
+	// SaveListMessage creates or replaces the list message of the chat.
+	SaveListMessage(context.Context, *ListMessage) error
+}
@@ -0,0 +1,34 @@
+package units
+
+import (
+	"context"
+	"time"
+)
+
+// Snooze is a one-off extra reminder about a task, it does not move the task.
+type Snooze struct {
+	ID        uint
+	TaskID    uint      `db:"task_id"`
+	CreatedBy uint      `db:"created_by"`
+	RemindAt  time.Time `db:"remind_at"`
+}
+
+type NotificationService interface {
+	// LastTick returns when the named job last finished successfully,
+	// nil means it has never run.
+	LastTick(context.Context, string) (*time.Time, error)
+
+	SetLastTick(context.Context, string, time.Time) error
+
+	// MarkSent records a reminder of the task for the given offset in minutes
+	// before the due time and reports false when it was already recorded.
+	MarkSent(ctx context.Context, taskID uint, offset int, dueAt time.Time) (bool, error)
+
+	CreateSnooze(context.Context, *Snooze) error
+
+	// DueSnoozes returns unsent snoozes due at or before the given time.
+	DueSnoozes(context.Context, time.Time) ([]*Snooze, error)
+
+	// MarkSnoozeSent reports false when the snooze was already sent.
+	MarkSnoozeSent(context.Context, uint) (bool, error)
+}
@@ -0,0 +1,171 @@
+package units
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Recurrence string
+
+const (
+	RecurrenceNone    Recurrence = ""
+	RecurrenceDaily   Recurrence = "daily"
+	RecurrenceWeekly  Recurrence = "weekly"
+	RecurrenceMonthly Recurrence = "monthly"
+	RecurrenceYearly  Recurrence = "yearly"
+
+	recurrenceDaysPrefix = "days:"
+	// recurrenceAnchorSeparator separates the day of the month monthly and
+	// yearly rules repeat on, e.g. "monthly:31".
+	recurrenceAnchorSeparator = ":"
+)
+
+// NewWeekdaysRecurrence builds a rule which repeats on the given weekdays,
+// e.g. "days:1,5" for every Monday and Friday.
+func NewWeekdaysRecurrence(days ...time.Weekday) Recurrence {
+	set := map[time.Weekday]bool{}
+	for _, d := range days {
+		set[d] = true
+	}
+	if len(set) == 0 {
+		return RecurrenceNone
+	}
+	if len(set) == 7 {
+		return RecurrenceDaily
+	}
+
+	var values []int
+	for d := range set {
+		values = append(values, int(d))
+	}
+	sort.Ints(values)
+
+	var parts []string
+	for _, v := range values {
+		parts = append(parts, strconv.Itoa(v))
+	}
+
+	return Recurrence(recurrenceDaysPrefix + strings.Join(parts, ","))
+}
+
+// Weekdays returns the days of a custom weekday rule or nil for other rules.
+func (r Recurrence) Weekdays() []time.Weekday {
+	if !strings.HasPrefix(string(r), recurrenceDaysPrefix) {
+		return nil
+	}
+
+	var days []time.Weekday
+	for _, part := range strings.Split(strings.TrimPrefix(string(r), recurrenceDaysPrefix), ",") {
+		day, err := strconv.Atoi(part)
+		if err != nil || day < 0 || day > 6 {
+			continue
+		}
+		days = append(days, time.Weekday(day))
+	}
+
+	return days
+}
+
+// Base returns the rule without the day it is anchored to, so anchored
+// rules compare equal to the plain ones.
+func (r Recurrence) Base() Recurrence {
+	base, _, found := strings.Cut(string(r), recurrenceAnchorSeparator)
+	if !found || (Recurrence(base) != RecurrenceMonthly && Recurrence(base) != RecurrenceYearly) {
+		return r
+	}
+
+	return Recurrence(base)
+}
+
+// Anchored anchors monthly and yearly rules to the day of the month, months
+// shorter than the day end the rule on their last day and the next months
+// are back on the day. Other rules are returned unchanged.
+func (r Recurrence) Anchored(day int) Recurrence {
+	base := r.Base()
+	if (base != RecurrenceMonthly && base != RecurrenceYearly) || day < 1 || day > 31 {
+		return r
+	}
+
+	return Recurrence(string(base) + recurrenceAnchorSeparator + strconv.Itoa(day))
+}
+
+// AnchorDay returns the day of the month of an anchored rule or 0.
+func (r Recurrence) AnchorDay() int {
+	if r.Base() == r {
+		return 0
+	}
+
+	_, value, _ := strings.Cut(string(r), recurrenceAnchorSeparator)
+	day, err := strconv.Atoi(value)
+	if err != nil || day < 1 || day > 31 {
+		return 0
+	}
+
+	return day
+}
+
+func (r Recurrence) IsValid() bool {
+	switch r {
+	case RecurrenceNone, RecurrenceDaily, RecurrenceWeekly, RecurrenceMonthly, RecurrenceYearly:
+		return true
+	}
+	if r.Base() != r {
+		return r.AnchorDay() != 0
+	}
+
+	return len(r.Weekdays()) > 0
+}
+
+// Next returns the first occurrence of the rule after t keeping the time of day.
+// Monthly and yearly rules which are not anchored repeat on the day of t.
+// Non-recurring rules return t unchanged.
+func (r Recurrence) Next(t time.Time) time.Time {
+	day := r.AnchorDay()
+	if day == 0 {
+		day = t.Day()
+	}
+
+	switch r.Base() {
+	case RecurrenceNone:
+		return t
+	case RecurrenceDaily:
+		return t.AddDate(0, 0, 1)
+	case RecurrenceWeekly:
+		return t.AddDate(0, 0, 7)
+	case RecurrenceMonthly:
+		return addMonths(t, 1, day)
+	case RecurrenceYearly:
+		return addMonths(t, 12, day)
+	}
+
+	days := r.Weekdays()
+	if len(days) == 0 {
+		return t
+	}
+	for delta := 1; delta <= 7; delta++ {
+		next := t.AddDate(0, 0, delta)
+		for _, d := range days {
+			if next.Weekday() == d {
+				return next
+			}
+		}
+	}
+
+	return t
+}
+
+// addMonths moves t by the given number of months to the day clamped to the
+// end of the target month, so a rule started on the 31st does not skip
+// February and is back on the 31st in March.
+func addMonths(t time.Time, months int, day int) time.Time {
+	first := time.Date(t.Year(), t.Month(), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	target := first.AddDate(0, months, 0)
+	lastDay := target.AddDate(0, 1, -1).Day()
+	if day > lastDay {
+		day = lastDay
+	}
+
+	return target.AddDate(0, 0, day-1)
+}
@@ -0,0 +1,98 @@
+package units
+
+import (
+	"testing"
+	"time"
+)
+
+const testLayout = "2006-01-02 15:04"
+
+func TestRecurrenceNext(t *testing.T) {
+	kyiv, err := time.LoadLocation("Europe/Kyiv")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		name       string
+		recurrence Recurrence
+		from       string
+		loc        *time.Location
+		want       string
+	}{
+		{name: "none", recurrence: RecurrenceNone, from: "2022-05-04 10:15", want: "2022-05-04 10:15"},
+		{name: "daily", recurrence: RecurrenceDaily, from: "2022-12-31 10:15", want: "2023-01-01 10:15"},
+		{name: "weekly", recurrence: RecurrenceWeekly, from: "2022-05-04 10:15", want: "2022-05-11 10:15"},
+		{name: "monthly", recurrence: RecurrenceMonthly, from: "2022-05-04 10:15", want: "2022-06-04 10:15"},
+		{name: "monthly end of year", recurrence: RecurrenceMonthly, from: "2022-12-15 10:15", want: "2023-01-15 10:15"},
+		{name: "monthly 31st to february", recurrence: RecurrenceMonthly, from: "2022-01-31 10:15", want: "2022-02-28 10:15"},
+		{name: "monthly 31st to leap february", recurrence: RecurrenceMonthly, from: "2024-01-31 10:15", want: "2024-02-29 10:15"},
+		{name: "monthly 31st to april", recurrence: RecurrenceMonthly, from: "2022-03-31 10:15", want: "2022-04-30 10:15"},
+		{name: "anchored back to 31st", recurrence: RecurrenceMonthly.Anchored(31), from: "2022-02-28 10:15", want: "2022-03-31 10:15"},
+		{name: "anchored back to 30th", recurrence: RecurrenceMonthly.Anchored(30), from: "2022-02-28 10:15", want: "2022-03-30 10:15"},
+		{name: "anchored clamped", recurrence: RecurrenceMonthly.Anchored(31), from: "2022-03-31 10:15", want: "2022-04-30 10:15"},
+		{name: "yearly", recurrence: RecurrenceYearly, from: "2022-05-04 10:15", want: "2023-05-04 10:15"},
+		{name: "yearly leap day", recurrence: RecurrenceYearly, from: "2024-02-29 10:15", want: "2025-02-28 10:15"},
+		{name: "yearly anchored to leap day", recurrence: RecurrenceYearly.Anchored(29), from: "2027-02-28 10:15", want: "2028-02-29 10:15"},
+		{name: "yearly anchored not leap", recurrence: RecurrenceYearly.Anchored(29), from: "2025-02-28 10:15", want: "2026-02-28 10:15"},
+		{name: "weekdays", recurrence: NewWeekdaysRecurrence(time.Monday, time.Friday), from: "2022-05-04 10:15", want: "2022-05-06 10:15"},
+		{name: "weekdays next week", recurrence: NewWeekdaysRecurrence(time.Monday, time.Friday), from: "2022-05-06 10:15", want: "2022-05-09 10:15"},
+		{name: "single weekday", recurrence: NewWeekdaysRecurrence(time.Wednesday), from: "2022-05-04 10:15", want: "2022-05-11 10:15"},
+		{name: "daily over dst start", recurrence: RecurrenceDaily, from: "2022-03-26 09:00", loc: kyiv, want: "2022-03-27 09:00"},
+		{name: "weekly over dst end", recurrence: RecurrenceWeekly, from: "2022-10-27 09:00", loc: kyiv, want: "2022-11-03 09:00"},
+		{name: "monthly over dst start", recurrence: RecurrenceMonthly.Anchored(31), from: "2022-02-28 09:00", loc: kyiv, want: "2022-03-31 09:00"},
+		{name: "weekdays over dst end", recurrence: NewWeekdaysRecurrence(time.Sunday), from: "2022-10-29 09:00", loc: kyiv, want: "2022-10-30 09:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc := tt.loc
+			if loc == nil {
+				loc = time.UTC
+			}
+			from, err := time.ParseInLocation(testLayout, tt.from, loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := tt.recurrence.Next(from).In(loc).Format(testLayout); got != tt.want {
+				t.Errorf("%q.Next(%s) = %s, want %s", tt.recurrence, tt.from, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecurrenceAnchored(t *testing.T) {
+	tests := []struct {
+		recurrence Recurrence
+		day        int
+		want       Recurrence
+	}{
+		{recurrence: RecurrenceMonthly, day: 31, want: "monthly:31"},
+		{recurrence: RecurrenceYearly, day: 29, want: "yearly:29"},
+		{recurrence: Recurrence("monthly:31"), day: 15, want: "monthly:15"},
+		{recurrence: RecurrenceMonthly, day: 32, want: RecurrenceMonthly},
+		{recurrence: RecurrenceDaily, day: 31, want: RecurrenceDaily},
+		{recurrence: RecurrenceNone, day: 31, want: RecurrenceNone},
+		{recurrence: Recurrence("days:1,5"), day: 31, want: "days:1,5"},
+	}
+
+	for _, tt := range tests {
+		got := tt.recurrence.Anchored(tt.day)
+		if got != tt.want {
+			t.Errorf("%q.Anchored(%d) = %q, want %q", tt.recurrence, tt.day, got, tt.want)
+		}
+		if !got.IsValid() {
+			t.Errorf("%q is not valid", got)
+		}
+		if got.Base() != tt.recurrence.Base() {
+			t.Errorf("%q.Base() = %q, want %q", got, got.Base(), tt.recurrence.Base())
+		}
+	}
+
+	for _, invalid := range []Recurrence{"monthly:", "monthly:0", "monthly:32", "weekly:3", "hourly", "days:"} {
+		if invalid.IsValid() {
+			t.Errorf("%q.IsValid() = true, want false", invalid)
+		}
+	}
+}
@@ -3,29 +3,129 @@ package units
 import (
 	"context"
 	"database/sql"
+	"time"
 )
 
+// MaxTitleLength is how many characters titles of tasks are stored with.
+const MaxTitleLength = 255
+
 type Task struct {
 	ID            uint
-	Title         string         `db:"title"`
-	Date          sql.NullString `db:"date"`
-	Done          bool           `db:"done"`
-	Notifications int            `db:"notifications"`
+	Title         string       `db:"title"`
+	Date          sql.NullTime `db:"date"`
+	Done          bool         `db:"done"`
+	Notifications int          `db:"notifications"`
+	Recurrence    Recurrence   `db:"recurrence"`
+	Assignees     []uint       `db:"-"`
+	FamilyID      uint         `db:"family_id"`
+	ListID        uint         `db:"list_id"`
+	// Quantity and Unit are the amount of a shopping item, Quantity is not
+	// valid for items without one and Unit is empty for a plain count.
+	Quantity sql.NullFloat64 `db:"quantity"`
+	Unit     string          `db:"unit"`
+	// Category is the store section of a shopping item.
+	Category string `db:"category"`
+	Notes    string `db:"notes"`
+	// UID is the id of the calendar event the task was imported from,
+	// it keeps the event from being imported twice.
+	UID string `db:"uid"`
+	// CreatedBy and CompletedBy are ids of the users, they are not valid
+	// for tasks made before the history was kept.
+	CreatedBy   sql.NullInt64 `db:"created_by"`
+	CreatedAt   time.Time     `db:"created_at"`
+	UpdatedAt   time.Time     `db:"updated_at"`
+	CompletedBy sql.NullInt64 `db:"completed_by"`
+	CompletedAt sql.NullTime  `db:"completed_at"`
+	// DeletedAt is set for removed tasks which can still be restored,
+	// services do not return them.
+	DeletedAt sql.NullTime `db:"deleted_at"`
+	// Items is the checklist of the task in its order.
+	Items       []*TaskItem   `db:"-"`
+	Attachments []*Attachment `db:"-"`
+}
+
+// TruncateTitle cuts the title to MaxTitleLength characters.
+func TruncateTitle(title string) string {
+	runes := []rune(title)
+	if len(runes) <= MaxTitleLength {
+		return title
+	}
+
+	return string(runes[:MaxTitleLength])
+}
+
+// TaskItem is an entry of the task checklist.
+type TaskItem struct {
+	ID       uint
+	TaskID   uint   `db:"task_id"`
+	Title    string `db:"title"`
+	Done     bool   `db:"done"`
+	Position int    `db:"position"`
+}
+
+type AttachmentKind string
+
+const (
+	AttachmentPhoto    AttachmentKind = "photo"
+	AttachmentDocument AttachmentKind = "document"
+)
+
+func (k AttachmentKind) IsValid() bool {
+	return k == AttachmentPhoto || k == AttachmentDocument
+}
+
+// Attachment is a file sent with the task, Telegram keeps the file itself
+// and FileID is enough to send it again.
+type Attachment struct {
+	ID     uint
+	TaskID uint           `db:"task_id"`
+	Kind   AttachmentKind `db:"kind"`
+	FileID string         `db:"file_id"`
 }
 
 type TaskPatch struct {
 	Title         *string
 	Done          *bool
-	Date          *sql.NullString
+	Date          *sql.NullTime
 	Notifications *int
+	Recurrence    *Recurrence
+	Assignees     *[]uint
+	Notes         *string
+	// DoneItems are ids of the checklist items which are done, the other
+	// items of the task are not.
+	DoneItems *[]uint
 }
 
+// TaskOrder is the order tasks are returned in, the zero value orders them
+// by id.
+type TaskOrder string
+
+const (
+	TaskOrderID TaskOrder = "id"
+	// TaskOrderDate puts tasks without a date last.
+	TaskOrderDate  TaskOrder = "date"
+	TaskOrderTitle TaskOrder = "title"
+)
+
 type TaskFilter struct {
-	Id   *uint
-	Done *bool
+	Id       *uint
+	Done     *bool
+	FamilyID *uint
+	ListID   *uint
+	// AssigneeID keeps the tasks assigned to the user.
+	AssigneeID *uint
+	// From and To bound the date, From is inclusive and To is not. Tasks
+	// without a date are left out when either of them is set.
+	From    *time.Time
+	To      *time.Time
+	HasDate *bool
+	// Query keeps the tasks whose title or notes contain it, the case is
+	// ignored.
+	Query *string
 
-	Limit  int
-	Offset int
+	OrderBy TaskOrder
+	Limit   int
+	Offset  int
 }
 
 type TaskService interface {
@@ -37,9 +137,36 @@ type TaskService interface {
 
 	UpdateTask(context.Context, *Task, TaskPatch) error
 
-	CompleteTask(context.Context, int) (bool, error)
+	// CompleteTask toggles the task, recurring tasks move to the next
+	// occurrence counted in the given location instead.
+	CompleteTask(context.Context, int, *time.Location) (bool, error)
 
-	RemoveCompete(context.Context) error
+	// RemoveCompete removes done tasks of the list, ErrNotFound means the
+	// list has none.
+	RemoveCompete(context.Context, uint) error
 
+	// RemoveByID removes the task, ErrNotFound means it has been removed
+	// already.
 	RemoveByID(context.Context, int) error
+
+	// UndoLast reverts the latest change of the user of the context made
+	// after since, all tasks of a change are reverted together. Changes of
+	// checklists and files are skipped. The reverted events are returned
+	// as they were, ErrNotFound means there is nothing to undo.
+	UndoLast(ctx context.Context, since time.Time) ([]*TaskEvent, error)
+
+	// PurgeDeleted removes tasks removed before the time for good.
+	PurgeDeleted(ctx context.Context, before time.Time) error
+
+	// AddTaskItems appends entries with the given titles to the checklist.
+	AddTaskItems(ctx context.Context, taskID uint, titles []string) error
+
+	ToggleTaskItem(ctx context.Context, taskID uint, itemID uint) error
+
+	RemoveTaskItem(ctx context.Context, taskID uint, itemID uint) error
+
+	AddTaskAttachment(context.Context, *Attachment) error
+
+	// TaskEvents returns the history newest first.
+	TaskEvents(context.Context, TaskEventFilter) ([]*TaskEvent, error)
 }
@@ -0,0 +1,71 @@
+package units
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type TaskEventKind string
+
+const (
+	TaskEventCreated   TaskEventKind = "created"
+	TaskEventEdited    TaskEventKind = "edited"
+	TaskEventCompleted TaskEventKind = "completed"
+	TaskEventReopened  TaskEventKind = "reopened"
+	TaskEventDeleted   TaskEventKind = "deleted"
+)
+
+// names of task fields recorded in edit events
+const (
+	TaskFieldTitle         = "title"
+	TaskFieldDate          = "date"
+	TaskFieldNotifications = "notifications"
+	TaskFieldRecurrence    = "recurrence"
+	TaskFieldAssignees     = "assignees"
+	TaskFieldNotes         = "notes"
+	TaskFieldItems         = "items"
+	TaskFieldAttachments   = "attachments"
+	TaskFieldDone          = "done"
+)
+
+// TaskEvent records a change of a task made by a user, events are kept
+// after the task is removed.
+type TaskEvent struct {
+	ID       uint
+	TaskID   uint          `db:"task_id"`
+	FamilyID uint          `db:"family_id"`
+	UserID   sql.NullInt64 `db:"user_id"`
+	Kind     TaskEventKind `db:"kind"`
+	// Title is the title of the task at the moment of the event.
+	Title string `db:"title"`
+	// Fields are names of the changed fields of an edit separated by commas.
+	Fields string `db:"fields"`
+	// Previous is the JSON of a TaskPatch restoring what the change replaced.
+	Previous  string    `db:"previous"`
+	Undone    bool      `db:"undone"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+type TaskEventFilter struct {
+	TaskID   *uint
+	FamilyID *uint
+
+	Limit  int
+	Offset int
+}
+
+type actorKey struct{}
+
+// WithActor returns a context of changes made by the user with the given id,
+// services record the user as the author of them.
+func WithActor(ctx context.Context, userID uint) context.Context {
+	return context.WithValue(ctx, actorKey{}, userID)
+}
+
+// ActorFromContext returns the id of the user set with WithActor.
+func ActorFromContext(ctx context.Context) (uint, bool) {
+	userID, ok := ctx.Value(actorKey{}).(uint)
+
+	return userID, ok
+}
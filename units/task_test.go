@@ -0,0 +1,19 @@
+package units
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateTitle(t *testing.T) {
+	if got := TruncateTitle("купити хліб"); got != "купити хліб" {
+		t.Errorf("TruncateTitle() = %q, want the short title unchanged", got)
+	}
+
+	long := strings.Repeat("я", MaxTitleLength+10)
+	got := TruncateTitle(long)
+	if utf8.RuneCountInString(got) != MaxTitleLength || !strings.HasPrefix(long, got) {
+		t.Errorf("TruncateTitle() kept %d characters, want %d", utf8.RuneCountInString(got), MaxTitleLength)
+	}
+}
@@ -2,15 +2,24 @@ package units
 
 import (
 	"context"
+	"database/sql"
 )
 
 type User struct {
 	ID            uint
-	TelegramID    uint   `db:"telegram_id"`
-	FirstName     string `db:"first_name"`
-	LastName      string `db:"last_name"`
-	UserName      string `db:"user_name"`
-	Notifications bool   `db:"notifications"`
+	TelegramID    uint          `db:"telegram_id"`
+	FirstName     string        `db:"first_name"`
+	LastName      string        `db:"last_name"`
+	UserName      string        `db:"user_name"`
+	Notifications bool          `db:"notifications"`
+	FamilyID      sql.NullInt64 `db:"family_id"`
+	// Timezone is an IANA zone name, empty means the bot's default location.
+	Timezone string `db:"timezone"`
+	// Language is the code of the catalog messages are sent in.
+	Language string `db:"language"`
+	// ActiveListID is the list new tasks of the user go to, not valid means
+	// the task list of the family.
+	ActiveListID sql.NullInt64 `db:"active_list_id"`
 }
 
 type UserPatch struct {
@@ -18,10 +27,15 @@ type UserPatch struct {
 	LastName      *string
 	UserName      *string
 	Notifications *bool
+	FamilyID      *sql.NullInt64
+	Timezone      *string
+	Language      *string
+	ActiveListID  *sql.NullInt64
 }
 
 type UserFilter struct {
 	TelegramID *uint
+	FamilyID   *uint
 
 	Limit  int
 	Offset int